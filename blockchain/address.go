@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/mr-tron/base58"
+)
+
+// On chain an account's address is whatever string its transactions name, a node ID (a hex public key), a
+// script address (hex SHA256) or anything else. Typing 64 hex characters is easy to get wrong and nothing would
+// notice, so addresses can also be written base58check encoded, like Bitcoin's: a version byte saying what
+// the 32 bytes are, the bytes, then the first 4 bytes of their double SHA256. ParseAddress turns one back into
+// the hex the chain keys accounts by, and rejects it if a character was mistyped. Hex and plain names are
+// still taken as they are, there's nothing in them to check.
+
+// version bytes of encoded addresses
+const (
+	AddressVersionKey    byte = 0x26 // a hex public key, eg a node or validator ID
+	AddressVersionScript byte = 0x32 // a script address, eg a wallet's or a multisig's
+)
+
+// the reasons an address doesn't parse
+var (
+	ErrBadAddress      = errors.New("address isn't valid, encoded it's 51 base58 characters, as hex 32 bytes")
+	ErrAddressChecksum = errors.New("address checksum doesn't match, it's been mistyped")
+	ErrAddressVersion  = errors.New("address has a version byte this node doesn't know")
+)
+
+// sizes of an encoded address
+const (
+	encodedAddressSize = 1 + 32 + 4 // version, payload, checksum
+	encodedAddressLen  = 51         // in base58, with either version byte
+)
+
+// EncodeAddress returns the base58check encoding of a hex address with a version byte
+func EncodeAddress(version byte, address string) (string, error) {
+	payload, err := hex.DecodeString(address)
+	if err != nil || len(payload) != 32 {
+		return "", ErrBadAddress
+	}
+	data := append([]byte{version}, payload...)
+	return base58.Encode(append(data, addressChecksum(data)...)), nil
+}
+
+// KeyAddress returns the encoded address of a public key
+func KeyAddress(pub ed25519.PublicKey) string {
+	encoded, _ := EncodeAddress(AddressVersionKey, hex.EncodeToString(pub))
+	return encoded
+}
+
+// DecodeAddress returns the hex address and version byte of an encoded address
+func DecodeAddress(encoded string) (address string, version byte, err error) {
+	data, err := base58.Decode(encoded)
+	if err != nil || len(data) != encodedAddressSize {
+		return "", 0, ErrBadAddress
+	}
+	body, checksum := data[:encodedAddressSize-4], data[encodedAddressSize-4:]
+	if !bytes.Equal(addressChecksum(body), checksum) {
+		return "", 0, ErrAddressChecksum
+	}
+	if body[0] != AddressVersionKey && body[0] != AddressVersionScript {
+		return "", 0, ErrAddressVersion
+	}
+	return hex.EncodeToString(body[1:]), body[0], nil
+}
+
+// ParseAddress returns the address the chain knows an address given by a user as: the hex of an encoded
+// address, or anything else as it is. Anything that looks like an encoded address is taken to be one, so a
+// typo in one is an error rather than a different account.
+func ParseAddress(address string) (string, error) {
+	if !IsEncodedAddress(address) {
+		return address, nil
+	}
+	decoded, _, err := DecodeAddress(address)
+	return decoded, err
+}
+
+// IsEncodedAddress returns if an address looks encoded, valid or not: 51 letters and digits, counting the
+// ones base58 leaves out, so a 0 typed for an o still counts
+func IsEncodedAddress(address string) bool {
+	if len(address) != encodedAddressLen {
+		return false
+	}
+	for _, c := range address {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// addressChecksum returns the first 4 bytes of data's double SHA256
+func addressChecksum(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:4]
+}
@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mr-tron/base58"
+)
+
+func TestDecodeAddress(t *testing.T) {
+	key := testAddress(testKey(1))
+	script := ScriptAddress(key + " CHECKSIG")
+	encodedKey, _ := EncodeAddress(AddressVersionKey, key)
+	encodedScript, _ := EncodeAddress(AddressVersionScript, script)
+
+	// mistyped swaps the last character for another base58 one
+	mistyped := func(encoded string) string {
+		last := "2"
+		if strings.HasSuffix(encoded, last) {
+			last = "3"
+		}
+		return encoded[:len(encoded)-1] + last
+	}
+	unknown := append([]byte{0x99}, make([]byte, 32)...)
+	unknownVersion := base58.Encode(append(unknown, addressChecksum(unknown)...))
+
+	tests := []struct {
+		name        string
+		encoded     string
+		wantAddress string
+		wantVersion byte
+		wantErr     error
+	}{
+		{"key", encodedKey, key, AddressVersionKey, nil},
+		{"script", encodedScript, script, AddressVersionScript, nil},
+		{"mistyped", mistyped(encodedKey), "", 0, ErrAddressChecksum},
+		{"unknown version", unknownVersion, "", 0, ErrAddressVersion},
+		{"too short", encodedKey[:len(encodedKey)-2], "", 0, ErrBadAddress},
+		{"not base58", "0" + encodedKey[1:], "", 0, ErrBadAddress},
+		{"hex", key, "", 0, ErrBadAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, version, err := DecodeAddress(tt.encoded)
+			if address != tt.wantAddress || version != tt.wantVersion || err != tt.wantErr {
+				t.Errorf("DecodeAddress() = %q, %#x, %v, want %q, %#x, %v", address, version, err, tt.wantAddress, tt.wantVersion, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	key := testAddress(testKey(1))
+	encoded, _ := EncodeAddress(AddressVersionKey, key)
+
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr error
+	}{
+		{"encoded", encoded, key, nil},
+		{"hex taken as it is", key, key, nil},
+		{"name taken as it is", "alice", "alice", nil},
+		{"encoded with a typo", strings.Replace(encoded, encoded[10:11], "0", 1), "", ErrBadAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ParseAddress(tt.address); got != tt.want || err != tt.wantErr {
+				t.Errorf("ParseAddress() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+	if _, err := EncodeAddress(AddressVersionKey, "alice"); err != ErrBadAddress {
+		t.Errorf("EncodeAddress() of a name = %v, want %v", err, ErrBadAddress)
+	}
+}
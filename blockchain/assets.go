@@ -0,0 +1,145 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// the errors asset transactions can fail with
+var (
+	ErrUnknownAsset = errors.New("asset doesn't exist")
+	ErrNotOwner     = errors.New("sender doesn't own the asset")
+)
+
+// Asset ... a unique, non-fungible asset minted on chain by a ClassAssetMint transaction
+type Asset struct {
+	ID           string // hash of the transaction that minted the asset
+	MetadataHash string // hex SHA256 of the asset's metadata, which lives off chain
+	Creator      string // who minted it
+	Owner        string // who holds it now
+}
+
+// AssetRegistry ... every asset minted on a chain and who owns it
+type AssetRegistry struct {
+	assets map[string]Asset
+}
+
+// NewAssetRegistry returns a registry with no assets in it
+func NewAssetRegistry() *AssetRegistry {
+	return &AssetRegistry{assets: make(map[string]Asset)}
+}
+
+// assetRegistry builds the registry for a chain, skipping anything that doesn't apply
+func assetRegistry(blocks []Block) *AssetRegistry {
+	registry := NewAssetRegistry()
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			registry.Apply(tx)
+		}
+	}
+	return registry
+}
+
+// ValidateAssets returns if every asset transaction in a chain applies, so no one transfers assets they don't own.
+// Ownership can't be worked out past a pruned block, so pruned chains aren't checked.
+func ValidateAssets(blocks []Block) bool {
+	registry := NewAssetRegistry()
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+		if registry.ApplyBlock(block) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy returns a copy of the registry that can be changed without touching this one
+func (r *AssetRegistry) Copy() *AssetRegistry {
+	c := NewAssetRegistry()
+	for id, asset := range r.assets {
+		c.assets[id] = asset
+	}
+	return c
+}
+
+// Apply updates the registry with a transaction, returning an error and leaving the registry alone if it doesn't apply.
+// Transactions that aren't asset transactions are ignored.
+func (r *AssetRegistry) Apply(tx Transaction) error {
+	switch tx.Class {
+	case ClassAssetMint: // Payload is the metadata hash
+		if hashed, err := hex.DecodeString(tx.Payload); err != nil || len(hashed) != 32 {
+			return errors.New("asset mint needs the hex SHA256 of the asset's metadata as its payload")
+		}
+		if tx.From == "" {
+			return errors.New("asset mint needs a creator")
+		}
+		id := tx.Hash()
+		r.assets[id] = Asset{ID: id, MetadataHash: tx.Payload, Creator: tx.From, Owner: tx.From}
+
+	case ClassAssetTransfer: // Payload is the asset ID
+		asset, ok := r.assets[tx.Payload]
+		if !ok {
+			return ErrUnknownAsset
+		}
+		if asset.Owner != tx.From {
+			return ErrNotOwner
+		}
+		if tx.To == "" {
+			return errors.New("asset transfer needs a recipient")
+		}
+		asset.Owner = tx.To
+		r.assets[asset.ID] = asset
+	}
+	return nil
+}
+
+// assetChange ... what a transaction changed in the registry, so it can be put back
+type assetChange struct {
+	id      string
+	prev    Asset // the asset before
+	existed bool  // false if the transaction minted it
+}
+
+// apply is Apply, also returning what changed, false if nothing did
+func (r *AssetRegistry) apply(tx Transaction) (assetChange, bool) {
+	var change assetChange
+	switch tx.Class {
+	case ClassAssetMint:
+		change.id = tx.Hash()
+	case ClassAssetTransfer:
+		change.id = tx.Payload
+	default:
+		return change, false
+	}
+	change.prev, change.existed = r.assets[change.id]
+	return change, r.Apply(tx) == nil
+}
+
+// revert puts back what a transaction changed, the latest one applied first
+func (r *AssetRegistry) revert(change assetChange) {
+	if !change.existed {
+		delete(r.assets, change.id)
+		return
+	}
+	r.assets[change.id] = change.prev
+}
+
+// ApplyBlock applies every transaction in a block, leaving the registry alone if any of them don't apply
+func (r *AssetRegistry) ApplyBlock(block Block) error {
+	next := r.Copy()
+	for _, tx := range block.Transactions {
+		if err := next.Apply(tx); err != nil {
+			return err
+		}
+	}
+	*r = *next
+	return nil
+}
+
+// Asset returns the asset with the given ID
+func (r *AssetRegistry) Asset(id string) (Asset, bool) {
+	asset, ok := r.assets[id]
+	return asset, ok
+}
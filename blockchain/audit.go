@@ -0,0 +1,45 @@
+package blockchain
+
+// AuditReport ... the result of comparing two copies of a chain block-by-block
+type AuditReport struct {
+	LocalLength     int   // number of blocks in the local chain
+	RemoteLength    int   // number of blocks in the remote chain
+	FirstDivergence int   // index of the first block that differs or is missing on one side, -1 if the chains match
+	Mismatched      []int // indexes present on both sides where the blocks differ
+}
+
+// Diverged returns if the two chains differ at all
+func (r AuditReport) Diverged() bool {
+	return r.FirstDivergence != -1
+}
+
+// Audit compares a local chain against a remote one block-by-block
+func Audit(local, remote []Block) AuditReport {
+	report := AuditReport{
+		LocalLength:     len(local),
+		RemoteLength:    len(remote),
+		FirstDivergence: -1,
+	}
+
+	shared := len(local)
+	if len(remote) < shared {
+		shared = len(remote)
+	}
+
+	for i := 0; i < shared; i++ {
+		sameBody := local[i].Pruned || remote[i].Pruned || local[i].Body.Root() == remote[i].Body.Root() // pruned bodies can't be compared
+		if local[i].Header == remote[i].Header && sameBody {
+			continue
+		}
+		report.Mismatched = append(report.Mismatched, i)
+		if report.FirstDivergence == -1 {
+			report.FirstDivergence = i
+		}
+	}
+
+	if report.FirstDivergence == -1 && len(local) != len(remote) { // one chain is just longer than the other
+		report.FirstDivergence = shared
+	}
+
+	return report
+}
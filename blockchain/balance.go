@@ -0,0 +1,199 @@
+package blockchain
+
+import "errors"
+
+// ErrBalancePruned is returned when working out a balance needs the body of a block that's been pruned
+var ErrBalancePruned = errors.New("balance needs blocks this node has pruned")
+
+// ErrInsufficientFunds is returned for a transaction sending more coins than its sender has
+var ErrInsufficientFunds = errors.New("sender doesn't have the coins the transaction sends and pays in fees")
+
+// Coins returns how many coins the transaction moves from From to To. Token and asset transactions move
+// their own units, not coins, and validator votes, proposals and votes move nothing, so only their fee is paid in coins. Staking
+// moves coins between From and its stake, not To, see coinFlows, and slashing burns stake, not coins.
+func (tx Transaction) Coins() int {
+	switch tx.Class {
+	case ClassTokenIssue, ClassTokenTransfer, ClassAssetMint, ClassAssetTransfer, ClassValidator, ClassDelegate, ClassUndelegate, ClassSlash,
+		ClassProposal, ClassVote:
+		return 0
+	}
+	return tx.Amount
+}
+
+// coinFlows returns how many coins a transaction takes from From, fee included, and how many it gives To.
+// Delegating takes the staked coins from From without giving them to anyone, and undelegating gives them back.
+func (tx Transaction) coinFlows() (sent, received int) {
+	switch tx.Class {
+	case ClassDelegate:
+		return tx.Amount + tx.Fee, 0
+	case ClassUndelegate:
+		return tx.Fee - tx.Amount, 0
+	}
+	return tx.Coins() + tx.Fee, tx.Coins()
+}
+
+// Spends returns how many coins the transaction takes from its sender, fee included. Undelegating can give
+// back more than its fee, so it's negative then.
+func (tx Transaction) Spends() int {
+	sent, _ := tx.coinFlows()
+	return sent
+}
+
+// balanceDelta returns how much a block changes an address's coin balance, the fees it pays going to the
+// block's miner through the coinbase
+func balanceDelta(block Block, address string) int {
+	delta := 0
+	for _, tx := range block.Transactions {
+		sent, received := tx.coinFlows()
+		if tx.To == address {
+			delta += received
+		}
+		if tx.From == address {
+			delta -= sent
+		}
+	}
+	return delta
+}
+
+// applyBalances adds a block's transactions to a map of address -> coin balance
+func applyBalances(balances map[string]int, block Block) {
+	for _, tx := range block.Transactions {
+		sent, received := tx.coinFlows()
+		if tx.To != "" {
+			balances[tx.To] += received
+		}
+		if tx.From != "" {
+			balances[tx.From] -= sent
+		}
+	}
+}
+
+// Funds ... coin balances transactions are checked against one after another, the way a block's are
+type Funds struct {
+	base    map[string]int // as of the block's parent, never changed
+	changed map[string]int // what the transactions so far have changed them by
+}
+
+func newFunds(balances map[string]int) *Funds {
+	return &Funds{base: balances, changed: make(map[string]int)}
+}
+
+// Apply moves the coins a transaction sends, or returns ErrInsufficientFunds without moving any if its
+// sender doesn't have them
+func (f *Funds) Apply(tx Transaction) error {
+	sent, received := tx.coinFlows()
+	if tx.From != "" && sent > 0 && f.base[tx.From]+f.changed[tx.From] < sent {
+		return ErrInsufficientFunds
+	}
+	if tx.To != "" {
+		f.changed[tx.To] += received
+	}
+	if tx.From != "" {
+		f.changed[tx.From] -= sent
+	}
+	return nil
+}
+
+// overspends returns if any transaction in a block sends more coins than its sender has, counting what the
+// ones before it in the block paid and took
+func overspends(block Block, balances map[string]int) bool {
+	funds := newFunds(balances)
+	for _, tx := range block.Transactions {
+		if funds.Apply(tx) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateBalances returns if no transaction in a chain sends more coins than its sender has. Balances can't be
+// worked out past a pruned block, so the rest of a pruned chain isn't checked.
+func ValidateBalances(blocks []Block) bool {
+	balances := make(map[string]int)
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+		if block.Index > 0 && overspends(block, balances) { // the genesis block is whatever the network started with
+			return false
+		}
+		applyBalances(balances, block)
+	}
+	return true
+}
+
+// Funds returns the coin balances as of the head, to check the transactions of a block being built against.
+// It's ErrStateUnknown for a chain that's added pruned blocks without a state to start from.
+func (c *Chain) Funds() (*Funds, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.partial {
+		return nil, ErrStateUnknown
+	}
+	balances := make(map[string]int, len(c.balances))
+	for address, balance := range c.balances {
+		balances[address] = balance
+	}
+	return newFunds(balances), nil
+}
+
+// Spendable returns the coins an address has to spend as of the head, what new transactions from it are
+// checked against. It's ErrStateUnknown for a chain that's added pruned blocks without a state to start from.
+func (c *Chain) Spendable(address string) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.partial {
+		return 0, ErrStateUnknown
+	}
+	return c.balances[address], nil
+}
+
+// balanceIndex returns the coin balance of every address that's been in a transaction in a chain
+func balanceIndex(blocks []Block) map[string]int {
+	balances := make(map[string]int)
+	for _, block := range blocks {
+		applyBalances(balances, block)
+	}
+	return balances
+}
+
+// Balance returns an address's coin balance counting only blocks with at least confirmations confirmations,
+// the head having one, along with the height it's the balance as of. It works back from the head's balance,
+// so it needs the bodies of the blocks it skips, unless their bloom filters rule the address out.
+func (c *Chain) Balance(address string, confirmations int) (balance, height int, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if confirmations < 1 {
+		confirmations = 1
+	}
+	height = len(c.blocks) - confirmations
+	if height < 0 { // nothing's that deep, and the genesis block holds nothing
+		height = 0
+	}
+	balance, err = c.balanceAt(address, height)
+	return balance, height, err
+}
+
+// BalanceAt returns an address's coin balance as of the block at height, the way Balance does. A height past
+// the head is the head's balance.
+func (c *Chain) BalanceAt(address string, height int) (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.balanceAt(address, height)
+}
+
+// balanceAt works an address's balance at height back from the head's, c.mu has to be held
+func (c *Chain) balanceAt(address string, height int) (int, error) {
+	balance := c.balances[address]
+	for i := len(c.blocks) - 1; i > height; i-- {
+		if !c.blocks[i].MayContain(address) { // nothing to take off
+			continue
+		}
+		if c.blocks[i].Pruned {
+			return 0, ErrBalancePruned
+		}
+		balance -= balanceDelta(c.blocks[i], address)
+	}
+	return balance, nil
+}
@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+)
+
+// the consensus engines a network can run, see Params.Engine
+const (
+	EnginePoW = "pow" // proof of work, the heaviest chain wins, the default
+	EngineBFT = "bft" // a known validator set votes on every block, see node/bft.go, and committed blocks are final
+	EnginePoA = "poa" // a known set of authorities take turns sealing blocks, see poa.go
+)
+
+// the kinds of vote, and the proposal a round starts with, each signed by a validator
+const (
+	VoteProposal  = "proposal"
+	VotePrevote   = "prevote"
+	VotePrecommit = "precommit"
+)
+
+// Vote ... a validator's signed say on a block in one round of BFT consensus
+type Vote struct {
+	Type      string // VoteProposal, VotePrevote or VotePrecommit
+	Height    int
+	Round     int
+	Hash      string // the block voted for, "" to vote for none
+	Validator string // the validator's node ID, its hex ed25519 public key
+	Signature string // hex, over SignBytes
+}
+
+// Commit ... the precommits of more than two thirds of the validators for a block, proving it was agreed on.
+// It's carried alongside the block rather than hashed into it, since it signs the block's hash.
+type Commit struct {
+	Round      int // the round the block was agreed in
+	Signatures []CommitSig
+}
+
+// CommitSig ... one validator's precommit in a commit
+type CommitSig struct {
+	Validator string
+	Signature string
+}
+
+// the reasons a commit doesn't hold up
+var (
+	ErrNoCommit         = errors.New("block has no commit")
+	ErrNoQuorum         = errors.New("commit isn't signed by more than two thirds of the validators")
+	ErrUnknownValidator = errors.New("signed by someone who isn't a validator")
+	ErrBadSignature     = errors.New("signature doesn't check out")
+)
+
+// Quorum returns how many of n validators have to agree, more than two thirds
+func Quorum(n int) int {
+	return 2*n/3 + 1
+}
+
+// SignBytes returns what a vote's signature signs, everything but the signature in the canonical encoding
+func (v Vote) SignBytes() []byte {
+	buf := appendString(nil, v.Type)
+	buf = appendInt(buf, int64(v.Height))
+	buf = appendInt(buf, int64(v.Round))
+	buf = appendString(buf, v.Hash)
+	return appendString(buf, v.Validator)
+}
+
+// Sign fills in the vote's validator and signature with key
+func (v Vote) Sign(key ed25519.PrivateKey) Vote {
+	v.Validator = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	v.Signature = hex.EncodeToString(ed25519.Sign(key, v.SignBytes()))
+	return v
+}
+
+// Verify returns if the vote was signed by its validator
+func (v Vote) Verify() bool {
+	pub, err := hex.DecodeString(v.Validator)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := hex.DecodeString(v.Signature)
+	return err == nil && ed25519.Verify(ed25519.PublicKey(pub), v.SignBytes(), sig)
+}
+
+// Precommit returns the precommit a commit signature stands for
+func (c Commit) Precommit(block Block, sig CommitSig) Vote {
+	return Vote{Type: VotePrecommit, Height: block.Index, Round: c.Round, Hash: block.Hash, Validator: sig.Validator, Signature: sig.Signature}
+}
+
+// VerifyCommit returns an error unless the block's commit has valid precommits for it from more than two
+// thirds of validators, counting each validator once
+func VerifyCommit(block Block, validators []string) error {
+	if block.Commit == nil {
+		return ErrNoCommit
+	}
+	set := make(map[string]bool, len(validators))
+	for _, v := range validators {
+		set[v] = true
+	}
+	if block.Sealer != "" && !set[block.Sealer] { // the proposer that made it, its delegators get a share of the reward
+		return ErrUnknownValidator
+	}
+
+	signed := make(map[string]bool)
+	for _, sig := range block.Commit.Signatures {
+		if !set[sig.Validator] {
+			return ErrUnknownValidator
+		}
+		if !block.Commit.Precommit(block, sig).Verify() {
+			return ErrBadSignature
+		}
+		signed[sig.Validator] = true
+	}
+	if len(signed) < Quorum(len(validators)) {
+		return ErrNoQuorum
+	}
+	return nil
+}
+
+// Proposer returns who proposes the block at a height in a round, taking turns through the validators
+func Proposer(validators []string, height, round int) string {
+	if len(validators) == 0 {
+		return ""
+	}
+	return validators[(height+round)%len(validators)]
+}
+
+// ValidateCommits returns if every block after the genesis block has a commit from the validators of the time,
+// starting from the genesis set
+func ValidateCommits(blocks []Block, genesis []string) bool {
+	return eachValidatorSet(blocks, genesis, func(i int, validators []string) bool {
+		return VerifyCommit(blocks[i], validators) == nil
+	})
+}
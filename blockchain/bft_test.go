@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestVerifyCommit(t *testing.T) {
+	keys := []ed25519.PrivateKey{testKey(1), testKey(2), testKey(3), testKey(4)}
+	var validators []string
+	for _, key := range keys {
+		validators = append(validators, testAddress(key))
+	}
+	genesis := NewGenesisBlock()
+	genesis.Hash = GenerateHash(genesis)
+	block, _ := GenerateBlock(genesis, 0)
+	other, _ := GenerateBlock(genesis, 1)
+
+	// precommit returns key's signature on a precommit for hash in round
+	precommit := func(key ed25519.PrivateKey, hash string, round int) CommitSig {
+		vote := Vote{Type: VotePrecommit, Height: block.Index, Round: round, Hash: hash, Validator: testAddress(key)}.Sign(key)
+		return CommitSig{Validator: vote.Validator, Signature: vote.Signature}
+	}
+	signedBy := func(round int, sigs ...CommitSig) *Commit { return &Commit{Round: round, Signatures: sigs} }
+	sig := func(i int) CommitSig { return precommit(keys[i], block.Hash, 0) }
+
+	tests := []struct {
+		name   string
+		commit *Commit
+		sealer string
+		want   error
+	}{
+		{"more than two thirds", signedBy(0, sig(0), sig(1), sig(2)), "", nil},
+		{"every validator", signedBy(0, sig(0), sig(1), sig(2), sig(3)), "", nil},
+		{"sealed by a validator", signedBy(0, sig(1), sig(2), sig(3)), validators[1], nil},
+		{"no commit", nil, "", ErrNoCommit},
+		{"two thirds isn't enough", signedBy(0, sig(0), sig(1)), "", ErrNoQuorum},
+		{"the same validator twice", signedBy(0, sig(0), sig(1), sig(1)), "", ErrNoQuorum},
+		{"signed by an outsider", signedBy(0, sig(0), sig(1), precommit(testKey(9), block.Hash, 0)), "", ErrUnknownValidator},
+		{"sealed by an outsider", signedBy(0, sig(0), sig(1), sig(2)), testAddress(testKey(9)), ErrUnknownValidator},
+		{"a precommit for another block", signedBy(0, sig(0), sig(1), precommit(keys[2], other.Hash, 0)), "", ErrBadSignature},
+		{"precommits from another round", signedBy(1, sig(0), sig(1), sig(2)), "", ErrBadSignature},
+		{"a signature under someone else's name", signedBy(0, sig(0), sig(1), CommitSig{Validator: validators[2], Signature: sig(3).Signature}), "", ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := block
+			b.Commit, b.Sealer = tt.commit, tt.sealer
+			if err := VerifyCommit(b, validators); err != tt.want {
+				t.Errorf("VerifyCommit() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
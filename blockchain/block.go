@@ -0,0 +1,251 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Header ... everything needed to link and verify a block without its payload
+type Header struct {
+	Version    int    // how the header is hashed, HeaderVersion for new blocks
+	Index      int    // the position of the data record in the blockchain
+	Timestamp  int64  // the time the data is written, in unix nanoseconds
+	Hash       string // SHA256 identifier representing this data record
+	PrevHash   string // SHA256 identifier of the previous record in the chain
+	MerkleRoot string // root of the merkle tree over the body, commits the header to the payload
+	Difficulty int64  // how many tries finding the hash took on average, see difficulty.go
+	Nonce      int64  // varied by the miner until the hash meets the difficulty
+	Bloom      string // hex bloom filter over the addresses and transaction hashes in the body, see bloom.go
+	Sealer     string `json:",omitempty"` // on PoA networks the authority that sealed the block, see poa.go
+	Seal       string `json:",omitempty"` // the sealer's hex signature over the hash
+	Signals    uint32 `json:",omitempty"` // a bit for every soft fork its maker is ready for, see softforks.go
+	ChainID    string `json:",omitempty"` // the network the block belongs to, see chainid.go
+	StateRoot  string `json:",omitempty"` // root of the trie of every account as of the block, see trie.go
+}
+
+// Body ... the payload a block carries
+type Body struct {
+	Data         int           // the custom data, could be anything, this represents an integer
+	Transactions []Transaction // transactions confirmed by this block
+}
+
+// Block ... the blocks that will make up the blockchain, a header plus its body
+type Block struct {
+	Header
+	Body
+	Pruned bool    // the body has been dropped to save space, only the header is kept
+	Commit *Commit `json:",omitempty"` // the validators' signatures agreeing on it, on BFT networks, see bft.go
+}
+
+// the header versions, each hashes the header a different way
+const (
+	LegacyHeaderVersion   = 0                  // the original hash over the fields run together, where the index went in as a rune
+	UnixTimeHeaderVersion = 2                  // SHA256 over the canonical encoding of the fields, with the timestamp in unix nanoseconds
+	WorkHeaderVersion     = 3                  // as 2 plus Difficulty and Nonce, so the hash proves the work that went into it
+	BloomHeaderVersion    = 4                  // as 3 plus Bloom, so a header says what its body might hold
+	SealHeaderVersion     = 5                  // as 4 plus Sealer, the Seal signs the hash so it can't be part of it
+	SignalHeaderVersion   = 6                  // as 5 plus Signals
+	ChainHeaderVersion    = 7                  // as 6 plus ChainID
+	StateHeaderVersion    = 8                  // as 7 plus StateRoot
+	HeaderVersion         = StateHeaderVersion // what new blocks use
+)
+
+// Version 1 hashed the canonical encoding with the timestamp as time.Time's String. Neither it nor the legacy
+// hash can be checked any more since the exact text isn't kept, chains that old are migrated, see MigrateTimestamps.
+
+// MaxClockDrift is how far ahead of the local clock a block's timestamp can be, allowing for clocks that disagree a little
+var MaxClockDrift = 2 * time.Hour
+
+// Time returns the header's timestamp as a time
+func (h Header) Time() time.Time {
+	return time.Unix(0, h.Timestamp)
+}
+
+// Leaves returns the hashes the body's merkle tree is built from, the data followed by each transaction
+func (b Body) Leaves() [][]byte {
+	leaf := sha256.Sum256([]byte(strconv.Itoa(b.Data)))
+	leaves := [][]byte{leaf[:]}
+
+	for _, tx := range b.Transactions {
+		hashed, _ := hex.DecodeString(tx.Hash())
+		leaves = append(leaves, hashed)
+	}
+	return leaves
+}
+
+// Root returns the root of the merkle tree over the body
+func (b Body) Root() string {
+	return MerkleRoot(b.Leaves())
+}
+
+// NewGenesisBlock returns the first block in a blockchain
+func NewGenesisBlock() Block {
+	return NewGenesisBlockAt(time.Now())
+}
+
+// NewGenesisBlockAt returns a genesis block made at t, nodes given the same time start from the same block
+func NewGenesisBlockAt(t time.Time) Block {
+	genesisBlock := Block{Header: Header{Index: 0, Timestamp: t.UnixNano()}} // a genesis block is the first block in a blockchain
+	genesisBlock.Version = HeaderVersion
+	genesisBlock.ChainID = DefaultParams.ChainID
+	genesisBlock.MerkleRoot = genesisBlock.Body.Root()
+	genesisBlock.Bloom = genesisBlock.Body.BloomFilter().String()
+	genesisBlock.StateRoot = StateTrie{}.Root() // no accounts yet
+	return genesisBlock
+}
+
+// GenerateHash creates a hash out of block data
+func GenerateHash(block Block) string { // returns a string
+	return GenerateHeaderHash(block.Header)
+}
+
+// GenerateHeaderHash creates a hash out of a block header, the body is covered by the merkle root.
+// It returns "" for headers from before UnixTimeHeaderVersion, they can't be checked.
+func GenerateHeaderHash(header Header) string {
+	if header.Version < UnixTimeHeaderVersion || header.Version > HeaderVersion {
+		return ""
+	}
+	record := appendInt(nil, int64(header.Version))
+	record = appendInt(record, int64(header.Index))
+	record = appendInt(record, header.Timestamp)
+	record = appendString(record, header.PrevHash)
+	record = appendString(record, header.MerkleRoot)
+	if header.Version >= WorkHeaderVersion {
+		record = appendInt(record, header.Difficulty)
+		record = appendInt(record, header.Nonce)
+	}
+	if header.Version >= BloomHeaderVersion {
+		record = appendString(record, header.Bloom)
+	}
+	if header.Version >= SealHeaderVersion {
+		record = appendString(record, header.Sealer)
+	}
+	if header.Version >= SignalHeaderVersion {
+		record = appendInt(record, int64(header.Signals))
+	}
+	if header.Version >= ChainHeaderVersion {
+		record = appendString(record, header.ChainID)
+	}
+	if header.Version >= StateHeaderVersion {
+		record = appendString(record, header.StateRoot)
+	}
+	hashed := sha256.Sum256(record)
+	return hex.EncodeToString(hashed[:]) // return hexadecimal encoding of hashed string
+}
+
+// GenerateBlock returns a new block or error, based on a previous block, confirming any transactions given.
+// It's at MinDifficulty, for anything harder set Difficulty and Solve it. Its state root is left empty, only
+// the chain knows the accounts it leaves behind, see Chain.StateRootAfter.
+func GenerateBlock(prevBlock Block, Data int, txs ...Transaction) (Block, error) {
+	var newBlock Block            // init block
+	t := time.Now().UnixNano()    // new timestamp
+	if t <= prevBlock.Timestamp { // it has to come after its parent, even if the clock went backwards
+		t = prevBlock.Timestamp + 1
+	}
+	newBlock.Version = HeaderVersion                      // hash it the current way
+	newBlock.Index = prevBlock.Index + 1                  // make block index prev + 1
+	newBlock.Timestamp = t                                // set block timestamp in unix nanoseconds
+	newBlock.Data = Data                                  // set Data as param, this is relative data (eg currency)
+	newBlock.Transactions = txs                           // the transactions this block confirms
+	newBlock.PrevHash = prevBlock.Hash                    // set the previous hash as the prev blocks hash
+	newBlock.MerkleRoot = newBlock.Body.Root()            // commit the header to the body
+	newBlock.Bloom = newBlock.Body.BloomFilter().String() // and say what's in it
+	newBlock.Difficulty = MinDifficulty                   // any hash will do
+	newBlock.ChainID = prevBlock.ChainID                  // on the same network as its parent
+	newBlock.Hash = GenerateHash(newBlock)                // generate this blocks hash with current data
+
+	return newBlock, nil
+}
+
+// ValidateBlock returns if a block is valid or not, pruned blocks only have their header checked
+func ValidateBlock(prevBlock, newBlock Block) bool {
+	if !ValidateHeader(prevBlock.Header, newBlock.Header) {
+		return false
+	}
+
+	if newBlock.Pruned { // nothing left to check the header against
+		return true
+	}
+
+	if !newBlock.matchesBody() { // make sure the body is the one the header committed to
+		return false
+	}
+
+	blockTime := newBlock.Time()
+	for _, tx := range newBlock.Transactions { // every transaction has to be well formed, past its timelock and not expired
+		if tx.Validate() != nil || !tx.Final(newBlock.Index, blockTime) || tx.Expired(newBlock.Index, blockTime) {
+			return false
+		}
+	}
+
+	return true // block is valid
+}
+
+// matchesBody returns if the header's merkle root, and bloom filter if it has one, are the body's
+func (b Block) matchesBody() bool {
+	if b.Body.Root() != b.MerkleRoot {
+		return false
+	}
+	return b.Version < BloomHeaderVersion || b.Body.BloomFilter().String() == b.Bloom
+}
+
+// ValidateHeader returns if a header links up to the previous header, without needing either body
+func ValidateHeader(prevHeader, newHeader Header) bool {
+	if prevHeader.Index+1 != newHeader.Index { // check if the previous block is actually the previous block by index
+		return false
+	}
+
+	if prevHeader.Hash != newHeader.PrevHash { // check if the previous block hash matches the new block prev hash
+		return false
+	}
+
+	if newHeader.Version < prevHeader.Version || newHeader.Version > HeaderVersion { // no going back to an older hash, or one we don't know
+		return false
+	}
+
+	if newHeader.Timestamp <= prevHeader.Timestamp { // time only goes forward along the chain
+		return false
+	}
+
+	if newHeader.Time().After(time.Now().Add(MaxClockDrift)) { // a block from the future is someone's clock, or someone gaming time locks
+		return false
+	}
+
+	if GenerateHeaderHash(newHeader) != newHeader.Hash { // double check the current / new block hash is valid
+		return false
+	}
+
+	if newHeader.Version >= BloomHeaderVersion { // the filter has to be one, even if the body isn't here to check it against
+		if _, err := ParseBloom(newHeader.Bloom); err != nil {
+			return false
+		}
+	}
+
+	if newHeader.Version >= WorkHeaderVersion && (newHeader.Difficulty < MinDifficulty || !newHeader.MeetsDifficulty()) { // the work has to have been done
+		return false
+	}
+
+	return true // header is valid
+}
+
+// ValidateChain returns if every block in a chain links up to the one before it
+func ValidateChain(blocks []Block) bool {
+	for i := 1; i < len(blocks); i++ {
+		if !ValidateBlock(blocks[i-1], blocks[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateHeaders returns if every header in a chain of headers links up to the one before it
+func ValidateHeaders(headers []Header) bool {
+	for i := 1; i < len(headers); i++ {
+		if !ValidateHeader(headers[i-1], headers[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// the shape of a block's bloom filter. With 2048 bits and 3 bits an item, a block with 100 addresses and
+// transaction hashes in it matches something it doesn't hold about 0.3% of the time.
+const (
+	BloomBits   = 2048 // bits in the filter
+	BloomHashes = 3    // bits each item sets
+)
+
+// Bloom ... a bloom filter over the addresses and transaction hashes in a block, committed to in its header,
+// so light clients and indexes can skip blocks that definitely have nothing for them without their bodies.
+// It never says an item is missing when it's there, but can say one might be there when it isn't.
+type Bloom [BloomBits / 8]byte
+
+// fullBloom matches everything, for blocks whose bodies are gone so what's in them can't be worked out
+var fullBloom = func() (b Bloom) {
+	for i := range b {
+		b[i] = 0xff
+	}
+	return b
+}()
+
+// Add sets the item's bits
+func (b *Bloom) Add(item string) {
+	for _, bit := range bloomBits(item) {
+		b[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Test returns false if the item is definitely not in the filter, true if it might be
+func (b *Bloom) Test(item string) bool {
+	for _, bit := range bloomBits(item) {
+		if b[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the filter as hex, how headers carry it
+func (b Bloom) String() string {
+	return hex.EncodeToString(b[:])
+}
+
+// ParseBloom reads a filter written by Bloom.String
+func ParseBloom(s string) (Bloom, error) {
+	var b Bloom
+	if len(s) != hex.EncodedLen(len(b)) {
+		return b, fmt.Errorf("bloom filter has to be %d hex digits, not %d", hex.EncodedLen(len(b)), len(s))
+	}
+	_, err := hex.Decode(b[:], []byte(s))
+	return b, err
+}
+
+// bloomBits picks the bits an item sets, two bytes of its SHA256 each
+func bloomBits(item string) [BloomHashes]uint {
+	sum := sha256.Sum256([]byte(item))
+	var bits [BloomHashes]uint
+	for i := range bits {
+		bits[i] = uint(binary.BigEndian.Uint16(sum[2*i:])) % BloomBits
+	}
+	return bits
+}
+
+// BloomFilter returns the bloom filter over the body, every transaction's hash, sender and recipient
+func (b Body) BloomFilter() Bloom {
+	var bloom Bloom
+	for _, tx := range b.Transactions {
+		bloom.Add(tx.Hash())
+		if tx.From != "" {
+			bloom.Add(tx.From)
+		}
+		if tx.To != "" {
+			bloom.Add(tx.To)
+		}
+	}
+	return bloom
+}
+
+// MayContain returns false if the header's block definitely has no transaction with that hash, sender or
+// recipient. Headers from before BloomHeaderVersion have no filter, so anything might be in their blocks.
+func (h Header) MayContain(item string) bool {
+	if h.Version < BloomHeaderVersion {
+		return true
+	}
+	bloom, err := ParseBloom(h.Bloom)
+	return err != nil || bloom.Test(item)
+}
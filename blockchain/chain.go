@@ -0,0 +1,431 @@
+package blockchain
+
+import (
+	"sort"
+	"sync"
+)
+
+// Chain is a slice of blocks that's safe to share between goroutines
+type Chain struct {
+	mu          sync.RWMutex
+	blocks      []Block
+	checkpoints Checkpoints
+	params      Params
+	spent       map[string]string // spend key of every confirmed transaction -> its hash
+	nonces      map[string]int    // highest confirmed nonce of every sender
+	balances    map[string]int    // coin balance of every address as of the head
+	tokens      *TokenLedger
+	assets      *AssetRegistry
+	index       *Index
+	undo        []undo        // what each of the latest UndoDepth blocks changed, oldest first
+	finalized   int           // height of the highest final block, -1 for none
+	genesisSet  []string      // the validators the network started with, on networks that aren't mined
+	validators  *ValidatorSet // as of the head
+	stakes      *StakeLedger
+	governance  *Governance
+	softForks   *SoftForks
+
+	base       *State // the state the chain started from, nil if it started from the genesis block
+	stateEvery int    // how often the state's taken as blocks are added, 0 for never
+	latest     *State // the latest state taken, nil if none has been since the chain was loaded
+
+	trie    StateTrie // every account as of the head, see trie.go
+	partial bool      // the state's missing what pruned blocks changed, so balances and state roots can't be checked
+}
+
+// NewChain returns a chain made up of the given blocks, the first one being the genesis block
+func NewChain(blocks ...Block) *Chain {
+	c := &Chain{params: DefaultParams, finalized: -1}
+	c.reset(blocks)
+	return c
+}
+
+// Tokens returns a copy of the token ledger as of the head of the chain
+func (c *Chain) Tokens() *TokenLedger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tokens.Copy()
+}
+
+// Stakes returns a copy of the stake ledger as of the head of the chain
+func (c *Chain) Stakes() *StakeLedger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stakes.Copy()
+}
+
+// Assets returns a copy of the asset registry as of the head of the chain
+func (c *Chain) Assets() *AssetRegistry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.assets.Copy()
+}
+
+// Nonce returns the highest nonce confirmed for a sender, new transactions from them need a higher one
+func (c *Chain) Nonce(sender string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	nonce, ok := c.nonces[sender]
+	return nonce, ok
+}
+
+// Spent returns the hash of the confirmed transaction with the given spend key, if there is one
+func (c *Chain) Spent(key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	hash, ok := c.spent[key]
+	return hash, ok
+}
+
+// SetParams sets the consensus rules new blocks are checked against
+func (c *Chain) SetParams(params Params) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.params = params
+	c.replaySoftForks() // the deployments may have changed
+	c.finalize()
+}
+
+// Params returns the consensus rules the chain follows, with the changes governance has passed and active
+// soft forks make in Changes.
+// Params.At gives the ones in force at a height.
+func (c *Chain) Params() Params {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rules()
+}
+
+// Validate returns if a whole chain is valid under this chain's checkpoints and consensus rules
+func (c *Chain) Validate(blocks []Block) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	params := c.params
+	params.Changes = mergeChanges(softForks(blocks, c.params).Changes(), governance(blocks).Changes()) // its own signals and governance decide its rules
+	return ValidateChainWithCheckpoints(blocks, c.checkpoints) && ValidateVersions(blocks, params) && ValidateChainIDs(blocks, params) && ValidateRewards(blocks, params) && ValidateSizes(blocks, params) && ValidateDifficulty(blocks, params) && ValidateNoDoubleSpends(blocks) && ValidateNonces(blocks) && ValidateBalances(blocks) && ValidateStateRoots(blocks) && ValidateTokens(blocks) && ValidateAssets(blocks) && ValidateValidators(blocks, c.genesisSet) && ValidateStakes(blocks, c.genesisSet, c.params) && ValidateGovernance(blocks) &&
+		(c.params.Engine != EngineBFT || ValidateCommits(blocks, c.genesisSet)) && (c.params.Engine != EnginePoA || ValidateSeals(blocks, c.genesisSet))
+}
+
+// SetCheckpoints sets the trusted block hashes new blocks and replacement chains have to match
+func (c *Chain) SetCheckpoints(checkpoints Checkpoints) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkpoints = checkpoints
+}
+
+// Checkpoints returns the chain's checkpoints
+func (c *Chain) Checkpoints() Checkpoints {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.checkpoints
+}
+
+// NextDifficulty returns the difficulty the next block on top of the head has to have
+func (c *Chain) NextDifficulty() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nextDifficulty()
+}
+
+// nextDifficulty is NextDifficulty for callers already holding the lock
+func (c *Chain) nextDifficulty() int64 {
+	return NextDifficulty(tailHeaders(c.blocks, c.params.RetargetWindow+2), c.rules()) // more than the window it looks at, even a 0 one
+}
+
+// Blocks returns a copy of every block in the chain
+func (c *Chain) Blocks() []Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	blocks := make([]Block, len(c.blocks))
+	copy(blocks, c.blocks)
+	return blocks
+}
+
+// Headers returns the header of every block in the chain
+func (c *Chain) Headers() []Header {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	headers := make([]Header, len(c.blocks))
+	for i, block := range c.blocks {
+		headers[i] = block.Header
+	}
+	return headers
+}
+
+// HeaderRange returns the headers of up to limit blocks starting at index from
+func (c *Chain) HeaderRange(from, limit int) []Header {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if from < 0 || from >= len(c.blocks) || limit <= 0 {
+		return []Header{}
+	}
+	to := from + limit
+	if to > len(c.blocks) {
+		to = len(c.blocks)
+	}
+
+	headers := make([]Header, 0, to-from)
+	for _, block := range c.blocks[from:to] {
+		headers = append(headers, block.Header)
+	}
+	return headers
+}
+
+// Len returns the number of blocks in the chain
+func (c *Chain) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.blocks)
+}
+
+// Genesis returns the chain's first block
+func (c *Chain) Genesis() Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.blocks[0]
+}
+
+// Last returns the block at the head of the chain
+func (c *Chain) Last() Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.blocks[len(c.blocks)-1]
+}
+
+// BlockByHash finds a block by its hash
+func (c *Chain) BlockByHash(hash string) (Block, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	height, ok := c.index.Blocks[hash]
+	if !ok {
+		return Block{}, false
+	}
+	return c.blocks[height], true
+}
+
+// BlockAt returns the last block made at or before t, unix nanoseconds, false if the chain starts after it.
+// Timestamps go up from block to block, so it's a binary search.
+func (c *Chain) BlockAt(t int64) (Block, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	after := sort.Search(len(c.blocks), func(i int) bool { return c.blocks[i].Timestamp > t })
+	if after == 0 {
+		return Block{}, false
+	}
+	return c.blocks[after-1], true
+}
+
+// EncodeIndex returns the chain's index for storing, encoded since it's only safe to read under the chain's lock
+func (c *Chain) EncodeIndex() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.index.Encode()
+}
+
+// UseIndex swaps in an index read from storage, which knows about pruned blocks the chain can't index itself.
+// It has to be for this chain, see DecodeIndex.
+func (c *Chain) UseIndex(ix *Index) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index = ix
+}
+
+// Range returns up to limit blocks starting at index from
+func (c *Chain) Range(from, limit int) []Block {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if from < 0 || from >= len(c.blocks) || limit <= 0 {
+		return []Block{}
+	}
+	to := from + limit
+	if to > len(c.blocks) {
+		to = len(c.blocks)
+	}
+
+	blocks := make([]Block, to-from)
+	copy(blocks, c.blocks[from:to])
+	return blocks
+}
+
+// AddBlock appends a block to the chain if it's valid on top of the current head
+func (c *Chain) AddBlock(block Block) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.validNext(block) {
+		return false
+	}
+	if c.params.Engine == EngineBFT && VerifyCommit(block, c.validators.active) != nil { // and the validators have to have agreed on it
+		return false
+	}
+	if c.params.Engine == EnginePoA && ValidateSeal(tailHeaders(c.blocks, SealLimit(c.validators.active)), block.Header, c.validators.active) != nil { // or an authority has to have sealed it in its turn
+		return false
+	}
+	c.push(block)
+	return true
+}
+
+// CanAdd returns if a block would be valid on top of the current head, apart from any commit it needs, so
+// validators can check a proposed block before voting for it
+func (c *Chain) CanAdd(block Block) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.validNext(block)
+}
+
+// ValidateNextHeaders returns if headers carry on from prev, a header in the chain or the last of an earlier
+// run, each linking to the one before with its work done, hashed the way the fork schedule says, for this
+// network and matching any checkpoint it reaches. It's what can be checked before the bodies arrive, which
+// are checked in full when they're added.
+func (c *Chain) ValidateNextHeaders(prev Header, headers []Header) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.validHeaders(prev, headers)
+}
+
+// AddHeader appends a block to the chain with just its header, for light nodes, if the header carries on from
+// the head. The body's never seen, so the block's transactions aren't in the chain's state.
+func (c *Chain) AddHeader(header Header) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.validHeaders(c.blocks[len(c.blocks)-1].Header, []Header{header}) {
+		return false
+	}
+	c.push(Block{Header: header, Pruned: true})
+	return true
+}
+
+// validHeaders is ValidateNextHeaders for callers already holding the lock
+func (c *Chain) validHeaders(prev Header, headers []Header) bool {
+	for _, header := range headers {
+		if !ValidateHeader(prev, header) || ValidateVersion(header, c.params) != nil {
+			return false
+		}
+		if header.Version >= ChainHeaderVersion && header.ChainID != c.params.ChainID {
+			return false
+		}
+		if hash, ok := c.checkpoints[header.Index]; ok && hash != header.Hash {
+			return false
+		}
+		prev = header
+	}
+	return true
+}
+
+// validNext returns if a block follows every rule on top of the head, c.mu has to be held
+func (c *Chain) validNext(block Block) bool {
+	if block.Pruned || !ValidateBlock(c.blocks[len(c.blocks)-1], block) { // make sure the block is whole and builds on the head
+		return false
+	}
+	if !c.checkpoints.Matches(block) {
+		return false
+	}
+	if ValidateVersion(block.Header, c.params) != nil { // hashed the way the fork schedule says for its height
+		return false
+	}
+	if ValidateChainID(block, c.params) != nil { // and for this network, not replayed from another
+		return false
+	}
+	if ValidateCoinbase(block, c.rules()) != nil { // the miner has to pay themselves exactly the block reward
+		return false
+	}
+	if ValidateSize(block, c.rules()) != nil { // no oversize blocks
+		return false
+	}
+	if block.Version >= WorkHeaderVersion && block.Difficulty != c.nextDifficulty() { // and no easier work than the chain calls for
+		return false
+	}
+	if conflictsWith(block, c.spent) { // no double spends
+		return false
+	}
+	if !nonceOrdered(block, c.nonces) { // no replays
+		return false
+	}
+	if !c.partial && overspends(block, c.balances) { // no spending coins you don't have
+		return false
+	}
+	if block.Version >= StateHeaderVersion && !c.partial && c.trie.afterBlock(block, c.balances, c.nonces).Root() != block.StateRoot { // the accounts it leaves are the ones it says
+		return false
+	}
+	if c.tokens.Copy().ApplyBlock(block) != nil || c.assets.Copy().ApplyBlock(block) != nil { // no spending tokens or assets you don't have
+		return false
+	}
+	if hasValidatorTxs(block) && c.validators.Copy().ApplyBlock(block) != nil { // and only validators change the validator set
+		return false
+	}
+	if c.governance.CheckBlock(block) != nil { // no votes on proposals that aren't open
+		return false
+	}
+	if c.params.Engine == EngineBFT && block.Sealer != "" && !contains(c.validators.active, block.Sealer) { // made by a validator
+		return false
+	}
+	if c.stakes.CheckBlock(block, c.validators.active, c.params) != nil { // whose delegators get their share
+		return false
+	}
+	return true
+}
+
+// Prune drops the bodies of every block more than keep blocks behind the head, keeping their headers.
+// It returns how many bodies were dropped.
+func (c *Chain) Prune(keep int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pruned := 0
+	for i := 0; i < len(c.blocks)-keep; i++ {
+		if c.blocks[i].Pruned {
+			continue
+		}
+		c.blocks[i].Body = Body{}
+		c.blocks[i].Pruned = true
+		pruned++
+	}
+	return pruned
+}
+
+// ReplaceChain replaces the slice with a chain that has more work behind it, as long as it agrees with every checkpoint
+// and keeps every final block.
+// If the new chain forks off within the last UndoDepth blocks, the state is rolled back to the fork and only the
+// new blocks are applied, otherwise it's rebuilt from scratch. It returns what changed.
+func (c *Chain) ReplaceChain(newBlocks []Block) (Reorg, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, block := range newBlocks {
+		if !c.checkpoints.Matches(block) { // no reorganizing past a trusted point
+			return Reorg{}, false
+		}
+	}
+	if ChainWork(newBlocks).Cmp(ChainWork(c.blocks)) <= 0 { // only a chain that took more work replaces the blockchain
+		return Reorg{}, false
+	}
+
+	reorg := Reorg{Fork: forkPoint(c.blocks, newBlocks)}
+	if reorg.Fork < c.finalized { // final blocks are never replaced
+		return Reorg{}, false
+	}
+	reorg.Added = append([]Block(nil), newBlocks[reorg.Fork+1:]...)
+	if c.canRollBack(reorg.Fork) {
+		for len(c.blocks) > reorg.Fork+1 {
+			reorg.Removed = append(reorg.Removed, c.pop())
+		}
+		for _, block := range reorg.Added {
+			c.push(block)
+		}
+		return reorg, true
+	}
+
+	for i := len(c.blocks) - 1; i > reorg.Fork; i-- {
+		reorg.Removed = append(reorg.Removed, c.blocks[i])
+	}
+	c.reset(newBlocks)
+	return reorg, true
+}
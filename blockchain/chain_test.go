@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+// testChain returns a chain whose first block after genesis pays its reward to funded
+func testChain(t *testing.T, funded ed25519.PrivateKey) *Chain {
+	t.Helper()
+
+	genesis := NewGenesisBlock()
+	genesis.Hash = GenerateHash(genesis)
+	c := NewChain(genesis)
+	if block := nextBlock(c, testAddress(funded)); !c.AddBlock(block) {
+		t.Fatal("chain won't take its first block")
+	}
+	return c
+}
+
+// nextBlock returns the block with txs that goes on top of c, its coinbase paying miner
+func nextBlock(c *Chain, miner string, txs ...Transaction) Block {
+	prev := c.Last()
+	coinbase := NewCoinbase(miner, prev.Index+1, c.Params().Reward(prev.Index+1)+TotalFees(txs))
+	block, _ := GenerateBlock(prev, 0, append([]Transaction{coinbase}, txs...)...)
+	if root, err := c.StateRootAfter(block); err == nil {
+		block.StateRoot = root
+		block.Hash = GenerateHash(block)
+	}
+	return block
+}
+
+// transfer returns a transaction sending amount coins to to, with the chain's ID
+func transfer(c *Chain, to string, amount, nonce int) Transaction {
+	return Transaction{Class: ClassUser, To: to, Amount: amount, Nonce: nonce, ChainID: c.Params().ChainID}
+}
+
+func TestValidNextSignatures(t *testing.T) {
+	alice, bob := testKey(1), testKey(2)
+
+	tests := []struct {
+		name string
+		tx   func(c *Chain) Transaction
+		want bool
+	}{
+		{"signed", func(c *Chain) Transaction {
+			return signed(alice, transfer(c, testAddress(bob), 5, 1))
+		}, true},
+		{"unsigned", func(c *Chain) Transaction {
+			tx := transfer(c, testAddress(bob), 5, 1)
+			tx.From = testAddress(alice)
+			return tx
+		}, false},
+		{"changed after signing", func(c *Chain) Transaction {
+			tx := signed(alice, transfer(c, testAddress(bob), 5, 1))
+			tx.To = testAddress(testKey(3))
+			return tx
+		}, false},
+		{"signed by the recipient", func(c *Chain) Transaction {
+			tx := transfer(c, testAddress(bob), 5, 1)
+			tx.From = testAddress(alice)
+			tx.Witness = []string{tx.Sign(bob)}
+			return tx
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testChain(t, alice)
+			block := nextBlock(c, testAddress(bob), tt.tx(c))
+			if got := c.Validate(append(c.Blocks(), block)); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+			if got := c.AddBlock(block); got != tt.want {
+				t.Errorf("AddBlock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidNextBalances(t *testing.T) {
+	alice, bob, carol := testKey(1), testKey(2), testKey(3)
+	reward := DefaultParams.BlockReward // what alice starts with
+
+	pay := func(c *Chain, from ed25519.PrivateKey, to ed25519.PrivateKey, amount, fee, nonce int) Transaction {
+		tx := transfer(c, testAddress(to), amount, nonce)
+		tx.Fee = fee
+		return signed(from, tx)
+	}
+	tests := []struct {
+		name string
+		txs  func(c *Chain) []Transaction
+		want bool
+	}{
+		{"within the balance", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, alice, bob, reward-10, 0, 1)}
+		}, true},
+		{"the whole balance with the fee", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, alice, bob, reward-5, 5, 1)}
+		}, true},
+		{"more than the balance", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, alice, bob, reward+1, 0, 1)}
+		}, false},
+		{"the fee tips it over", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, alice, bob, reward, 1, 1)}
+		}, false},
+		{"overspent across two transactions", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, alice, bob, reward/2+1, 0, 1), pay(c, alice, carol, reward/2, 0, 2)}
+		}, false},
+		{"spending what's received earlier in the block", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, alice, bob, 30, 0, 1), pay(c, bob, carol, 20, 0, 1)}
+		}, true},
+		{"spending what's received later in the block", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, bob, carol, 20, 0, 1), pay(c, alice, bob, 30, 0, 1)}
+		}, false},
+		{"sender with nothing", func(c *Chain) []Transaction {
+			return []Transaction{pay(c, bob, carol, 1, 0, 1)}
+		}, false},
+		{"more token units than coins", func(c *Chain) []Transaction {
+			return []Transaction{signed(alice, Transaction{Class: ClassTokenIssue, Amount: reward * 10, Payload: "GOLD", Nonce: 1, ChainID: c.Params().ChainID})}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testChain(t, alice)
+			block := nextBlock(c, testAddress(carol), tt.txs(c)...)
+			if got := c.Validate(append(c.Blocks(), block)); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+			if got := c.AddBlock(block); got != tt.want {
+				t.Errorf("AddBlock() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package blockchain
+
+import "errors"
+
+// Every network has a chain ID, eg "mainnet" or "testnet", and from ChainHeaderVersion on every block carries
+// its network's in the header, genesis block included, so networks that start at the same moment still get
+// different genesis blocks and never take each other's blocks. Transactions carry it too, and since signatures
+// sign everything but the witness, one signed for one network can't be replayed on another. Transactions a
+// block's maker creates, coinbases and the like, don't need one, they only ever go in that block.
+
+// ErrWrongChain is returned for a block or transaction made for another network
+var ErrWrongChain = errors.New("block or transaction is for another network")
+
+// ValidateChainID returns an error if a block, or any transaction in it, is for another network. Blocks from
+// before ChainHeaderVersion don't say, so they aren't checked.
+func ValidateChainID(block Block, params Params) error {
+	if block.Version < ChainHeaderVersion {
+		return nil
+	}
+	if block.ChainID != params.ChainID {
+		return ErrWrongChain
+	}
+	for _, tx := range block.Transactions {
+		if !tx.BlockMade() && tx.ChainID != params.ChainID {
+			return ErrWrongChain
+		}
+	}
+	return nil
+}
+
+// ValidateChainIDs returns if every block in a chain is for the network
+func ValidateChainIDs(blocks []Block, params Params) bool {
+	for _, block := range blocks {
+		if ValidateChainID(block, params) != nil {
+			return false
+		}
+	}
+	return true
+}
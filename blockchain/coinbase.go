@@ -0,0 +1,94 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// NewCoinbase returns the transaction paying the block reward to the miner of the block at height
+func NewCoinbase(miner string, height, reward int) Transaction {
+	return Transaction{
+		Class:     ClassCoinbase,
+		To:        miner,
+		Amount:    reward,
+		Payload:   strconv.Itoa(height), // keeps coinbases for different blocks apart
+		Timestamp: time.Now().UnixNano(),
+	}
+}
+
+// NewStakeReward returns the transaction paying a delegator its share of the reward of the block at height
+func NewStakeReward(delegator string, height, amount int) Transaction {
+	return Transaction{
+		Class:     ClassStakeReward,
+		To:        delegator,
+		Amount:    amount,
+		Payload:   strconv.Itoa(height),
+		Timestamp: time.Now().UnixNano(),
+	}
+}
+
+// IsReward returns if the transaction pays out part of a block's reward, a coinbase or a stake reward
+func (tx Transaction) IsReward() bool {
+	return tx.Class == ClassCoinbase || tx.Class == ClassStakeReward
+}
+
+// BlockMade returns if only a block's maker can create the transaction, rewards and slashes, so it's never
+// submitted or relayed on its own
+func (tx Transaction) BlockMade() bool {
+	return tx.IsReward() || tx.Class == ClassSlash
+}
+
+// ValidateCoinbase returns an error unless a block pays out exactly one coinbase, as its first
+// transaction, for exactly the block's reward under the emission schedule plus the fees of every other
+// transaction in the block, less what its stake rewards pay delegators.
+// The genesis block has no coinbase.
+func ValidateCoinbase(block Block, params Params) error {
+	if block.Index == 0 || block.Pruned {
+		return nil
+	}
+
+	coinbases, paid := 0, 0
+	for i, tx := range block.Transactions {
+		if !tx.IsReward() {
+			continue
+		}
+		if tx.Class == ClassCoinbase {
+			coinbases++
+			if i != 0 {
+				return errors.New("coinbase has to be the first transaction in a block")
+			}
+		} else if tx.Amount <= 0 {
+			return errors.New("stake reward has to pay a positive amount")
+		}
+		if tx.From != "" {
+			return errors.New("coinbase can't have a sender")
+		}
+		if tx.Fee != 0 {
+			return errors.New("coinbase can't pay a fee")
+		}
+		if tx.Payload != strconv.Itoa(block.Index) {
+			return errors.New("coinbase is for a different block")
+		}
+		paid += tx.Amount
+	}
+
+	if coinbases != 1 {
+		return fmt.Errorf("block %d has %d coinbase transactions, it needs exactly one", block.Index, coinbases)
+	}
+	if want := params.Reward(block.Index) + TotalFees(block.Transactions); paid != want {
+		return fmt.Errorf("coinbase pays %d, the block reward plus fees is %d", paid, want)
+	}
+	return nil
+}
+
+// ValidateRewards returns if every block in a chain pays out the right coinbase
+func ValidateRewards(blocks []Block, params Params) bool {
+	for _, block := range blocks {
+		if ValidateCoinbase(block, params) != nil {
+			return false
+		}
+	}
+	return true
+}
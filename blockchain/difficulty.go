@@ -0,0 +1,204 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+// Proof of work: a block's hash, read as a 256 bit number, has to be below 2^256 / Difficulty, so finding one takes
+// Difficulty tries on average. Difficulty 1 means any hash will do, which is what chains get unless their params
+// turn proof of work on. All the arithmetic is on integers, so every node works out exactly the same difficulty.
+
+// MinDifficulty is the easiest a block can be, where any hash meets it
+const MinDifficulty = 1
+
+// maxTarget is 2^256, one more than the biggest hash
+var maxTarget = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// Target returns the number a hash has to be below to meet the difficulty
+func Target(difficulty int64) *big.Int {
+	return new(big.Int).Div(maxTarget, big.NewInt(difficultyOf(difficulty)))
+}
+
+// MeetsDifficulty returns if the header's hash is below its difficulty's target
+func (h Header) MeetsDifficulty() bool {
+	hashed, err := hex.DecodeString(h.Hash)
+	if err != nil || len(hashed) != sha256.Size {
+		return false
+	}
+	return new(big.Int).SetBytes(hashed).Cmp(Target(h.Difficulty)) < 0
+}
+
+// Solve tries nonces until the header's hash meets its difficulty, setting Nonce and Hash. It gives up with
+// ctx's error once ctx is done.
+func (h *Header) Solve(ctx context.Context) error {
+	return h.solveFrom(ctx, 0, 1, nil)
+}
+
+// SolveParallel is Solve split across workers goroutines, each taking its own share of the nonces: worker i
+// tries i, i+workers, i+2*workers and so on. The first to find one stops the others. If hashes isn't nil,
+// every nonce tried is counted in it as the search goes, so the caller can work out a hash rate.
+func (h *Header) SolveParallel(ctx context.Context, workers int, hashes *atomic.Int64) error {
+	if workers <= 1 {
+		return h.solveFrom(ctx, 0, 1, hashes)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan Header, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(start int64) {
+			defer wg.Done()
+			w := *h
+			if w.solveFrom(ctx, start, int64(workers), hashes) == nil {
+				found <- w
+				cancel()
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	select {
+	case solved := <-found: // if a few found one at once, any of them will do
+		*h = solved
+		return nil
+	default:
+		return ctx.Err()
+	}
+}
+
+// solveFrom tries every step'th nonce from start, counting them in hashes if it isn't nil
+func (h *Header) solveFrom(ctx context.Context, start, step int64, hashes *atomic.Int64) error {
+	tries, counted := int64(0), int64(0)
+	count := func() {
+		if hashes != nil {
+			hashes.Add(tries - counted)
+			counted = tries
+		}
+	}
+	defer count()
+
+	for nonce := start; ; nonce += step {
+		if tries%1024 == 0 { // don't check on every hash
+			count()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		}
+		h.Nonce = nonce
+		h.Hash = GenerateHeaderHash(*h)
+		tries++
+		if h.MeetsDifficulty() {
+			return nil
+		}
+	}
+}
+
+// NextDifficulty returns the difficulty the block after the last of headers has to have, headers being the
+// chain up to and including its parent. Without a TargetBlockTime it's always params.Difficulty. With one,
+// it's retargeted on every block over a sliding window of the last RetargetWindow blocks: their average
+// difficulty, scaled by how much faster or slower than TargetBlockTime they came. It's then clamped to
+// within RetargetMaxRise and RetargetMaxFall percent of the parent's, so a swing in hash rate moves it
+// gradually instead of throwing block times around.
+func NextDifficulty(headers []Header, params Params) int64 {
+	if len(headers) > 0 {
+		params = params.At(headers[len(headers)-1].Index + 1)
+	}
+	if params.TargetBlockTime <= 0 || len(headers) < 2 { // nothing to measure yet
+		return difficultyOf(params.Difficulty)
+	}
+
+	window := params.RetargetWindow
+	if window < 1 {
+		window = 1
+	}
+	if window > len(headers)-1 {
+		window = len(headers) - 1
+	}
+	recent := headers[len(headers)-window-1:]
+
+	total := new(big.Int)
+	for _, h := range recent[1:] {
+		total.Add(total, big.NewInt(difficultyOf(h.Difficulty)))
+	}
+	span := recent[len(recent)-1].Timestamp - recent[0].Timestamp // timestamps always go up, so this is positive
+	next := total.Mul(total, big.NewInt(int64(params.TargetBlockTime)))
+	next.Div(next, big.NewInt(span)) // average difficulty * target time / average time
+
+	parent := big.NewInt(difficultyOf(headers[len(headers)-1].Difficulty))
+	if params.RetargetMaxRise > 0 {
+		ceiling := percentOf(parent, params.RetargetMaxRise)
+		if ceiling.Sign() == 0 { // a small difficulty can still go up by one
+			ceiling.SetInt64(1)
+		}
+		if ceiling.Add(ceiling, parent); next.Cmp(ceiling) > 0 {
+			next = ceiling
+		}
+	}
+	if params.RetargetMaxFall > 0 {
+		floor := new(big.Int).Sub(parent, percentOf(parent, params.RetargetMaxFall))
+		if next.Cmp(floor) < 0 {
+			next = floor
+		}
+	}
+
+	if !next.IsInt64() {
+		return 1<<63 - 1
+	}
+	return difficultyOf(next.Int64())
+}
+
+// percentOf returns percent percent of n, rounded down
+func percentOf(n *big.Int, percent int) *big.Int {
+	p := new(big.Int).Mul(n, big.NewInt(int64(percent)))
+	return p.Div(p, big.NewInt(100))
+}
+
+// difficultyOf returns a difficulty, counting blocks from before proof of work (difficulty 0) as the minimum
+func difficultyOf(difficulty int64) int64 {
+	if difficulty < MinDifficulty {
+		return MinDifficulty
+	}
+	return difficulty
+}
+
+// tailHeaders returns the headers of the last n blocks
+func tailHeaders(blocks []Block, n int) []Header {
+	if n > len(blocks) {
+		n = len(blocks)
+	}
+	headers := make([]Header, n)
+	for i, block := range blocks[len(blocks)-n:] {
+		headers[i] = block.Header
+	}
+	return headers
+}
+
+// ValidateDifficulty returns if every block with proof of work has the difficulty the blocks before it called for
+func ValidateDifficulty(blocks []Block, params Params) bool {
+	headers := make([]Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header
+	}
+	for i := 1; i < len(blocks); i++ {
+		if headers[i].Version >= WorkHeaderVersion && headers[i].Difficulty != NextDifficulty(headers[:i], params) {
+			return false
+		}
+	}
+	return true
+}
+
+// ChainWork returns the total work that went into a chain, the sum of its blocks' difficulties
+func ChainWork(blocks []Block) *big.Int {
+	work := new(big.Int)
+	for _, block := range blocks {
+		work.Add(work, big.NewInt(difficultyOf(block.Difficulty)))
+	}
+	return work
+}
@@ -0,0 +1,50 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// testHeaders returns count headers at difficulty, interval apart
+func testHeaders(count int, difficulty int64, interval time.Duration) []Header {
+	headers := make([]Header, count)
+	for i := range headers {
+		headers[i] = Header{Index: i, Timestamp: int64(i) * int64(interval), Difficulty: difficulty}
+	}
+	return headers
+}
+
+func TestNextDifficulty(t *testing.T) {
+	params := Params{Difficulty: 1000, TargetBlockTime: 10 * time.Second, RetargetWindow: 4, RetargetMaxRise: 25, RetargetMaxFall: 20}
+	fixed := params
+	fixed.TargetBlockTime = 0
+	unclamped := params
+	unclamped.RetargetMaxRise, unclamped.RetargetMaxFall = 0, 0
+
+	tests := []struct {
+		name    string
+		headers []Header
+		params  Params
+		want    int64
+	}{
+		{"no target block time", testHeaders(10, 500, time.Second), fixed, 1000},
+		{"nothing to measure yet", testHeaders(1, 500, time.Second), params, 1000},
+		{"on target", testHeaders(10, 1000, 10*time.Second), params, 1000},
+		{"a little fast", testHeaders(10, 1000, 9*time.Second), params, 1111},
+		{"a little slow", testHeaders(10, 1000, 11*time.Second), params, 909},
+		{"twice as fast, held to the rise", testHeaders(10, 1000, 5*time.Second), params, 1250},
+		{"twice as slow, held to the fall", testHeaders(10, 1000, 20*time.Second), params, 800},
+		{"twice as fast, unclamped", testHeaders(10, 1000, 5*time.Second), unclamped, 2000},
+		{"fewer blocks than the window", testHeaders(3, 1000, 10*time.Second), params, 1000},
+		{"from before proof of work", testHeaders(10, 0, 10*time.Second), params, MinDifficulty},
+		{"the minimum can still rise", testHeaders(10, MinDifficulty, 5*time.Second), params, MinDifficulty + 1},
+		{"never below the minimum", testHeaders(10, MinDifficulty, time.Minute), params, MinDifficulty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextDifficulty(tt.headers, tt.params); got != tt.want {
+				t.Errorf("NextDifficulty() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The canonical binary encoding of blocks, used to store the chain and send blocks between nodes.
+// Every block encodes to exactly one byte string, whatever platform or JSON library wrote it:
+//
+//	int       8 bytes, big endian two's complement
+//	bool      1 byte, 0 or 1
+//	string    4 byte big endian length, then the bytes
+//	list      4 byte big endian count, then each item
+//	Header    Version, Index, Timestamp, Hash, PrevHash, MerkleRoot, Difficulty, Nonce, Bloom, Sealer, Seal, Signals, ChainID, StateRoot
+//	Tx        Class, From, To, Amount, Fee, Nonce, Payload, Timestamp, LockTime, Script, Witness (list of strings), ChainID, Expiry
+//	Commit    Round, Signatures (list of Validator then Signature, both strings)
+//	Block     version byte, Header, Data, Pruned, Transactions (list of Tx), whether it has a Commit (bool), Commit if so
+//	Blocks    list of Block
+//
+// Fields are written in that fixed order with nothing optional, so adding a field means a new version.
+// Older blocks are still read: version 1 has no header Version, and versions 1 and 2 have the timestamp
+// as a string, which is parsed into unix nanoseconds, versions before 4 have no Difficulty or Nonce,
+// versions before 5 have no Bloom, versions before 6 have no Commit, versions before 7 have no Sealer or Seal,
+// versions before 8 have no Signals, versions before 9 have no ChainID in headers or transactions,
+// versions before 10 have no Expiry and versions before 11 have no StateRoot.
+
+// BlockEncodingVersion is the first byte of every encoded block
+const BlockEncodingVersion = 11
+
+// ErrTruncated is returned when decoding runs out of bytes partway through
+var ErrTruncated = errors.New("encoded block is truncated")
+
+// the fewest bytes a block or transaction can encode to in any version, so a count can't make the decoder
+// allocate more than the data could hold
+const (
+	minEncodedBlock = 38 // version byte, Index, a legacy timestamp, Hash, PrevHash, MerkleRoot, Data, Pruned and Transactions all empty
+	minEncodedTx    = 64 // Class, From, To, Amount, Fee, Nonce, Payload, Timestamp, LockTime, Script and Witness all empty
+)
+
+// Encode returns the canonical encoding of the header
+func (h Header) Encode() []byte {
+	return appendHeader(nil, h)
+}
+
+// Encode returns the canonical encoding of the transaction
+func (tx Transaction) Encode() []byte {
+	return appendTx(nil, tx)
+}
+
+// Encode returns the canonical encoding of the block
+func (b Block) Encode() []byte {
+	return appendBlock(nil, b)
+}
+
+// EncodeBlocks returns the canonical encoding of a list of blocks
+func EncodeBlocks(blocks []Block) []byte {
+	buf := appendUint32(nil, uint32(len(blocks)))
+	for _, block := range blocks {
+		buf = appendBlock(buf, block)
+	}
+	return buf
+}
+
+// DecodeBlock reads a block written by Block.Encode
+func DecodeBlock(data []byte) (Block, error) {
+	d := decoder{data: data}
+	block := d.block()
+	return block, d.finish()
+}
+
+// DecodeBlocks reads a list of blocks written by EncodeBlocks
+func DecodeBlocks(data []byte) ([]Block, error) {
+	d := decoder{data: data}
+	count := d.count()
+	blocks := make([]Block, 0, d.capacity(count, minEncodedBlock))
+	for i := 0; i < count && d.err == nil; i++ {
+		blocks = append(blocks, d.block())
+	}
+	return blocks, d.finish()
+}
+
+// DecodeTransaction reads a transaction written by Transaction.Encode
+func DecodeTransaction(data []byte) (Transaction, error) {
+	d := decoder{data: data}
+	tx := d.tx(BlockEncodingVersion)
+	return tx, d.finish()
+}
+
+func appendHeader(buf []byte, h Header) []byte {
+	buf = appendInt(buf, int64(h.Version))
+	buf = appendInt(buf, int64(h.Index))
+	buf = appendInt(buf, h.Timestamp)
+	buf = appendString(buf, h.Hash)
+	buf = appendString(buf, h.PrevHash)
+	buf = appendString(buf, h.MerkleRoot)
+	buf = appendInt(buf, h.Difficulty)
+	buf = appendInt(buf, h.Nonce)
+	buf = appendString(buf, h.Bloom)
+	buf = appendString(buf, h.Sealer)
+	buf = appendString(buf, h.Seal)
+	buf = appendInt(buf, int64(h.Signals))
+	buf = appendString(buf, h.ChainID)
+	return appendString(buf, h.StateRoot)
+}
+
+func appendTx(buf []byte, tx Transaction) []byte {
+	buf = appendString(buf, string(tx.Class))
+	buf = appendString(buf, tx.From)
+	buf = appendString(buf, tx.To)
+	buf = appendInt(buf, int64(tx.Amount))
+	buf = appendInt(buf, int64(tx.Fee))
+	buf = appendInt(buf, int64(tx.Nonce))
+	buf = appendString(buf, tx.Payload)
+	buf = appendInt(buf, tx.Timestamp)
+	buf = appendInt(buf, tx.LockTime)
+	buf = appendString(buf, tx.Script)
+	buf = appendUint32(buf, uint32(len(tx.Witness)))
+	for _, w := range tx.Witness {
+		buf = appendString(buf, w)
+	}
+	buf = appendString(buf, tx.ChainID)
+	return appendInt(buf, tx.Expiry)
+}
+
+func appendBlock(buf []byte, b Block) []byte {
+	buf = append(buf, BlockEncodingVersion)
+	buf = appendHeader(buf, b.Header)
+	buf = appendInt(buf, int64(b.Data))
+	if b.Pruned {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = appendUint32(buf, uint32(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		buf = appendTx(buf, tx)
+	}
+	if b.Commit == nil {
+		return append(buf, 0)
+	}
+	buf = append(buf, 1)
+	buf = appendInt(buf, int64(b.Commit.Round))
+	buf = appendUint32(buf, uint32(len(b.Commit.Signatures)))
+	for _, sig := range b.Commit.Signatures {
+		buf = appendString(buf, sig.Validator)
+		buf = appendString(buf, sig.Signature)
+	}
+	return buf
+}
+
+func appendInt(buf []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(buf, uint64(v))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(buf, v)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
+
+// decoder reads the canonical encoding, remembering the first error so callers can check once at the end
+type decoder struct {
+	data []byte
+	err  error
+}
+
+// next takes n bytes, or nil once anything has gone wrong
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data) {
+		d.err = ErrTruncated
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) int() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// count reads a length, which can't be more than the bytes left since every item takes at least one
+func (d *decoder) count() int {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(d.data)) {
+		d.err = ErrTruncated
+		return 0
+	}
+	return int(n)
+}
+
+// capacity is how many of n items of at least size bytes each the bytes left could hold
+func (d *decoder) capacity(n, size int) int {
+	if most := len(d.data) / size; n > most {
+		return most
+	}
+	return n
+}
+
+func (d *decoder) string() string {
+	return string(d.next(d.count()))
+}
+
+func (d *decoder) bool() bool {
+	b := d.next(1)
+	if b != nil && b[0] > 1 {
+		d.err = fmt.Errorf("invalid bool %d in encoded block", b[0])
+	}
+	return b != nil && b[0] == 1
+}
+
+// header reads a header, which only has a version from block encoding version 2, a unix timestamp from 3,
+// proof of work from 4, a bloom filter from 5, a seal from 7, signals from 8, a chain ID from 9 and a state root from 11
+func (d *decoder) header(encoding byte) Header {
+	var h Header
+	if encoding >= 2 {
+		h.Version = int(d.int())
+	}
+	h.Index = int(d.int())
+	if encoding >= 3 {
+		h.Timestamp = d.int()
+	} else if ts := d.string(); d.err == nil {
+		h.Timestamp, d.err = parseLegacyTime(ts)
+	}
+	h.Hash = d.string()
+	h.PrevHash = d.string()
+	h.MerkleRoot = d.string()
+	if encoding >= 4 {
+		h.Difficulty = d.int()
+		h.Nonce = d.int()
+	}
+	if encoding >= 5 {
+		h.Bloom = d.string()
+	}
+	if encoding >= 7 {
+		h.Sealer = d.string()
+		h.Seal = d.string()
+	}
+	if encoding >= 8 {
+		h.Signals = uint32(d.int())
+	}
+	if encoding >= 9 {
+		h.ChainID = d.string()
+	}
+	if encoding >= 11 {
+		h.StateRoot = d.string()
+	}
+	return h
+}
+
+// tx reads a transaction, which only has a chain ID from block encoding version 9 and an expiry from 10
+func (d *decoder) tx(encoding byte) Transaction {
+	tx := Transaction{
+		Class:     TxClass(d.string()),
+		From:      d.string(),
+		To:        d.string(),
+		Amount:    int(d.int()),
+		Fee:       int(d.int()),
+		Nonce:     int(d.int()),
+		Payload:   d.string(),
+		Timestamp: d.int(),
+		LockTime:  d.int(),
+		Script:    d.string(),
+	}
+	if n := d.count(); n > 0 {
+		tx.Witness = make([]string, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			tx.Witness = append(tx.Witness, d.string())
+		}
+	}
+	if encoding >= 9 {
+		tx.ChainID = d.string()
+	}
+	if encoding >= 10 {
+		tx.Expiry = d.int()
+	}
+	return tx
+}
+
+func (d *decoder) block() Block {
+	var encoding byte
+	if version := d.next(1); version != nil {
+		if encoding = version[0]; encoding < 1 || encoding > BlockEncodingVersion {
+			d.err = fmt.Errorf("unknown block encoding version %d", encoding)
+		}
+	}
+	var b Block
+	b.Header = d.header(encoding)
+	b.Data = int(d.int())
+	b.Pruned = d.bool()
+	if n := d.count(); n > 0 {
+		b.Transactions = make([]Transaction, 0, d.capacity(n, minEncodedTx))
+		for i := 0; i < n && d.err == nil; i++ {
+			b.Transactions = append(b.Transactions, d.tx(encoding))
+		}
+	}
+	if encoding >= 6 && d.bool() {
+		b.Commit = &Commit{Round: int(d.int())}
+		n := d.count()
+		for i := 0; i < n && d.err == nil; i++ {
+			b.Commit.Signatures = append(b.Commit.Signatures, CommitSig{Validator: d.string(), Signature: d.string()})
+		}
+	}
+	return b
+}
+
+// finish returns the first error, or an error if there are bytes left over
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.data) > 0 {
+		d.err = fmt.Errorf("%d unexpected bytes after encoded block", len(d.data))
+	}
+	return d.err
+}
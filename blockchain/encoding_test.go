@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testBlocks returns a genesis block and a block on top of it with a couple of transactions
+func testBlocks() []Block {
+	genesis := NewGenesisBlock()
+	genesis.Hash = GenerateHash(genesis)
+	block, _ := GenerateBlock(genesis, 42,
+		NewCoinbase("miner", 1, 50),
+		Transaction{Class: ClassUser, From: "alice", To: "bob", Amount: 5, Fee: 1, Nonce: 3, Payload: "rent", Timestamp: 1700000000000000000, Script: "1", Witness: []string{"ab", "cd"}},
+	)
+	return []Block{genesis, block}
+}
+
+func TestBlockEncodingRoundTrip(t *testing.T) {
+	blocks := testBlocks()
+	pruned := blocks[1]
+	pruned.Body, pruned.Pruned = Body{}, true
+
+	tests := []struct {
+		name  string
+		block Block
+	}{
+		{"genesis", blocks[0]},
+		{"with transactions", blocks[1]},
+		{"pruned", pruned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeBlock(tt.block.Encode())
+			if err != nil {
+				t.Fatalf("DecodeBlock() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.block) {
+				t.Errorf("DecodeBlock() = %+v, want %+v", got, tt.block)
+			}
+		})
+	}
+
+	got, err := DecodeBlocks(EncodeBlocks(blocks))
+	if err != nil || !reflect.DeepEqual(got, blocks) {
+		t.Errorf("DecodeBlocks() = %+v, %v, want %+v", got, err, blocks)
+	}
+}
+
+func TestDecodeBlockErrors(t *testing.T) {
+	encoded := testBlocks()[1].Encode()
+	badVersion := append([]byte{BlockEncodingVersion + 1}, encoded[1:]...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"whole block", encoded, false},
+		{"empty", nil, true},
+		{"truncated", encoded[:len(encoded)-1], true},
+		{"cut off in the header", encoded[:20], true},
+		{"bytes left over", append(encoded[:len(encoded):len(encoded)], 0), true},
+		{"unknown version", badVersion, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeBlock(tt.data); (err != nil) != tt.wantErr {
+				t.Errorf("DecodeBlock() error = %v, want an error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecoderCapacity(t *testing.T) {
+	if size := len(Transaction{}.Encode()); size < minEncodedTx {
+		t.Errorf("an empty transaction encodes to %d bytes, less than minEncodedTx %d", size, minEncodedTx)
+	}
+	if size := len(Block{}.Encode()); size < minEncodedBlock {
+		t.Errorf("an empty block encodes to %d bytes, less than minEncodedBlock %d", size, minEncodedBlock)
+	}
+
+	tests := []struct {
+		name  string
+		count int
+		left  int
+		want  int
+	}{
+		{"room for all of them", 2, 2 * minEncodedBlock, 2},
+		{"count past the bytes left", 1 << 30, 3*minEncodedBlock + 1, 3},
+		{"nothing left", 5, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := decoder{data: make([]byte, tt.left)}
+			if got := d.capacity(tt.count, minEncodedBlock); got != tt.want {
+				t.Errorf("capacity(%d) with %d bytes left = %d, want %d", tt.count, tt.left, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package blockchain
+
+// Finalized returns the header of the highest final block, false if the chain has no finality rule or isn't
+// FinalityDepth blocks long yet. On BFT networks it's the head. Final blocks are never replaced by a reorg,
+// however much work the other branch has, so a payment in one is settled.
+func (c *Chain) Finalized() (Header, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.finalized < 0 {
+		return Header{}, false
+	}
+	return c.blocks[c.finalized].Header, true
+}
+
+// finalize moves the final height up to FinalityDepth below the head. It never moves back down, even if the
+// rule is turned off, so it works like a checkpoint the chain set itself. c.mu has to be held.
+func (c *Chain) finalize() {
+	if c.params.Engine == EngineBFT { // every block was agreed on before it was added
+		c.finalized = len(c.blocks) - 1
+		return
+	}
+	if c.params.FinalityDepth <= 0 {
+		return
+	}
+	if height := len(c.blocks) - 1 - c.params.FinalityDepth; height > c.finalized {
+		c.finalized = height
+	}
+}
@@ -0,0 +1,125 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A network upgrades its rules with hard forks, scheduled in every node's config ahead of time. Each fork
+// names the height it activates at and what changes from there on: the header version blocks have to use,
+// which decides how their hash is worked out, and any of the params governance can change. Blocks are
+// checked against the rules for their own height, so nodes that have the schedule all switch at the same
+// block, and ones that don't get left on the old branch. Blocks before a fork can't use the version it
+// brings in, so no one switches early.
+
+// the reasons a block's version doesn't fit the fork schedule
+var (
+	ErrVersionTooOld = errors.New("block's header version is older than a fork at its height calls for")
+	ErrVersionTooNew = errors.New("block's header version belongs to a fork that isn't active at its height")
+)
+
+// Fork ... a change to the rules every node switches to at the same height
+type Fork struct {
+	Name    string
+	Height  int              // the first block it applies to
+	Version int              // the header version blocks need from Height on, 0 leaves it alone
+	Params  map[string]int64 `json:",omitempty"` // params it changes from Height on, named as in governance.go
+}
+
+// ValidateForks returns an error if a fork schedule has a fork with no height, a header version this node
+// doesn't know or a param that can't be changed, or versions that go backwards
+func ValidateForks(forks []Fork) error {
+	version := 0
+	for _, fork := range sortForks(forks) {
+		if fork.Height <= 0 {
+			return fmt.Errorf("fork %s needs a height after the genesis block", fork.Name)
+		}
+		if fork.Version != 0 && (fork.Version < UnixTimeHeaderVersion || fork.Version > HeaderVersion) {
+			return fmt.Errorf("fork %s has header version %d, this node knows %d to %d", fork.Name, fork.Version, UnixTimeHeaderVersion, HeaderVersion)
+		}
+		if fork.Version != 0 && fork.Version < version {
+			return fmt.Errorf("fork %s goes back to header version %d", fork.Name, fork.Version)
+		}
+		if fork.Version != 0 {
+			version = fork.Version
+		}
+		for param, value := range fork.Params {
+			if !validParam(param, value) {
+				return fmt.Errorf("fork %s can't set %s to %d", fork.Name, param, value)
+			}
+		}
+	}
+	return nil
+}
+
+// Versions returns the oldest and newest header versions a block at height can have: no older than the last
+// fork by then brought in, and older than any version a fork still to come brings in
+func (p Params) Versions(height int) (oldest, newest int) {
+	oldest, newest = LegacyHeaderVersion, HeaderVersion
+	for _, fork := range p.Forks {
+		switch {
+		case fork.Version == 0:
+		case fork.Height <= height && fork.Version > oldest:
+			oldest = fork.Version
+		case fork.Height > height && fork.Version <= newest:
+			newest = fork.Version - 1
+		}
+	}
+	return oldest, newest
+}
+
+// ValidateVersion returns an error if a header's version doesn't fit the fork schedule at its height. The
+// genesis block is whatever the network started with.
+func ValidateVersion(header Header, params Params) error {
+	if header.Index == 0 {
+		return nil
+	}
+	oldest, newest := params.Versions(header.Index)
+	if header.Version < oldest {
+		return ErrVersionTooOld
+	}
+	if header.Version > newest {
+		return ErrVersionTooNew
+	}
+	return nil
+}
+
+// ValidateVersions returns if every block in a chain fits the fork schedule
+func ValidateVersions(blocks []Block, params Params) bool {
+	for _, block := range blocks {
+		if ValidateVersion(block.Header, params) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// schedule returns every param change forks and governance make, by the height they apply from. Governance
+// changes come after forks at the same height, so they win.
+func (p Params) schedule() []ParamChange {
+	if len(p.Forks) == 0 {
+		return p.Changes
+	}
+	var changes []ParamChange
+	for _, fork := range sortForks(p.Forks) {
+		params := make([]string, 0, len(fork.Params))
+		for param := range fork.Params {
+			params = append(params, param)
+		}
+		sort.Strings(params) // the same order on every node
+		for _, param := range params {
+			changes = append(changes, ParamChange{Param: param, Value: fork.Params[param], Height: fork.Height})
+		}
+	}
+	changes = append(changes, p.Changes...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Height < changes[j].Height })
+	return changes
+}
+
+// sortForks returns a copy of forks sorted by height
+func sortForks(forks []Fork) []Fork {
+	sorted := append([]Fork(nil), forks...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Height < sorted[j].Height })
+	return sorted
+}
@@ -0,0 +1,312 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Coin holders can change some of the consensus rules on chain. A ClassProposal transaction proposes setting
+// one of the governed params to a new value, with its Payload the JSON of a ProposalTerms, and its hash is the
+// proposal's ID. Anyone can then vote on it with a ClassVote transaction, To the proposal's ID and Payload
+// VoteYes or VoteNo, one vote per address, until the proposal's End height. Proposals and votes are signed by
+// their sender like any other transaction, so no one can vote with someone else's address. Votes are weighed by
+// the voter's coin balance as of the End block, which can't be more than the coins it really has since balances
+// are enforced, and moving coins around doesn't count them twice. The proposal passes if more coins voted for it
+// than against. A passed change is added to Params.Changes and takes effect from
+// its Activation height, which has to be after End so every node knows the result well before.
+
+// the params proposals can change
+const (
+	ParamBlockReward     = "block_reward"
+	ParamMaxBlockSize    = "max_block_size"
+	ParamMaxBlockCost    = "max_block_cost"
+	ParamDifficulty      = "difficulty"
+	ParamTargetBlockTime = "target_block_time" // in seconds
+)
+
+// what a ClassVote transaction's Payload can be
+const (
+	VoteYes = "yes"
+	VoteNo  = "no"
+)
+
+// where a proposal is at
+const (
+	ProposalOpen     = "open"
+	ProposalPassed   = "passed"
+	ProposalRejected = "rejected"
+)
+
+// the reasons a proposal or vote doesn't apply
+var (
+	ErrBadProposal     = errors.New("proposal needs a governed param, a valid value, a sender and an activation after its end")
+	ErrUnknownProposal = errors.New("no such proposal")
+	ErrVotingClosed    = errors.New("proposal's voting has closed")
+	ErrBadVote         = errors.New("vote needs a voter and Payload yes or no")
+	ErrAlreadyVoted    = errors.New("address already voted on the proposal")
+)
+
+// ProposalTerms ... the Payload of a proposal, eg {"Param":"block_reward","Value":25,"End":1200,"Activation":1500}
+type ProposalTerms struct {
+	Param      string
+	Value      int64
+	End        int // the last height votes are taken at, and the one they're counted as of
+	Activation int // the first block the change applies to, if it passes
+}
+
+// ParamProposal ... a proposed change to a param, its votes and how it went
+type ParamProposal struct {
+	ProposalTerms
+	ID       string // the hash of the proposal transaction
+	Proposer string
+	Height   int               // of the block that has the proposal
+	Votes    map[string]string // voter -> VoteYes or VoteNo
+	Status   string            // ProposalOpen, ProposalPassed or ProposalRejected
+	Yes, No  int               // coins behind each side, once counted at End
+}
+
+// ParamChange ... a change governance passed, applied to blocks from Height on
+type ParamChange struct {
+	Param    string
+	Value    int64
+	Height   int
+	Proposal string // its ID
+}
+
+// Governance ... every proposal on a chain and the changes that passed
+type Governance struct {
+	proposals map[string]*ParamProposal
+	changes   []ParamChange // by Height, then in the order they passed
+}
+
+// NewGovernance returns governance with no proposals
+func NewGovernance() *Governance {
+	return &Governance{proposals: make(map[string]*ParamProposal)}
+}
+
+// governance builds the governance of a chain, skipping anything that doesn't apply
+func governance(blocks []Block) *Governance {
+	g, balances := NewGovernance(), make(map[string]int)
+	for _, block := range blocks {
+		applyBalances(balances, block)
+		g.applyBlock(block, balances)
+	}
+	return g
+}
+
+// ValidateGovernance returns if every proposal and vote in a chain applies. Votes can't be counted past a
+// pruned block, so pruned chains are only checked up to it.
+func ValidateGovernance(blocks []Block) bool {
+	g, balances := NewGovernance(), make(map[string]int)
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+		if g.CheckBlock(block) != nil {
+			return false
+		}
+		applyBalances(balances, block)
+		g.applyBlock(block, balances)
+	}
+	return true
+}
+
+// Copy returns a copy that can be changed without touching this one
+func (g *Governance) Copy() *Governance {
+	c := NewGovernance()
+	for id, p := range g.proposals {
+		cp := *p
+		cp.Votes = make(map[string]string, len(p.Votes))
+		for voter, vote := range p.Votes {
+			cp.Votes[voter] = vote
+		}
+		c.proposals[id] = &cp
+	}
+	c.changes = append([]ParamChange(nil), g.changes...)
+	return c
+}
+
+// Proposals returns copies of every proposal, newest first
+func (g *Governance) Proposals() []ParamProposal {
+	proposals := make([]ParamProposal, 0, len(g.proposals))
+	for _, p := range g.Copy().proposals {
+		proposals = append(proposals, *p)
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if proposals[i].Height != proposals[j].Height {
+			return proposals[i].Height > proposals[j].Height
+		}
+		return proposals[i].ID < proposals[j].ID
+	})
+	return proposals
+}
+
+// Proposal returns a copy of a proposal by ID
+func (g *Governance) Proposal(id string) (ParamProposal, bool) {
+	if _, ok := g.proposals[id]; !ok {
+		return ParamProposal{}, false
+	}
+	return *g.Copy().proposals[id], true
+}
+
+// Changes returns the changes that passed, by the height they apply from
+func (g *Governance) Changes() []ParamChange {
+	return append([]ParamChange(nil), g.changes...)
+}
+
+// Apply adds a proposal or vote in a block at height, returning an error and leaving governance alone if it
+// doesn't apply. Transactions that aren't proposals or votes are ignored.
+func (g *Governance) Apply(tx Transaction, height int) error {
+	switch tx.Class {
+	case ClassProposal:
+		var terms ProposalTerms
+		if tx.From == "" || json.Unmarshal([]byte(tx.Payload), &terms) != nil || !terms.valid(height) {
+			return ErrBadProposal
+		}
+		if _, ok := g.proposals[tx.Hash()]; ok {
+			return ErrBadProposal
+		}
+		g.proposals[tx.Hash()] = &ParamProposal{
+			ProposalTerms: terms, ID: tx.Hash(), Proposer: tx.From, Height: height, Votes: make(map[string]string), Status: ProposalOpen,
+		}
+
+	case ClassVote:
+		p, ok := g.proposals[tx.To]
+		switch {
+		case !ok:
+			return ErrUnknownProposal
+		case p.Status != ProposalOpen || height > p.End:
+			return ErrVotingClosed
+		case tx.From == "" || (tx.Payload != VoteYes && tx.Payload != VoteNo):
+			return ErrBadVote
+		}
+		if _, voted := p.Votes[tx.From]; voted {
+			return ErrAlreadyVoted
+		}
+		p.Votes[tx.From] = tx.Payload
+	}
+	return nil
+}
+
+// CheckBlock returns an error if any of a block's proposals or votes don't apply, without changing anything
+func (g *Governance) CheckBlock(block Block) error {
+	if !hasGovernanceTxs(block) {
+		return nil
+	}
+	next := g.Copy()
+	for _, tx := range block.Transactions {
+		if err := next.Apply(tx, block.Index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyBlock applies the block's proposals and votes that apply, skipping the rest, then counts the votes on
+// every proposal ending at it with the coin balances as of the block
+func (g *Governance) applyBlock(block Block, balances map[string]int) {
+	for _, tx := range block.Transactions {
+		g.Apply(tx, block.Index)
+	}
+	for _, id := range g.ending(block.Index) {
+		p := g.proposals[id]
+		for voter, vote := range p.Votes {
+			if vote == VoteYes {
+				p.Yes += balances[voter]
+			} else {
+				p.No += balances[voter]
+			}
+		}
+		p.Status = ProposalRejected
+		if p.Yes > p.No {
+			p.Status = ProposalPassed
+			g.changes = append(g.changes, ParamChange{Param: p.Param, Value: p.Value, Height: p.Activation, Proposal: p.ID})
+		}
+	}
+	sort.SliceStable(g.changes, func(i, j int) bool { return g.changes[i].Height < g.changes[j].Height })
+}
+
+// ending returns the IDs of the open proposals whose voting ends at height, sorted so they pass in the same
+// order on every node
+func (g *Governance) ending(height int) []string {
+	var ids []string
+	for id, p := range g.proposals {
+		if p.Status == ProposalOpen && p.End == height {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// touchesGovernance returns if a block changes governance, with its transactions or by ending a vote
+func (g *Governance) touchesGovernance(block Block) bool {
+	return hasGovernanceTxs(block) || len(g.ending(block.Index)) > 0
+}
+
+// valid returns if the terms can be proposed in a block at height
+func (t ProposalTerms) valid(height int) bool {
+	return t.End >= height && t.Activation > t.End && validParam(t.Param, t.Value)
+}
+
+// validParam returns if a param can be changed, and to the value
+func validParam(param string, value int64) bool {
+	switch param {
+	case ParamBlockReward, ParamMaxBlockSize, ParamMaxBlockCost, ParamTargetBlockTime:
+		return value >= 0
+	case ParamDifficulty:
+		return value >= MinDifficulty
+	}
+	return false
+}
+
+// hasGovernanceTxs returns if a block has any proposals or votes
+func hasGovernanceTxs(block Block) bool {
+	for _, tx := range block.Transactions {
+		if tx.Class == ClassProposal || tx.Class == ClassVote {
+			return true
+		}
+	}
+	return false
+}
+
+// At returns the params in force for the block at height, with every fork and change that's passed by then
+// applied. It has to be called on the network's params with their Changes, not ones already moved to a height.
+func (p Params) At(height int) Params {
+	at := p
+	for _, change := range p.schedule() {
+		if change.Height > height {
+			break
+		}
+		switch change.Param {
+		case ParamBlockReward:
+			at.BlockReward = int(change.Value)
+		case ParamMaxBlockSize:
+			at.MaxBlockSize = int(change.Value)
+		case ParamMaxBlockCost:
+			at.MaxBlockCost = int(change.Value)
+		case ParamDifficulty:
+			at.Difficulty = change.Value
+		case ParamTargetBlockTime:
+			at.TargetBlockTime = time.Duration(change.Value) * time.Second
+		}
+	}
+	return at
+}
+
+// Governance returns a copy of the chain's governance as of the head
+func (c *Chain) Governance() *Governance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.governance.Copy()
+}
+
+// rules returns the chain's params with the changes governance has passed and active soft forks make, c.mu
+// has to be held
+func (c *Chain) rules() Params {
+	params := c.params
+	params.Changes = mergeChanges(c.softForks.Changes(), c.governance.Changes())
+	return params
+}
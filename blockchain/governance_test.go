@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+)
+
+func TestGovernanceVotes(t *testing.T) {
+	alice, bob, carol := testKey(1), testKey(2), testKey(3)
+	reward := DefaultParams.BlockReward // what alice starts with
+
+	// alice proposes in block 2, votes are taken in block 3
+	terms, _ := json.Marshal(ProposalTerms{Param: ParamBlockReward, Value: 25, End: 3, Activation: 5})
+	setup := func(c *Chain) string {
+		proposal := signed(alice, Transaction{Class: ClassProposal, Payload: string(terms), Nonce: 1, ChainID: c.Params().ChainID})
+		if !c.AddBlock(nextBlock(c, testAddress(carol), proposal, signed(alice, transfer(c, testAddress(bob), 10, 2)))) {
+			t.Fatal("chain won't take the proposal")
+		}
+		return proposal.Hash()
+	}
+	vote := func(c *Chain, key ed25519.PrivateKey, id, choice string) Transaction {
+		return signed(key, Transaction{Class: ClassVote, To: id, Payload: choice, Nonce: c.nonces[testAddress(key)] + 1, ChainID: c.Params().ChainID})
+	}
+
+	tests := []struct {
+		name    string
+		votes   func(c *Chain, id string) []Transaction
+		valid   bool
+		status  string
+		yes, no int
+	}{
+		{"weighed by balance", func(c *Chain, id string) []Transaction {
+			return []Transaction{vote(c, alice, id, VoteYes), vote(c, bob, id, VoteNo)}
+		}, true, ProposalPassed, reward - 10, 10},
+		{"outweighed", func(c *Chain, id string) []Transaction {
+			return []Transaction{vote(c, alice, id, VoteNo), vote(c, bob, id, VoteYes)}
+		}, true, ProposalRejected, 10, reward - 10},
+		{"no coins, no weight", func(c *Chain, id string) []Transaction {
+			return []Transaction{vote(c, testKey(4), id, VoteYes), vote(c, bob, id, VoteNo)}
+		}, true, ProposalRejected, 0, 10},
+		{"unsigned", func(c *Chain, id string) []Transaction {
+			tx := vote(c, alice, id, VoteYes)
+			tx.Witness = nil
+			return []Transaction{tx}
+		}, false, ProposalOpen, 0, 0},
+		{"signed by someone else", func(c *Chain, id string) []Transaction {
+			tx := vote(c, bob, id, VoteYes)
+			tx.From = testAddress(alice) // bob voting with alice's coins
+			return []Transaction{tx}
+		}, false, ProposalOpen, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testChain(t, alice)
+			id := setup(c)
+			if got := c.AddBlock(nextBlock(c, testAddress(carol), tt.votes(c, id)...)); got != tt.valid {
+				t.Fatalf("AddBlock() = %v, want %v", got, tt.valid)
+			}
+			p, _ := c.Governance().Proposal(id)
+			if p.Status != tt.status || p.Yes != tt.yes || p.No != tt.no {
+				t.Errorf("proposal is %s with %d yes and %d no, want %s with %d and %d", p.Status, p.Yes, p.No, tt.status, tt.yes, tt.no)
+			}
+		})
+	}
+}
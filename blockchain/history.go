@@ -0,0 +1,53 @@
+package blockchain
+
+// TxRef ... where a confirmed transaction sits in the chain
+type TxRef struct {
+	Height int    // of the block it's in
+	Index  int    // its position in the block
+	Hash   string // the transaction's hash
+}
+
+// TxRecord ... a confirmed transaction and where it is
+type TxRecord struct {
+	TxRef
+	BlockHash string
+	Tx        *Transaction // nil if the block's body has been pruned
+	Final     bool         // if its block is final, see Chain.Finalized
+}
+
+// History returns a page of the transactions an address has sent or received, newest first, skipping the
+// newest offset, along with how many there are in all
+func (c *Chain) History(address string, offset, limit int) ([]TxRecord, int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	refs := c.index.Addresses[address]
+	records := []TxRecord{}
+	for i := len(refs) - 1 - offset; i >= 0 && len(records) < limit; i-- {
+		records = append(records, c.record(refs[i]))
+	}
+	return records, len(refs)
+}
+
+// Tx returns a confirmed transaction by its hash
+func (c *Chain) Tx(hash string) (TxRecord, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ref, ok := c.index.Txs[hash]
+	if !ok {
+		return TxRecord{}, false
+	}
+	return c.record(ref), true
+}
+
+// record looks up the transaction a ref points to, c.mu has to be held
+func (c *Chain) record(ref TxRef) TxRecord {
+	block := c.blocks[ref.Height]
+	record := TxRecord{TxRef: ref, BlockHash: block.Hash, Final: ref.Height <= c.finalized}
+	if !block.Pruned {
+		tx := block.Transactions[ref.Index]
+		record.Tx = &tx
+	}
+	return record
+}
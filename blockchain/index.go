@@ -0,0 +1,135 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Index ... lookups into a chain that would otherwise mean scanning it: blocks by hash, and transactions by
+// hash and by address. The chain keeps it up to date as blocks are added, and nodes store it next to the
+// chain so it survives restarts, even for blocks whose bodies have since been pruned. Blocks by time don't
+// need one, timestamps always go up so the headers can be binary searched.
+type Index struct {
+	Head      string             // hash of the block the index is up to
+	Blocks    map[string]int     // block hash -> height
+	Txs       map[string]TxRef   // transaction hash -> where it is
+	Addresses map[string][]TxRef // address -> transactions it's sent or received, oldest first
+}
+
+// IndexEncodingVersion is the first byte of an encoded index
+const IndexEncodingVersion = 1
+
+// NewIndex indexes a chain
+func NewIndex(blocks []Block) *Index {
+	ix := &Index{Blocks: make(map[string]int), Txs: make(map[string]TxRef), Addresses: make(map[string][]TxRef)}
+	for _, block := range blocks {
+		ix.apply(block)
+	}
+	return ix
+}
+
+// apply adds a block to the index, returning the transaction hashes and the address of each address entry it
+// added, so it can be taken off again with revert
+func (ix *Index) apply(block Block) (txs, addresses []string) {
+	ix.Head = block.Hash
+	ix.Blocks[block.Hash] = block.Index
+	for i, tx := range block.Transactions {
+		ref := TxRef{Height: block.Index, Index: i, Hash: tx.Hash()}
+		ix.Txs[ref.Hash] = ref
+		txs = append(txs, ref.Hash)
+		if tx.From != "" {
+			ix.Addresses[tx.From] = append(ix.Addresses[tx.From], ref)
+			addresses = append(addresses, tx.From)
+		}
+		if tx.To != "" && tx.To != tx.From {
+			ix.Addresses[tx.To] = append(ix.Addresses[tx.To], ref)
+			addresses = append(addresses, tx.To)
+		}
+	}
+	return txs, addresses
+}
+
+// revert takes the head block off the index, given what apply returned for it
+func (ix *Index) revert(block Block, txs, addresses []string) {
+	ix.Head = block.PrevHash
+	delete(ix.Blocks, block.Hash)
+	for _, hash := range txs {
+		delete(ix.Txs, hash)
+	}
+	for i := len(addresses) - 1; i >= 0; i-- {
+		refs := ix.Addresses[addresses[i]]
+		if len(refs) <= 1 {
+			delete(ix.Addresses, addresses[i])
+			continue
+		}
+		ix.Addresses[addresses[i]] = refs[:len(refs)-1]
+	}
+}
+
+// Encode returns the index for storing. Only the address lists are written, with the head they're up to,
+// the rest can be worked out from them and the headers, which are never pruned:
+//
+//	version byte, Head, Addresses (list of: address, list of TxRef as Height, Index, Hash)
+//
+// in the chain's canonical encoding, addresses sorted so the same index always encodes the same way.
+func (ix *Index) Encode() []byte {
+	addresses := make([]string, 0, len(ix.Addresses))
+	for address := range ix.Addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	buf := []byte{IndexEncodingVersion}
+	buf = appendString(buf, ix.Head)
+	buf = appendUint32(buf, uint32(len(addresses)))
+	for _, address := range addresses {
+		refs := ix.Addresses[address]
+		buf = appendString(buf, address)
+		buf = appendUint32(buf, uint32(len(refs)))
+		for _, ref := range refs {
+			buf = appendInt(buf, int64(ref.Height))
+			buf = appendInt(buf, int64(ref.Index))
+			buf = appendString(buf, ref.Hash)
+		}
+	}
+	return buf
+}
+
+// DecodeIndex reads an index written by Index.Encode for the chain made up of blocks. It fails if the index
+// isn't up to the chain's head, like when a crash came between writing one and the other.
+func DecodeIndex(data []byte, blocks []Block) (*Index, error) {
+	if len(blocks) == 0 {
+		return nil, errors.New("there's no chain to index")
+	}
+	d := decoder{data: data}
+	if version := d.next(1); version != nil && version[0] != IndexEncodingVersion {
+		return nil, fmt.Errorf("unknown index encoding version %d", version[0])
+	}
+
+	ix := &Index{Head: d.string(), Blocks: make(map[string]int, len(blocks)), Txs: make(map[string]TxRef), Addresses: make(map[string][]TxRef)}
+	for i := d.count(); i > 0 && d.err == nil; i-- {
+		address := d.string()
+		n := d.count()
+		refs := make([]TxRef, 0, n)
+		for j := 0; j < n && d.err == nil; j++ {
+			ref := TxRef{Height: int(d.int()), Index: int(d.int()), Hash: d.string()}
+			if ref.Height < 0 || ref.Height >= len(blocks) {
+				return nil, fmt.Errorf("index has a transaction at height %d, past the head", ref.Height)
+			}
+			refs = append(refs, ref)
+			ix.Txs[ref.Hash] = ref
+		}
+		ix.Addresses[address] = refs
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	if head := blocks[len(blocks)-1].Hash; ix.Head != head {
+		return nil, fmt.Errorf("index is up to block %s, not the head %s", ix.Head, head)
+	}
+	for _, block := range blocks {
+		ix.Blocks[block.Hash] = block.Index
+	}
+	return ix, nil
+}
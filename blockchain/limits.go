@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The cost model, what a transaction uses up of a block's MaxBlockCost. Every transaction pays a base cost,
+// then for its size and for the script it makes every node run, with signature checks the dearest part.
+const (
+	TxBaseCost     = 1000 // every transaction, for checking and indexing it
+	TxByteCost     = 10   // every byte of its canonical encoding
+	ScriptWordCost = 10   // every word of its script
+	SigCheckCost   = 500  // every signature its script can check, a multisig counts as its most keys, or its sender's without one
+)
+
+// Size returns how many bytes the block takes up in the canonical encoding, not counting its commit, which
+// is added after the block's been checked
+func (b Block) Size() int {
+	b.Commit = nil
+	return len(b.Encode())
+}
+
+// Cost returns what the transaction uses up of a block's MaxBlockCost
+func (tx Transaction) Cost() int {
+	cost := TxBaseCost + TxByteCost*len(tx.Encode())
+	if tx.Script == "" && !tx.BlockMade() { // signed by From's key
+		cost += SigCheckCost
+	}
+	for _, word := range strings.Fields(tx.Script) {
+		cost += ScriptWordCost
+		switch word {
+		case "CHECKSIG", "CHECKSIGVERIFY":
+			cost += SigCheckCost
+		case "CHECKMULTISIG", "CHECKMULTISIGVERIFY": // how many keys isn't known until it runs
+			cost += SigCheckCost * maxMultisigKeys
+		}
+	}
+	return cost
+}
+
+// TotalCost adds up the cost of a set of transactions
+func TotalCost(txs []Transaction) int {
+	total := 0
+	for _, tx := range txs {
+		total += tx.Cost()
+	}
+	return total
+}
+
+// ValidateSize returns an error if a block is bigger or costs more than the params allow.
+// Pruned blocks aren't checked, their bodies are gone.
+func ValidateSize(block Block, params Params) error {
+	if block.Pruned {
+		return nil
+	}
+	params = params.At(block.Index)
+	if size := block.Size(); params.MaxBlockSize > 0 && size > params.MaxBlockSize {
+		return fmt.Errorf("block %d is %d bytes, more than the %d allowed", block.Index, size, params.MaxBlockSize)
+	}
+	if cost := TotalCost(block.Transactions); params.MaxBlockCost > 0 && cost > params.MaxBlockCost {
+		return fmt.Errorf("block %d costs %d, more than the %d allowed", block.Index, cost, params.MaxBlockCost)
+	}
+	return nil
+}
+
+// ValidateSizes returns if every block in a chain is within the size and cost limits
+func ValidateSizes(blocks []Block, params Params) bool {
+	for _, block := range blocks {
+		if ValidateSize(block, params) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// hashPair hashes two child nodes together into their parent node
+func hashPair(left, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write(left)
+	hash.Write(right)
+	return hash.Sum(nil)
+}
+
+// MerkleRoot builds a merkle tree out of leaf hashes and returns its root as a hex string.
+// An odd node out at any level is paired with itself, the same way bitcoin does it.
+func MerkleRoot(leaves [][]byte) string {
+	if len(leaves) == 0 {
+		return ""
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashPair(level[i], right))
+		}
+		level = next
+	}
+	return hex.EncodeToString(level[0])
+}
+
+// ProofStep ... one sibling hash on the path from a leaf up to the merkle root
+type ProofStep struct {
+	Hash string // hex encoded sibling hash
+	Left bool   // if the sibling sits on the left, so it's hashed before the running hash
+}
+
+// MerkleProof returns the branch of sibling hashes proving the leaf at index is in the tree
+func MerkleProof(leaves [][]byte, index int) ([]ProofStep, bool) {
+	if index < 0 || index >= len(leaves) {
+		return nil, false
+	}
+
+	var proof []ProofStep
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1 // the other half of the pair
+		if sibling >= len(level) {
+			sibling = index // odd node out is paired with itself
+		}
+		proof = append(proof, ProofStep{
+			Hash: hex.EncodeToString(level[sibling]),
+			Left: sibling < index,
+		})
+
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, hashPair(level[i], right))
+		}
+		level = next
+		index /= 2
+	}
+	return proof, true
+}
+
+// VerifyProof returns if a merkle branch links a leaf hash up to the given root, all hex encoded
+func VerifyProof(leafHash string, proof []ProofStep, root string) bool {
+	current, err := hex.DecodeString(leafHash)
+	if err != nil {
+		return false
+	}
+
+	for _, step := range proof {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false
+		}
+		if step.Left {
+			current = hashPair(sibling, current)
+		} else {
+			current = hashPair(current, sibling)
+		}
+	}
+	return hex.EncodeToString(current) == root
+}
@@ -0,0 +1,92 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// legacyTimeLayout is how block timestamps were written before they were unix nanoseconds, time.Time's String format
+const legacyTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// Rehash returns a copy of a chain with every header moved to HeaderVersion, relinking each block to
+// its parent's new hash. Every block gets a new identity, so it's for chains every node can be
+// migrated together, like a private network, and checkpoints have to be set again afterwards.
+// A genesis block without a hash keeps going without one. Blocks with proof of work are solved again
+// at the same difficulty, blocks from before it get MinDifficulty. Pruned blocks get a bloom filter that
+// matches everything, what's in them can't be worked out any more, and state roots from them on only
+// count the bodies that are left.
+func Rehash(blocks []Block) []Block {
+	rehashed := make([]Block, len(blocks))
+	var accounts StateTrie
+	balances, nonces := make(map[string]int), make(map[string]int)
+	for i, block := range blocks {
+		block.Version = HeaderVersion
+		block.Difficulty = difficultyOf(block.Difficulty)
+		if block.Pruned {
+			block.Bloom = fullBloom.String()
+		} else {
+			block.Bloom = block.Body.BloomFilter().String()
+		}
+		accounts = accounts.afterBlock(block, balances, nonces)
+		block.StateRoot = accounts.Root()
+		applyAccounts(balances, nonces, block)
+		if i > 0 {
+			block.PrevHash = rehashed[i-1].Hash
+		}
+		if i > 0 || block.Hash != "" {
+			block.SolveParallel(context.Background(), runtime.NumCPU(), nil)
+		}
+		rehashed[i] = block
+	}
+	return rehashed
+}
+
+// MigrateTimestamps rehashes a chain read from before timestamps were unix nanoseconds, returning it as it
+// is if it's already current. Old headers hashed the timestamp's text, which is gone once it's been parsed,
+// so they can't be checked and the chain has to be taken on trust, like a node trusts its own storage.
+func MigrateTimestamps(blocks []Block) ([]Block, bool) {
+	for _, block := range blocks {
+		if block.Version < UnixTimeHeaderVersion {
+			return Rehash(blocks), true
+		}
+	}
+	return blocks, false
+}
+
+// DecodeLegacyJSON reads blocks stored as JSON with the old text timestamps
+func DecodeLegacyJSON(data []byte) ([]Block, error) {
+	var legacy []struct {
+		Block
+		Timestamp string // shadows the header's
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+
+	blocks := make([]Block, len(legacy))
+	for i, l := range legacy {
+		ts, err := parseLegacyTime(l.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %v", l.Index, err)
+		}
+		blocks[i] = l.Block
+		blocks[i].Timestamp = ts
+	}
+	return blocks, nil
+}
+
+// parseLegacyTime reads a timestamp written by time.Time's String into unix nanoseconds
+func parseLegacyTime(s string) (int64, error) {
+	if i := strings.Index(s, " m="); i >= 0 { // drop the monotonic clock reading
+		s = s[:i]
+	}
+	t, err := time.Parse(legacyTimeLayout, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixNano(), nil
+}
@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// maxMultisigKeys is the most public keys a multisig script can list
+const maxMultisigKeys = 20
+
+// MultisigScript returns the script requiring signatures from m of the given hex ed25519 public keys,
+// "<m> <pubkey>... <n> CHECKMULTISIG". Signatures go in the witness in the same order as their keys.
+func MultisigScript(m int, pubKeys []string) (string, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > maxMultisigKeys {
+		return "", errors.New("multisig needs between 1 and " + strconv.Itoa(maxMultisigKeys) + " public keys")
+	}
+	if m < 1 || m > len(pubKeys) {
+		return "", errors.New("multisig threshold has to be between 1 and the number of keys")
+	}
+	for _, pub := range pubKeys {
+		if key, err := hex.DecodeString(pub); err != nil || len(key) != ed25519.PublicKeySize {
+			return "", errors.New("invalid public key " + strconv.Quote(pub))
+		}
+	}
+
+	words := append([]string{strconv.Itoa(m)}, pubKeys...)
+	words = append(words, strconv.Itoa(len(pubKeys)), "CHECKMULTISIG")
+	return strings.Join(words, " "), nil
+}
+
+// MultisigAddress returns the address of the m of n multisig script over the given public keys
+func MultisigAddress(m int, pubKeys []string) (string, error) {
+	script, err := MultisigScript(m, pubKeys)
+	if err != nil {
+		return "", err
+	}
+	return ScriptAddress(script), nil
+}
+
+// checkMultisig is the CHECKMULTISIG op: <sig>... <m> <pubkey>... <n> CHECKMULTISIG.
+// It pushes true if m of the signatures are valid, each from a different key, in the same order
+// as the keys are listed.
+func checkMultisig(vm *scriptVM) error {
+	n, err := vm.popInt()
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > maxMultisigKeys {
+		return errors.New("CHECKMULTISIG key count out of range")
+	}
+	pubKeys := make([]string, n)
+	for i := n - 1; i >= 0; i-- { // popped in reverse
+		if pubKeys[i], err = vm.pop(); err != nil {
+			return err
+		}
+	}
+
+	m, err := vm.popInt()
+	if err != nil {
+		return err
+	}
+	if m < 1 || m > n {
+		return errors.New("CHECKMULTISIG threshold out of range")
+	}
+	sigs := make([]string, m)
+	for i := m - 1; i >= 0; i-- {
+		if sigs[i], err = vm.pop(); err != nil {
+			return err
+		}
+	}
+
+	key := 0
+	for _, sig := range sigs { // each signature has to match a key after the last one matched
+		for key < n && !verifySig(pubKeys[key], sig, vm.tx) {
+			key++
+		}
+		if key == n {
+			return vm.pushBool(false)
+		}
+		key++
+	}
+	return vm.pushBool(true)
+}
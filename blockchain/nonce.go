@@ -0,0 +1,78 @@
+package blockchain
+
+import "sort"
+
+// nonceIndex maps every sender to the highest nonce they've had confirmed
+func nonceIndex(blocks []Block) map[string]int {
+	nonces := make(map[string]int)
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			if tx.From != "" {
+				nonces[tx.From] = tx.Nonce // nonces only go up, so the latest is the highest
+			}
+		}
+	}
+	return nonces
+}
+
+// ValidateNonces returns if every sender's nonces strictly increase through a chain,
+// so a captured transaction can't be replayed once its sender has moved on
+func ValidateNonces(blocks []Block) bool {
+	nonces := make(map[string]int)
+	for _, block := range blocks {
+		if !nonceOrdered(block, nonces) {
+			return false
+		}
+		for _, tx := range block.Transactions {
+			if tx.From != "" {
+				nonces[tx.From] = tx.Nonce
+			}
+		}
+	}
+	return true
+}
+
+// nonceOrdered returns if every transaction in a block has a higher nonce than its sender's last one,
+// counting earlier transactions in the same block
+func nonceOrdered(block Block, nonces map[string]int) bool {
+	last := make(map[string]int)
+	for _, tx := range block.Transactions {
+		if tx.From == "" {
+			continue
+		}
+		prev, ok := last[tx.From]
+		if !ok {
+			prev, ok = nonces[tx.From]
+		}
+		if ok && tx.Nonce <= prev {
+			return false
+		}
+		last[tx.From] = tx.Nonce
+	}
+	return true
+}
+
+// SortByNonce puts each sender's transactions in nonce order, keeping the slots each sender's
+// transactions were in so the rest of the ordering is left alone
+func SortByNonce(txs []Transaction) {
+	slots := make(map[string][]int)
+	for i, tx := range txs {
+		if tx.From != "" {
+			slots[tx.From] = append(slots[tx.From], i)
+		}
+	}
+
+	for _, idx := range slots {
+		if len(idx) < 2 {
+			continue
+		}
+		sender := make([]Transaction, len(idx))
+		for i, j := range idx {
+			sender[i] = txs[j]
+		}
+		sort.SliceStable(sender, func(a, b int) bool { return sender[a].Nonce < sender[b].Nonce })
+		for i, j := range idx {
+			txs[j] = sender[i]
+		}
+	}
+}
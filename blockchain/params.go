@@ -0,0 +1,78 @@
+package blockchain
+
+import "time"
+
+// Params ... the consensus rules every node on a network has to agree on
+type Params struct {
+	Engine  string // how blocks are agreed on, EnginePoW (the default, same as ""), EngineBFT or EnginePoA
+	ChainID string // the network's name, blocks and transactions for any other are refused, see chainid.go
+
+	BlockReward  int // coins the coinbase transaction of every block pays its miner, before any halvings
+	MaxBlockSize int // most bytes a block can take up in the canonical encoding, 0 for no limit
+	MaxBlockCost int // most a block's transactions can cost between them, see Transaction.Cost, 0 for no limit
+
+	Difficulty      int64         // proof of work for the first blocks, and every block without a TargetBlockTime, 1 turns it off
+	TargetBlockTime time.Duration // how often blocks should come, the difficulty is retargeted to keep to it, 0 keeps it fixed
+	RetargetWindow  int           // how many of the latest blocks the difficulty is worked out from
+	RetargetMaxRise int           // most the difficulty can go up by from one block to the next, as a percent
+	RetargetMaxFall int           // and most it can go down by
+
+	HalvingInterval int // the block reward halves every this many blocks, 0 keeps it the same forever
+
+	FinalityDepth int // blocks this far below the head are final and no reorg can replace them, 0 for no finality
+
+	Commission int // percent of a block's reward and fees its validator keeps, the rest is split between its delegators
+
+	SlashPercent   int // percent of a validator's stake slashing burns, 0 turns slashing off
+	DowntimeWindow int // how many of the latest blocks a validator's missed turns are counted over, 0 turns downtime slashing off
+	MaxMissed      int // how many turns in the window a validator can miss before it's slashed for downtime
+
+	Forks        []Fork       // the hard forks the network upgrades with, see forks.go
+	Deployments  []Deployment // the soft forks miners can signal for, see softforks.go
+	SignalWindow int          // how many blocks soft fork signals are counted over
+
+	Changes []ParamChange // what governance and active soft forks have changed, from the heights they apply, filled in by the chain, see At
+}
+
+// DefaultParams are the rules used unless a network overrides them
+var DefaultParams = Params{
+	ChainID: "mainnet",
+
+	BlockReward:  50,
+	MaxBlockSize: 1 << 20, // 1MB
+	MaxBlockCost: 4000000,
+
+	Difficulty:      1,
+	RetargetWindow:  30,
+	RetargetMaxRise: 25,
+	RetargetMaxFall: 20, // the same step back down, 1.25 * 0.8 = 1
+
+	Commission:     10,
+	SlashPercent:   5,
+	DowntimeWindow: 100,
+	MaxMissed:      50,
+
+	SignalWindow: 1000,
+}
+
+// TestnetParams are the rules of the test network, which developers can try things out on without touching
+// mainnet. Blocks are just as cheap to make, and windows are shorter so soft forks and slashing happen sooner.
+var TestnetParams = Params{
+	ChainID: "testnet",
+
+	BlockReward:  50,
+	MaxBlockSize: 1 << 20,
+	MaxBlockCost: 4000000,
+
+	Difficulty:      1,
+	RetargetWindow:  10,
+	RetargetMaxRise: 25,
+	RetargetMaxFall: 20,
+
+	Commission:     10,
+	SlashPercent:   5,
+	DowntimeWindow: 20,
+	MaxMissed:      10,
+
+	SignalWindow: 100,
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+)
+
+// On a PoA network (Params.Engine "poa") blocks aren't mined, they're sealed by a known set of authorities, the
+// chain's validators, each signing the blocks it makes. They take turns: the block at a height is the turn of
+// InTurn's authority, and so no one authority can run the chain, an authority can't seal a block if it sealed
+// any of the last len(authorities)/2 blocks. Authorities out of turn can still seal, which keeps the chain
+// going when the one in turn is down, node/poa.go has them wait longer before they do.
+
+// the reasons a seal doesn't hold up
+var (
+	ErrNotAuthority   = errors.New("block isn't sealed by an authority")
+	ErrBadSeal        = errors.New("block's seal doesn't check out")
+	ErrSealedRecently = errors.New("authority sealed one of the last few blocks, it has to let the others take a turn")
+)
+
+// SignSeal seals the header with an authority's key, signing its hash. The header's Sealer has to be the
+// authority before the hash is worked out, since the hash covers it.
+func (h *Header) SignSeal(key ed25519.PrivateKey) {
+	hash, _ := hex.DecodeString(h.Hash)
+	h.Seal = hex.EncodeToString(ed25519.Sign(key, hash))
+}
+
+// VerifySeal returns if the header was sealed by its sealer
+func (h Header) VerifySeal() bool {
+	pub, err := hex.DecodeString(h.Sealer)
+	if err != nil || len(pub) != ed25519.PublicKeySize || h.Version < SealHeaderVersion {
+		return false
+	}
+	hash, err := hex.DecodeString(h.Hash)
+	if err != nil {
+		return false
+	}
+	sig, err := hex.DecodeString(h.Seal)
+	return err == nil && ed25519.Verify(ed25519.PublicKey(pub), hash, sig)
+}
+
+// InTurn returns the authority whose turn it is to seal the block at a height
+func InTurn(authorities []string, height int) string {
+	return Proposer(authorities, height, 0)
+}
+
+// SealLimit returns how many of the blocks before one its sealer can't have sealed
+func SealLimit(authorities []string) int {
+	return len(authorities) / 2
+}
+
+// ValidateSeal returns an error unless a header is sealed by an authority that didn't seal any of the
+// SealLimit headers before it, recent being at least those, oldest first
+func ValidateSeal(recent []Header, header Header, authorities []string) error {
+	if !contains(authorities, header.Sealer) {
+		return ErrNotAuthority
+	}
+	if !header.VerifySeal() {
+		return ErrBadSeal
+	}
+	for i := len(recent) - 1; i >= 0 && i >= len(recent)-SealLimit(authorities); i-- {
+		if recent[i].Sealer == header.Sealer {
+			return ErrSealedRecently
+		}
+	}
+	return nil
+}
+
+// ValidateSeals returns if every block after the genesis block is sealed by an authority of the time taking its
+// turn, starting from the genesis set
+func ValidateSeals(blocks []Block, genesis []string) bool {
+	headers := make([]Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header
+	}
+	return eachValidatorSet(blocks, genesis, func(i int, authorities []string) bool {
+		return ValidateSeal(headers[:i], headers[i], authorities) == nil
+	})
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
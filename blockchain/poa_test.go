@@ -0,0 +1,50 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestValidateSeal(t *testing.T) {
+	keys := []ed25519.PrivateKey{testKey(1), testKey(2), testKey(3)}
+	authorities := []string{testAddress(keys[0]), testAddress(keys[1]), testAddress(keys[2])}
+	genesis := NewGenesisBlock()
+	genesis.Hash = GenerateHash(genesis)
+
+	// sealed returns a header at data sealed under sealer's name with key
+	sealed := func(data int, sealer string, key ed25519.PrivateKey) Header {
+		block, _ := GenerateBlock(genesis, data)
+		block.Sealer = sealer
+		block.Hash = GenerateHash(block)
+		block.SignSeal(key)
+		return block.Header
+	}
+	by := func(i int) Header { return sealed(i, authorities[i], keys[i]) }
+	tampered := by(0)
+	tampered.Hash = by(1).Hash
+	old := by(0)
+	old.Version = BloomHeaderVersion
+
+	tests := []struct {
+		name   string
+		recent []Header
+		header Header
+		want   error
+	}{
+		{"first block", nil, by(0), nil},
+		{"someone else sealed the last one", []Header{by(1)}, by(0), nil},
+		{"sealed two blocks back", []Header{by(0), by(1)}, by(0), nil},
+		{"sealed the last one", []Header{by(1), by(0)}, by(0), ErrSealedRecently},
+		{"not an authority", nil, sealed(0, testAddress(testKey(9)), testKey(9)), ErrNotAuthority},
+		{"signed with another authority's key", nil, sealed(0, authorities[0], keys[1]), ErrBadSeal},
+		{"hash changed after sealing", nil, tampered, ErrBadSeal},
+		{"header from before seals", nil, old, ErrBadSeal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSeal(tt.recent, tt.header, authorities); err != tt.want {
+				t.Errorf("ValidateSeal() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package blockchain
+
+// UndoDepth is how many of the latest blocks a chain keeps undo data for. Moving onto a branch that forks off
+// deeper than that rebuilds the chain's state from the genesis block instead of rolling it back.
+var UndoDepth = 1000
+
+// undo ... what adding a block changed in the chain's derived state, enough to take it off again without
+// its body, which may have been pruned since
+type undo struct {
+	balances   map[string]int // how much the block changed each address's coin balance
+	newcomers  []string       // addresses it was the first block to have
+	spent      []string       // spend keys it used up
+	nonces     []nonceChange  // senders' nonces before it, in the order it changed them
+	txs        []string       // hashes of its transactions, as indexed
+	addresses  []string       // the address of every entry it added to the index
+	tokens     []tokenChange
+	assets     []assetChange
+	validators *ValidatorSet // the set before it, if it had validator transactions
+	stakes     *StakeLedger  // the stakes before it, if it changed them
+	governance *Governance   // governance before it, if it had proposals or votes or ended a vote
+	softForks  *SoftForks    // the deployments before it, if the network has any
+	pruned     bool          // the block was pruned when it was added, so what it changed was never known
+	trie       StateTrie     // the accounts before it
+	partial    bool          // if the state was already missing what pruned blocks changed
+}
+
+// nonceChange ... a sender's highest nonce before a transaction raised it
+type nonceChange struct {
+	sender string
+	prev   int
+	had    bool // false if it was the sender's first transaction
+}
+
+// Reorg ... how a chain changed moving onto another branch
+type Reorg struct {
+	Fork    int     // height of the last block both branches have, -1 if they don't even share a genesis block
+	Removed []Block // the blocks taken off the old branch, its head first, bodies gone if they'd been pruned
+	Added   []Block // the blocks the new branch has after the fork
+}
+
+// forkPoint returns the height of the last block two chains both have, -1 if they don't share a genesis block
+func forkPoint(a, b []Block) int {
+	i := len(a) - 1
+	if len(b) < len(a) {
+		i = len(b) - 1
+	}
+	for ; i >= 0; i-- {
+		if a[i].Hash == b[i].Hash && (a[i].Hash != "" || a[i].Header == b[i].Header) { // genesis blocks can be unhashed
+			break
+		}
+	}
+	return i
+}
+
+// hasPruned returns if any of the blocks has been pruned
+func hasPruned(blocks []Block) bool {
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+	}
+	return false
+}
+
+// reset makes the chain blocks, building its state from scratch. The last UndoDepth blocks are pushed one at a
+// time so they can be taken off again. c.mu has to be held, or the chain not shared yet.
+func (c *Chain) reset(blocks []Block) {
+	base := len(blocks) - UndoDepth
+	if base < 0 {
+		base = 0
+	}
+	prefix := blocks[:base]
+
+	c.blocks = make([]Block, base, len(blocks)) // its own, pushing can't write into the caller's
+	copy(c.blocks, prefix)
+	c.spent = spentIndex(prefix)
+	c.nonces = nonceIndex(prefix)
+	c.balances = balanceIndex(prefix)
+	c.index = NewIndex(prefix)
+	c.tokens = tokenLedger(prefix)
+	c.assets = assetRegistry(prefix)
+	c.validators = validatorSet(c.genesisSet, prefix)
+	c.stakes = stakeLedger(c.genesisSet, prefix, c.params)
+	c.governance = governance(prefix)
+	c.softForks = softForks(prefix, c.params)
+	c.trie = accountTrie(c.balances, c.nonces)
+	c.partial = hasPruned(prefix)
+	c.undo = nil
+	c.base, c.latest = nil, nil
+	for _, block := range blocks[base:] {
+		c.push(block)
+	}
+}
+
+// push adds a block to the top of the chain and its state, keeping undo data for it. c.mu has to be held, and
+// the block has to have been checked, transactions that don't apply to tokens or assets are skipped.
+func (c *Chain) push(block Block) {
+	u := undo{balances: make(map[string]int), pruned: block.Pruned, trie: c.trie, partial: c.partial}
+	c.trie = c.trie.afterBlock(block, c.balances, c.nonces) // before they change
+	c.partial = c.partial || block.Pruned
+	applyBalances(u.balances, block) // what it changes them by, starting from nothing
+	for _, tx := range block.Transactions {
+		if key := tx.SpendKey(); key != "" {
+			prev, had := c.nonces[tx.From]
+			u.nonces = append(u.nonces, nonceChange{tx.From, prev, had})
+			u.spent = append(u.spent, key)
+			c.spent[key] = tx.Hash()
+			c.nonces[tx.From] = tx.Nonce
+		}
+		if change, ok := c.tokens.apply(tx); ok {
+			u.tokens = append(u.tokens, change)
+		}
+		if change, ok := c.assets.apply(tx); ok {
+			u.assets = append(u.assets, change)
+		}
+	}
+	if touchesStakes(block, c.validators.active) { // with the validators as of its parent
+		u.stakes = c.stakes.Copy()
+		c.stakes.applyBlock(block, c.validators.active, c.params)
+	}
+	if hasValidatorTxs(block) {
+		u.validators = c.validators.Copy()
+		c.validators.applyBlock(block)
+	}
+	for address, delta := range u.balances {
+		if _, ok := c.balances[address]; !ok {
+			u.newcomers = append(u.newcomers, address)
+		}
+		c.balances[address] += delta
+	}
+	if c.governance.touchesGovernance(block) { // votes are counted with the balances as of the block
+		u.governance = c.governance.Copy()
+		c.governance.applyBlock(block, c.balances)
+	}
+	if len(c.softForks.forks) > 0 {
+		u.softForks = c.softForks.Copy()
+		c.softForks.apply(block.Header)
+	}
+	u.txs, u.addresses = c.index.apply(block)
+
+	c.blocks = append(c.blocks, block)
+	c.finalize()
+	c.undo = append(c.undo, u)
+	if len(c.undo) > UndoDepth {
+		c.undo[0] = undo{}
+		c.undo = c.undo[1:]
+	}
+	c.takeState(block)
+}
+
+// pop takes the head off the chain and rolls its state back with the head's undo data, returning it.
+// c.mu has to be held, and there has to be undo data for the head.
+func (c *Chain) pop() Block {
+	block, u := c.blocks[len(c.blocks)-1], c.undo[len(c.undo)-1]
+	c.blocks, c.undo = c.blocks[:len(c.blocks)-1], c.undo[:len(c.undo)-1]
+
+	for address, delta := range u.balances {
+		c.balances[address] -= delta
+	}
+	for _, address := range u.newcomers {
+		delete(c.balances, address)
+	}
+	for _, key := range u.spent {
+		delete(c.spent, key)
+	}
+	for i := len(u.nonces) - 1; i >= 0; i-- {
+		if change := u.nonces[i]; change.had {
+			c.nonces[change.sender] = change.prev
+		} else {
+			delete(c.nonces, change.sender)
+		}
+	}
+	for i := len(u.tokens) - 1; i >= 0; i-- {
+		c.tokens.revert(u.tokens[i])
+	}
+	for i := len(u.assets) - 1; i >= 0; i-- {
+		c.assets.revert(u.assets[i])
+	}
+	if u.validators != nil {
+		c.validators = u.validators
+	}
+	if u.stakes != nil {
+		c.stakes = u.stakes
+	}
+	if u.governance != nil {
+		c.governance = u.governance
+	}
+	if u.softForks != nil {
+		c.softForks = u.softForks
+	}
+	c.trie, c.partial = u.trie, u.partial
+	c.index.revert(block, u.txs, u.addresses)
+	if c.latest != nil && c.latest.Height >= block.Index {
+		c.latest = nil
+	}
+	return block
+}
+
+// canRollBack returns if the chain has undo data for every block above height, from when their bodies were there
+func (c *Chain) canRollBack(height int) bool {
+	depth := len(c.blocks) - 1 - height
+	if height < 0 || depth > len(c.undo) {
+		return false
+	}
+	for _, u := range c.undo[len(c.undo)-depth:] {
+		if u.pruned {
+			return false
+		}
+	}
+	return true
+}
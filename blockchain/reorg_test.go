@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"reflect"
+	"testing"
+)
+
+// extend adds count blocks to c, each with alice paying to one coin
+func extend(t *testing.T, c *Chain, alice ed25519.PrivateKey, to string, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		nonce, _ := c.Nonce(testAddress(alice))
+		if !c.AddBlock(nextBlock(c, testAddress(alice), signed(alice, transfer(c, to, 1, nonce+1)))) {
+			t.Fatalf("chain won't take block %d", c.Last().Index+1)
+		}
+	}
+}
+
+func TestReplaceChainState(t *testing.T) {
+	alice, bob, carol := testKey(1), testAddress(testKey(2)), testAddress(testKey(3))
+
+	tests := []struct {
+		name      string
+		main      int // blocks on the old branch after the fork
+		branch    int // blocks on the new one
+		undoDepth int
+	}{
+		{"one block swapped for two", 1, 2, 1000},
+		{"several blocks rolled back", 3, 4, 1000},
+		{"nothing to roll back", 0, 2, 1000},
+		{"deeper than the undo data", 3, 4, 2},
+		{"no undo data", 1, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(depth int) { UndoDepth = depth }(UndoDepth)
+			UndoDepth = tt.undoDepth
+
+			c := testChain(t, alice)
+			extend(t, c, alice, bob, 2)
+			fork := NewChain(c.Blocks()...)
+			extend(t, c, alice, bob, tt.main)
+			extend(t, fork, alice, carol, tt.branch)
+
+			reorg, ok := c.ReplaceChain(fork.Blocks())
+			if !ok {
+				t.Fatal("ReplaceChain() refused a branch with more work")
+			}
+			if reorg.Fork != 3 || len(reorg.Removed) != tt.main || len(reorg.Added) != tt.branch {
+				t.Errorf("reorg forked at %d, removed %d and added %d blocks, want 3, %d and %d", reorg.Fork, len(reorg.Removed), len(reorg.Added), tt.main, tt.branch)
+			}
+
+			want := NewChain(fork.Blocks()...) // the state built from scratch
+			if !reflect.DeepEqual(c.balances, want.balances) {
+				t.Errorf("balances = %v, want %v", c.balances, want.balances)
+			}
+			if !reflect.DeepEqual(c.nonces, want.nonces) {
+				t.Errorf("nonces = %v, want %v", c.nonces, want.nonces)
+			}
+			if !reflect.DeepEqual(c.spent, want.spent) {
+				t.Errorf("spent = %v, want %v", c.spent, want.spent)
+			}
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Scripts are a tiny stack language deciding whether a transaction is allowed to spend from an address.
+// A script is a list of space separated words: upper case words (eg CHECKSIG) are ops, anything else
+// is pushed onto the stack as-is, eg "2 3 ADD 5 EQUAL". The transaction's Witness is pushed first (never
+// run), then the script runs and the transaction is only valid if it leaves a true value on top of the stack.
+// Every value is a string, numbers are decimal, keys and signatures are hex, and "" and "0" are false.
+// There are no loops and every script is capped in length and stack depth, so scripts always finish
+// and evaluate the same on every node.
+const (
+	maxScriptWords = 1000 // most words a script can have
+	maxStackDepth  = 100  // most values the stack can hold
+)
+
+// the errors a script can fail with
+var (
+	ErrScriptFailed   = errors.New("script didn't evaluate to true")
+	ErrScriptTooLong  = errors.New("script is too long")
+	ErrStackOverflow  = errors.New("script stack overflow")
+	ErrStackUnderflow = errors.New("script popped an empty stack")
+	ErrUnbalancedIf   = errors.New("script has an unbalanced IF")
+	ErrScriptAddress  = errors.New("script doesn't hash to the sender's address")
+	ErrUnsigned       = errors.New("transaction isn't signed by its sender's key")
+	ErrScriptRequired = errors.New("transaction spends from a script address without its script")
+)
+
+// ScriptAddress returns the address funds locked by a script live at, the hex SHA256 of the script
+func ScriptAddress(script string) string {
+	hashed := sha256.Sum256([]byte(strings.Join(strings.Fields(script), " "))) // whitespace doesn't change the address
+	return hex.EncodeToString(hashed[:])
+}
+
+// SigHash returns what signatures over a transaction sign: the hash of everything but the witness
+func (tx Transaction) SigHash() []byte {
+	tx.Witness = nil
+	record, _ := json.Marshal(tx)
+	hashed := sha256.Sum256(record)
+	return hashed[:]
+}
+
+// Sign returns the hex signature of the transaction by key, ready to go in its witness
+func (tx Transaction) Sign(key ed25519.PrivateKey) string {
+	return hex.EncodeToString(ed25519.Sign(key, tx.SigHash()))
+}
+
+// ValidateScript returns an error unless the transaction is allowed to spend from its sender's address. With a
+// script, that's the script the address commits to evaluating to true. Without one, From has to be a hex public
+// key and the Witness its signature over the transaction, so nothing leaves a script address, a hash no one has
+// the key to, without its script. Rewards and slashes don't spend from anyone, the checks on the block making
+// them cover them.
+func ValidateScript(tx Transaction) error {
+	if tx.BlockMade() {
+		return nil
+	}
+	if tx.Script == "" {
+		if _, version, err := DecodeAddress(tx.From); err == nil && version == AddressVersionScript {
+			return ErrScriptRequired
+		}
+		if len(tx.Witness) != 1 || !verifySig(tx.From, tx.Witness[0], tx) {
+			return ErrUnsigned
+		}
+		return nil
+	}
+	if ScriptAddress(tx.Script) != tx.From {
+		return ErrScriptAddress
+	}
+	return RunScript(tx.Script, tx.Witness, tx)
+}
+
+// RunScript evaluates a script on top of the witness, checking signatures against tx
+func RunScript(script string, witness []string, tx Transaction) error {
+	words := strings.Fields(script)
+	if len(words) > maxScriptWords {
+		return ErrScriptTooLong
+	}
+
+	vm := &scriptVM{tx: tx}
+	for _, item := range witness {
+		if err := vm.push(item); err != nil {
+			return err
+		}
+	}
+
+	for _, word := range words {
+		if err := vm.step(word); err != nil {
+			return err
+		}
+	}
+	if len(vm.branches) > 0 {
+		return ErrUnbalancedIf
+	}
+
+	top, err := vm.pop()
+	if err != nil || !truthy(top) {
+		return ErrScriptFailed
+	}
+	return nil
+}
+
+// scriptVM is the state of a running script
+type scriptVM struct {
+	tx       Transaction
+	stack    []string
+	branches []bool // for each IF we're inside, whether its branch is running
+}
+
+// running returns if the current branch is being executed, rather than skipped
+func (vm *scriptVM) running() bool {
+	for _, taken := range vm.branches {
+		if !taken {
+			return false
+		}
+	}
+	return true
+}
+
+func (vm *scriptVM) push(value string) error {
+	if len(vm.stack) >= maxStackDepth {
+		return ErrStackOverflow
+	}
+	vm.stack = append(vm.stack, value)
+	return nil
+}
+
+func (vm *scriptVM) pop() (string, error) {
+	if len(vm.stack) == 0 {
+		return "", ErrStackUnderflow
+	}
+	top := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return top, nil
+}
+
+// popInt pops a value and reads it as a number
+func (vm *scriptVM) popInt() (int, error) {
+	value, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("script expected a number, got " + strconv.Quote(value))
+	}
+	return n, nil
+}
+
+// pushBool pushes "1" for true and "0" for false
+func (vm *scriptVM) pushBool(b bool) error {
+	if b {
+		return vm.push("1")
+	}
+	return vm.push("0")
+}
+
+// step runs a single word of a script
+func (vm *scriptVM) step(word string) error {
+	switch word { // flow control runs even inside skipped branches, so they can be matched up
+	case "IF":
+		taken := false
+		if vm.running() {
+			cond, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			taken = truthy(cond)
+		}
+		vm.branches = append(vm.branches, taken)
+		return nil
+	case "ELSE":
+		if len(vm.branches) == 0 {
+			return ErrUnbalancedIf
+		}
+		vm.branches[len(vm.branches)-1] = !vm.branches[len(vm.branches)-1]
+		return nil
+	case "ENDIF":
+		if len(vm.branches) == 0 {
+			return ErrUnbalancedIf
+		}
+		vm.branches = vm.branches[:len(vm.branches)-1]
+		return nil
+	}
+
+	if !vm.running() {
+		return nil
+	}
+
+	if !isOp(word) { // data
+		return vm.push(word)
+	}
+
+	op, ok := scriptOps[word]
+	if !ok {
+		return errors.New("unknown script op " + word)
+	}
+	return op(vm)
+}
+
+// isOp returns if a word is an op rather than data, ops start with an upper case letter
+// and are made of upper case letters and digits
+func isOp(word string) bool {
+	for i, r := range word {
+		if (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// truthy returns if a stack value counts as true
+func truthy(value string) bool {
+	return value != "" && value != "0"
+}
+
+// scriptOps are the ops scripts can use, besides IF, ELSE and ENDIF
+var scriptOps map[string]func(*scriptVM) error
+
+func init() { // assigned here since CHECKSIGVERIFY refers back to the table
+	scriptOps = map[string]func(*scriptVM) error{
+		"DUP": func(vm *scriptVM) error {
+			top, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			vm.push(top)
+			return vm.push(top)
+		},
+		"DROP": func(vm *scriptVM) error {
+			_, err := vm.pop()
+			return err
+		},
+		"SWAP": func(vm *scriptVM) error {
+			a, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			b, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			vm.push(a)
+			return vm.push(b)
+		},
+		"EQUAL": func(vm *scriptVM) error {
+			a, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			b, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			return vm.pushBool(a == b)
+		},
+		"VERIFY": func(vm *scriptVM) error {
+			top, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			if !truthy(top) {
+				return ErrScriptFailed
+			}
+			return nil
+		},
+		"RETURN": func(vm *scriptVM) error { // always fails, marks a script as unspendable
+			return ErrScriptFailed
+		},
+		"NOT": func(vm *scriptVM) error {
+			top, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			return vm.pushBool(!truthy(top))
+		},
+		"ADD":         arithmetic(func(a, b int) int { return a + b }),
+		"SUB":         arithmetic(func(a, b int) int { return a - b }),
+		"LESSTHAN":    comparison(func(a, b int) bool { return a < b }),
+		"GREATERTHAN": comparison(func(a, b int) bool { return a > b }),
+		"SHA256": func(vm *scriptVM) error {
+			top, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			hashed := sha256.Sum256([]byte(top))
+			return vm.push(hex.EncodeToString(hashed[:]))
+		},
+		"CHECKSIG": func(vm *scriptVM) error { // <sig> <pubkey> CHECKSIG, both hex
+			pub, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			sig, err := vm.pop()
+			if err != nil {
+				return err
+			}
+			return vm.pushBool(verifySig(pub, sig, vm.tx))
+		},
+		"CHECKMULTISIG": checkMultisig,
+	}
+	scriptOps["EQUALVERIFY"] = verifying(scriptOps["EQUAL"])
+	scriptOps["CHECKSIGVERIFY"] = verifying(scriptOps["CHECKSIG"])
+	scriptOps["CHECKMULTISIGVERIFY"] = verifying(scriptOps["CHECKMULTISIG"])
+}
+
+// arithmetic returns an op that pops a then b and pushes f(b, a), so "5 3 SUB" is 2
+func arithmetic(f func(a, b int) int) func(*scriptVM) error {
+	return func(vm *scriptVM) error {
+		a, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		b, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		return vm.push(strconv.Itoa(f(b, a)))
+	}
+}
+
+// comparison returns an op that pops a then b and pushes whether f(b, a), so "2 3 LESSTHAN" is true
+func comparison(f func(a, b int) bool) func(*scriptVM) error {
+	return func(vm *scriptVM) error {
+		a, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		b, err := vm.popInt()
+		if err != nil {
+			return err
+		}
+		return vm.pushBool(f(b, a))
+	}
+}
+
+// verifying returns op followed by VERIFY
+func verifying(op func(*scriptVM) error) func(*scriptVM) error {
+	return func(vm *scriptVM) error {
+		if err := op(vm); err != nil {
+			return err
+		}
+		return scriptOps["VERIFY"](vm)
+	}
+}
+
+// verifySig returns if sig is pub's signature over the transaction
+func verifySig(pub, sig string, tx Transaction) bool {
+	pubKey, err := hex.DecodeString(pub)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return false
+	}
+	signature, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pubKey, tx.SigHash(), signature)
+}
@@ -0,0 +1,144 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"testing"
+)
+
+// testKey returns the key made from a seed of n's, so tests get the same keys every run
+func testKey(n byte) ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(bytes.Repeat([]byte{n}, ed25519.SeedSize))
+}
+
+// testAddress returns the address of a test key, the hex of its public key
+func testAddress(key ed25519.PrivateKey) string {
+	return hex.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// signed returns tx from key's address, signed by key
+func signed(key ed25519.PrivateKey, tx Transaction) Transaction {
+	tx.From = testAddress(key)
+	tx.Witness = []string{tx.Sign(key)}
+	return tx
+}
+
+func TestValidateScriptSignatures(t *testing.T) {
+	alice, bob := testKey(1), testKey(2)
+	transfer := Transaction{Class: ClassUser, To: testAddress(bob), Amount: 5, Nonce: 1, ChainID: "testnet"}
+
+	tampered := signed(alice, transfer)
+	tampered.Amount = 500
+	wrongKey := signed(alice, transfer)
+	wrongKey.Witness = []string{transfer.Sign(bob)}
+	extra := signed(alice, transfer)
+	extra.Witness = append(extra.Witness, extra.Witness[0])
+	named := transfer
+	named.From = "alice"
+	named.Witness = []string{named.Sign(alice)}
+
+	tests := []struct {
+		name string
+		tx   Transaction
+		want error
+	}{
+		{"signed by the sender", signed(alice, transfer), nil},
+		{"no witness", Transaction{Class: ClassUser, From: testAddress(alice), To: testAddress(bob), Amount: 5, Nonce: 1}, ErrUnsigned},
+		{"changed after signing", tampered, ErrUnsigned},
+		{"signed by someone else", wrongKey, ErrUnsigned},
+		{"more than the signature", extra, ErrUnsigned},
+		{"sender isn't a key", named, ErrUnsigned},
+		{"no sender", Transaction{Class: ClassUser, To: testAddress(bob), Amount: 5}, ErrUnsigned},
+		{"signed governance vote", signed(alice, Transaction{Class: ClassVote, To: "proposal", Payload: VoteYes, Nonce: 2}), nil},
+		{"unsigned vote", Transaction{Class: ClassVote, From: testAddress(alice), To: "proposal", Payload: VoteYes, Nonce: 2}, ErrUnsigned},
+		{"coinbase", NewCoinbase(testAddress(alice), 1, 50), nil},
+		{"stake reward", NewStakeReward(testAddress(alice), 1, 5), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateScript(tt.tx); err != tt.want {
+				t.Errorf("ValidateScript() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateScriptAddresses(t *testing.T) {
+	owner := testKey(1)
+	script := testAddress(owner) + " CHECKSIG" // a wallet key's
+	address := ScriptAddress(script)
+	encoded, _ := EncodeAddress(AddressVersionScript, address)
+	spend := Transaction{Class: ClassUser, From: address, To: testAddress(testKey(2)), Amount: 5, Nonce: 1}
+
+	withScript := func(tx Transaction, script string, witness ...string) Transaction {
+		tx.Script, tx.Witness = script, witness
+		return tx
+	}
+	scripted := withScript(spend, script)
+	signedSpend := withScript(spend, script, scripted.Sign(owner))
+	otherScript := testAddress(testKey(3)) + " CHECKSIG"
+	encodedSpend := spend
+	encodedSpend.From = encoded
+
+	tests := []struct {
+		name string
+		tx   Transaction
+		want error
+	}{
+		{"script and its signature", signedSpend, nil},
+		{"no script or witness", spend, ErrUnsigned},
+		{"no script, encoded address", encodedSpend, ErrScriptRequired},
+		{"no script, the owner's signature", withScript(spend, "", signedSpend.Witness...), ErrUnsigned},
+		{"script without a witness", scripted, ErrStackUnderflow},
+		{"someone else's script", withScript(spend, otherScript, withScript(spend, otherScript).Sign(testKey(3))), ErrScriptAddress},
+		{"script that's always true", withScript(spend, "1"), ErrScriptAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateScript(tt.tx); err != tt.want {
+				t.Errorf("ValidateScript() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMultisig(t *testing.T) {
+	keys := []ed25519.PrivateKey{testKey(1), testKey(2), testKey(3)}
+	pubs := []string{testAddress(keys[0]), testAddress(keys[1]), testAddress(keys[2])}
+	script, err := MultisigScript(2, pubs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend := Transaction{Class: ClassUser, From: ScriptAddress(script), To: testAddress(testKey(4)), Amount: 5, Nonce: 1, Script: script}
+	sig := func(i int) string { return spend.Sign(keys[i]) }
+	other := spend
+	other.Amount = 500
+
+	tests := []struct {
+		name    string
+		script  string
+		witness []string
+		want    error
+	}{
+		{"first two keys", script, []string{sig(0), sig(1)}, nil},
+		{"first and last keys", script, []string{sig(0), sig(2)}, nil},
+		{"last two keys", script, []string{sig(1), sig(2)}, nil},
+		{"out of key order", script, []string{sig(1), sig(0)}, ErrScriptFailed},
+		{"one signature short", script, []string{sig(0)}, ErrStackUnderflow},
+		{"one signature and padding", script, []string{"00", sig(0)}, ErrScriptFailed},
+		{"the same signature twice", script, []string{sig(0), sig(0)}, ErrScriptFailed},
+		{"a signature over another transaction", script, []string{sig(0), other.Sign(keys[1])}, ErrScriptFailed},
+		{"empty witness", script, nil, ErrStackUnderflow},
+		{"empty script and witness", "", nil, ErrUnsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := spend
+			tx.Script, tx.Witness = tt.script, tt.witness
+			if err := ValidateScript(tx); err != tt.want {
+				t.Errorf("ValidateScript() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
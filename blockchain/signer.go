@@ -0,0 +1,45 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+)
+
+// Signer ... something that signs with an ed25519 key without handing the key over, so a validator's key can
+// live in a keystore, a hardware wallet or another process rather than in the node's memory. The signer
+// package has the implementations.
+type Signer interface {
+	PublicKey() ed25519.PublicKey
+	Sign(message []byte) ([]byte, error)
+}
+
+// SignWith returns the hex signature of the transaction by a signer, ready to go in its witness
+func (tx Transaction) SignWith(s Signer) (string, error) {
+	sig, err := s.Sign(tx.SigHash())
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// SignWith fills in the vote's validator and signature with a signer
+func (v Vote) SignWith(s Signer) (Vote, error) {
+	v.Validator = hex.EncodeToString(s.PublicKey())
+	sig, err := s.Sign(v.SignBytes())
+	if err != nil {
+		return v, err
+	}
+	v.Signature = hex.EncodeToString(sig)
+	return v, nil
+}
+
+// SealWith seals the header with a signer, like SignSeal
+func (h *Header) SealWith(s Signer) error {
+	hash, _ := hex.DecodeString(h.Hash)
+	sig, err := s.Sign(hash)
+	if err != nil {
+		return err
+	}
+	h.Seal = hex.EncodeToString(sig)
+	return nil
+}
@@ -0,0 +1,248 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Validators with stake behind them can be slashed, burning Params.SlashPercent of everything staked with them,
+// taken from each delegator in proportion. A ClassSlash transaction does it, made by the maker of a block like
+// the coinbase, with the validator in To, the coins burned in Amount and why in Payload, the JSON of a
+// SlashEvidence. Burned coins are gone for good, they don't go back to anyone. There are two reasons:
+//
+//   - double signing: sealing two different blocks at the same height on PoA, or signing two different votes
+//     in the same round on BFT. The evidence carries both, so anyone can check the signatures, and a validator
+//     is only slashed once for a height.
+//   - downtime: missing at least Params.MaxMissed of its turns in the last Params.DowntimeWindow blocks. On PoA
+//     the authority in turn misses it when another one seals the block, and on BFT the round 0 proposer misses
+//     it when the block is agreed in a later round. Every node tracks the misses from the chain, so the
+//     evidence is just the height they were counted up to, and they start again from nothing once it's slashed.
+
+// the reasons a validator is slashed
+const (
+	SlashDoubleSign = "double_sign"
+	SlashDowntime   = "downtime"
+)
+
+// the reasons a slash doesn't apply
+var (
+	ErrSlashingOff    = errors.New("slashing is turned off on this network")
+	ErrBadEvidence    = errors.New("slashing evidence doesn't show the validator double signing")
+	ErrAlreadySlashed = errors.New("validator was already slashed for double signing at that height")
+	ErrNotDown        = errors.New("validator hasn't missed enough turns to be slashed for downtime")
+	ErrSlashAmount    = errors.New("slash has to burn exactly the network's share of the validator's stake")
+)
+
+// SlashEvidence ... why a validator is being slashed, the Payload of a slash transaction
+type SlashEvidence struct {
+	Reason  string   // SlashDoubleSign or SlashDowntime
+	Height  int      // where it double signed, or the height its missed turns were counted up to
+	Headers []Header `json:",omitempty"` // double signing on PoA: two different headers it sealed at Height
+	Votes   []Vote   `json:",omitempty"` // double signing on BFT: two different votes it signed at Height in one round
+}
+
+// NewSlash returns the transaction slashing a validator, burning the given amount of its stake
+func NewSlash(validator string, evidence SlashEvidence, burned int) Transaction {
+	payload, _ := json.Marshal(evidence)
+	return Transaction{
+		Class:     ClassSlash,
+		To:        validator,
+		Amount:    burned,
+		Payload:   string(payload),
+		Timestamp: time.Now().UnixNano(),
+	}
+}
+
+// DoubleSeal returns the evidence of two headers showing their sealer sealed two different blocks at one
+// height, false if they don't
+func DoubleSeal(a, b Header) (SlashEvidence, bool) {
+	evidence := SlashEvidence{Reason: SlashDoubleSign, Height: a.Index, Headers: []Header{a, b}}
+	return evidence, evidence.verify(a.Sealer) == nil
+}
+
+// DoubleVote returns the evidence of two votes showing their validator signed two different ones in one
+// round, false if they don't
+func DoubleVote(a, b Vote) (SlashEvidence, bool) {
+	evidence := SlashEvidence{Reason: SlashDoubleSign, Height: a.Height, Votes: []Vote{a, b}}
+	return evidence, evidence.verify(a.Validator) == nil
+}
+
+// Offender returns the validator double signing evidence is against, "" for downtime
+func (e SlashEvidence) Offender() string {
+	switch {
+	case len(e.Headers) > 0:
+		return e.Headers[0].Sealer
+	case len(e.Votes) > 0:
+		return e.Votes[0].Validator
+	}
+	return ""
+}
+
+// verify returns an error unless the evidence shows the validator double signing
+func (e SlashEvidence) verify(validator string) error {
+	if e.Reason != SlashDoubleSign || validator == "" {
+		return ErrBadEvidence
+	}
+	switch {
+	case len(e.Headers) == 2:
+		a, b := e.Headers[0], e.Headers[1]
+		if a.Index != e.Height || b.Index != e.Height || a.Hash == b.Hash || a.Sealer != validator || b.Sealer != validator {
+			return ErrBadEvidence
+		}
+		if GenerateHeaderHash(a) != a.Hash || GenerateHeaderHash(b) != b.Hash || !a.VerifySeal() || !b.VerifySeal() { // really its blocks
+			return ErrBadEvidence
+		}
+	case len(e.Votes) == 2:
+		a, b := e.Votes[0], e.Votes[1]
+		if a.Height != e.Height || b.Height != e.Height || a.Type != b.Type || a.Round != b.Round || a.Hash == b.Hash {
+			return ErrBadEvidence
+		}
+		if a.Validator != validator || b.Validator != validator || !a.Verify() || !b.Verify() {
+			return ErrBadEvidence
+		}
+	default:
+		return ErrBadEvidence
+	}
+	return nil
+}
+
+// slash applies a slash transaction, checking it all before changing anything
+func (l *StakeLedger) slash(tx Transaction, params Params) error {
+	if params.SlashPercent <= 0 {
+		return ErrSlashingOff
+	}
+	if tx.From != "" || tx.Fee != 0 {
+		return errors.New("slash can't have a sender or pay a fee")
+	}
+	var evidence SlashEvidence
+	if err := json.Unmarshal([]byte(tx.Payload), &evidence); err != nil {
+		return ErrBadEvidence
+	}
+
+	key := tx.To + "/" + strconv.Itoa(evidence.Height)
+	switch evidence.Reason {
+	case SlashDoubleSign:
+		if err := evidence.verify(tx.To); err != nil {
+			return err
+		}
+		if l.slashed[key] {
+			return ErrAlreadySlashed
+		}
+	case SlashDowntime:
+		if !l.down(tx.To, evidence.Height, params) {
+			return ErrNotDown
+		}
+	default:
+		return ErrBadEvidence
+	}
+	if tx.Amount != l.Burned(tx.To, params) {
+		return ErrSlashAmount
+	}
+
+	if evidence.Reason == SlashDoubleSign {
+		l.slashed[key] = true
+	} else {
+		delete(l.missed, tx.To)
+	}
+	delegators := l.stakes[tx.To]
+	for delegator, amount := range delegators {
+		if delegators[delegator] -= burn(amount, params); delegators[delegator] == 0 {
+			delete(delegators, delegator)
+		}
+	}
+	if len(delegators) == 0 {
+		delete(l.stakes, tx.To)
+	}
+	return nil
+}
+
+// Burned returns how much of what's staked with a validator slashing it burns
+func (l *StakeLedger) Burned(validator string, params Params) int {
+	burned := 0
+	for _, amount := range l.stakes[validator] {
+		burned += burn(amount, params)
+	}
+	return burned
+}
+
+// burn returns how much of a stake slashing burns, rounded down
+func burn(amount int, params Params) int {
+	if params.SlashPercent >= 100 {
+		return amount
+	}
+	return amount * params.SlashPercent / 100
+}
+
+// Missed returns how many turns a validator missed in the DowntimeWindow blocks up to height
+func (l *StakeLedger) Missed(validator string, height int, params Params) int {
+	missed := 0
+	for _, h := range l.missed[validator] {
+		if h > height-params.DowntimeWindow && h <= height {
+			missed++
+		}
+	}
+	return missed
+}
+
+// down returns if a validator missed enough of its turns in the window up to height to be slashed
+func (l *StakeLedger) down(validator string, height int, params Params) bool {
+	return params.DowntimeWindow > 0 && params.MaxMissed > 0 && l.Missed(validator, height, params) >= params.MaxMissed
+}
+
+// Downtimes returns the validators that can be slashed for downtime as of height, sorted
+func (l *StakeLedger) Downtimes(height int, params Params) []string {
+	var down []string
+	for validator := range l.missed {
+		if l.down(validator, height, params) {
+			down = append(down, validator)
+		}
+	}
+	sort.Strings(down)
+	return down
+}
+
+// recordMiss notes the turn, if any, the block shows a validator missing, dropping misses that have left the window
+func (l *StakeLedger) recordMiss(block Block, validators []string, params Params) {
+	validator := missedTurn(block, validators)
+	if validator == "" || params.DowntimeWindow <= 0 {
+		return
+	}
+	var kept []int
+	for _, h := range l.missed[validator] {
+		if h > block.Index-params.DowntimeWindow {
+			kept = append(kept, h)
+		}
+	}
+	l.missed[validator] = append(kept, block.Index)
+}
+
+// downtimesCurrent returns if every downtime slash in a block counts missed turns up to the block before it,
+// so one can't be slashed for an old stretch of downtime it's since made up for
+func downtimesCurrent(block Block) bool {
+	for _, tx := range block.Transactions {
+		var evidence SlashEvidence
+		if tx.Class != ClassSlash || json.Unmarshal([]byte(tx.Payload), &evidence) != nil {
+			continue
+		}
+		if evidence.Reason == SlashDowntime && evidence.Height != block.Index-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// missedTurn returns the validator whose turn a block was that didn't make it, "" if it did or there are no turns
+func missedTurn(block Block, validators []string) string {
+	switch {
+	case block.Index == 0 || len(validators) == 0:
+		return ""
+	case block.Commit != nil && block.Commit.Round > 0: // BFT, the first proposer's block wasn't agreed on
+		return Proposer(validators, block.Index, 0)
+	case block.Commit == nil && block.Sealer != "" && block.Sealer != InTurn(validators, block.Index): // PoA
+		return InTurn(validators, block.Index)
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Soft forks tighten the rules in a way nodes that don't know about them still accept, so unlike hard forks
+// they don't need a height every node agrees on ahead of time, only most of the miners being ready. Each
+// Deployment gets a bit of the header's Signals, and miners set it in their blocks once their node has it.
+// Blocks are counted in windows of SignalWindow: a deployment is started from the first window at or after its
+// Start, locked in once Threshold percent of a started window's blocks signal it, then active from the window
+// after that. If it hasn't locked in by its Timeout it fails for good. Once active its Params apply to every
+// block, and they can only lower the block size and cost limits, so old nodes follow the chain it makes.
+
+// where a soft fork is at
+const (
+	SoftForkDefined  = "defined"   // waiting for its Start
+	SoftForkStarted  = "started"   // signals are being counted
+	SoftForkLockedIn = "locked_in" // enough signalled, it activates at the next window
+	SoftForkActive   = "active"
+	SoftForkFailed   = "failed" // it timed out before it locked in
+)
+
+// DefaultSignalThreshold is the percent of a window's blocks that have to signal a deployment without a Threshold
+const DefaultSignalThreshold = 95
+
+// Deployment ... a soft fork, and how miners signal they're ready for it
+type Deployment struct {
+	Name      string
+	Bit       int              // the bit of Signals blocks set for it, 0 to 31
+	Start     int              // the height signals start being counted from, rounded up to a window
+	Timeout   int              // the height it fails at if it hasn't locked in, 0 for never
+	Threshold int              // percent of a window's blocks that have to signal it, 0 for DefaultSignalThreshold
+	Params    map[string]int64 `json:",omitempty"` // the limits it lowers once active, max_block_size or max_block_cost
+}
+
+// SoftFork ... a deployment and where it's at
+type SoftFork struct {
+	Deployment
+	State   string
+	Since   int // the height it got to State at
+	Signals int // blocks in the current window that have signalled it so far, while it's started
+}
+
+// SoftForks ... where every deployment on a chain is at
+type SoftForks struct {
+	window int
+	forks  []SoftFork // in the order they're configured
+}
+
+// ValidateDeployments returns an error if a deployment has a bit another one uses or that doesn't fit in
+// Signals, a threshold that isn't a percent, a timeout before its start or a param it isn't allowed to lower
+func ValidateDeployments(deployments []Deployment, window int) error {
+	if len(deployments) > 0 && window <= 0 {
+		return fmt.Errorf("soft forks need a signal window")
+	}
+	bits := make(map[int]string)
+	for _, d := range deployments {
+		if d.Bit < 0 || d.Bit > 31 {
+			return fmt.Errorf("soft fork %s has bit %d, it has to be 0 to 31", d.Name, d.Bit)
+		}
+		if other, ok := bits[d.Bit]; ok {
+			return fmt.Errorf("soft forks %s and %s both use bit %d", other, d.Name, d.Bit)
+		}
+		bits[d.Bit] = d.Name
+		if d.Threshold < 0 || d.Threshold > 100 {
+			return fmt.Errorf("soft fork %s has a threshold of %d%%", d.Name, d.Threshold)
+		}
+		if d.Timeout != 0 && d.Timeout <= d.Start {
+			return fmt.Errorf("soft fork %s times out before it starts", d.Name)
+		}
+		for param, value := range d.Params {
+			if (param != ParamMaxBlockSize && param != ParamMaxBlockCost) || value <= 0 {
+				return fmt.Errorf("soft fork %s can't set %s to %d, only lower the block limits", d.Name, param, value)
+			}
+		}
+	}
+	return nil
+}
+
+// NewSoftForks returns the network's deployments before any blocks
+func NewSoftForks(params Params) *SoftForks {
+	s := &SoftForks{window: params.SignalWindow}
+	for _, d := range params.Deployments {
+		s.forks = append(s.forks, SoftFork{Deployment: d, State: SoftForkDefined})
+	}
+	return s
+}
+
+// softForks works out where the network's deployments are at after a chain's blocks. Only headers are
+// needed, so pruned blocks count too.
+func softForks(blocks []Block, params Params) *SoftForks {
+	s := NewSoftForks(params)
+	for _, block := range blocks {
+		s.apply(block.Header)
+	}
+	return s
+}
+
+// Copy returns a copy that can be changed without touching this one
+func (s *SoftForks) Copy() *SoftForks {
+	return &SoftForks{window: s.window, forks: s.List()}
+}
+
+// List returns every deployment and where it's at
+func (s *SoftForks) List() []SoftFork {
+	return append([]SoftFork(nil), s.forks...)
+}
+
+// Signals returns the bits a block on top of the head sets to signal every deployment that's being counted
+func (s *SoftForks) Signals() uint32 {
+	var signals uint32
+	for _, f := range s.forks {
+		if f.State == SoftForkStarted || f.State == SoftForkLockedIn { // keep signalling till it's active, like BIP 9
+			signals |= 1 << uint(f.Bit)
+		}
+	}
+	return signals
+}
+
+// Changes returns the limits active deployments lower, from the heights they activated at
+func (s *SoftForks) Changes() []ParamChange {
+	var changes []ParamChange
+	for _, f := range s.forks {
+		if f.State != SoftForkActive {
+			continue
+		}
+		params := make([]string, 0, len(f.Params))
+		for param := range f.Params {
+			params = append(params, param)
+		}
+		sort.Strings(params) // the same order on every node
+		for _, param := range params {
+			changes = append(changes, ParamChange{Param: param, Value: f.Params[param], Height: f.Since})
+		}
+	}
+	return changes
+}
+
+// apply counts a header's signals, moving every deployment on once it ends a window
+func (s *SoftForks) apply(header Header) {
+	for i := range s.forks {
+		if f := &s.forks[i]; f.State == SoftForkStarted && header.Signals&(1<<uint(f.Bit)) != 0 {
+			f.Signals++
+		}
+	}
+	next := header.Index + 1
+	if s.window <= 0 || next%s.window != 0 {
+		return
+	}
+	for i := range s.forks {
+		f := &s.forks[i]
+		state := f.State
+		timedOut := f.Timeout > 0 && next >= f.Timeout
+		switch {
+		case f.State == SoftForkDefined && timedOut:
+			state = SoftForkFailed
+		case f.State == SoftForkDefined && next >= f.Start:
+			state = SoftForkStarted
+		case f.State == SoftForkStarted && f.Signals*100 >= f.threshold()*s.window:
+			state = SoftForkLockedIn
+		case f.State == SoftForkStarted && timedOut:
+			state = SoftForkFailed
+		case f.State == SoftForkLockedIn:
+			state = SoftForkActive
+		}
+		if state != f.State {
+			f.State, f.Since = state, next
+		}
+		f.Signals = 0
+	}
+}
+
+func (d Deployment) threshold() int {
+	if d.Threshold == 0 {
+		return DefaultSignalThreshold
+	}
+	return d.Threshold
+}
+
+// mergeChanges returns two lists of param changes as one, by height, with b's winning at the same height
+func mergeChanges(a, b []ParamChange) []ParamChange {
+	changes := append(append([]ParamChange(nil), a...), b...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Height < changes[j].Height })
+	return changes
+}
+
+// SoftForks returns the network's deployments and where they're at as of the head
+func (c *Chain) SoftForks() *SoftForks {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.softForks.Copy()
+}
+
+// replaySoftForks works out the deployments again from the genesis block, for when the params change, along
+// with what rolling back each block with undo data puts back. c.mu has to be held.
+func (c *Chain) replaySoftForks() {
+	s := NewSoftForks(c.params)
+	base := len(c.blocks) - len(c.undo)
+	for i, block := range c.blocks {
+		if i >= base {
+			c.undo[i-base].softForks = nil
+			if len(s.forks) > 0 {
+				c.undo[i-base].softForks = s.Copy()
+			}
+		}
+		s.apply(block.Header)
+	}
+	c.softForks = s
+}
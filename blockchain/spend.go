@@ -0,0 +1,59 @@
+package blockchain
+
+import "strconv"
+
+// SpendKey identifies what a transaction spends: its sender's account at a nonce.
+// Two transactions with the same key are a double spend. Coinbases don't spend anything.
+func (tx Transaction) SpendKey() string {
+	if tx.From == "" {
+		return ""
+	}
+	return tx.From + "/" + strconv.Itoa(tx.Nonce)
+}
+
+// spentIndex maps the spend key of every confirmed transaction to that transaction's hash
+func spentIndex(blocks []Block) map[string]string {
+	spent := make(map[string]string)
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			if key := tx.SpendKey(); key != "" {
+				spent[key] = tx.Hash()
+			}
+		}
+	}
+	return spent
+}
+
+// ValidateNoDoubleSpends returns if no two transactions anywhere in a chain spend the same thing
+func ValidateNoDoubleSpends(blocks []Block) bool {
+	spent := make(map[string]bool)
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			key := tx.SpendKey()
+			if key == "" {
+				continue
+			}
+			if spent[key] {
+				return false
+			}
+			spent[key] = true
+		}
+	}
+	return true
+}
+
+// conflictsWith returns if a block spends something that's already spent, or spends the same thing twice
+func conflictsWith(block Block, spent map[string]string) bool {
+	inBlock := make(map[string]bool)
+	for _, tx := range block.Transactions {
+		key := tx.SpendKey()
+		if key == "" {
+			continue
+		}
+		if _, ok := spent[key]; ok || inBlock[key] {
+			return true
+		}
+		inBlock[key] = true
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// On BFT and PoA networks coin holders can back validators with stake. A ClassDelegate transaction stakes
+// Amount coins from From with the validator To, and a ClassUndelegate transaction takes Amount of it back.
+// Staked coins come out of the delegator's balance until they're taken back. The validator that made a block,
+// its Sealer, keeps Params.Commission percent of the block's reward and fees through the coinbase, and the
+// rest goes to its delegators in proportion to their stake, each paid by a ClassStakeReward transaction in the
+// same block. Shares are rounded down and whatever that leaves over goes to the validator too, as does the
+// lot if no one has staked with it.
+
+// the reasons a staking transaction doesn't apply
+var (
+	ErrDelegateValidator = errors.New("stake can only be delegated to a validator")
+	ErrInsufficientStake = errors.New("delegator hasn't staked that much with the validator")
+	ErrStakeRewards      = errors.New("block's stake rewards don't match its validator's delegations")
+)
+
+// Delegation ... coins a delegator has staked with a validator
+type Delegation struct {
+	Delegator string
+	Validator string
+	Amount    int
+}
+
+// StakeLedger ... every delegation on a chain, and what validators could be slashed for, see slashing.go
+type StakeLedger struct {
+	stakes  map[string]map[string]int // validator -> delegator -> coins staked
+	missed  map[string][]int          // validator -> heights of the turns it missed since it was last slashed for it
+	slashed map[string]bool           // validator/height of every double signing it's been slashed for
+}
+
+// NewStakeLedger returns a ledger with nothing staked
+func NewStakeLedger() *StakeLedger {
+	return &StakeLedger{stakes: make(map[string]map[string]int), missed: make(map[string][]int), slashed: make(map[string]bool)}
+}
+
+// stakeLedger builds the ledger for a chain starting from the genesis validators, skipping anything that
+// doesn't apply
+func stakeLedger(genesis []string, blocks []Block, params Params) *StakeLedger {
+	l := NewStakeLedger()
+	eachValidatorSet(blocks, genesis, func(i int, validators []string) bool {
+		l.applyBlock(blocks[i], validators, params)
+		return true
+	})
+	return l
+}
+
+// ValidateStakes returns if every staking transaction in a chain applies and every block pays its validator's
+// delegators their share. Stakes can't be worked out past a pruned block, so pruned chains aren't checked.
+func ValidateStakes(blocks []Block, genesis []string, params Params) bool {
+	l := NewStakeLedger()
+	pruned := false
+	return eachValidatorSet(blocks, genesis, func(i int, validators []string) bool {
+		if pruned = pruned || blocks[i].Pruned; pruned {
+			return true
+		}
+		return l.ApplyBlock(blocks[i], validators, params) == nil
+	})
+}
+
+// Copy returns a copy of the ledger that can be changed without touching this one
+func (l *StakeLedger) Copy() *StakeLedger {
+	c := NewStakeLedger()
+	for validator, delegators := range l.stakes {
+		c.stakes[validator] = make(map[string]int, len(delegators))
+		for delegator, amount := range delegators {
+			c.stakes[validator][delegator] = amount
+		}
+	}
+	for validator, heights := range l.missed {
+		c.missed[validator] = append([]int(nil), heights...)
+	}
+	for key := range l.slashed {
+		c.slashed[key] = true
+	}
+	return c
+}
+
+// Apply updates the ledger with a transaction in a block made with the given validators, returning an error
+// and leaving the ledger alone if it doesn't apply. Transactions that aren't staking or slashing transactions
+// are ignored.
+func (l *StakeLedger) Apply(tx Transaction, validators []string, params Params) error {
+	switch tx.Class {
+	case ClassSlash:
+		return l.slash(tx, params)
+
+	case ClassDelegate:
+		if tx.From == "" || tx.Amount <= 0 {
+			return errors.New("delegating needs a delegator and a positive amount")
+		}
+		if !contains(validators, tx.To) {
+			return ErrDelegateValidator
+		}
+		if l.stakes[tx.To] == nil {
+			l.stakes[tx.To] = make(map[string]int)
+		}
+		l.stakes[tx.To][tx.From] += tx.Amount
+
+	case ClassUndelegate: // works even once the validator's gone, so the stake isn't stuck
+		if tx.Amount <= 0 {
+			return errors.New("undelegating has to take back a positive amount")
+		}
+		delegators := l.stakes[tx.To]
+		if delegators[tx.From] < tx.Amount {
+			return ErrInsufficientStake
+		}
+		delegators[tx.From] -= tx.Amount
+		if delegators[tx.From] == 0 {
+			delete(delegators, tx.From)
+		}
+		if len(delegators) == 0 {
+			delete(l.stakes, tx.To)
+		}
+	}
+	return nil
+}
+
+// CheckBlock returns an error if a block made with the given validators doesn't pay its delegators their
+// share, or any of its staking transactions don't apply, without changing the ledger
+func (l *StakeLedger) CheckBlock(block Block, validators []string, params Params) error {
+	if block.Index > 0 && !block.Pruned && !l.paysRewards(block, params) {
+		return ErrStakeRewards
+	}
+	if !hasStakeTxs(block) {
+		return nil
+	}
+	if !downtimesCurrent(block) {
+		return ErrNotDown
+	}
+	next := l.Copy()
+	for _, tx := range block.Transactions {
+		if err := next.Apply(tx, validators, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyBlock checks a block like CheckBlock and applies its staking transactions, leaving the ledger alone if
+// it doesn't check out
+func (l *StakeLedger) ApplyBlock(block Block, validators []string, params Params) error {
+	if err := l.CheckBlock(block, validators, params); err != nil {
+		return err
+	}
+	l.applyBlock(block, validators, params)
+	return nil
+}
+
+// applyBlock applies the block's staking and slashing transactions that apply, skipping the rest, and notes
+// any turn it shows a validator missing
+func (l *StakeLedger) applyBlock(block Block, validators []string, params Params) {
+	for _, tx := range block.Transactions {
+		l.Apply(tx, validators, params)
+	}
+	l.recordMiss(block, validators, params)
+}
+
+// paysRewards returns if a block's stake rewards are exactly its validator's delegators' shares of everything
+// the block pays out
+func (l *StakeLedger) paysRewards(block Block, params Params) bool {
+	total := 0
+	paid := make(map[string]int)
+	for _, tx := range block.Transactions {
+		if !tx.IsReward() {
+			continue
+		}
+		total += tx.Amount
+		if tx.Class == ClassStakeReward {
+			if _, ok := paid[tx.To]; ok { // one each
+				return false
+			}
+			paid[tx.To] = tx.Amount
+		}
+	}
+
+	shares := l.Shares(block.Sealer, total, params)
+	if len(shares) != len(paid) {
+		return false
+	}
+	for delegator, share := range shares {
+		if paid[delegator] != share {
+			return false
+		}
+	}
+	return true
+}
+
+// Shares returns what a block made by a validator pays each of its delegators out of total, the block's reward
+// plus fees. Delegators whose share rounds down to nothing are left out.
+func (l *StakeLedger) Shares(validator string, total int, params Params) map[string]int {
+	delegators := l.stakes[validator]
+	staked := 0
+	for _, amount := range delegators {
+		staked += amount
+	}
+	if staked == 0 {
+		return nil
+	}
+
+	commission := params.Commission
+	if commission < 0 {
+		commission = 0
+	} else if commission > 100 {
+		commission = 100
+	}
+	pool := big.NewInt(int64(total * (100 - commission) / 100))
+	shares := make(map[string]int)
+	for delegator, amount := range delegators {
+		share := new(big.Int).Mul(pool, big.NewInt(int64(amount)))
+		if share.Div(share, big.NewInt(int64(staked))).Sign() > 0 { // worked out in big ints, stake times reward can overflow
+			shares[delegator] = int(share.Int64())
+		}
+	}
+	return shares
+}
+
+// Delegations returns what's staked with a validator, by delegator
+func (l *StakeLedger) Delegations(validator string) []Delegation {
+	delegations := make([]Delegation, 0, len(l.stakes[validator]))
+	for delegator, amount := range l.stakes[validator] {
+		delegations = append(delegations, Delegation{Delegator: delegator, Validator: validator, Amount: amount})
+	}
+	sort.Slice(delegations, func(i, j int) bool { return delegations[i].Delegator < delegations[j].Delegator })
+	return delegations
+}
+
+// DelegationsFrom returns what a delegator has staked, by validator
+func (l *StakeLedger) DelegationsFrom(delegator string) []Delegation {
+	var delegations []Delegation
+	for validator, delegators := range l.stakes {
+		if amount, ok := delegators[delegator]; ok {
+			delegations = append(delegations, Delegation{Delegator: delegator, Validator: validator, Amount: amount})
+		}
+	}
+	sort.Slice(delegations, func(i, j int) bool { return delegations[i].Validator < delegations[j].Validator })
+	return delegations
+}
+
+// Stakes returns the total staked with every validator that has any stake
+func (l *StakeLedger) Stakes() map[string]int {
+	stakes := make(map[string]int, len(l.stakes))
+	for validator, delegators := range l.stakes {
+		for _, amount := range delegators {
+			stakes[validator] += amount
+		}
+	}
+	return stakes
+}
+
+// StakeRewards returns the stake reward transactions a block made by a validator at height pays out of total,
+// the block's reward plus fees, in delegator order, along with what they add up to
+func (l *StakeLedger) StakeRewards(validator string, height, total int, params Params) ([]Transaction, int) {
+	shares := l.Shares(validator, total, params)
+	delegators := make([]string, 0, len(shares))
+	for delegator := range shares {
+		delegators = append(delegators, delegator)
+	}
+	sort.Strings(delegators)
+
+	var rewards []Transaction
+	paid := 0
+	for _, delegator := range delegators {
+		rewards = append(rewards, NewStakeReward(delegator, height, shares[delegator]))
+		paid += shares[delegator]
+	}
+	return rewards, paid
+}
+
+// hasStakeTxs returns if a block has any delegating, undelegating or slashing transactions
+func hasStakeTxs(block Block) bool {
+	for _, tx := range block.Transactions {
+		if tx.Class == ClassDelegate || tx.Class == ClassUndelegate || tx.Class == ClassSlash {
+			return true
+		}
+	}
+	return false
+}
+
+// touchesStakes returns if a block changes the stake ledger, with its transactions or a missed turn
+func touchesStakes(block Block, validators []string) bool {
+	return hasStakeTxs(block) || missedTurn(block, validators) != ""
+}
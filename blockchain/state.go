@@ -0,0 +1,272 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// A State is everything a chain works out from its blocks' bodies, as of one block: balances, nonces, spend
+// keys, tokens, assets, validators, stakes and governance. A chain can start from one instead of from the
+// genesis block, with just the headers before it, and carry on checking the blocks after it exactly as if it
+// had applied every one. Soft forks aren't in it, they're worked out from the headers, and neither is the
+// transaction index, so a chain that started from a state only knows about the transactions after it.
+//
+// Its digest is the SHA256 of its JSON, which has maps in key order and lists in a fixed order, so every node
+// gets the same digest for the state as of the same block, and a state can be checked against a digest it got
+// from somewhere it trusts.
+
+// State ... a chain's state as of a block
+type State struct {
+	Height           int
+	Hash             string // the block it's as of
+	Balances         map[string]int
+	Nonces           map[string]int
+	Spent            map[string]string         // spend key -> the hash of the transaction that used it
+	Tokens           []Token                   // in the order they were issued
+	TokenHolders     map[string]map[string]int // token ID -> address -> units held
+	Assets           []Asset                   // by ID
+	Validators       []string                  // in the order they take turns
+	ValidatorVotes   []ValidatorVotes
+	ValidatorHistory []ValidatorChange
+	Stakes           map[string]map[string]int // validator -> delegator -> coins staked
+	Missed           map[string][]int          // validator -> heights of the turns it's missed since it was last slashed for it
+	Slashed          []string                  // validator/height of every double signing slashed, sorted
+	Proposals        []ParamProposal           // by ID
+	ParamChanges     []ParamChange
+}
+
+// Digest returns the hex SHA256 of the state, what state checkpoints name
+func (s State) Digest() string {
+	encoded, _ := json.Marshal(s) // nothing in it that can't be encoded
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// state returns a copy of the chain's state as of the head, c.mu has to be held
+func (c *Chain) state() State {
+	head := c.blocks[len(c.blocks)-1]
+	s := State{
+		Height:           head.Index,
+		Hash:             head.Hash,
+		Balances:         make(map[string]int, len(c.balances)),
+		Nonces:           make(map[string]int, len(c.nonces)),
+		Spent:            make(map[string]string, len(c.spent)),
+		Tokens:           make([]Token, 0, len(c.tokens.order)),
+		TokenHolders:     make(map[string]map[string]int, len(c.tokens.balances)),
+		Assets:           make([]Asset, 0, len(c.assets.assets)),
+		Validators:       append([]string{}, c.validators.active...),
+		ValidatorVotes:   c.validators.Pending(),
+		ValidatorHistory: append([]ValidatorChange{}, c.validators.history...),
+		Stakes:           make(map[string]map[string]int, len(c.stakes.stakes)),
+		Missed:           make(map[string][]int, len(c.stakes.missed)),
+		Slashed:          make([]string, 0, len(c.stakes.slashed)),
+		Proposals:        c.governance.Proposals(),
+		ParamChanges:     append([]ParamChange{}, c.governance.changes...),
+	}
+	for address, balance := range c.balances {
+		s.Balances[address] = balance
+	}
+	for sender, nonce := range c.nonces {
+		s.Nonces[sender] = nonce
+	}
+	for key, hash := range c.spent {
+		s.Spent[key] = hash
+	}
+	tokens := c.tokens.Copy()
+	for _, id := range tokens.order {
+		s.Tokens = append(s.Tokens, tokens.tokens[id])
+	}
+	s.TokenHolders = tokens.balances
+	for _, asset := range c.assets.assets {
+		s.Assets = append(s.Assets, asset)
+	}
+	sort.Slice(s.Assets, func(i, j int) bool { return s.Assets[i].ID < s.Assets[j].ID })
+	stakes := c.stakes.Copy()
+	s.Stakes = stakes.stakes
+	for validator, heights := range stakes.missed {
+		if len(heights) > 0 { // none missed and never missed are the same thing
+			s.Missed[validator] = heights
+		}
+	}
+	s.Slashed = sortedKeys(stakes.slashed)
+	sort.Slice(s.Proposals, func(i, j int) bool { return s.Proposals[i].ID < s.Proposals[j].ID })
+	return s
+}
+
+// restore makes the chain blocks, with the state as of the block at the state's height instead of what the
+// bodies up to it would give, and applies the blocks after it. c.mu has to be held, or the chain not shared yet,
+// and the blocks checked with checkState.
+func (c *Chain) restore(blocks []Block, s State) {
+	prefix := blocks[:s.Height+1]
+	c.blocks = make([]Block, len(prefix), len(blocks))
+	copy(c.blocks, prefix)
+
+	c.balances = make(map[string]int, len(s.Balances))
+	for address, balance := range s.Balances {
+		c.balances[address] = balance
+	}
+	c.nonces = make(map[string]int, len(s.Nonces))
+	for sender, nonce := range s.Nonces {
+		c.nonces[sender] = nonce
+	}
+	c.spent = make(map[string]string, len(s.Spent))
+	for key, hash := range s.Spent {
+		c.spent[key] = hash
+	}
+	tokens := NewTokenLedger()
+	for _, token := range s.Tokens {
+		tokens.tokens[token.ID] = token
+		tokens.order = append(tokens.order, token.ID)
+	}
+	for id, holders := range s.TokenHolders {
+		tokens.balances[id] = make(map[string]int, len(holders))
+		for address, units := range holders {
+			tokens.balances[id][address] = units
+		}
+	}
+	c.tokens = tokens
+	c.assets = NewAssetRegistry()
+	for _, asset := range s.Assets {
+		c.assets.assets[asset.ID] = asset
+	}
+	c.validators = s.validatorSet()
+	c.stakes = s.stakeLedger()
+	c.governance = NewGovernance()
+	for i := range s.Proposals {
+		p := s.Proposals[i]
+		p.Votes = make(map[string]string, len(p.Votes))
+		for voter, vote := range s.Proposals[i].Votes {
+			p.Votes[voter] = vote
+		}
+		c.governance.proposals[p.ID] = &p
+	}
+	c.governance.changes = append([]ParamChange(nil), s.ParamChanges...)
+
+	c.trie = accountTrie(c.balances, c.nonces)
+	c.partial = false
+	c.index = NewIndex(prefix) // the headers, the bodies aren't there to index
+	c.undo = nil
+	c.base = &s
+	c.latest = nil
+	c.replaySoftForks()
+	c.finalize()
+	for _, block := range blocks[s.Height+1:] {
+		c.push(block)
+	}
+}
+
+// validatorSet returns the validator set as of the state
+func (s State) validatorSet() *ValidatorSet {
+	set := &ValidatorSet{active: append([]string(nil), s.Validators...), votes: make(map[validatorProposal]map[string]bool), history: append([]ValidatorChange(nil), s.ValidatorHistory...)}
+	for _, v := range s.ValidatorVotes {
+		voters := make(map[string]bool, len(v.Voters))
+		for _, voter := range v.Voters {
+			voters[voter] = true
+		}
+		set.votes[validatorProposal{v.Action, v.Validator}] = voters
+	}
+	return set
+}
+
+// stakeLedger returns the stake ledger as of the state
+func (s State) stakeLedger() *StakeLedger {
+	l := NewStakeLedger()
+	for validator, delegators := range s.Stakes {
+		l.stakes[validator] = make(map[string]int, len(delegators))
+		for delegator, amount := range delegators {
+			l.stakes[validator][delegator] = amount
+		}
+	}
+	for validator, heights := range s.Missed {
+		l.missed[validator] = append([]int(nil), heights...)
+	}
+	for _, key := range s.Slashed {
+		l.slashed[key] = true
+	}
+	return l
+}
+
+// checkState returns an error unless a state is as of one of the blocks, with the accounts its state root says
+// if it has one, and every block after it has its body
+func checkState(blocks []Block, s State) error {
+	if s.Height < 0 || s.Height >= len(blocks) || blocks[s.Height].Hash != s.Hash {
+		return fmt.Errorf("the state is as of block %d %s, which isn't in the chain", s.Height, s.Hash)
+	}
+	if header := blocks[s.Height].Header; header.Version >= StateHeaderVersion && accountTrie(s.Balances, s.Nonces).Root() != header.StateRoot {
+		return fmt.Errorf("the state's accounts don't match block %d's state root", s.Height)
+	}
+	for _, block := range blocks[s.Height+1:] {
+		if block.Pruned {
+			return fmt.Errorf("block %d after the state has been pruned", block.Index)
+		}
+	}
+	return nil
+}
+
+// NewChainFromState returns a chain made up of the given blocks, starting from a state instead of the bodies of
+// the blocks up to it, which can be pruned. The blocks after it are applied to it. A chain that started from a
+// state can't be rolled back past it.
+func NewChainFromState(blocks []Block, s State) (*Chain, error) {
+	if err := checkState(blocks, s); err != nil {
+		return nil, err
+	}
+	c := &Chain{params: DefaultParams, finalized: -1}
+	c.restore(blocks, s)
+	return c, nil
+}
+
+// ReplaceWithState replaces the chain with blocks starting from a state, as long as they've more work behind
+// them and agree with every checkpoint, the way ReplaceChain does. It's for a new node starting from a state it
+// trusts, with the headers before it and any blocks after it.
+func (c *Chain) ReplaceWithState(blocks []Block, s State) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := checkState(blocks, s); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		if !c.checkpoints.Matches(block) {
+			return fmt.Errorf("block %d doesn't match the checkpoint", block.Index)
+		}
+	}
+	if ChainWork(blocks).Cmp(ChainWork(c.blocks)) <= 0 {
+		return fmt.Errorf("the chain has no more work behind it than ours")
+	}
+	if forkPoint(c.blocks, blocks) < c.finalized {
+		return fmt.Errorf("the chain doesn't keep our final blocks")
+	}
+	c.restore(blocks, s)
+	return nil
+}
+
+// SetStateInterval has the chain take its state every interval blocks as they're added, for LatestState, 0
+// for never
+func (c *Chain) SetStateInterval(interval int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stateEvery = interval
+}
+
+// LatestState returns the state as of the latest block at a multiple of the state interval, if one's been
+// added since the chain was loaded and it's still in the chain
+func (c *Chain) LatestState() (State, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.latest == nil {
+		return State{}, false
+	}
+	return *c.latest, true
+}
+
+// takeState keeps the state as of a block just pushed, if it's at a multiple of the state interval. c.mu has to
+// be held.
+func (c *Chain) takeState(block Block) {
+	if c.stateEvery > 0 && block.Index > 0 && block.Index%c.stateEvery == 0 {
+		s := c.state()
+		c.latest = &s
+	}
+}
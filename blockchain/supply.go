@@ -0,0 +1,97 @@
+package blockchain
+
+// The emission schedule: every block's coinbase mints Params.BlockReward, halved every HalvingInterval
+// blocks, like bitcoin's. Fees aren't counted, they're coins that already exist changing hands. Without a
+// HalvingInterval the reward never changes and there's no cap on the supply. Forks and governance can change
+// the BlockReward from a height on, the halvings carry on as they were.
+
+// Reward returns the coins the coinbase of the block at height mints, before fees
+func (p Params) Reward(height int) int {
+	p = p.At(height)
+	if p.HalvingInterval <= 0 {
+		return p.BlockReward
+	}
+	halvings := height / p.HalvingInterval
+	if halvings >= 63 { // shifted right out of an int
+		return 0
+	}
+	return p.BlockReward >> halvings
+}
+
+// Issued returns how many coins the chain has minted once it's height blocks long, not counting the genesis block
+func (p Params) Issued(height int) int {
+	total, from := 0, 1
+	for _, start := range p.rewardChanges() { // each stretch at the block reward it had
+		if start > height {
+			break
+		}
+		at := p.At(from)
+		total += at.issued(start-1) - at.issued(from-1)
+		from = start
+	}
+	at := p.At(from)
+	return total + at.issued(height) - at.issued(from-1)
+}
+
+// issued is Issued with the block reward the params have all along
+func (p Params) issued(height int) int {
+	if height <= 0 {
+		return 0
+	}
+	if p.HalvingInterval <= 0 {
+		return p.BlockReward * height
+	}
+
+	total := -p.BlockReward // the genesis block is in the first era, but has no coinbase
+	reward := p.BlockReward
+	for start := 0; reward > 0 && start <= height; start, reward = start+p.HalvingInterval, reward>>1 {
+		blocks := p.HalvingInterval
+		if left := height - start + 1; left < blocks {
+			blocks = left
+		}
+		total += reward * blocks
+	}
+	return total
+}
+
+// MaxSupply returns how many coins will ever be minted, false if the reward never halves so there's no limit
+func (p Params) MaxSupply() (int, bool) {
+	if p.HalvingInterval <= 0 {
+		return 0, false
+	}
+	changes := p.rewardChanges()
+	if len(changes) == 0 {
+		return p.maxSupply(), true
+	}
+	last := changes[len(changes)-1] // everything after it is minted at the latest reward
+	at := p.At(last)
+	return p.Issued(last-1) + at.maxSupply() - at.issued(last-1), true
+}
+
+// maxSupply is MaxSupply with the block reward the params have all along
+func (p Params) maxSupply() int {
+	total := -p.BlockReward
+	for reward := p.BlockReward; reward > 0; reward >>= 1 {
+		total += reward * p.HalvingInterval
+	}
+	return total
+}
+
+// rewardChanges returns the heights forks and governance change the block reward from, in order
+func (p Params) rewardChanges() []int {
+	var heights []int
+	for _, change := range p.schedule() {
+		if change.Param == ParamBlockReward && change.Height > 0 {
+			heights = append(heights, change.Height)
+		}
+	}
+	return heights
+}
+
+// NextHalving returns the height of the first block after height with a smaller reward, false if there isn't one
+func (p Params) NextHalving(height int) (int, bool) {
+	if p.HalvingInterval <= 0 || p.Reward(height) == 0 {
+		return 0, false
+	}
+	return (height/p.HalvingInterval + 1) * p.HalvingInterval, true
+}
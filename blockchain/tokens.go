@@ -0,0 +1,180 @@
+package blockchain
+
+import "errors"
+
+// the errors token transactions can fail with
+var (
+	ErrUnknownToken      = errors.New("token doesn't exist")
+	ErrInsufficientToken = errors.New("sender doesn't hold enough of the token")
+)
+
+// Token ... a fungible asset issued on chain by a ClassTokenIssue transaction
+type Token struct {
+	ID     string // hash of the transaction that issued the token
+	Name   string // the Payload of the issuing transaction
+	Issuer string // who issued it, and was credited the whole supply
+	Supply int    // how many units exist
+}
+
+// TokenLedger ... every token issued on a chain and who holds how much of each
+type TokenLedger struct {
+	tokens   map[string]Token
+	order    []string                  // token IDs in the order they were issued
+	balances map[string]map[string]int // token ID -> address -> units held
+}
+
+// NewTokenLedger returns a ledger with no tokens in it
+func NewTokenLedger() *TokenLedger {
+	return &TokenLedger{tokens: make(map[string]Token), balances: make(map[string]map[string]int)}
+}
+
+// tokenLedger builds the ledger for a chain, skipping anything that doesn't apply
+func tokenLedger(blocks []Block) *TokenLedger {
+	ledger := NewTokenLedger()
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			ledger.Apply(tx)
+		}
+	}
+	return ledger
+}
+
+// ValidateTokens returns if every token transaction in a chain applies, so no one transfers tokens they don't have.
+// Balances can't be worked out past a pruned block, so pruned chains aren't checked.
+func ValidateTokens(blocks []Block) bool {
+	ledger := NewTokenLedger()
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+		if ledger.ApplyBlock(block) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy returns a copy of the ledger that can be changed without touching this one
+func (l *TokenLedger) Copy() *TokenLedger {
+	c := NewTokenLedger()
+	for id, token := range l.tokens {
+		c.tokens[id] = token
+	}
+	c.order = append(c.order, l.order...)
+	for id, holders := range l.balances {
+		c.balances[id] = make(map[string]int, len(holders))
+		for addr, balance := range holders {
+			c.balances[id][addr] = balance
+		}
+	}
+	return c
+}
+
+// Apply updates the ledger with a transaction, returning an error and leaving the ledger alone if it doesn't apply.
+// Transactions that aren't token transactions are ignored.
+func (l *TokenLedger) Apply(tx Transaction) error {
+	switch tx.Class {
+	case ClassTokenIssue: // Payload is the token's name, Amount its supply
+		if tx.From == "" || tx.Payload == "" || tx.Amount <= 0 {
+			return errors.New("token issue needs an issuer, a name and a positive supply")
+		}
+		id := tx.Hash()
+		l.tokens[id] = Token{ID: id, Name: tx.Payload, Issuer: tx.From, Supply: tx.Amount}
+		l.order = append(l.order, id)
+		l.balances[id] = map[string]int{tx.From: tx.Amount}
+
+	case ClassTokenTransfer: // Payload is the token ID, Amount how many units move
+		holders, ok := l.balances[tx.Payload]
+		if !ok {
+			return ErrUnknownToken
+		}
+		if tx.Amount <= 0 {
+			return errors.New("token transfer has to move a positive amount")
+		}
+		if holders[tx.From] < tx.Amount {
+			return ErrInsufficientToken
+		}
+		holders[tx.From] -= tx.Amount
+		if holders[tx.From] == 0 {
+			delete(holders, tx.From)
+		}
+		holders[tx.To] += tx.Amount
+	}
+	return nil
+}
+
+// tokenChange ... what a transaction changed in the ledger, so it can be put back
+type tokenChange struct {
+	issued  string         // ID of the token it issued
+	id      string         // the token whose balances it moved
+	holders map[string]int // what the addresses it moved them between held before
+}
+
+// apply is Apply, also returning what changed, false if nothing did
+func (l *TokenLedger) apply(tx Transaction) (tokenChange, bool) {
+	var change tokenChange
+	switch tx.Class {
+	case ClassTokenIssue:
+		change.issued = tx.Hash()
+	case ClassTokenTransfer:
+		holders := l.balances[tx.Payload]
+		change.id, change.holders = tx.Payload, map[string]int{tx.From: holders[tx.From], tx.To: holders[tx.To]}
+	default:
+		return change, false
+	}
+	return change, l.Apply(tx) == nil
+}
+
+// revert puts back what a transaction changed, the latest one applied first
+func (l *TokenLedger) revert(change tokenChange) {
+	if change.issued != "" {
+		delete(l.tokens, change.issued)
+		delete(l.balances, change.issued)
+		l.order = l.order[:len(l.order)-1]
+		return
+	}
+	holders := l.balances[change.id]
+	for addr, balance := range change.holders {
+		if balance == 0 {
+			delete(holders, addr)
+		} else {
+			holders[addr] = balance
+		}
+	}
+}
+
+// ApplyBlock applies every transaction in a block, leaving the ledger alone if any of them don't apply
+func (l *TokenLedger) ApplyBlock(block Block) error {
+	next := l.Copy()
+	for _, tx := range block.Transactions {
+		if err := next.Apply(tx); err != nil {
+			return err
+		}
+	}
+	*l = *next
+	return nil
+}
+
+// Tokens returns every token in the order they were issued
+func (l *TokenLedger) Tokens() []Token {
+	tokens := make([]Token, 0, len(l.order))
+	for _, id := range l.order {
+		tokens = append(tokens, l.tokens[id])
+	}
+	return tokens
+}
+
+// Token returns the token with the given ID
+func (l *TokenLedger) Token(id string) (Token, bool) {
+	token, ok := l.tokens[id]
+	return token, ok
+}
+
+// Balances returns how many units of a token each address holds
+func (l *TokenLedger) Balances(id string) map[string]int {
+	balances := make(map[string]int, len(l.balances[id]))
+	for addr, balance := range l.balances[id] {
+		balances[addr] = balance
+	}
+	return balances
+}
@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// TxClass ... what kind of transaction something is, used to give some classes priority in blocks
+type TxClass string
+
+// the transaction classes the chain knows about
+const (
+	ClassUser       TxClass = "user"       // regular user traffic
+	ClassGovernance TxClass = "governance" // governance votes
+	ClassOracle     TxClass = "oracle"     // oracle updates feeding outside data into the chain
+	ClassCoinbase   TxClass = "coinbase"   // the block reward, only ever created by the miner of a block
+
+	ClassTokenIssue    TxClass = "token_issue"    // creates a fungible token, Payload is its name and Amount its supply
+	ClassTokenTransfer TxClass = "token_transfer" // moves Amount units of the token with ID Payload
+	ClassAssetMint     TxClass = "asset_mint"     // creates a unique asset, Payload is the hash of its metadata
+	ClassAssetTransfer TxClass = "asset_transfer" // hands the asset with ID Payload to To
+	ClassValidator     TxClass = "validator"      // a validator's vote to add or remove (Payload) the validator To, see validators.go
+
+	ClassDelegate    TxClass = "delegate"     // stakes Amount coins with the validator To, see staking.go
+	ClassUndelegate  TxClass = "undelegate"   // takes Amount coins of the stake with the validator To back
+	ClassStakeReward TxClass = "stake_reward" // a delegator's share of a block's reward, only ever created by the block's validator
+	ClassSlash       TxClass = "slash"        // burns some of the stake with the validator To, only ever created by a block's validator, see slashing.go
+
+	ClassProposal TxClass = "proposal" // proposes changing a param, Payload is the ProposalTerms, see governance.go
+	ClassVote     TxClass = "vote"     // votes yes or no (Payload) on the proposal To
+)
+
+// Transaction ... a transfer or message submitted to the chain
+type Transaction struct {
+	Class     TxClass // the kind of transaction, defaults to a user transaction
+	From      string  // sender address
+	To        string  // recipient address
+	Amount    int     // how much is being sent
+	Fee       int     // what the sender pays the miner to include the transaction
+	Nonce     int     // the sender's transaction counter, an account can only spend each nonce once
+	Payload   string  // extra data, eg the vote being cast or the value an oracle reports
+	Timestamp int64   // unix nano time the transaction was created, keeps otherwise identical transactions apart
+	LockTime  int64   // if set, the transaction can't go in a block before this height, or unix time if it's at least LockTimeThreshold
+	Expiry    int64   `json:",omitempty"` // if set, the last height it can go in a block at, or unix time like LockTime
+
+	Script  string   // if set, the conditions for spending from From, which has to be the script's address
+	Witness []string // values the script runs on, eg signatures, pushed before the script runs
+
+	ChainID string `json:",omitempty"` // the network it's for, signatures cover it so it can't be replayed on another
+}
+
+// LockTimeThreshold ... LockTimes below this are block heights, anything else is a unix timestamp
+const LockTimeThreshold = 500000000
+
+// Final returns if the transaction's timelock has passed, so it can go in a block at height made at blockTime
+func (tx Transaction) Final(height int, blockTime time.Time) bool {
+	switch {
+	case tx.LockTime == 0:
+		return true
+	case tx.LockTime < LockTimeThreshold:
+		return int64(height) >= tx.LockTime
+	default:
+		return blockTime.Unix() >= tx.LockTime
+	}
+}
+
+// Expired returns if the transaction's expiry has passed, so it can't go in a block at height made at blockTime
+func (tx Transaction) Expired(height int, blockTime time.Time) bool {
+	switch {
+	case tx.Expiry == 0:
+		return false
+	case tx.Expiry < LockTimeThreshold:
+		return int64(height) > tx.Expiry
+	default:
+		return blockTime.Unix() > tx.Expiry
+	}
+}
+
+// Hash returns the SHA256 identifier of the transaction as a hex string
+func (tx Transaction) Hash() string {
+	record, _ := json.Marshal(tx) // struct fields always marshal in the same order, so this is deterministic
+	hashed := sha256.Sum256(record)
+	return hex.EncodeToString(hashed[:])
+}
+
+// Size returns how many bytes the transaction takes up serialized
+func (tx Transaction) Size() int {
+	record, _ := json.Marshal(tx)
+	return len(record)
+}
+
+// FeeRate returns the fee paid per byte, which is what miners rank transactions by
+func (tx Transaction) FeeRate() float64 {
+	return float64(tx.Fee) / float64(tx.Size())
+}
+
+// TotalFees adds up the fees paid by a set of transactions
+func TotalFees(txs []Transaction) int {
+	total := 0
+	for _, tx := range txs {
+		total += tx.Fee
+	}
+	return total
+}
+
+// Validate returns an error if the transaction is malformed, or isn't authorized to spend from its sender
+func (tx Transaction) Validate() error {
+	switch tx.Class {
+	case ClassUser, ClassGovernance, ClassOracle, ClassCoinbase, ClassTokenIssue, ClassTokenTransfer, ClassAssetMint, ClassAssetTransfer, ClassValidator,
+		ClassDelegate, ClassUndelegate, ClassStakeReward, ClassSlash, ClassProposal, ClassVote:
+	default:
+		return errors.New("unknown transaction class " + string(tx.Class))
+	}
+
+	if tx.Amount < 0 {
+		return errors.New("transaction amount can't be negative")
+	}
+	if tx.Fee < 0 {
+		return errors.New("transaction fee can't be negative")
+	}
+	if tx.LockTime < 0 {
+		return errors.New("transaction lock time can't be negative")
+	}
+	if tx.Expiry < 0 {
+		return errors.New("transaction expiry can't be negative")
+	}
+	if tx.Expiry > 0 && tx.LockTime > tx.Expiry && (tx.LockTime < LockTimeThreshold) == (tx.Expiry < LockTimeThreshold) {
+		return errors.New("transaction expires before its lock time")
+	}
+	return ValidateScript(tx)
+}
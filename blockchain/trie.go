@@ -0,0 +1,431 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Every account, an address's coin balance and nonce, is kept in a binary merkle trie keyed by the SHA256 of
+// the address, and the root of the trie as of a block goes in its header. Two nodes agree on every balance if
+// they have the same root, and a header's root commits its maker to the balances the block leaves behind, so
+// a block whose transactions don't add up to it is rejected like one whose body doesn't match its merkle root.
+//
+// The trie is a sparse merkle tree cut short the way a Patricia trie is: a subtree with one account in it is
+// just that account's leaf, wherever it sits, so a trie of n accounts is about log2(n) deep rather than 256,
+// and an empty subtree hashes to 32 zero bytes. Either way the root only depends on which accounts there are,
+// not the order they were added in. Leaves hash as 0x00, the key and the account's hash, and branches as 0x01
+// and their children's hashes, so neither can pass for the other.
+//
+// Tries never change, setting an account returns a new one sharing everything off the path to it, so the
+// chain keeps the trie as of each block it can roll back for nothing more than the nodes that block changed.
+// Accounts with no coins and no nonce aren't in the trie at all, an address that's never been used is the
+// same as one that's been emptied. Tokens, assets, stakes and the rest of the state aren't in it either, see
+// state.go for snapshots of those.
+//
+// A state proof is the path from the root down to where an address's account is, or would be: the hash on
+// the other side of every branch on the way, and the leaf at the end. Hashing back up from the leaf has to
+// give the root in a header, so anyone with just the headers can check an account's balance without trusting
+// whoever sent the proof. An address that isn't in the trie is proved by the path ending at an empty subtree,
+// or at another account's leaf whose key starts the same way, since that subtree holds nothing else.
+
+// the reasons a chain can't prove an account
+var (
+	ErrStateUnknown = errors.New("the chain's state is missing what pruned blocks changed")
+	ErrNoStateRoot  = errors.New("the block is from before state roots")
+)
+
+// the reasons a state proof doesn't check out
+var (
+	ErrProofTooLong  = errors.New("state proof has more siblings than a key has bits")
+	ErrBadStateProof = errors.New("state proof doesn't lead up to the state root")
+)
+
+// Account ... an address's coin balance and highest nonce, its entry in the state trie
+type Account struct {
+	Address string
+	Balance int
+	Nonce   int
+}
+
+// Hash returns the SHA256 of the account's canonical encoding
+func (a Account) Hash() [32]byte {
+	record := appendString(nil, a.Address)
+	record = appendInt(record, int64(a.Balance))
+	record = appendInt(record, int64(a.Nonce))
+	return sha256.Sum256(record)
+}
+
+// StateTrie ... the merkle trie of every account, see above
+type StateTrie struct {
+	root *trieNode
+}
+
+// trieNode ... a branch, with either child nil for an empty side, or a leaf holding one account
+type trieNode struct {
+	hash        [32]byte
+	left, right *trieNode
+	key         [32]byte // a leaf's key, the SHA256 of its address
+	account     *Account // nil for a branch
+}
+
+// trieKey returns where an address's account goes in the trie
+func trieKey(address string) [32]byte {
+	return sha256.Sum256([]byte(address))
+}
+
+// keyBit returns the bit of a key that picks the side at depth, 0 for left
+func keyBit(key [32]byte, depth int) byte {
+	return key[depth/8] >> (7 - depth%8) & 1
+}
+
+// nodeHash returns a node's hash, zeroes for an empty subtree
+func nodeHash(n *trieNode) [32]byte {
+	if n == nil {
+		return [32]byte{}
+	}
+	return n.hash
+}
+
+func newLeaf(key [32]byte, account Account) *trieNode {
+	accountHash := account.Hash()
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(key[:])
+	h.Write(accountHash[:])
+	n := &trieNode{key: key, account: &account}
+	h.Sum(n.hash[:0])
+	return n
+}
+
+func newBranch(left, right *trieNode) *trieNode {
+	leftHash, rightHash := nodeHash(left), nodeHash(right)
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(leftHash[:])
+	h.Write(rightHash[:])
+	n := &trieNode{left: left, right: right}
+	h.Sum(n.hash[:0])
+	return n
+}
+
+// StateProof ... the path through the state trie to an address's account, see above
+type StateProof struct {
+	Account  Account  // the address's account, with no coins and no nonce if it isn't in the trie
+	Siblings []string // hex hash of the other side of each branch from the root down, 64 zeroes for an empty one
+	Other    *Account `json:",omitempty"` // the account whose leaf is where the address's would be, if it isn't in the trie but another is
+}
+
+// Root returns the hex root hash of the trie, what headers carry
+func (t StateTrie) Root() string {
+	hash := nodeHash(t.root)
+	return hex.EncodeToString(hash[:])
+}
+
+// Get returns an address's account, false if it has no coins and no nonce
+func (t StateTrie) Get(address string) (Account, bool) {
+	key := trieKey(address)
+	n := t.root
+	for depth := 0; n != nil && n.account == nil; depth++ {
+		if keyBit(key, depth) == 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	if n == nil || n.key != key {
+		return Account{}, false
+	}
+	return *n.account, true
+}
+
+// Prove returns the proof of an address's account, or that it has none
+func (t StateTrie) Prove(address string) StateProof {
+	key := trieKey(address)
+	proof := StateProof{Account: Account{Address: address}}
+	n := t.root
+	for depth := 0; n != nil && n.account == nil; depth++ {
+		next, sibling := n.left, n.right
+		if keyBit(key, depth) == 1 {
+			next, sibling = n.right, n.left
+		}
+		hash := nodeHash(sibling)
+		proof.Siblings = append(proof.Siblings, hex.EncodeToString(hash[:]))
+		n = next
+	}
+	switch {
+	case n == nil:
+	case n.key == key:
+		proof.Account = *n.account
+	default:
+		other := *n.account
+		proof.Other = &other
+	}
+	return proof
+}
+
+// VerifyStateProof returns an error unless a state proof leads up to root, proving the account it gives is the
+// address's. A path can't be deeper than a key has bits, a proof with more siblings than that is refused before
+// anything's hashed.
+func VerifyStateProof(proof StateProof, root string) error {
+	if len(proof.Siblings) > 8*len([32]byte{}) {
+		return ErrProofTooLong
+	}
+	key := trieKey(proof.Account.Address)
+	var hash [32]byte // an empty subtree
+	switch {
+	case proof.Account.Balance != 0 || proof.Account.Nonce != 0:
+		if proof.Other != nil {
+			return ErrBadStateProof
+		}
+		hash = newLeaf(key, proof.Account).hash
+	case proof.Other != nil:
+		otherKey := trieKey(proof.Other.Address)
+		if otherKey == key || (proof.Other.Balance == 0 && proof.Other.Nonce == 0) {
+			return ErrBadStateProof
+		}
+		for depth := range proof.Siblings { // it has to be on the address's path
+			if keyBit(otherKey, depth) != keyBit(key, depth) {
+				return ErrBadStateProof
+			}
+		}
+		hash = newLeaf(otherKey, *proof.Other).hash
+	}
+
+	for depth := len(proof.Siblings) - 1; depth >= 0; depth-- {
+		decoded, err := hex.DecodeString(proof.Siblings[depth])
+		if err != nil || len(decoded) != len(hash) {
+			return ErrBadStateProof
+		}
+		var sibling [32]byte
+		copy(sibling[:], decoded)
+		left, right := &trieNode{hash: hash}, &trieNode{hash: sibling}
+		if keyBit(key, depth) == 1 {
+			left, right = right, left
+		}
+		hash = newBranch(left, right).hash
+	}
+	if hex.EncodeToString(hash[:]) != root {
+		return ErrBadStateProof
+	}
+	return nil
+}
+
+// Set returns the trie with an account in it, replacing the address's old one, or without the address at all
+// if the account has no coins and no nonce. t is left as it was.
+func (t StateTrie) Set(account Account) StateTrie {
+	key := trieKey(account.Address)
+	if account.Balance == 0 && account.Nonce == 0 {
+		return StateTrie{root: removeLeaf(t.root, key, 0)}
+	}
+	return StateTrie{root: insertLeaf(t.root, newLeaf(key, account), 0)}
+}
+
+// insertLeaf returns the subtree at depth with leaf in it
+func insertLeaf(n, leaf *trieNode, depth int) *trieNode {
+	switch {
+	case n == nil:
+		return leaf
+	case n.account != nil && n.key == leaf.key:
+		return leaf
+	case n.account != nil:
+		return splitLeaves(n, leaf, depth)
+	case keyBit(leaf.key, depth) == 0:
+		return newBranch(insertLeaf(n.left, leaf, depth+1), n.right)
+	default:
+		return newBranch(n.left, insertLeaf(n.right, leaf, depth+1))
+	}
+}
+
+// splitLeaves returns the subtree at depth holding two leaves, branching down until their keys differ
+func splitLeaves(a, b *trieNode, depth int) *trieNode {
+	bitA, bitB := keyBit(a.key, depth), keyBit(b.key, depth)
+	switch {
+	case bitA == bitB && bitA == 0:
+		return newBranch(splitLeaves(a, b, depth+1), nil)
+	case bitA == bitB:
+		return newBranch(nil, splitLeaves(a, b, depth+1))
+	case bitA == 0:
+		return newBranch(a, b)
+	default:
+		return newBranch(b, a)
+	}
+}
+
+// removeLeaf returns the subtree at depth without the leaf for key. A branch left with a single leaf under
+// it becomes that leaf, so the trie's shape only depends on what's in it.
+func removeLeaf(n *trieNode, key [32]byte, depth int) *trieNode {
+	if n == nil {
+		return nil
+	}
+	if n.account != nil {
+		if n.key == key {
+			return nil
+		}
+		return n
+	}
+	left, right := n.left, n.right
+	if keyBit(key, depth) == 0 {
+		left = removeLeaf(left, key, depth+1)
+	} else {
+		right = removeLeaf(right, key, depth+1)
+	}
+	switch {
+	case left == n.left && right == n.right: // it wasn't there
+		return n
+	case left == nil && (right == nil || right.account != nil):
+		return right
+	case right == nil && left.account != nil:
+		return left
+	}
+	return newBranch(left, right)
+}
+
+// accountTrie returns the trie of every account with coins or a nonce
+func accountTrie(balances, nonces map[string]int) StateTrie {
+	var t StateTrie
+	for address, balance := range balances {
+		t = t.Set(Account{Address: address, Balance: balance, Nonce: nonces[address]})
+	}
+	for sender, nonce := range nonces {
+		if _, ok := balances[sender]; !ok {
+			t = t.Set(Account{Address: sender, Nonce: nonce})
+		}
+	}
+	return t
+}
+
+// afterBlock returns the trie with a block's transactions applied to it, given the balances and nonces as of
+// the block's parent, which are left alone
+func (t StateTrie) afterBlock(block Block, balances, nonces map[string]int) StateTrie {
+	deltas := make(map[string]int)
+	applyBalances(deltas, block)
+	raised := make(map[string]int)
+	for _, tx := range block.Transactions {
+		if tx.SpendKey() != "" {
+			raised[tx.From] = tx.Nonce
+		}
+	}
+
+	for address, delta := range deltas {
+		nonce, ok := raised[address]
+		if !ok {
+			nonce = nonces[address]
+		}
+		t = t.Set(Account{Address: address, Balance: balances[address] + delta, Nonce: nonce})
+	}
+	for sender, nonce := range raised {
+		if _, ok := deltas[sender]; !ok {
+			t = t.Set(Account{Address: sender, Balance: balances[sender], Nonce: nonce})
+		}
+	}
+	return t
+}
+
+// ValidateStateRoots returns if the state root in every block's header is the root of the accounts it leaves
+// behind. Accounts can't be worked out past a pruned block, so the rest of a pruned chain isn't checked.
+func ValidateStateRoots(blocks []Block) bool {
+	var t StateTrie
+	balances, nonces := make(map[string]int), make(map[string]int)
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+		t = t.afterBlock(block, balances, nonces)
+		if block.Index > 0 && block.Version >= StateHeaderVersion && t.Root() != block.StateRoot { // the genesis block is whatever the network started with
+			return false
+		}
+		applyAccounts(balances, nonces, block)
+	}
+	return true
+}
+
+// applyAccounts adds a block's transactions to maps of address -> coin balance and sender -> highest nonce
+func applyAccounts(balances, nonces map[string]int, block Block) {
+	applyBalances(balances, block)
+	for _, tx := range block.Transactions {
+		if tx.SpendKey() != "" {
+			nonces[tx.From] = tx.Nonce
+		}
+	}
+}
+
+// StateRootAfter returns the state root a block has to carry to go on top of the chain, the root of the
+// accounts as of the head with its transactions applied. It's ErrStateUnknown for a chain that's added
+// pruned blocks without a state to start from.
+func (c *Chain) StateRootAfter(block Block) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.partial {
+		return "", ErrStateUnknown
+	}
+	return c.trie.afterBlock(block, c.balances, c.nonces).Root(), nil
+}
+
+// StateRoot returns the root of the accounts as of the head, ErrStateUnknown if the chain doesn't know them all
+func (c *Chain) StateRoot() (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.partial {
+		return "", ErrStateUnknown
+	}
+	return c.trie.Root(), nil
+}
+
+// ProveAccount returns the proof of an address's account as of the block at height, against its state root.
+// The chain has the trie as of every block it can roll back, older ones are worked out again from the bodies,
+// which is ErrBalancePruned if any of them have been pruned.
+func (c *Chain) ProveAccount(address string, height int) (StateProof, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if height < 0 || height >= len(c.blocks) {
+		return StateProof{}, fmt.Errorf("no block at height %d", height)
+	}
+	if c.blocks[height].Version < StateHeaderVersion {
+		return StateProof{}, ErrNoStateRoot
+	}
+	t, err := c.trieAt(height)
+	if err != nil {
+		return StateProof{}, err
+	}
+	return t.Prove(address), nil
+}
+
+// trieAt returns the accounts as of the block at height, c.mu has to be held
+func (c *Chain) trieAt(height int) (StateTrie, error) {
+	if c.partial {
+		return StateTrie{}, ErrStateUnknown
+	}
+	depth := len(c.blocks) - 1 - height
+	if depth == 0 {
+		return c.trie, nil
+	}
+	if depth <= len(c.undo) { // the trie before the block after it
+		return c.undo[len(c.undo)-depth].trie, nil
+	}
+
+	var t StateTrie
+	balances, nonces, start := make(map[string]int), make(map[string]int), 0
+	if c.base != nil {
+		if height < c.base.Height {
+			return StateTrie{}, ErrBalancePruned
+		}
+		for address, balance := range c.base.Balances {
+			balances[address] = balance
+		}
+		for sender, nonce := range c.base.Nonces {
+			nonces[sender] = nonce
+		}
+		t, start = accountTrie(balances, nonces), c.base.Height+1
+	}
+	for _, block := range c.blocks[start : height+1] {
+		if block.Pruned {
+			return StateTrie{}, ErrBalancePruned
+		}
+		t = t.afterBlock(block, balances, nonces)
+		applyAccounts(balances, nonces, block)
+	}
+	return t, nil
+}
@@ -0,0 +1,57 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyStateProof(t *testing.T) {
+	trie := StateTrie{}
+	for i, address := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		trie = trie.Set(Account{Address: address, Balance: 10 * (i + 1), Nonce: i})
+	}
+	root := trie.Root()
+	zeroes := strings.Repeat("0", 64)
+
+	tamperedSibling := trie.Prove("carol")
+	tamperedSibling.Siblings = append([]string(nil), tamperedSibling.Siblings...)
+	tamperedSibling.Siblings[0] = strings.Repeat("ab", 32)
+	tamperedBalance := trie.Prove("carol")
+	tamperedBalance.Account.Balance = 1000
+	badHex := trie.Prove("carol")
+	badHex.Siblings = append([]string{"not hex"}, badHex.Siblings[1:]...)
+	full := StateProof{Account: Account{Address: "carol", Balance: 30, Nonce: 2}, Siblings: make([]string, 256)}
+	oversized := StateProof{Account: Account{Address: "carol", Balance: 30, Nonce: 2}, Siblings: make([]string, 257)}
+	oversizedOther := StateProof{Account: Account{Address: "zed"}, Other: &Account{Address: "carol", Balance: 30, Nonce: 2}, Siblings: make([]string, 257)}
+	for _, proof := range []StateProof{full, oversized, oversizedOther} {
+		for i := range proof.Siblings {
+			proof.Siblings[i] = zeroes
+		}
+	}
+
+	tests := []struct {
+		name  string
+		proof StateProof
+		root  string
+		want  error
+	}{
+		{"an account", trie.Prove("carol"), root, nil},
+		{"another account", trie.Prove("erin"), root, nil},
+		{"an address that isn't in the trie", trie.Prove("zed"), root, nil},
+		{"an empty trie", StateTrie{}.Prove("alice"), StateTrie{}.Root(), nil},
+		{"tampered sibling", tamperedSibling, root, ErrBadStateProof},
+		{"tampered balance", tamperedBalance, root, ErrBadStateProof},
+		{"sibling that isn't hex", badHex, root, ErrBadStateProof},
+		{"wrong root", trie.Prove("carol"), trie.Set(Account{Address: "frank", Balance: 1}).Root(), ErrBadStateProof},
+		{"as deep as a key", full, root, ErrBadStateProof},
+		{"deeper than a key", oversized, root, ErrProofTooLong},
+		{"deeper than a key, ending at another account", oversizedOther, root, ErrProofTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyStateProof(tt.proof, tt.root); err != tt.want {
+				t.Errorf("VerifyStateProof() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
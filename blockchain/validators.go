@@ -0,0 +1,296 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"sort"
+)
+
+// On BFT and PoA networks the validator set can change on chain. A ClassValidator transaction is one
+// validator's vote to add the node ID in To to the set, or to remove it: From is the voting validator's node
+// ID and Witness its signature over the transaction (Transaction.Sign with its node key). Once more than half
+// of the current validators have voted for the same change it happens, taking effect from the next block.
+// New validators join the end of the turn order. Votes still pending when the set changes start over, since
+// they were counted against the old set.
+
+// the changes a ClassValidator transaction's Payload can vote for
+const (
+	ValidatorAdd    = "add"
+	ValidatorRemove = "remove"
+)
+
+// the reasons a validator transaction doesn't apply
+var (
+	ErrNotValidator     = errors.New("only validators can vote on the validator set")
+	ErrValidatorVote    = errors.New("validator vote needs Payload add or remove and a node ID in To")
+	ErrAlreadyValidator = errors.New("node is already a validator")
+	ErrLastValidator    = errors.New("the last validator can't be removed")
+)
+
+// ValidatorChange ... a change to the validator set, and the height of the block that made it
+type ValidatorChange struct {
+	Height    int
+	Action    string // ValidatorAdd or ValidatorRemove
+	Validator string
+	Voters    []string `json:",omitempty"` // who voted for it, empty for the validators a network starts with
+}
+
+// ValidatorVotes ... the votes so far for a change that hasn't happened yet
+type ValidatorVotes struct {
+	Action    string
+	Validator string
+	Voters    []string
+}
+
+// validatorProposal ... a change validators can vote for
+type validatorProposal struct {
+	action, validator string
+}
+
+// ValidatorSet ... a network's validators as of a block, the votes in progress and how the set got here
+type ValidatorSet struct {
+	active  []string // in the order they take turns
+	votes   map[validatorProposal]map[string]bool
+	history []ValidatorChange
+}
+
+// NewValidatorSet returns the set a network starts with
+func NewValidatorSet(validators []string) *ValidatorSet {
+	s := &ValidatorSet{votes: make(map[validatorProposal]map[string]bool)}
+	for _, v := range validators {
+		s.active = append(s.active, v)
+		s.history = append(s.history, ValidatorChange{Height: 0, Action: ValidatorAdd, Validator: v})
+	}
+	return s
+}
+
+// validatorSet builds the set for a chain starting from genesis, skipping anything that doesn't apply
+func validatorSet(genesis []string, blocks []Block) *ValidatorSet {
+	s := NewValidatorSet(genesis)
+	for _, block := range blocks {
+		s.applyBlock(block)
+	}
+	return s
+}
+
+// ValidateValidators returns if every validator transaction in a chain applies, so only validators change the set
+func ValidateValidators(blocks []Block, genesis []string) bool {
+	s := NewValidatorSet(genesis)
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+		if s.ApplyBlock(block) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// eachValidatorSet calls check with every block after the genesis block and the validators it's checked
+// against, the set as of its parent, returning false as soon as check does
+func eachValidatorSet(blocks []Block, genesis []string, check func(i int, validators []string) bool) bool {
+	s := NewValidatorSet(genesis)
+	for i, block := range blocks {
+		if i > 0 && !check(i, s.active) {
+			return false
+		}
+		s.applyBlock(block)
+	}
+	return true
+}
+
+// Copy returns a copy of the set that can be changed without touching this one
+func (s *ValidatorSet) Copy() *ValidatorSet {
+	c := &ValidatorSet{
+		active:  append([]string(nil), s.active...),
+		votes:   make(map[validatorProposal]map[string]bool, len(s.votes)),
+		history: append([]ValidatorChange(nil), s.history...),
+	}
+	for p, voters := range s.votes {
+		c.votes[p] = make(map[string]bool, len(voters))
+		for v := range voters {
+			c.votes[p][v] = true
+		}
+	}
+	return c
+}
+
+// Active returns the validators, in the order they take turns
+func (s *ValidatorSet) Active() []string {
+	return append([]string(nil), s.active...)
+}
+
+// History returns every change to the set, oldest first
+func (s *ValidatorSet) History() []ValidatorChange {
+	return append([]ValidatorChange(nil), s.history...)
+}
+
+// Pending returns the votes for changes that haven't happened yet
+func (s *ValidatorSet) Pending() []ValidatorVotes {
+	pending := make([]ValidatorVotes, 0, len(s.votes))
+	for p, voters := range s.votes {
+		pending = append(pending, ValidatorVotes{Action: p.action, Validator: p.validator, Voters: sortedKeys(voters)})
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].Validator != pending[j].Validator {
+			return pending[i].Validator < pending[j].Validator
+		}
+		return pending[i].Action < pending[j].Action
+	})
+	return pending
+}
+
+// Apply counts a validator transaction in a block at height, making the change once more than half the
+// validators have voted for it. It returns an error and leaves the set alone if the transaction doesn't apply.
+// Transactions that aren't validator transactions are ignored.
+func (s *ValidatorSet) Apply(tx Transaction, height int) error {
+	if tx.Class != ClassValidator {
+		return nil
+	}
+	if !contains(s.active, tx.From) {
+		return ErrNotValidator
+	}
+	if !validatorSigned(tx) {
+		return ErrBadSignature
+	}
+	if pub, err := hex.DecodeString(tx.To); err != nil || len(pub) != ed25519.PublicKeySize {
+		return ErrValidatorVote
+	}
+	p := validatorProposal{action: tx.Payload, validator: tx.To}
+	switch {
+	case p.action == ValidatorAdd && contains(s.active, p.validator):
+		return ErrAlreadyValidator
+	case p.action == ValidatorRemove && !contains(s.active, p.validator):
+		return ErrUnknownValidator
+	case p.action == ValidatorRemove && len(s.active) == 1:
+		return ErrLastValidator
+	case p.action != ValidatorAdd && p.action != ValidatorRemove:
+		return ErrValidatorVote
+	}
+
+	if s.votes[p] == nil {
+		s.votes[p] = make(map[string]bool)
+	}
+	s.votes[p][tx.From] = true
+	if len(s.votes[p]) <= len(s.active)/2 {
+		return nil
+	}
+
+	if p.action == ValidatorAdd {
+		s.active = append(s.active, p.validator)
+	} else {
+		s.active = without(s.active, p.validator)
+	}
+	s.history = append(s.history, ValidatorChange{Height: height, Action: p.action, Validator: p.validator, Voters: sortedKeys(s.votes[p])})
+	s.votes = make(map[validatorProposal]map[string]bool)
+	return nil
+}
+
+// ApplyBlock applies every transaction in a block, leaving the set alone if any of them don't apply
+func (s *ValidatorSet) ApplyBlock(block Block) error {
+	if !hasValidatorTxs(block) {
+		return nil
+	}
+	next := s.Copy()
+	for _, tx := range block.Transactions {
+		if err := next.Apply(tx, block.Index); err != nil {
+			return err
+		}
+	}
+	*s = *next
+	return nil
+}
+
+// applyBlock applies the block's transactions that apply, skipping the rest
+func (s *ValidatorSet) applyBlock(block Block) {
+	for _, tx := range block.Transactions {
+		s.Apply(tx, block.Index)
+	}
+}
+
+// validatorSigned returns if a validator transaction carries its sender's signature
+func validatorSigned(tx Transaction) bool {
+	if len(tx.Witness) != 1 {
+		return false
+	}
+	return verifySig(tx.From, tx.Witness[0], tx)
+}
+
+// hasValidatorTxs returns if a block has any validator transactions
+func hasValidatorTxs(block Block) bool {
+	for _, tx := range block.Transactions {
+		if tx.Class == ClassValidator {
+			return true
+		}
+	}
+	return false
+}
+
+func without(list []string, s string) []string {
+	var kept []string
+	for _, v := range list {
+		if v != s {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SetValidators sets the validators a network that isn't mined starts with, in the order they take turns
+// proposing or sealing, and works out the set and the stakes with it as of the head from the transactions since
+func (c *Chain) SetValidators(validators []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.genesisSet = append([]string(nil), validators...)
+	set, stakes := NewValidatorSet(validators), NewStakeLedger()
+	from := 0
+	if c.base != nil { // the bodies before the state may not be there, but the state has what they'd give
+		set, stakes, from = c.base.validatorSet(), c.base.stakeLedger(), c.base.Height+1
+	}
+	base := len(c.blocks) - len(c.undo)
+	for i := from; i < len(c.blocks); i++ {
+		block := c.blocks[i]
+		if i >= base && touchesStakes(block, set.active) { // what rolling the block back puts back
+			c.undo[i-base].stakes = stakes.Copy()
+		}
+		if i >= base && hasValidatorTxs(block) {
+			c.undo[i-base].validators = set.Copy()
+		}
+		stakes.applyBlock(block, set.active, c.params)
+		set.applyBlock(block)
+	}
+	c.validators, c.stakes = set, stakes
+}
+
+// Validators returns the validators as of the head, in the order they take turns
+func (c *Chain) Validators() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.validators.Active()
+}
+
+// IsValidator returns if a node ID is one of the validators as of the head
+func (c *Chain) IsValidator(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return contains(c.validators.active, id)
+}
+
+// ValidatorSet returns a copy of the validator set as of the head, with the votes in progress and its history
+func (c *Chain) ValidatorSet() *ValidatorSet {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.validators.Copy()
+}
@@ -0,0 +1,313 @@
+// Package blockchaintest provides helpers for integration testing against a running node.
+//
+// A test starts a fully wired node on a random port with its own temp storage,
+// drives it through the HTTP API and asserts on what comes back:
+//
+//	n := blockchaintest.NewNode(t)
+//	n.MineBlocks(t, 3)
+//	n.AssertHeight(t, 3)
+//	n.AssertValid(t)
+//
+// Transactions have to be signed by their sender. The node's key is its mining address, so its block
+// rewards pay for transactions it signs, and Fund moves them on to other test keys:
+//
+//	alice := blockchaintest.NewKey(t)
+//	n.MineBlocks(t, 1)
+//	n.Fund(t, blockchaintest.Address(alice), 10)
+//	n.SubmitTx(t, n.Sign(t, alice, blockchain.Transaction{To: "ab12...", Amount: 5}))
+package blockchaintest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// Node is a running node along with a client for talking to its API
+type Node struct {
+	*node.Node
+	Client *http.Client
+
+	Key        ed25519.PrivateKey // the node's identity key, its ID is the address it mines to, nil without a data directory
+	AdminToken string             // sent with every request from Do, if the node has one
+}
+
+// NewNode starts a node on a random local port, storing its chain in a temp directory.
+// Options can tweak the config before the node starts, eg to point it at peers.
+// The node is shut down when the test finishes.
+func NewNode(t testing.TB, options ...func(*node.Config)) *Node {
+	t.Helper()
+
+	logs := &testWriter{t: t}
+	t.Cleanup(logs.close) // after the node's closed, cleanups run last first
+	cfg := node.Config{
+		Addr:    "127.0.0.1:0",
+		DataDir: t.TempDir(),
+		Logger:  log.New(logs, "", 0),
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	n, err := node.New(cfg)
+	if err != nil {
+		t.Fatalf("blockchaintest: creating node: %v", err)
+	}
+	if err := n.Start(); err != nil {
+		t.Fatalf("blockchaintest: starting node: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+
+	return &Node{Node: n, Client: n.HTTPClient(), Key: nodeKey(t, cfg.DataDir), AdminToken: cfg.AdminToken}
+}
+
+// nodeKey reads the identity key a node keeps in its data directory
+func nodeKey(t testing.TB, dataDir string) ed25519.PrivateKey {
+	if dataDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dataDir, "node.key"))
+	if err != nil {
+		t.Fatalf("blockchaintest: reading node key: %v", err)
+	}
+	seed, err := hex.DecodeString(string(data))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		t.Fatalf("blockchaintest: node key isn't a hex seed")
+	}
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// NewKey returns a new key for a test account
+func NewKey(t testing.TB) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("blockchaintest: generating key: %v", err)
+	}
+	return key
+}
+
+// Address returns the address of a key's account, the hex of its public key
+func Address(key ed25519.PrivateKey) string {
+	return hex.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// Sign fills in a transaction's sender, class, chain ID and timestamp if they're left out, and its nonce, the
+// one after the sender's confirmed and pending ones, then signs it with key
+func (n *Node) Sign(t testing.TB, key ed25519.PrivateKey, tx blockchain.Transaction) blockchain.Transaction {
+	t.Helper()
+
+	tx.From = Address(key)
+	if tx.Class == "" {
+		tx.Class = blockchain.ClassUser
+	}
+	if tx.ChainID == "" {
+		tx.ChainID = n.Chain().Params().ChainID
+	}
+	if tx.Timestamp == 0 {
+		tx.Timestamp = time.Now().UnixNano()
+	}
+	if tx.Nonce == 0 {
+		tx.Nonce = n.nextNonce(tx.From)
+	}
+	tx.Witness = []string{tx.Sign(key)}
+	return tx
+}
+
+// nextNonce returns the nonce after a sender's confirmed and pending transactions
+func (n *Node) nextNonce(sender string) int {
+	next := 1
+	if last, ok := n.Chain().Nonce(sender); ok {
+		next = last + 1
+	}
+	for _, tx := range n.Mempool().Pending() {
+		if tx.From == sender && tx.Nonce >= next {
+			next = tx.Nonce + 1
+		}
+	}
+	return next
+}
+
+// Fund sends coins from the node's key to an address and mines them into a block. The node's key has to have
+// them, from blocks it's mined.
+func (n *Node) Fund(t testing.TB, address string, amount int) {
+	t.Helper()
+
+	n.SubmitTx(t, n.Sign(t, n.Key, blockchain.Transaction{To: address, Amount: amount}))
+	n.MineBlock(t, 0)
+}
+
+// WithPeers points the node at other nodes to sync with
+func WithPeers(peers ...*Node) func(*node.Config) {
+	return func(cfg *node.Config) {
+		for _, peer := range peers {
+			cfg.Peers = append(cfg.Peers, peer.URL())
+		}
+	}
+}
+
+// WithKey gives the node an identity key of the test's choosing, eg so a network's validators are known before
+// they start. It has to come after any option changing the data directory.
+func WithKey(key ed25519.PrivateKey) func(*node.Config) {
+	return func(cfg *node.Config) {
+		os.WriteFile(filepath.Join(cfg.DataDir, "node.key"), []byte(hex.EncodeToString(key.Seed())), 0o600)
+	}
+}
+
+// Do sends a request to the node's API and decodes the JSON response into out, if out isn't nil.
+// It returns the response status code.
+func (n *Node) Do(t testing.TB, method, path string, body, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("blockchaintest: encoding request: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, n.URL()+path, reader)
+	if err != nil {
+		t.Fatalf("blockchaintest: building request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if n.AdminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+n.AdminToken)
+	}
+
+	res, err := n.Client.Do(req)
+	if err != nil {
+		t.Fatalf("blockchaintest: %s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("blockchaintest: decoding %s %s response: %v", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+// MineBlock posts data to the node and returns the block it created
+func (n *Node) MineBlock(t testing.TB, data int) blockchain.Block {
+	t.Helper()
+
+	var block blockchain.Block
+	if code := n.Do(t, http.MethodPost, "/v1/", node.Message{Data: data}, &block); code != http.StatusCreated {
+		t.Fatalf("blockchaintest: mining block: got status %d, want %d", code, http.StatusCreated)
+	}
+	return block
+}
+
+// MineBlocks mines count blocks with increasing data and returns them in order
+func (n *Node) MineBlocks(t testing.TB, count int) []blockchain.Block {
+	t.Helper()
+
+	blocks := make([]blockchain.Block, 0, count)
+	for i := 0; i < count; i++ {
+		blocks = append(blocks, n.MineBlock(t, i))
+	}
+	return blocks
+}
+
+// SubmitTx sends a transaction to the node's mempool and returns its hash
+func (n *Node) SubmitTx(t testing.TB, tx blockchain.Transaction) string {
+	t.Helper()
+
+	var res json.RawMessage // the hash, or why the transaction was rejected
+	if code := n.Do(t, http.MethodPost, "/v1/tx", tx, &res); code != http.StatusAccepted {
+		t.Fatalf("blockchaintest: submitting transaction: got status %d, want %d: %s", code, http.StatusAccepted, res)
+	}
+
+	var hash string
+	if err := json.Unmarshal(res, &hash); err != nil {
+		t.Fatalf("blockchaintest: decoding transaction hash: %v", err)
+	}
+	return hash
+}
+
+// Blocks fetches the whole chain through the API
+func (n *Node) Blocks(t testing.TB) []blockchain.Block {
+	t.Helper()
+
+	var blocks []blockchain.Block
+	if code := n.Do(t, http.MethodGet, "/v1/", nil, &blocks); code != http.StatusOK {
+		t.Fatalf("blockchaintest: fetching chain: got status %d, want %d", code, http.StatusOK)
+	}
+	return blocks
+}
+
+// AssertHeight fails the test if the index of the head block isn't height
+func (n *Node) AssertHeight(t testing.TB, height int) {
+	t.Helper()
+
+	blocks := n.Blocks(t)
+	if got := blocks[len(blocks)-1].Index; got != height {
+		t.Errorf("blockchaintest: chain height is %d, want %d", got, height)
+	}
+}
+
+// AssertValid fails the test if the chain served by the API doesn't validate
+func (n *Node) AssertValid(t testing.TB) {
+	t.Helper()
+
+	if !blockchain.ValidateChain(n.Blocks(t)) {
+		t.Errorf("blockchaintest: chain served by %s is not valid", n.URL())
+	}
+}
+
+// AssertBlock fails the test if the block at index doesn't match want
+func (n *Node) AssertBlock(t testing.TB, index int, want blockchain.Block) {
+	t.Helper()
+
+	blocks := n.Blocks(t)
+	if index < 0 || index >= len(blocks) {
+		t.Fatalf("blockchaintest: no block at index %d, chain has %d blocks", index, len(blocks))
+	}
+	if got := blocks[index]; !reflect.DeepEqual(got, want) {
+		t.Errorf("blockchaintest: block %d is %+v, want %+v", index, got, want)
+	}
+}
+
+// testWriter sends node logs to the test log so they only show up for failing tests. Once the test's done
+// they're dropped, the node's goroutines can still be finishing up and logging after it's gone is a panic.
+type testWriter struct {
+	t      testing.TB
+	mu     sync.Mutex
+	closed bool
+}
+
+func (w *testWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.t.Logf("%s", bytes.TrimRight(p, "\n"))
+	}
+	return len(p), nil
+}
+
+func (w *testWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+}
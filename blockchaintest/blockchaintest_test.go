@@ -0,0 +1,40 @@
+package blockchaintest_test
+
+import (
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/blockchaintest"
+)
+
+func TestNode(t *testing.T) {
+	n := blockchaintest.NewNode(t)
+	mined := n.MineBlocks(t, 3)
+	n.AssertHeight(t, 3)
+	n.AssertBlock(t, 2, mined[1])
+	n.AssertValid(t)
+
+	if got := blockchaintest.Address(n.Key); got != n.ID() {
+		t.Errorf("the node key's address is %s, want the node ID %s", got, n.ID())
+	}
+
+	alice, bob := blockchaintest.NewKey(t), blockchaintest.Address(blockchaintest.NewKey(t))
+	n.Fund(t, blockchaintest.Address(alice), 20)
+	if balance, _ := n.Chain().Spendable(blockchaintest.Address(alice)); balance != 20 {
+		t.Fatalf("alice was funded %d, want 20", balance)
+	}
+
+	// signed transactions take the next nonce, counting the ones still pending
+	for _, want := range []int{1, 2, 3} {
+		tx := n.Sign(t, alice, blockchain.Transaction{To: bob, Amount: 1})
+		if tx.Nonce != want || tx.Validate() != nil {
+			t.Fatalf("signed %+v, want a valid transaction with nonce %d", tx, want)
+		}
+		n.SubmitTx(t, tx)
+	}
+	n.MineBlock(t, 0)
+	n.AssertHeight(t, 5)
+	if balance, _ := n.Chain().Spendable(bob); balance != 3 {
+		t.Errorf("bob has %d, want 3", balance)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// runChain handles the `chain` subcommands
+func runChain(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: chain <audit|rehash|import> [flags]")
+	}
+
+	switch args[0] {
+	case "audit":
+		return runChainAudit(args[1:])
+	case "rehash":
+		return runChainRehash(args[1:])
+	case "import":
+		return runChainImport(args[1:])
+	default:
+		return fmt.Errorf("unknown chain command %q", args[0])
+	}
+}
+
+// runChainAudit compares the local chain against a remote node and reports where they diverge
+func runChainAudit(args []string) error {
+	fs := flag.NewFlagSet("chain audit", flag.ContinueOnError)
+	remote := fs.String("remote", "", "base URL of the node to compare against")
+	local := fs.String("local", "http://localhost:"+os.Getenv("ADDR"), "base URL of the local node")
+	dataDir := fs.String("data-dir", "", "read the local chain straight from this data directory instead of the local node")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *remote == "" {
+		return errors.New("chain audit: --remote is required")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var localBlocks []blockchain.Block
+	var err error
+	if *dataDir != "" {
+		var store *node.Store
+		if store, err = node.OpenStore(*dataDir); err == nil {
+			localBlocks, err = store.Load()
+		}
+	} else {
+		localBlocks, err = node.FetchBlocks(client, *local)
+	}
+	if err != nil {
+		return fmt.Errorf("chain audit: loading local chain: %v", err)
+	}
+
+	remoteBlocks, err := node.FetchBlocks(client, *remote)
+	if err != nil {
+		return fmt.Errorf("chain audit: %v", err)
+	}
+
+	report := blockchain.Audit(localBlocks, remoteBlocks)
+	fmt.Printf("local blocks:  %d\n", report.LocalLength)
+	fmt.Printf("remote blocks: %d\n", report.RemoteLength)
+
+	if !report.Diverged() {
+		fmt.Println("chains match")
+		return nil
+	}
+
+	fmt.Printf("first divergence at height %d\n", report.FirstDivergence)
+	fmt.Printf("mismatched heights (%d): %v\n", len(report.Mismatched), report.Mismatched)
+	if report.LocalLength != report.RemoteLength {
+		fmt.Printf("heights missing on one side: %d\n", abs(report.LocalLength-report.RemoteLength))
+	}
+	return errors.New("chain audit: chains diverge")
+}
+
+// runChainRehash migrates a stored chain to the current header hash. Run it on every node of the
+// network while they're stopped, they all have to end up with the same chain.
+func runChainRehash(args []string) error {
+	fs := flag.NewFlagSet("chain rehash", flag.ContinueOnError)
+	dataDir := fs.String("data-dir", os.Getenv("DATA_DIR"), "data directory of the chain to migrate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" {
+		return errors.New("chain rehash: --data-dir is required")
+	}
+
+	store, err := node.OpenStore(*dataDir)
+	if err != nil {
+		return err
+	}
+	blocks, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("chain rehash: loading chain: %v", err)
+	}
+	if len(blocks) == 0 {
+		return errors.New("chain rehash: there's no chain in " + *dataDir)
+	}
+	blocks, _ = blockchain.MigrateTimestamps(blocks) // chains with text timestamps can't be checked until they're moved over
+	if !blockchain.ValidateChain(blocks) {
+		return errors.New("chain rehash: the stored chain isn't valid, not touching it")
+	}
+
+	rehashed := blockchain.Rehash(blocks)
+	if err := store.Save(rehashed, nil); err != nil { // every hash has changed, the node reindexes when it starts
+		return err
+	}
+	head := rehashed[len(rehashed)-1]
+	fmt.Printf("rehashed %d blocks, head %d is now %s\n", len(rehashed), head.Index, head.Hash)
+	fmt.Println("checkpoints from before the migration won't match any more, set them again")
+	return nil
+}
+
+// runChainImport adds the blocks in a dump, a list of blocks in the canonical encoding like GET / sends as
+// application/x-go-blockchain, to a stopped node's stored chain. Each block is checked like the node would,
+// and they're stored in batches.
+func runChainImport(args []string) error {
+	fs := flag.NewFlagSet("chain import", flag.ContinueOnError)
+	file := fs.String("file", "", "the dump to import")
+	dataDir := fs.String("data-dir", os.Getenv("DATA_DIR"), "data directory of the chain to add to, it's started from the dump's genesis block if it's empty")
+	config := fs.String("config", os.Getenv("CONFIG"), "config file with the network's consensus rules and checkpoints")
+	network := fs.String("network", os.Getenv("NETWORK"), "mainnet or testnet, whose rules the dump is checked against")
+	batch := fs.Int("batch", 500, "how many blocks to store in each write")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" || *dataDir == "" {
+		return errors.New("chain import: --file and --data-dir are required")
+	}
+	if *batch < 1 {
+		return errors.New("chain import: --batch has to be at least 1")
+	}
+
+	cfg, err := loadConfig(*config, *network)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	dump, err := blockchain.DecodeBlocks(data)
+	if err != nil {
+		return fmt.Errorf("chain import: reading %s: %v", *file, err)
+	}
+	if len(dump) == 0 {
+		return errors.New("chain import: the dump has no blocks")
+	}
+
+	store, err := node.OpenStore(*dataDir)
+	if err != nil {
+		return err
+	}
+	blocks, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("chain import: loading chain: %v", err)
+	}
+	if len(blocks) == 0 {
+		blocks = dump[:1]
+		if err := store.Save(blocks, nil); err != nil {
+			return err
+		}
+	} else if blocks[0].Hash != dump[0].Hash {
+		return errors.New("chain import: the dump is for a different chain, its genesis block doesn't match")
+	}
+
+	chain := blockchain.NewChain(blocks...)
+	chain.SetCheckpoints(blockchain.DefaultCheckpoints.Merge(cfg.Checkpoints))
+	chain.SetParams(cfg.Params)
+	chain.SetValidators(cfg.Validators)
+
+	start := time.Now()
+	imported := 0
+	var pending []blockchain.Block
+	for _, block := range dump {
+		if block.Index < chain.Len() { // already stored, or the genesis block, as long as it's the same block
+			if stored := chain.Range(block.Index, 1); len(stored) == 0 || stored[0].Hash != block.Hash {
+				err = fmt.Errorf("chain import: the dump's block %d isn't the one stored, it's from another branch", block.Index)
+				break
+			}
+			continue
+		}
+		if !chain.AddBlock(block) {
+			err = fmt.Errorf("chain import: block %d isn't valid on top of block %d", block.Index, chain.Last().Index)
+			break
+		}
+		imported++
+		if pending = append(pending, block); len(pending) == *batch {
+			if err := store.Append(pending, nil); err != nil { // the index is only worth writing with the last batch
+				return err
+			}
+			pending = nil
+		}
+	}
+	if imported > 0 {
+		if serr := store.Append(pending, chain.EncodeIndex()); serr != nil {
+			return serr
+		}
+	}
+	fmt.Printf("imported %d blocks in %v, head %d is %s\n", imported, time.Since(start).Round(time.Millisecond), chain.Last().Index, chain.Last().Hash)
+	return err
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
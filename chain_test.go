@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// testBranch returns count blocks on top of genesis, their coinbases paying miner
+func testBranch(t *testing.T, genesis blockchain.Block, miner string, count int) []blockchain.Block {
+	t.Helper()
+
+	chain := blockchain.NewChain(genesis)
+	for i := 0; i < count; i++ {
+		prev := chain.Last()
+		coinbase := blockchain.NewCoinbase(miner, prev.Index+1, chain.Params().Reward(prev.Index+1))
+		block, _ := blockchain.GenerateBlock(prev, 0, coinbase)
+		block.StateRoot, _ = chain.StateRootAfter(block)
+		block.Hash = blockchain.GenerateHash(block)
+		if !chain.AddBlock(block) {
+			t.Fatalf("block %d isn't valid", block.Index)
+		}
+	}
+	return chain.Blocks()
+}
+
+func TestChainImport(t *testing.T) {
+	genesis := blockchain.NewGenesisBlock()
+	genesis.Hash = blockchain.GenerateHash(genesis)
+	stored := testBranch(t, genesis, "alice", 5)
+	other := testBranch(t, genesis, "bob", 5)
+
+	tests := []struct {
+		name  string
+		dump  []blockchain.Block
+		valid bool
+	}{
+		{"the same blocks", stored, true},
+		{"fewer of the same blocks", stored[:3], true},
+		{"another branch as long", other, false},
+		{"another branch, shorter", other[:3], false},
+		{"another branch, longer", testBranch(t, genesis, "bob", 7), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dataDir, file := filepath.Join(dir, "data"), filepath.Join(dir, "dump.bin")
+			importDump := func(blocks []blockchain.Block) error {
+				if err := os.WriteFile(file, blockchain.EncodeBlocks(blocks), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return runChainImport([]string{"--file", file, "--data-dir", dataDir, "--network", "mainnet"})
+			}
+			if err := importDump(stored); err != nil {
+				t.Fatalf("importing into an empty data directory: %v", err)
+			}
+			if err := importDump(tt.dump); (err == nil) != tt.valid {
+				t.Errorf("chain import = %v, want it to succeed: %v", err, tt.valid)
+			}
+			store, err := node.OpenStore(dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if blocks, err := store.Load(); err != nil || len(blocks) != len(stored) || blocks[len(blocks)-1].Hash != stored[len(stored)-1].Hash {
+				t.Errorf("the stored chain changed, it's %d blocks (%v)", len(blocks), err)
+			}
+		})
+	}
+}
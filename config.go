@@ -0,0 +1,543 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/node"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultConfigFile is read if CONFIG isn't set and it exists
+const defaultConfigFile = "config.yaml"
+
+// fileConfig ... the layout of the YAML config file, see config.example.yaml
+type fileConfig struct {
+	Addr    string `yaml:"addr"`     // listen address, eg ":8080"
+	DataDir string `yaml:"data_dir"` // where the chain is stored
+
+	Peers         []string      `yaml:"peers"`
+	SyncInterval  time.Duration `yaml:"sync_interval"`
+	SyncBatchSize int           `yaml:"sync_batch_size"`
+	GossipFanout  int           `yaml:"gossip_fanout"`
+	MaxBlockAge   time.Duration `yaml:"max_block_age"` // /readyz fails when the head is older
+
+	AllowedPeers []string `yaml:"allowed_peers"`
+	BannedPeers  []string `yaml:"banned_peers"`
+	LANDiscovery bool     `yaml:"lan_discovery"` // find peers on the LAN by multicast
+	LANGroup     string   `yaml:"lan_group"`
+	PortMapping  string   `yaml:"port_mapping"` // auto, upnp or natpmp, ask the router to forward a port
+	NATGateway   string   `yaml:"nat_gateway"`
+	DNSSeeds     []string `yaml:"dns_seeds"` // hostnames to ask for peers when there are none
+	SeedPort     int      `yaml:"seed_port"`
+	DNSServer    string   `yaml:"dns_server"`
+
+	BanScore int           `yaml:"ban_score"` // misbehavior score peers are banned at
+	BanTime  time.Duration `yaml:"ban_time"`
+
+	EncryptPeers bool `yaml:"encrypt_peers"` // TLS between nodes with their node keys
+
+	SyncPeers   int           `yaml:"sync_peers"` // peers blocks are downloaded from at once
+	SyncTimeout time.Duration `yaml:"sync_timeout"`
+
+	Light bool `yaml:"light"` // keep just headers, checking transactions with proofs from peers
+
+	Profile string `yaml:"profile"` // archive, full or pruned
+
+	StateInterval    int                    `yaml:"state_interval"`    // blocks between state snapshots, -1 for none
+	StateCheckpoints blockchain.Checkpoints `yaml:"state_checkpoints"` // height: state digest
+
+	Consensus struct {
+		BlockReward  *int                   `yaml:"block_reward"` // a pointer so 0 can be told apart from unset
+		Checkpoints  blockchain.Checkpoints `yaml:"checkpoints"`  // height: hash
+		MaxBlockSize *int                   `yaml:"max_block_size"`
+		MaxBlockCost *int                   `yaml:"max_block_cost"`
+		MinerAddress string                 `yaml:"miner_address"`
+		PruneDepth   int                    `yaml:"prune_depth"`
+
+		Difficulty      int64         `yaml:"difficulty"`
+		TargetBlockTime time.Duration `yaml:"target_block_time"`
+		RetargetWindow  int           `yaml:"retarget_window"`
+		RetargetMaxRise *int          `yaml:"retarget_max_rise"`
+		RetargetMaxFall *int          `yaml:"retarget_max_fall"`
+
+		HalvingInterval int `yaml:"halving_interval"`
+		FinalityDepth   int `yaml:"finality_depth"`
+
+		Engine      string        `yaml:"engine"` // pow, bft or poa
+		ChainID     string        `yaml:"chain_id"`
+		Validators  []string      `yaml:"validators"`
+		BlockTime   time.Duration `yaml:"block_time"`
+		BFTTimeout  time.Duration `yaml:"bft_timeout"`
+		GenesisTime time.Time     `yaml:"genesis_time"`
+		Commission  *int          `yaml:"commission"`
+
+		SlashPercent   *int `yaml:"slash_percent"`
+		DowntimeWindow *int `yaml:"downtime_window"`
+		MaxMissed      *int `yaml:"max_missed"`
+
+		Forks []struct {
+			Name    string           `yaml:"name"`
+			Height  int              `yaml:"height"`
+			Version int              `yaml:"version"`
+			Params  map[string]int64 `yaml:"params"`
+		} `yaml:"forks"`
+		SoftForks []struct {
+			Name      string           `yaml:"name"`
+			Bit       int              `yaml:"bit"`
+			Start     int              `yaml:"start"`
+			Timeout   int              `yaml:"timeout"`
+			Threshold int              `yaml:"threshold"`
+			Params    map[string]int64 `yaml:"params"`
+		} `yaml:"soft_forks"`
+		SignalWindow *int `yaml:"signal_window"`
+	} `yaml:"consensus"`
+
+	Mempool struct {
+		MaxBlockTxs      int     `yaml:"max_block_txs"`
+		PriorityFraction float64 `yaml:"priority_fraction"`
+		MaxTxs           int     `yaml:"max_txs"`   // most transactions it holds, negative for no limit
+		MaxBytes         int     `yaml:"max_bytes"` // most bytes of transactions it holds, negative for no limit
+		Evict            string  `yaml:"evict"`     // fee or age, what goes first when it's full
+		RBFBump          int     `yaml:"rbf_bump"`  // percent more a replacement pays, negative turns replacing off
+	} `yaml:"mempool"`
+
+	Admin struct {
+		Token             string `yaml:"token"`
+		MaintenanceWindow string `yaml:"maintenance_window"`
+		DebugAddr         string `yaml:"debug_addr"` // serve pprof here
+		AuditLog          string `yaml:"audit_log"`
+	} `yaml:"admin"`
+
+	IdempotencyWindow time.Duration `yaml:"idempotency_window"` // how long retried POSTs get the original response
+	BlockCacheSize    int           `yaml:"block_cache_size"`   // encoded blocks kept for repeat requests
+	WalletDir         string        `yaml:"wallet_dir"`         // serve /wallet, keeping wallets here
+
+	Snapshots struct {
+		Dir         string `yaml:"dir"`
+		RestoreFrom string `yaml:"restore_from"`
+	} `yaml:"snapshots"`
+
+	CORS struct {
+		Origins []string `yaml:"origins"`
+		Methods []string `yaml:"methods"`
+		Headers []string `yaml:"headers"`
+	} `yaml:"cors"`
+
+	Webhooks struct {
+		URLs   []string `yaml:"urls"`
+		Secret string   `yaml:"secret"`
+	} `yaml:"webhooks"`
+
+	TLS struct {
+		Cert string `yaml:"cert"`
+		Key  string `yaml:"key"`
+		CA   string `yaml:"ca"`
+	} `yaml:"tls"`
+
+	Mining struct {
+		Enabled           bool          `yaml:"enabled"`
+		Interval          time.Duration `yaml:"interval"`
+		Threads           int           `yaml:"threads"`            // goroutines solving blocks, defaults to the number of CPUs
+		StratumAddr       string        `yaml:"stratum_addr"`       // serve external miners here, eg ":3333"
+		StratumDifficulty int64         `yaml:"stratum_difficulty"` // how hard a share is
+	} `yaml:"mining"`
+
+	Signer struct {
+		URL        string `yaml:"url"`      // a remote signer, eg http://127.0.0.1:7000
+		Token      string `yaml:"token"`    // its bearer token
+		Keystore   string `yaml:"keystore"` // or a keystore file
+		Passphrase string `yaml:"passphrase"`
+	} `yaml:"signer"`
+
+	Faucet struct {
+		Amount   *int          `yaml:"amount"` // a pointer so 0 can turn the testnet's faucet off
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"faucet"`
+
+	Logging struct {
+		File  string `yaml:"file"`  // log to this file instead of stderr
+		Level string `yaml:"level"` // "debug" (the default) also logs every block, "info" doesn't
+	} `yaml:"logging"`
+}
+
+// loadConfig builds the node config for a network from the config file at path (or config.yaml, if it
+// exists), with any env vars that are set taking precedence
+func loadConfig(path, network string) (node.Config, error) {
+	cfg, err := networkDefaults(network)
+	if err != nil {
+		return cfg, err
+	}
+
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			path = defaultConfigFile
+		}
+	}
+	if path != "" {
+		if err := applyConfigFile(&cfg, path); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// applyConfigFile reads a YAML config file into cfg
+func applyConfigFile(cfg *node.Config, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var file fileConfig
+	if err := yaml.UnmarshalStrict(raw, &file); err != nil { // strict so typos in keys don't go unnoticed
+		return fmt.Errorf("config %s: %v", path, err)
+	}
+
+	if file.Addr != "" { // the network may have its own default
+		cfg.Addr = file.Addr
+	}
+	cfg.DataDir = file.DataDir
+	cfg.Peers = file.Peers
+	cfg.SyncInterval = file.SyncInterval
+	cfg.SyncBatchSize = file.SyncBatchSize
+	cfg.GossipFanout = file.GossipFanout
+	cfg.MaxBlockAge = file.MaxBlockAge
+	cfg.AllowedPeers = file.AllowedPeers
+	cfg.BannedPeers = file.BannedPeers
+	cfg.BanScore = file.BanScore
+	cfg.BanTime = file.BanTime
+	cfg.EncryptPeers = file.EncryptPeers
+	cfg.SyncPeers = file.SyncPeers
+	cfg.SyncTimeout = file.SyncTimeout
+	cfg.Light = file.Light
+	cfg.Profile = file.Profile
+	cfg.StateInterval = file.StateInterval
+	cfg.StateCheckpoints = file.StateCheckpoints
+	cfg.LANDiscovery = file.LANDiscovery
+	cfg.LANGroup = file.LANGroup
+	cfg.PortMapping = file.PortMapping
+	cfg.NATGateway = file.NATGateway
+	cfg.DNSSeeds = file.DNSSeeds
+	cfg.SeedPort = file.SeedPort
+	cfg.DNSServer = file.DNSServer
+
+	if file.Consensus.BlockReward != nil {
+		cfg.Params.BlockReward = *file.Consensus.BlockReward
+	}
+	if file.Consensus.MaxBlockSize != nil {
+		cfg.Params.MaxBlockSize = *file.Consensus.MaxBlockSize
+	}
+	if file.Consensus.MaxBlockCost != nil {
+		cfg.Params.MaxBlockCost = *file.Consensus.MaxBlockCost
+	}
+	if file.Consensus.Difficulty != 0 {
+		cfg.Params.Difficulty = file.Consensus.Difficulty
+	}
+	cfg.Params.TargetBlockTime = file.Consensus.TargetBlockTime
+	if file.Consensus.RetargetWindow != 0 {
+		cfg.Params.RetargetWindow = file.Consensus.RetargetWindow
+	}
+	if file.Consensus.RetargetMaxRise != nil {
+		cfg.Params.RetargetMaxRise = *file.Consensus.RetargetMaxRise
+	}
+	if file.Consensus.RetargetMaxFall != nil {
+		cfg.Params.RetargetMaxFall = *file.Consensus.RetargetMaxFall
+	}
+	cfg.Params.HalvingInterval = file.Consensus.HalvingInterval
+	cfg.Params.FinalityDepth = file.Consensus.FinalityDepth
+	if file.Consensus.Engine != "" {
+		cfg.Params.Engine = file.Consensus.Engine
+	}
+	if file.Consensus.ChainID != "" {
+		cfg.Params.ChainID = file.Consensus.ChainID
+	}
+	cfg.Validators = file.Consensus.Validators
+	if file.Consensus.BlockTime != 0 {
+		cfg.BlockTime = file.Consensus.BlockTime
+	}
+	cfg.BFTTimeout = file.Consensus.BFTTimeout
+	if !file.Consensus.GenesisTime.IsZero() {
+		cfg.GenesisTime = file.Consensus.GenesisTime
+	}
+	if file.Consensus.Commission != nil {
+		cfg.Params.Commission = *file.Consensus.Commission
+	}
+	if file.Consensus.SlashPercent != nil {
+		cfg.Params.SlashPercent = *file.Consensus.SlashPercent
+	}
+	if file.Consensus.DowntimeWindow != nil {
+		cfg.Params.DowntimeWindow = *file.Consensus.DowntimeWindow
+	}
+	if file.Consensus.MaxMissed != nil {
+		cfg.Params.MaxMissed = *file.Consensus.MaxMissed
+	}
+	for _, fork := range file.Consensus.Forks {
+		cfg.Params.Forks = append(cfg.Params.Forks, blockchain.Fork{Name: fork.Name, Height: fork.Height, Version: fork.Version, Params: fork.Params})
+	}
+	if err := blockchain.ValidateForks(cfg.Params.Forks); err != nil {
+		return fmt.Errorf("config %s: %v", path, err)
+	}
+	for _, d := range file.Consensus.SoftForks {
+		cfg.Params.Deployments = append(cfg.Params.Deployments, blockchain.Deployment{Name: d.Name, Bit: d.Bit, Start: d.Start, Timeout: d.Timeout, Threshold: d.Threshold, Params: d.Params})
+	}
+	if file.Consensus.SignalWindow != nil {
+		cfg.Params.SignalWindow = *file.Consensus.SignalWindow
+	}
+	if err := blockchain.ValidateDeployments(cfg.Params.Deployments, cfg.Params.SignalWindow); err != nil {
+		return fmt.Errorf("config %s: %v", path, err)
+	}
+	cfg.Checkpoints = file.Consensus.Checkpoints
+	cfg.MinerAddress = file.Consensus.MinerAddress
+	cfg.PruneDepth = file.Consensus.PruneDepth
+
+	cfg.MaxBlockTxs = file.Mempool.MaxBlockTxs
+	cfg.PriorityFraction = file.Mempool.PriorityFraction
+	cfg.MempoolMaxTxs = file.Mempool.MaxTxs
+	cfg.MempoolMaxBytes = file.Mempool.MaxBytes
+	cfg.MempoolEvict = file.Mempool.Evict
+	cfg.RBFBump = file.Mempool.RBFBump
+	cfg.AdminToken = file.Admin.Token
+	cfg.MaintenanceWindow = file.Admin.MaintenanceWindow
+	cfg.DebugAddr = file.Admin.DebugAddr
+	cfg.AuditLog = file.Admin.AuditLog
+	cfg.IdempotencyWindow = file.IdempotencyWindow
+	cfg.BlockCacheSize = file.BlockCacheSize
+	cfg.WalletDir = file.WalletDir
+	cfg.SnapshotDir = file.Snapshots.Dir
+	cfg.RestoreFrom = file.Snapshots.RestoreFrom
+	cfg.CORSOrigins = file.CORS.Origins
+	cfg.CORSMethods = file.CORS.Methods
+	cfg.CORSHeaders = file.CORS.Headers
+	cfg.Webhooks = file.Webhooks.URLs
+	cfg.WebhookSecret = file.Webhooks.Secret
+
+	if file.TLS.Cert != "" {
+		cfg.TLS = &node.TLSConfig{CertFile: file.TLS.Cert, KeyFile: file.TLS.Key, CAFile: file.TLS.CA}
+	}
+
+	cfg.Mine = file.Mining.Enabled
+	if file.Mining.Interval != 0 {
+		cfg.MineInterval = file.Mining.Interval
+	}
+	cfg.MineThreads = file.Mining.Threads
+	cfg.StratumAddr = file.Mining.StratumAddr
+	cfg.StratumDifficulty = file.Mining.StratumDifficulty
+	cfg.SignerURL = file.Signer.URL
+	cfg.SignerToken = file.Signer.Token
+	cfg.SignerKeystore = file.Signer.Keystore
+	cfg.SignerPassphrase = file.Signer.Passphrase
+	if file.Faucet.Amount != nil {
+		cfg.FaucetAmount = *file.Faucet.Amount
+	}
+	cfg.FaucetInterval = file.Faucet.Interval
+
+	cfg.LogLevel = file.Logging.Level
+	if file.Logging.File != "" {
+		f, err := os.OpenFile(file.Logging.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		cfg.Logger = log.New(f, "", log.LstdFlags)
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with any env vars that are set
+func applyEnv(cfg *node.Config) error {
+	setString := func(key string, dst *string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	setList := func(key string, dst *[]string) { // comma separated
+		if v := os.Getenv(key); v != "" {
+			*dst = strings.Split(v, ",")
+		}
+	}
+
+	if addr := os.Getenv("ADDR"); addr != "" { // just the port
+		cfg.Addr = ":" + addr
+	}
+	setString("DATA_DIR", &cfg.DataDir)                     // where to keep the chain, empty keeps it in memory
+	setString("ADMIN_TOKEN", &cfg.AdminToken)               // protects the /admin routes
+	setString("MAINTENANCE_WINDOW", &cfg.MaintenanceWindow) // eg 02:00-04:00
+	setString("DEBUG_ADDR", &cfg.DebugAddr)                 // serve pprof on a separate address
+	setString("AUDIT_LOG", &cfg.AuditLog)                   // defaults to DATA_DIR/audit.log
+	setString("LAN_GROUP", &cfg.LANGroup)                   // multicast group and port for LAN discovery
+	setString("PORT_MAPPING", &cfg.PortMapping)             // auto, upnp or natpmp, ask the router to forward a port
+	setString("NAT_GATEWAY", &cfg.NATGateway)               // the router, for NAT-PMP, defaults to the default gateway
+	setString("DNS_SERVER", &cfg.DNSServer)                 // look DNS seeds up here instead of the system's resolver, eg 1.1.1.1:53
+	setString("SNAPSHOT_DIR", &cfg.SnapshotDir)
+	setString("RESTORE_FROM", &cfg.RestoreFrom)     // start from a snapshot file
+	setString("MINER_ADDRESS", &cfg.MinerAddress)   // where block rewards go, defaults to the node ID
+	setString("WEBHOOK_SECRET", &cfg.WebhookSecret) // signs webhook payloads
+	setString("STRATUM_ADDR", &cfg.StratumAddr)     // eg :3333, where external miners connect
+	setString("LOG_LEVEL", &cfg.LogLevel)
+	setString("SIGNER_URL", &cfg.SignerURL) // sign as a validator with a remote signer rather than the node key
+	setString("SIGNER_TOKEN", &cfg.SignerToken)
+	setString("SIGNER_KEYSTORE", &cfg.SignerKeystore) // or with the key in a keystore file
+	setString("SIGNER_PASSPHRASE", &cfg.SignerPassphrase)
+	setString("WALLET_DIR", &cfg.WalletDir)    // keep wallets for frontends under /wallet
+	setString("ENGINE", &cfg.Params.Engine)    // pow, bft or poa, has to match the rest of the network
+	setString("CHAIN_ID", &cfg.Params.ChainID) // eg mainnet or testnet, has to match the rest of the network
+
+	setList("ALLOWED_PEERS", &cfg.AllowedPeers) // node IDs
+	setList("BANNED_PEERS", &cfg.BannedPeers)
+	setList("WEBHOOKS", &cfg.Webhooks)     // URLs to POST new blocks to
+	setList("PEERS", &cfg.Peers)           // peers to sync with
+	setList("DNS_SEEDS", &cfg.DNSSeeds)    // hostnames to ask for peers when there are none
+	setList("VALIDATORS", &cfg.Validators) // node IDs, on a BFT or PoA network
+
+	setList("CORS_ORIGINS", &cfg.CORSOrigins) // origins browsers may call the API from
+	setList("CORS_METHODS", &cfg.CORSMethods)
+	setList("CORS_HEADERS", &cfg.CORSHeaders)
+
+	if v := os.Getenv("CHECKPOINTS"); v != "" { // eg 1000:ab12...,2000:cd34...
+		checkpoints, err := blockchain.ParseCheckpoints(v)
+		if err != nil {
+			return err
+		}
+		cfg.Checkpoints = cfg.Checkpoints.Merge(checkpoints)
+	}
+	if fraction, err := strconv.ParseFloat(os.Getenv("PRIORITY_FRACTION"), 64); err == nil { // share of each block kept for governance and oracle txs
+		cfg.PriorityFraction = fraction
+	}
+	if count, err := strconv.Atoi(os.Getenv("MEMPOOL_MAX_TXS")); err == nil { // most pending transactions, negative for no limit
+		cfg.MempoolMaxTxs = count
+	}
+	if size, err := strconv.Atoi(os.Getenv("MEMPOOL_MAX_BYTES")); err == nil { // most bytes of pending transactions, negative for no limit
+		cfg.MempoolMaxBytes = size
+	}
+	if v := os.Getenv("MEMPOOL_EVICT"); v != "" { // fee or age, what goes first when the mempool's full
+		cfg.MempoolEvict = v
+	}
+	if bump, err := strconv.Atoi(os.Getenv("RBF_BUMP")); err == nil { // percent more a replacement pays, negative turns replacing off
+		cfg.RBFBump = bump
+	}
+	if age, err := time.ParseDuration(os.Getenv("MAX_BLOCK_AGE")); err == nil { // eg 10m, /readyz fails when the head is older
+		cfg.MaxBlockAge = age
+	}
+	if window, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_WINDOW")); err == nil { // eg 1h, how long Idempotency-Key responses are kept
+		cfg.IdempotencyWindow = window
+	}
+	if size, err := strconv.Atoi(os.Getenv("BLOCK_CACHE_SIZE")); err == nil { // how many encoded blocks the API keeps, negative turns it off
+		cfg.BlockCacheSize = size
+	}
+	if score, err := strconv.Atoi(os.Getenv("BAN_SCORE")); err == nil { // misbehavior score peers are banned at, negative never bans
+		cfg.BanScore = score
+	}
+	if d, err := time.ParseDuration(os.Getenv("BAN_TIME")); err == nil { // eg 24h, how long they're banned for
+		cfg.BanTime = d
+	}
+	if peers, err := strconv.Atoi(os.Getenv("SYNC_PEERS")); err == nil { // how many peers a far-behind node downloads blocks from at once
+		cfg.SyncPeers = peers
+	}
+	if d, err := time.ParseDuration(os.Getenv("SYNC_TIMEOUT")); err == nil { // eg 30s, how long a peer gets to send a batch of blocks
+		cfg.SyncTimeout = d
+	}
+	if light, err := strconv.ParseBool(os.Getenv("LIGHT")); err == nil { // keep just the headers, checking transactions with merkle proofs from peers
+		cfg.Light = light
+	}
+	if profile := os.Getenv("NODE_PROFILE"); profile != "" { // archive, full or pruned, how much history the node keeps
+		cfg.Profile = profile
+	}
+	if interval, err := strconv.Atoi(os.Getenv("STATE_INTERVAL")); err == nil { // blocks between state snapshots, -1 for none
+		cfg.StateInterval = interval
+	}
+	if v := os.Getenv("STATE_CHECKPOINTS"); v != "" { // eg 1000:ab12..., state digests a new pruned node can start from
+		checkpoints, err := blockchain.ParseCheckpoints(v)
+		if err != nil {
+			return err
+		}
+		cfg.StateCheckpoints = cfg.StateCheckpoints.Merge(checkpoints)
+	}
+	if port, err := strconv.Atoi(os.Getenv("SEED_PORT")); err == nil { // the port nodes a DNS seed's A records point at listen on
+		cfg.SeedPort = port
+	}
+	if encrypt, err := strconv.ParseBool(os.Getenv("ENCRYPT_PEERS")); err == nil { // TLS between nodes with their node keys, every node has to set it
+		cfg.EncryptPeers = encrypt
+	}
+	if lan, err := strconv.ParseBool(os.Getenv("LAN_DISCOVERY")); err == nil { // announce the node on the LAN and add nodes found there as peers
+		cfg.LANDiscovery = lan
+	}
+	if mine, err := strconv.ParseBool(os.Getenv("MINE")); err == nil { // mine pending transactions in the background
+		cfg.Mine = mine
+	}
+	if threads, err := strconv.Atoi(os.Getenv("MINE_THREADS")); err == nil { // defaults to the number of CPUs
+		cfg.MineThreads = threads
+	}
+	if difficulty, err := strconv.ParseInt(os.Getenv("STRATUM_DIFFICULTY"), 10, 64); err == nil { // how hard a stratum share is
+		cfg.StratumDifficulty = difficulty
+	}
+	if amount, err := strconv.Atoi(os.Getenv("FAUCET_AMOUNT")); err == nil { // coins the faucet sends, test networks only, 0 turns it off
+		cfg.FaucetAmount = amount
+	}
+	if interval, err := time.ParseDuration(os.Getenv("FAUCET_INTERVAL")); err == nil { // how long an address waits between drips
+		cfg.FaucetInterval = interval
+	}
+	if reward, err := strconv.Atoi(os.Getenv("BLOCK_REWARD")); err == nil { // has to match the rest of the network
+		cfg.Params.BlockReward = reward
+	}
+	if interval, err := strconv.Atoi(os.Getenv("HALVING_INTERVAL")); err == nil { // blocks between reward halvings, has to match the rest of the network
+		cfg.Params.HalvingInterval = interval
+	}
+	if depth, err := strconv.Atoi(os.Getenv("FINALITY_DEPTH")); err == nil { // blocks this deep can't be reorganized away, has to match the rest of the network
+		cfg.Params.FinalityDepth = depth
+	}
+	if blockTime, err := time.ParseDuration(os.Getenv("BLOCK_TIME")); err == nil { // eg 5s, how long validators wait after a block before making the next
+		cfg.BlockTime = blockTime
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("BFT_TIMEOUT")); err == nil { // eg 3s, how long a BFT round waits for votes
+		cfg.BFTTimeout = timeout
+	}
+	if genesis, err := time.Parse(time.RFC3339, os.Getenv("GENESIS_TIME")); err == nil { // so every node of a new network makes the same genesis block
+		cfg.GenesisTime = genesis
+	}
+	if commission, err := strconv.Atoi(os.Getenv("COMMISSION")); err == nil { // percent of block rewards validators keep from their delegators, has to match the rest of the network
+		cfg.Params.Commission = commission
+	}
+	if percent, err := strconv.Atoi(os.Getenv("SLASH_PERCENT")); err == nil { // of a validator's stake slashing burns, 0 turns it off, has to match the rest of the network
+		cfg.Params.SlashPercent = percent
+	}
+	if window, err := strconv.Atoi(os.Getenv("DOWNTIME_WINDOW")); err == nil { // blocks missed turns are counted over, has to match the rest of the network
+		cfg.Params.DowntimeWindow = window
+	}
+	if missed, err := strconv.Atoi(os.Getenv("MAX_MISSED")); err == nil { // turns a validator can miss in the window before it's slashed, has to match the rest of the network
+		cfg.Params.MaxMissed = missed
+	}
+	if window, err := strconv.Atoi(os.Getenv("SIGNAL_WINDOW")); err == nil { // blocks soft fork signals are counted over, has to match the rest of the network
+		cfg.Params.SignalWindow = window
+	}
+	if size, err := strconv.Atoi(os.Getenv("MAX_BLOCK_SIZE")); err == nil { // bytes, 0 for no limit, has to match the rest of the network
+		cfg.Params.MaxBlockSize = size
+	}
+	if cost, err := strconv.Atoi(os.Getenv("MAX_BLOCK_COST")); err == nil { // 0 for no limit, has to match the rest of the network
+		cfg.Params.MaxBlockCost = cost
+	}
+	if difficulty, err := strconv.ParseInt(os.Getenv("DIFFICULTY"), 10, 64); err == nil { // 1 turns proof of work off
+		cfg.Params.Difficulty = difficulty
+	}
+	if target, err := time.ParseDuration(os.Getenv("TARGET_BLOCK_TIME")); err == nil { // eg 30s, retargets the difficulty to keep to it
+		cfg.Params.TargetBlockTime = target
+	}
+	if depth, err := strconv.Atoi(os.Getenv("PRUNE_DEPTH")); err == nil { // only keep the last N block bodies
+		cfg.PruneDepth = depth
+	}
+	if os.Getenv("TLS_CERT") != "" { // turn on mutual TLS for permissioned clusters
+		cfg.TLS = &node.TLSConfig{
+			CertFile: os.Getenv("TLS_CERT"),
+			KeyFile:  os.Getenv("TLS_KEY"),
+			CAFile:   os.Getenv("TLS_CA"),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package events is the node's internal event bus. Layers that react to node activity (the /events
+// stream and its WebSocket, webhooks, gossip) subscribe here instead of being called from each handler, so new
+// integrations can hook in without touching the code that accepts blocks and transactions.
+package events
+
+import (
+	"sync"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// Event ... something that happened on the node
+type Event interface {
+	Name() string // what kind of event it is, also used as the event name on the /events stream
+}
+
+// the event names
+const (
+	NameBlockAdded = "block-added"
+	NameChainReorg = "chain-replaced"
+	NameTxAdmitted = "tx-received"
+	NameTxReplaced = "tx-replaced"
+	NameTxExpired  = "tx-expired"
+)
+
+// where an added block came from
+const (
+	OriginLocal  = "local"  // built by this node
+	OriginGossip = "gossip" // pushed by a peer as it was announced
+	OriginSync   = "sync"   // fetched while catching up with a peer
+)
+
+// BlockAdded ... a block was appended to the chain
+type BlockAdded struct {
+	Block  blockchain.Block
+	Origin string // OriginLocal, OriginGossip or OriginSync
+}
+
+// ChainReorg ... the chain was swapped for a longer one from a peer
+type ChainReorg struct {
+	Head    blockchain.Header // the new head
+	Length  int               // how many blocks the new chain has
+	Fork    int               // height of the last block the old and new chains share
+	Removed int               // how many blocks of the old chain were dropped
+}
+
+// TxAdmitted ... a transaction was admitted to the mempool
+type TxAdmitted struct {
+	Tx blockchain.Transaction
+}
+
+// TxReplaced ... a pending transaction was replaced by one paying a higher fee for the same nonce, which is
+// also published as TxAdmitted
+type TxReplaced struct {
+	Old blockchain.Transaction
+	New blockchain.Transaction
+}
+
+// TxExpired ... a pending transaction was dropped from the mempool because its expiry passed
+type TxExpired struct {
+	Tx blockchain.Transaction
+}
+
+func (BlockAdded) Name() string { return NameBlockAdded }
+func (ChainReorg) Name() string { return NameChainReorg }
+func (TxAdmitted) Name() string { return NameTxAdmitted }
+func (TxReplaced) Name() string { return NameTxReplaced }
+func (TxExpired) Name() string  { return NameTxExpired }
+
+// Bus ... fans published events out to handlers and subscribers
+type Bus struct {
+	mu       sync.RWMutex
+	next     int
+	handlers map[int]handler
+}
+
+// handler is a registered callback and the event names it wants, nil for every event
+type handler struct {
+	fn    func(Event)
+	names map[string]bool
+}
+
+// NewBus returns a bus with nobody listening
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[int]handler)}
+}
+
+// Publish hands an event to every handler that wants it, in the caller's goroutine
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	var fns []func(Event)
+	for _, h := range b.handlers {
+		if h.names == nil || h.names[e.Name()] {
+			fns = append(fns, h.fn)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, fn := range fns { // outside the lock, so handlers can register and cancel handlers
+		fn(e)
+	}
+}
+
+// Handle registers fn to be called with every event with one of the given names, or every event if
+// none are given. fn runs synchronously in the publisher's goroutine, so it has to be quick, and
+// anything slow should be kicked off in a goroutine. It returns a function that unregisters fn.
+func (b *Bus) Handle(fn func(Event), names ...string) (cancel func()) {
+	h := handler{fn: fn}
+	if len(names) > 0 {
+		h.names = make(map[string]bool, len(names))
+		for _, name := range names {
+			h.names[name] = true
+		}
+	}
+
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.handlers[id] = h
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}
+}
+
+// Subscription ... a channel of events, for consumers that run in their own goroutine
+type Subscription struct {
+	C      <-chan Event
+	cancel func()
+}
+
+// Subscribe returns a subscription to events with the given names, or every event if none are given.
+// Events are dropped for subscribers that fall more than buffer events behind, so a slow consumer
+// never holds up the node.
+func (b *Bus) Subscribe(buffer int, names ...string) *Subscription {
+	ch := make(chan Event, buffer)
+	cancel := b.Handle(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	}, names...)
+	return &Subscription{C: ch, cancel: cancel}
+}
+
+// Close stops the subscription, no more events are sent on C
+func (s *Subscription) Close() {
+	s.cancel()
+}
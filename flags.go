@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// cliFlags ... the command line flags for running a node, these win over env vars and the config file
+type cliFlags struct {
+	config    string
+	network   string
+	addr      string
+	db        string
+	peers     string
+	mine      bool
+	logLevel  string
+	debugAddr string
+	set       map[string]bool // flags that were actually given, so defaults don't override anything
+}
+
+// parseFlags parses the node's command line flags
+func parseFlags(args []string) (cliFlags, error) {
+	var f cliFlags
+	fs := flag.NewFlagSet("go-blockchain", flag.ContinueOnError)
+	fs.StringVar(&f.config, "config", os.Getenv("CONFIG"), "path to a YAML config file, defaults to config.yaml if it exists")
+	fs.StringVar(&f.network, "network", os.Getenv("NETWORK"), "mainnet, or testnet to experiment on a network of its own with relaxed rules")
+	fs.StringVar(&f.addr, "addr", "", `address to listen on, eg ":8080", or just a port`)
+	fs.StringVar(&f.db, "db", "", "directory to store the chain in")
+	fs.StringVar(&f.peers, "peers", "", "comma separated base URLs of peers to sync with")
+	fs.BoolVar(&f.mine, "mine", false, "mine pending transactions into blocks in the background")
+	fs.StringVar(&f.logLevel, "log-level", "", "info or debug")
+	fs.StringVar(&f.debugAddr, "debug-addr", "", `serve pprof on this address, eg "127.0.0.1:6060"`)
+	if err := fs.Parse(args); err != nil {
+		return f, err
+	}
+
+	f.set = make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { f.set[fl.Name] = true })
+	return f, nil
+}
+
+// apply overrides cfg with the flags that were given
+func (f cliFlags) apply(cfg *node.Config) {
+	if f.set["addr"] {
+		cfg.Addr = f.addr
+		if !strings.Contains(f.addr, ":") { // just a port, like ADDR
+			cfg.Addr = ":" + f.addr
+		}
+	}
+	if f.set["db"] {
+		cfg.DataDir = f.db
+	}
+	if f.set["peers"] {
+		cfg.Peers = nil
+		if f.peers != "" { // --peers= clears the list
+			cfg.Peers = strings.Split(f.peers, ",")
+		}
+	}
+	if f.set["mine"] {
+		cfg.Mine = f.mine
+	}
+	if f.set["log-level"] {
+		cfg.LogLevel = f.logLevel
+	}
+	if f.set["debug-addr"] {
+		cfg.DebugAddr = f.debugAddr
+	}
+}
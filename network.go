@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// the networks a node can join with --network
+const (
+	networkMain = "mainnet"
+	networkTest = "testnet"
+)
+
+// testnetGenesisTime ... every testnet node makes the same genesis block from it
+var testnetGenesisTime = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// networkDefaults returns the config a network starts from, the config file, env vars and flags go on top
+func networkDefaults(network string) (node.Config, error) {
+	switch network {
+	case "", networkMain:
+		return node.Config{Params: blockchain.DefaultParams}, nil
+	case networkTest:
+		return node.Config{
+			Addr:         ":18080", // so it can run next to a mainnet node
+			Params:       blockchain.TestnetParams,
+			GenesisTime:  testnetGenesisTime,
+			MineInterval: 2 * time.Second,
+			BlockTime:    time.Second,
+			FaucetAmount: 100,
+		}, nil
+	}
+	return node.Config{}, fmt.Errorf("unknown network %q, has to be %s or %s", network, networkMain, networkTest)
+}
+
+// networkDataDir returns where a network keeps its chain: testnet goes in a directory of its own under the
+// data directory, so pointing it at a mainnet node's doesn't mix them up. Empty keeps it in memory either way.
+func networkDataDir(network, dataDir string) string {
+	if network != networkTest || dataDir == "" {
+		return dataDir
+	}
+	return filepath.Join(dataDir, networkTest)
+}
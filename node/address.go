@@ -0,0 +1,53 @@
+package node
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Wherever the API takes an address, in a path, a transaction or a request body, it can be base58check
+// encoded as well as hex or a plain name, see blockchain/address.go. Encoded ones are turned into the hex the
+// chain keys accounts by before anything else happens, and mistyped ones are refused with a 400.
+
+// ErrSignedEncodedAddress is returned for a signed transaction naming an encoded address, it can't be decoded
+// without breaking the signature
+var ErrSignedEncodedAddress = errors.New("a signed transaction has to name its addresses in hex, the form its signature covers")
+
+// parseTxAddresses returns a transaction with its encoded addresses decoded
+func parseTxAddresses(tx blockchain.Transaction) (blockchain.Transaction, error) {
+	from, err := blockchain.ParseAddress(tx.From)
+	if err != nil {
+		return tx, err
+	}
+	to, err := blockchain.ParseAddress(tx.To)
+	if err != nil {
+		return tx, err
+	}
+	if (from != tx.From || to != tx.To) && len(tx.Witness) > 0 {
+		return tx, ErrSignedEncodedAddress
+	}
+	tx.From, tx.To = from, to
+	return tx, nil
+}
+
+// pathAddress returns the :address in a route's path decoded, or responds with a 400 and returns false
+func pathAddress(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (string, bool) {
+	address, err := blockchain.ParseAddress(ps.ByName("address"))
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return address, true
+}
+
+// isAddressError returns if err is one of the reasons an address doesn't parse
+func isAddressError(err error) bool {
+	switch err {
+	case blockchain.ErrBadAddress, blockchain.ErrAddressChecksum, blockchain.ErrAddressVersion, ErrSignedEncodedAddress:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// GetBans handles the admin route to list banned node IDs
+func (n *Node) GetBans(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.BannedPeers())
+}
+
+// PostBan handles the admin route to ban a node by ID, till it's unbanned or for ?for=1h
+func (n *Node) PostBan(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var d time.Duration
+	if v := r.URL.Query().Get("for"); v != "" {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil || d <= 0 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "for has to be a duration, eg 1h")
+			return
+		}
+	}
+	n.BanPeerFor(ps.ByName("id"), d)
+	RespondWithJSON(w, r, http.StatusOK, n.BannedPeers())
+}
+
+// DeleteBan handles the admin route to lift a ban
+func (n *Node) DeleteBan(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	n.UnbanPeer(ps.ByName("id"))
+	RespondWithJSON(w, r, http.StatusOK, n.BannedPeers())
+}
+
+// adminOnly wraps an admin handler so it needs the admin token, if one is configured,
+// sent as "Authorization: Bearer <token>"
+func (n *Node) adminOnly(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if !n.adminAuthorized(r) {
+			RespondWithJSON(w, r, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		setAuditActor(r.Context(), "admin")
+		handle(w, r, ps)
+	}
+}
+
+// adminAuthorized returns if the request carries the admin token, or there isn't one
+func (n *Node) adminAuthorized(r *http.Request) bool {
+	if n.cfg.AdminToken == "" {
+		return true
+	}
+	want := "Bearer " + n.cfg.AdminToken
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) == 1
+}
+
+// tokenRequired is adminOnly for routes that change what the node mines or signs, which are refused outright
+// rather than left open when there's no admin token
+func (n *Node) tokenRequired(handle httprouter.Handle) httprouter.Handle {
+	admin := n.adminOnly(handle)
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if n.cfg.AdminToken == "" {
+			RespondWithJSON(w, r, http.StatusForbidden, "set an admin token to use this route")
+			return
+		}
+		admin(w, r, ps)
+	}
+}
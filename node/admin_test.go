@@ -0,0 +1,66 @@
+package node_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+func TestTokenRequiredRoutes(t *testing.T) {
+	open := blockchaintest.NewNode(t)
+	guarded := blockchaintest.NewNode(t, func(cfg *node.Config) { cfg.AdminToken = "secret" })
+
+	tests := []struct {
+		name string
+		path string
+		body interface{}
+		want int // with the token
+	}{
+		{"mining", "/v1/admin/mining", node.MiningSettings{Threads: 1}, http.StatusOK},
+		{"validators", "/v1/admin/validators", node.ValidatorVoteRequest{Action: "promote"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := open.Do(t, http.MethodPost, tt.path, tt.body, nil); status != http.StatusForbidden {
+				t.Errorf("without an admin token configured: %d, want %d", status, http.StatusForbidden)
+			}
+
+			req, _ := http.NewRequest(http.MethodPost, guarded.URL()+tt.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			res, err := guarded.Client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("without the token: %d, want %d", res.StatusCode, http.StatusUnauthorized)
+			}
+
+			if status := guarded.Do(t, http.MethodPost, tt.path, tt.body, nil); status != tt.want {
+				t.Errorf("with the token: %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostBlockNeedsAdminToken(t *testing.T) {
+	n := blockchaintest.NewNode(t, func(cfg *node.Config) { cfg.AdminToken = "secret" })
+
+	req, _ := http.NewRequest(http.MethodPost, n.URL()+"/v1/", strings.NewReader(`{"Data":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := n.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST / without the token = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+	n.AssertHeight(t, 0)
+
+	n.MineBlock(t, 1) // with it
+	n.AssertHeight(t, 1)
+}
@@ -0,0 +1,459 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxBlockBatch caps how many blocks one request to /blocks can return
+const maxBlockBatch = 500
+
+// maxHeaderBatch caps how many headers one request to /headers?from= can return
+const maxHeaderBatch = 2000
+
+// Message ... to be able to take the request body of the POST req / {"Data":100}
+type Message struct {
+	Data int
+}
+
+// MakeRouter creates all the http routes we'll use to view and post to our blockchain.
+// The API lives under /v1, breaking changes will go under /v2 with /v1 left as it is.
+func (n *Node) MakeRouter() http.Handler {
+	router := httprouter.New()
+	spec := newOpenAPI()
+	spec.invalid = n.penalizeMalformed // bodies are checked before peers' sessions are
+	n.routesV1(apiRoutes{router, "/v1", spec})
+	n.routesV1(apiRoutes{router, "", spec}) // unversioned paths stay aliases of v1, so clients from before versioning keep working
+
+	router.GET("/openapi.json", spec.serve)
+	router.GET("/v1/openapi.json", spec.serve)
+	router.GET("/version", n.GetVersion)
+	router.GET("/healthz", n.GetHealth)
+	router.GET("/readyz", n.GetReady)
+	router.Handler(http.MethodGet, "/explorer/*file", explorerHandler())
+	return router
+}
+
+// routesV1 registers the v1 API
+func (n *Node) routesV1(r apiRoutes) {
+	if n.cfg.Light {
+		n.routesLight(r)
+		return
+	}
+	r.GET("/", n.GetBlockchain)
+	r.POST("/", n.adminOnly(n.WriteBlockchain))
+	r.GET("/headers", n.GetHeaders)
+	r.GET("/head", n.GetHead)
+	r.GET("/finalized", n.GetFinalized)
+	r.GET("/forks", n.GetForks)
+	r.GET("/softforks", n.GetSoftForks)
+	r.GET("/blocks", n.GetBlocks)
+	r.GET("/block/:id", n.GetBlock)
+	r.GET("/block/:id/uncles", n.GetUncles)
+	r.GET("/stale", n.GetStale)
+	r.GET("/bft", n.GetBFT)
+	r.GET("/validators", n.GetValidators)
+	r.GET("/stakes", n.GetStakes)
+	r.GET("/delegations/:address", n.GetDelegations)
+	r.GET("/proposals", n.GetProposals)
+	r.GET("/proposals/:id", n.GetProposal)
+	r.GET("/blocks/at", n.GetBlockAt)
+	r.GET("/proof/:txhash", n.GetProof)
+	r.POST("/tx", n.SubmitTransaction)
+	r.POST("/tx/raw", n.PostRawTx)
+	r.POST("/tx/build", n.PostTxBuild)
+	r.GET("/fees/estimate", n.GetFeeEstimate)
+	r.GET("/mempool", n.GetMempool)
+	r.GET("/mempool/:txhash", n.GetMempoolTx)
+	r.POST("/txs", n.SubmitTransactions)
+	r.POST("/multisig", n.PostMultisig)
+	r.POST("/delegate", n.PostDelegate)
+	r.POST("/undelegate", n.PostUndelegate)
+	r.POST("/faucet/:address", n.PostFaucet)
+	r.POST("/proposals", n.PostParamProposal)
+	r.POST("/proposals/:id/vote", n.PostProposalVote)
+	r.GET("/tokens", n.GetTokens)
+	r.GET("/token/:id/balances", n.GetTokenBalances)
+	r.GET("/asset/:id", n.GetAsset)
+	r.GET("/supply", n.GetSupply)
+	r.GET("/profile", n.GetProfile)
+	r.GET("/state/snapshots", n.GetStateSnapshots)
+	r.GET("/state/proof/:address", n.GetStateProof)
+	r.GET("/state/snapshots/:height", n.GetStateSnapshot)
+	r.GET("/balance/:address", n.GetBalance)
+	r.GET("/address/:address/txs", n.GetAddressTxs)
+	r.GET("/graphql", n.PostGraphQL)
+	r.POST("/graphql", n.PostGraphQL)
+	r.POST("/rpc", n.PostRPC)
+	r.GET("/mining/template", n.GetBlockTemplate)
+	r.POST("/mining/submit", n.PostSolvedBlock)
+	r.GET("/mining/workers", n.GetStratumWorkers)
+	r.GET("/events", n.GetEvents)
+	r.GET("/events/ws", n.GetEventsWebSocket)
+	r.GET("/peers", n.GetPeers)
+	r.POST("/handshake", n.PostHandshake)
+	r.POST("/inv", n.peerOnly(n.PostInventory))
+	r.POST("/gossip/block", n.peerOnly(n.PostGossipBlock))
+	r.POST("/gossip/tx", n.peerOnly(n.PostGossipTx))
+	r.POST("/bft/proposal", n.peerOnly(n.PostProposal))
+	r.POST("/bft/vote", n.peerOnly(n.PostVote))
+
+	r.GET("/admin/maintenance", n.adminOnly(n.GetMaintenance))
+	r.POST("/admin/maintenance/:task", n.adminOnly(n.TriggerMaintenance))
+	r.POST("/admin/snapshot", n.adminOnly(n.PostSnapshot))
+	r.GET("/admin/bans", n.adminOnly(n.GetBans))
+	r.POST("/admin/bans/:id", n.adminOnly(n.PostBan))
+	r.DELETE("/admin/bans/:id", n.adminOnly(n.DeleteBan))
+	r.GET("/admin/scores", n.adminOnly(n.GetScores))
+	r.GET("/admin/nat", n.adminOnly(n.GetNAT))
+	r.POST("/admin/peers", n.adminOnly(n.PostPeers))
+	r.GET("/admin/mempool", n.adminOnly(n.GetMempoolStats))
+	r.DELETE("/admin/mempool/:txhash", n.adminOnly(n.DeleteMempoolTx))
+	r.GET("/admin/webhooks", n.adminOnly(n.GetWebhooks))
+	r.POST("/admin/webhooks", n.adminOnly(n.PostWebhook))
+	r.DELETE("/admin/webhooks", n.adminOnly(n.DeleteWebhook))
+	r.GET("/admin/settings", n.adminOnly(n.GetSettings))
+	r.POST("/admin/settings", n.adminOnly(n.PostSettings))
+	r.GET("/admin/mining", n.adminOnly(n.GetMining))
+	r.GET("/admin/cache", n.adminOnly(n.GetCache))
+	r.GET("/admin/orphans", n.adminOnly(n.GetOrphans))
+	r.POST("/admin/validators", n.tokenRequired(n.PostValidatorVote))
+	r.POST("/admin/mining", n.tokenRequired(n.PostMining))
+
+	r.GET("/wallet", n.adminOnly(n.GetWallets))
+	r.POST("/wallet", n.adminOnly(n.PostWallet))
+	r.GET("/wallet/:name", n.adminOnly(n.GetWallet))
+	r.POST("/wallet/:name/unlock", n.adminOnly(n.PostWalletUnlock))
+	r.POST("/wallet/:name/lock", n.adminOnly(n.PostWalletLock))
+	r.POST("/wallet/:name/addresses", n.adminOnly(n.PostWalletAddress))
+	r.POST("/wallet/:name/sign", n.adminOnly(n.PostWalletSign))
+}
+
+// GetBlockchain handles the route to view the blockchain
+func (n *Node) GetBlockchain(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if respondEncoded(w, r, http.StatusOK, n.chain.Blocks()) { // the client asked for CBOR or protobuf
+		return
+	}
+	if requestedFields(r) != nil { // only send the columns the client asked for
+		RespondWithFields(w, r, http.StatusOK, n.chain.Blocks())
+		return
+	}
+
+	bytes, err := json.MarshalIndent(n.chain.Blocks(), "", " ") // marshal / parse our blockchain slice
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError) // if theres an error, freak out
+		return
+	}
+
+	io.WriteString(w, string(bytes)) // write the blockchain to response
+}
+
+// GetHeaders handles the route to view just the block headers, so light clients can verify the chain without the payloads.
+// With ?from= it's a range of them, like /blocks, for syncing headers first.
+func (n *Node) GetHeaders(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	headers := n.chain.Headers()
+	if f := r.URL.Query().Get("from"); f != "" {
+		from, err := strconv.Atoi(f)
+		if err != nil {
+			RespondWithJSON(w, r, http.StatusBadRequest, "from must be a block index")
+			return
+		}
+		limit := maxHeaderBatch
+		if l := r.URL.Query().Get("limit"); l != "" {
+			if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+				RespondWithJSON(w, r, http.StatusBadRequest, "limit must be a positive number")
+				return
+			}
+		}
+		if limit > maxHeaderBatch {
+			limit = maxHeaderBatch
+		}
+		headers = n.chain.HeaderRange(from, limit)
+	}
+	if !respondEncoded(w, r, http.StatusOK, headers) {
+		RespondWithFields(w, r, http.StatusOK, headers)
+	}
+}
+
+// GetHead handles the route to view the header of the latest block, so peers can tell if they're behind
+func (n *Node) GetHead(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	head := n.chain.Last().Header
+	if !respondEncoded(w, r, http.StatusOK, head) {
+		RespondWithFields(w, r, http.StatusOK, head)
+	}
+}
+
+// GetBlocks handles the route to fetch a batch of blocks, eg /blocks?from=10&limit=100
+func (n *Node) GetBlocks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "from must be a block index")
+		return
+	}
+
+	limit := maxBlockBatch
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+	if limit > maxBlockBatch {
+		limit = maxBlockBatch
+	}
+
+	blocks := n.chain.Range(from, limit)
+	if !respondEncoded(w, r, http.StatusOK, blocks) {
+		RespondWithFields(w, r, http.StatusOK, blocks)
+	}
+}
+
+// Proof ... a merkle branch proving a transaction is in a block
+type Proof struct {
+	TxHash     string                  // hash of the transaction being proved, the merkle leaf
+	BlockIndex int                     // the block the transaction is in
+	BlockHash  string                  // hash of that block's header
+	MerkleRoot string                  // the root the branch leads up to, as committed in the header
+	Branch     []blockchain.ProofStep  // sibling hashes from the leaf up to the root
+	Tx         *blockchain.Transaction `json:",omitempty"` // the transaction itself, so a light node can check it hashes to TxHash
+}
+
+// GetBlockAt handles the route to find the last block made at or before a time, eg /blocks/at?time=2024-06-01T00:00:00Z,
+// or unix nanoseconds
+func (n *Node) GetBlockAt(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	v := r.URL.Query().Get("time")
+	t, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		parsed, perr := time.Parse(time.RFC3339Nano, v)
+		if perr != nil {
+			RespondWithJSON(w, r, http.StatusBadRequest, "time must be RFC 3339 or unix nanoseconds")
+			return
+		}
+		t = parsed.UnixNano()
+	}
+
+	block, ok := n.chain.BlockAt(t)
+	if !ok {
+		RespondWithJSON(w, r, http.StatusNotFound, "the chain starts after that")
+		return
+	}
+	n.respondBlock(w, r, block)
+}
+
+// GetProof handles the route to fetch a merkle proof that a transaction is in the chain
+func (n *Node) GetProof(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	txHash := ps.ByName("txhash")
+
+	record, ok := n.chain.Tx(txHash)
+	blocks := n.chain.Range(record.Height, 1)
+	if !ok || len(blocks) == 0 {
+		RespondWithJSON(w, r, http.StatusNotFound, "transaction not found")
+		return
+	}
+	if record.Tx == nil { // indexed, but its block's body has been pruned so it can't be proved any more
+		n.respondBodyGone(w, r, record.Height)
+		return
+	}
+
+	block := blocks[0]
+	branch, _ := blockchain.MerkleProof(block.Body.Leaves(), record.Index+1) // the first leaf is the block's data
+	RespondWithJSON(w, r, http.StatusOK, Proof{
+		TxHash:     txHash,
+		BlockIndex: block.Index,
+		BlockHash:  block.Hash,
+		MerkleRoot: block.MerkleRoot,
+		Branch:     branch,
+		Tx:         record.Tx,
+	})
+}
+
+// WriteBlockchain handles the route to post to our blockchain
+func (n *Node) WriteBlockchain(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var m Message // message struct for decoding the body
+
+	decoder := json.NewDecoder(r.Body) // decode the request body
+	if err := decoder.Decode(&m); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, r.Body) // return json over http
+		return
+	}
+
+	defer r.Body.Close() // close the request at the end
+
+	if !n.posted.CompareAndSwap(false, true) { // solving uses every core, so one posted block at a time
+		RespondWithJSON(w, r, http.StatusTooManyRequests, ErrMiningBusy.Error())
+		return
+	}
+	defer n.posted.Store(false)
+
+	newBlock, added, err := n.mineBlock(r.Context(), m.Data) // create a new block with the POST data and pending transactions
+	setAuditTarget(r.Context(), newBlock.Hash)
+	if err == ErrStaleBlock || err == ErrNotMined {
+		RespondWithJSON(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil && !added {
+		RespondWithJSON(w, r, http.StatusInternalServerError, m) // send error
+		return
+	}
+	if err != nil { // the block was added but couldn't be stored
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	RespondWithJSON(w, r, http.StatusCreated, newBlock) // return json over http
+}
+
+// SubmitTransaction handles the route to send a transaction to the mempool, to be confirmed in a later block
+func (n *Node) SubmitTransaction(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var tx blockchain.Transaction
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid transaction: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	hash, err := n.submitTx(r.Context(), tx)
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusAccepted, hash)
+}
+
+// submitTx fills in a new transaction's defaults and adds it to the mempool, returning its hash
+func (n *Node) submitTx(ctx context.Context, tx blockchain.Transaction) (string, error) {
+	tx, err := parseTxAddresses(withTxDefaults(tx, n.chain.Params().ChainID))
+	if err != nil {
+		setAuditTarget(ctx, tx.Hash())
+		setAuditRejected(ctx, err.Error())
+		return "", err
+	}
+	return n.submitRawTx(ctx, tx)
+}
+
+// submitRawTx adds a transaction to the mempool as it is, returning its hash
+func (n *Node) submitRawTx(ctx context.Context, tx blockchain.Transaction) (string, error) {
+	setAuditTarget(ctx, tx.Hash())
+
+	if err := n.addTx(tx); err != nil {
+		setAuditRejected(ctx, err.Error())
+		return "", err
+	}
+	return tx.Hash(), nil
+}
+
+// withTxDefaults fills in the class and timestamp of a submitted transaction if they were left out, and the
+// network's chain ID if it isn't signed, signed ones have to have it already
+func withTxDefaults(tx blockchain.Transaction, chainID string) blockchain.Transaction {
+	if tx.Class == "" {
+		tx.Class = blockchain.ClassUser
+	}
+	if tx.Timestamp == 0 {
+		tx.Timestamp = time.Now().UnixNano()
+	}
+	if tx.ChainID == "" && len(tx.Witness) == 0 {
+		tx.ChainID = chainID
+	}
+	return tx
+}
+
+// MultisigRequest ... the body of POST /multisig, eg {"Threshold":2,"PublicKeys":["ab12...","cd34...","ef56..."]}
+type MultisigRequest struct {
+	Threshold  int      // how many signatures are needed
+	PublicKeys []string // hex ed25519 public keys of everyone who can sign
+}
+
+// MultisigAddress ... a multisig address and the script transactions spending from it have to carry
+type MultisigAddress struct {
+	Address string
+	Encoded string // the address base58check encoded, so it can be handed out without typos
+	Script  string
+}
+
+// PostMultisig handles the route to derive an M-of-N multisig address from a set of public keys
+func (n *Node) PostMultisig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req MultisigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	script, err := blockchain.MultisigScript(req.Threshold, req.PublicKeys)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	address := blockchain.ScriptAddress(script)
+	encoded, _ := blockchain.EncodeAddress(blockchain.AddressVersionScript, address)
+	RespondWithJSON(w, r, http.StatusOK, MultisigAddress{Address: address, Encoded: encoded, Script: script})
+}
+
+// TxRejection ... the response when a transaction isn't accepted, Code is stable so clients can match on it
+type TxRejection struct {
+	Code  string // "invalid", "unsigned", "coinbase", "duplicate", "double_spend", "stale_nonce", "insufficient_funds", "replacement_fee", "timelocked", "expired", "too_large", "mempool_full", "wrong_chain" or "bad_address"
+	Error string // human readable reason
+}
+
+// rejectTx responds with the status and code for why a transaction was refused
+func rejectTx(w http.ResponseWriter, r *http.Request, err error) {
+	status, rejection := txRejection(err)
+	RespondWithJSON(w, r, status, rejection)
+}
+
+// txRejection returns the HTTP status and rejection code for why a transaction was refused
+func txRejection(err error) (int, TxRejection) {
+	switch err {
+	case ErrDoubleSpend:
+		return http.StatusConflict, TxRejection{Code: "double_spend", Error: err.Error()}
+	case ErrStaleNonce:
+		return http.StatusConflict, TxRejection{Code: "stale_nonce", Error: err.Error()}
+	case blockchain.ErrInsufficientFunds:
+		return http.StatusConflict, TxRejection{Code: "insufficient_funds", Error: err.Error()}
+	case ErrDuplicateTx:
+		return http.StatusConflict, TxRejection{Code: "duplicate", Error: err.Error()}
+	case ErrTimelocked:
+		return http.StatusBadRequest, TxRejection{Code: "timelocked", Error: err.Error()}
+	case ErrExpired:
+		return http.StatusBadRequest, TxRejection{Code: "expired", Error: err.Error()}
+	case ErrCoinbaseTx:
+		return http.StatusBadRequest, TxRejection{Code: "coinbase", Error: err.Error()}
+	case blockchain.ErrUnsigned:
+		return http.StatusBadRequest, TxRejection{Code: "unsigned", Error: err.Error()}
+	case ErrTxTooLarge:
+		return http.StatusBadRequest, TxRejection{Code: "too_large", Error: err.Error()}
+	case ErrReplacementFee:
+		return http.StatusConflict, TxRejection{Code: "replacement_fee", Error: err.Error()}
+	case ErrMempoolFull:
+		return http.StatusServiceUnavailable, TxRejection{Code: "mempool_full", Error: err.Error()}
+	case blockchain.ErrWrongChain:
+		return http.StatusBadRequest, TxRejection{Code: "wrong_chain", Error: err.Error()}
+	}
+	switch {
+	case isAddressError(err):
+		return http.StatusBadRequest, TxRejection{Code: "bad_address", Error: err.Error()}
+	default: // failed validation
+		return http.StatusBadRequest, TxRejection{Code: "invalid", Error: err.Error()}
+	}
+}
+
+// RespondWithJSON to handle HTTP requests
+func RespondWithJSON(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
+	response, err := json.MarshalIndent(payload, "", "  ") // get the json response
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("HTTP 500: Internal Server Error"))
+		return
+	}
+
+	w.WriteHeader(code) // status code
+	w.Write(response)   // send json over http
+}
@@ -0,0 +1,17 @@
+package node
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// GetAsset handles the route to view a unique asset and who owns it
+func (n *Node) GetAsset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	asset, ok := n.chain.Assets().Asset(ps.ByName("id"))
+	if !ok {
+		RespondWithJSON(w, r, http.StatusNotFound, "asset not found")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, asset)
+}
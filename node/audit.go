@@ -0,0 +1,143 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries a request's ID, callers can pick their own to tie requests to their logs
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID is what a caller's request ID has to look like to be used, anything else gets replaced
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// AuditEntry ... one line of the audit log, written for every request that tries to change something
+type AuditEntry struct {
+	Time      time.Time
+	RequestID string
+	Remote    string // the caller's address
+	Actor     string // "admin", "peer:<node ID>" or "anonymous"
+	Method    string
+	Path      string
+	Status    int
+	Target    string `json:",omitempty"` // hash of the block or transaction submitted
+	Accepted  bool
+	Reason    string `json:",omitempty"` // why it was rejected
+}
+
+// auditLog appends entries to a file as JSON lines, one per entry
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: f}, nil
+}
+
+func (l *auditLog) write(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+func (l *auditLog) Close() error {
+	return l.file.Close()
+}
+
+type auditKey struct{}
+
+// auditEntry returns the audit entry for a request, nil for requests that aren't audited
+func auditEntry(ctx context.Context) *AuditEntry {
+	entry, _ := ctx.Value(auditKey{}).(*AuditEntry)
+	return entry
+}
+
+// RequestID returns the ID of the API request a context belongs to, empty if there isn't one
+func RequestID(ctx context.Context) string {
+	if entry := auditEntry(ctx); entry != nil {
+		return entry.RequestID
+	}
+	return ""
+}
+
+// setAuditActor records who made a request, once they've been authenticated
+func setAuditActor(ctx context.Context, actor string) {
+	if entry := auditEntry(ctx); entry != nil {
+		entry.Actor = actor
+	}
+}
+
+// setAuditTarget records the hash of what a request submitted
+func setAuditTarget(ctx context.Context, target string) {
+	if entry := auditEntry(ctx); entry != nil {
+		entry.Target = target
+	}
+}
+
+// setAuditRejected records why a request was turned down, for rejections that don't come back as an error status
+func setAuditRejected(ctx context.Context, reason string) {
+	if entry := auditEntry(ctx); entry != nil {
+		entry.Reason = reason
+	}
+}
+
+// auditRequests gives every request an ID, returned in the X-Request-ID header, and writes
+// requests that can change something (anything but GET, HEAD and OPTIONS) to the log and the audit log
+func (n *Node) auditRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = randomHex(8)
+		}
+		w.Header().Set(requestIDHeader, id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", id))
+
+		entry := &AuditEntry{Time: time.Now().UTC(), RequestID: id, Remote: r.RemoteAddr, Actor: "anonymous", Method: r.Method, Path: r.URL.Path}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditKey{}, entry)))
+
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			return
+		}
+		entry.Status = rec.status
+		if entry.Reason == "" && rec.status >= 400 {
+			entry.Reason = rec.errorMessage()
+		}
+		entry.Accepted = entry.Reason == "" && rec.status < 400
+
+		n.logger.Printf("[%s] %s %s %d %s", id, r.Method, r.URL.Path, rec.status, entry.Actor)
+		if n.audit != nil {
+			if err := n.audit.write(*entry); err != nil {
+				n.logger.Printf("[%s] writing audit log failed: %v", id, err)
+			}
+		}
+	})
+}
+
+// errorMessage returns the start of an error response, unquoted if it was a JSON string
+func (rec *statusRecorder) errorMessage() string {
+	var msg string
+	if json.Unmarshal(rec.body, &msg) == nil {
+		return msg
+	}
+	return strings.TrimSpace(string(rec.body))
+}
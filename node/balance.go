@@ -0,0 +1,64 @@
+package node
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// AddressBalance ... an address's coin balance as of a height
+type AddressBalance struct {
+	Address       string
+	Balance       int
+	Confirmations int // every block counted has at least this many
+	Height        int // the balance is as of this block
+}
+
+// GetBalance handles the route reporting an address's balance, ?confirmations=6 leaves out the latest 5 blocks
+// and ?height=100 gives it as of block 100, as far back as the node's profile keeps the state
+func (n *Node) GetBalance(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	query := r.URL.Query()
+	if query.Get("confirmations") != "" && query.Get("height") != "" {
+		RespondWithJSON(w, r, http.StatusBadRequest, "ask for confirmations or a height, not both")
+		return
+	}
+	profile := n.history()
+	height := profile.Head
+	if v := query.Get("confirmations"); v != "" {
+		confirmations, err := strconv.Atoi(v)
+		if err != nil || confirmations < 1 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "confirmations must be at least 1")
+			return
+		}
+		if height -= confirmations - 1; height < 0 { // nothing's that deep, and the genesis block holds nothing
+			height = 0
+		}
+	}
+	if v := query.Get("height"); v != "" {
+		var err error
+		if height, err = strconv.Atoi(v); err != nil || height < 0 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "height must be a block index")
+			return
+		}
+		if height > profile.Head {
+			RespondWithJSON(w, r, http.StatusNotFound, "block not found")
+			return
+		}
+	}
+
+	address, ok := pathAddress(w, r, ps)
+	if !ok {
+		return
+	}
+	if height < profile.State {
+		n.respondStateGone(w, r, height)
+		return
+	}
+	balance, err := n.chain.BalanceAt(address, height)
+	if err != nil { // a prune depth that's been raised doesn't bring the bodies back
+		n.respondStateGone(w, r, height)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, AddressBalance{Address: address, Balance: balance, Confirmations: profile.Head - height + 1, Height: height})
+}
@@ -0,0 +1,112 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxTxBatch caps how many transactions one request to /txs can submit
+const maxTxBatch = 1000
+
+// BatchResult ... the response from POST /txs, with a result for each transaction in the order they were sent
+type BatchResult struct {
+	Accepted int // how many went in the mempool
+	Rejected int
+	Results  []TxResult
+}
+
+// TxResult ... what happened to one transaction in a batch, exactly one of Hash and Rejection is set
+type TxResult struct {
+	Hash      string       `json:",omitempty"`
+	Rejection *TxRejection `json:",omitempty"`
+}
+
+// abortedTx is the rejection given to the rest of an atomic batch when one transaction in it is refused
+var abortedTx = TxRejection{Code: "aborted", Error: "another transaction in the batch was rejected"}
+
+// SubmitTransactions handles the route to send a batch of transactions to the mempool. Each one is
+// accepted or rejected on its own, unless ?atomic=true, when either all of them go in or none do.
+func (n *Node) SubmitTransactions(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var txs []blockchain.Transaction
+	if err := json.NewDecoder(r.Body).Decode(&txs); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid transactions: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if len(txs) == 0 || len(txs) > maxTxBatch {
+		RespondWithJSON(w, r, http.StatusBadRequest, fmt.Sprintf("a batch has to have between 1 and %d transactions", maxTxBatch))
+		return
+	}
+	chainID := n.chain.Params().ChainID
+	parseErrs := make([]error, len(txs)) // a mistyped address rejects its transaction
+	for i := range txs {
+		txs[i], parseErrs[i] = parseTxAddresses(withTxDefaults(txs[i], chainID))
+	}
+
+	if r.URL.Query().Get("atomic") == "true" {
+		n.submitAtomic(w, r, txs, parseErrs)
+		return
+	}
+
+	result := BatchResult{Results: make([]TxResult, len(txs))}
+	for i, tx := range txs {
+		err := parseErrs[i]
+		if err == nil {
+			err = n.addTx(tx)
+		}
+		if err != nil {
+			_, rejection := txRejection(err)
+			result.Results[i].Rejection = &rejection
+			result.Rejected++
+			continue
+		}
+		result.Results[i].Hash = tx.Hash()
+		result.Accepted++
+	}
+	if result.Rejected > 0 {
+		setAuditRejected(r.Context(), fmt.Sprintf("%d of %d transactions rejected", result.Rejected, len(txs)))
+	}
+	RespondWithJSON(w, r, http.StatusOK, result) // per item results, even if some were rejected
+}
+
+// submitAtomic adds a whole batch to the mempool or, if any transaction in it is refused, none of it
+func (n *Node) submitAtomic(w http.ResponseWriter, r *http.Request, txs []blockchain.Transaction, parseErrs []error) {
+	reject := func(index int, err error) {
+		status, rejection := txRejection(err)
+		result := BatchResult{Rejected: len(txs), Results: make([]TxResult, len(txs))}
+		for i := range result.Results {
+			result.Results[i].Rejection = &abortedTx
+		}
+		result.Results[index].Rejection = &rejection
+		setAuditRejected(r.Context(), fmt.Sprintf("transaction %d: %v", index, err))
+		RespondWithJSON(w, r, status, result)
+	}
+
+	for i, tx := range txs {
+		err := parseErrs[i]
+		if err == nil {
+			err = n.checkTx(tx)
+		}
+		if err != nil {
+			reject(i, err)
+			return
+		}
+	}
+	if i, err := n.mempool.AddAll(txs); err != nil {
+		reject(i, err)
+		return
+	}
+
+	result := BatchResult{Accepted: len(txs), Results: make([]TxResult, len(txs))}
+	for i, tx := range txs {
+		n.bus.Publish(events.TxAdmitted{Tx: tx})
+		result.Results[i].Hash = tx.Hash()
+	}
+	RespondWithJSON(w, r, http.StatusAccepted, result)
+}
@@ -0,0 +1,527 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// On a BFT network (Params.Engine "bft") blocks aren't mined, a fixed set of validators agree on each one in
+// rounds, like Tendermint. The round's proposer, the validators taking turns, sends everyone a block. Each
+// validator prevotes for it if it's valid, or for nothing if it isn't or doesn't turn up in time. Once more than
+// two thirds prevote for the block they precommit it, locking on to it so they won't prevote for any other
+// block at that height unless more than two thirds do in a later round. More than two thirds of precommits
+// commit the block, it's added with them as its commit and is final. A round that doesn't get there times out
+// and the next proposer has a go, so consensus carries on as long as more than two thirds of the validators are
+// up and honest.
+
+// the steps of a round, what the engine is waiting for
+const (
+	stepNewHeight = "new-height" // the pause after a block before the next one is proposed
+	stepPropose   = "propose"    // the round's proposal
+	stepPrevote   = "prevote"    // more than two thirds of prevotes for one block, or for none
+	stepPrecommit = "precommit"  // more than two thirds of precommits
+)
+
+// Proposal ... a block the round's proposer puts up for a vote, Vote being the proposer's signature on it
+type Proposal struct {
+	Vote  blockchain.Vote
+	Block blockchain.Block
+}
+
+// BFTStatus ... where a validator is in BFT consensus, for /bft
+type BFTStatus struct {
+	Engine     string
+	Validator  bool // if this node is one of the validators
+	Validators []string
+	Height     int // the block being agreed on
+	Round      int
+	Step       string
+	Proposer   string // whose turn it is to propose this round
+	Locked     string // hash of the block this node is locked on at the height, if any
+}
+
+// voteKey ... the votes of one type in one round
+type voteKey struct {
+	typ   string
+	round int
+}
+
+// bftTimeout ... a step running out of time, ignored if the engine has moved on since
+type bftTimeout struct {
+	height, round int
+	step          string
+}
+
+// bftEngine is a node's side of BFT consensus, it only proposes and votes while the node is a validator.
+// Proposals and votes from the validators come in through inbox, and one goroutine runs the rounds.
+type bftEngine struct {
+	n        *Node
+	inbox    chan interface{} // Proposals and Votes
+	timeouts chan bftTimeout
+
+	mu          sync.Mutex // guards the state below, so /bft can read it
+	height      int
+	round       int
+	step        string
+	proposals   map[int]Proposal                       // this height's, by round
+	votes       map[voteKey]map[string]blockchain.Vote // this height's, by validator
+	locked      *blockchain.Block                      // the block precommitted in lockedRound
+	lockedRound int
+	future      []interface{} // the next height's proposals and votes that came in before this height was committed
+}
+
+// maxFutureMessages caps how many of the next height's messages are held
+const maxFutureMessages = 1000
+
+func newBFTEngine(n *Node) *bftEngine {
+	return &bftEngine{n: n, inbox: make(chan interface{}, 1024), timeouts: make(chan bftTimeout, 16)}
+}
+
+// run takes part in consensus until the node shuts down
+func (e *bftEngine) run() {
+	heads := make(chan struct{}, 1)
+	stop := e.n.bus.Handle(func(events.Event) {
+		select {
+		case heads <- struct{}{}:
+		default:
+		}
+	}, events.NameBlockAdded, events.NameChainReorg)
+	defer stop()
+
+	e.mu.Lock()
+	e.newHeight()
+	e.mu.Unlock()
+	for {
+		select {
+		case <-e.n.done:
+			return
+		case <-heads:
+			e.mu.Lock()
+			if e.n.chain.Last().Index >= e.height { // a block came in by gossip or sync before this node committed it
+				e.newHeight()
+			}
+			e.mu.Unlock()
+		case msg := <-e.inbox:
+			e.mu.Lock()
+			e.handle(msg)
+			e.mu.Unlock()
+		case t := <-e.timeouts:
+			e.mu.Lock()
+			if t.height == e.height && t.round == e.round && t.step == e.step {
+				e.timeout()
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+// handle takes a proposal or vote from another validator, e.mu has to be held from here on
+func (e *bftEngine) handle(msg interface{}) {
+	switch msg := msg.(type) {
+	case Proposal:
+		e.onProposal(msg)
+	case blockchain.Vote:
+		e.onVote(msg)
+	}
+}
+
+// newHeight starts on the block after the head, once BlockTime has passed
+func (e *bftEngine) newHeight() {
+	e.height, e.round, e.step = e.n.chain.Last().Index+1, 0, stepNewHeight
+	e.proposals = make(map[int]Proposal)
+	e.votes = make(map[voteKey]map[string]blockchain.Vote)
+	e.locked, e.lockedRound = nil, -1
+	e.schedule(e.n.cfg.BlockTime)
+
+	future := e.future
+	e.future = nil
+	for _, msg := range future {
+		e.handle(msg)
+	}
+}
+
+// startRound moves on to a round, proposing a block if it's this node's turn
+func (e *bftEngine) startRound(round int) {
+	e.round, e.step = round, stepPropose
+	e.schedule(e.roundTimeout())
+	if blockchain.Proposer(e.n.chain.Validators(), e.height, round) == e.n.validatorID() {
+		e.propose()
+	}
+	e.advance()
+}
+
+// roundTimeout returns how long each step of the round waits, longer every round so a slow network catches up
+func (e *bftEngine) roundTimeout() time.Duration {
+	return e.n.cfg.BFTTimeout * time.Duration(e.round+1)
+}
+
+// schedule times out the current step after d
+func (e *bftEngine) schedule(d time.Duration) {
+	t := bftTimeout{height: e.height, round: e.round, step: e.step}
+	time.AfterFunc(d, func() {
+		select {
+		case e.timeouts <- t:
+		case <-e.n.done:
+		}
+	})
+}
+
+// timeout moves on when a step runs out of time
+func (e *bftEngine) timeout() {
+	switch e.step {
+	case stepNewHeight:
+		e.startRound(0)
+	case stepPropose: // nothing usable was proposed in time
+		e.prevote("")
+		e.advance()
+	case stepPrevote: // no agreement on a block
+		e.precommit("")
+		e.advance()
+	case stepPrecommit:
+		e.startRound(e.round + 1)
+	}
+}
+
+// propose sends everyone the block this node is locked on, or a new one of pending transactions
+func (e *bftEngine) propose() {
+	block := e.locked
+	if block == nil {
+		ctx := context.Background()
+		built, err := e.n.buildBlock(ctx, 0, e.n.minerAddress())
+		if err == nil {
+			err = built.Solve(ctx) // at the network's difficulty, normally 1 so it's instant
+		}
+		if err != nil {
+			e.n.logger.Printf("bft: building a block to propose failed: %v", err)
+			return
+		}
+		block = &built
+	}
+
+	vote, err := blockchain.Vote{Type: blockchain.VoteProposal, Height: e.height, Round: e.round, Hash: block.Hash}.SignWith(e.n.signer)
+	if err != nil {
+		e.n.logger.Printf("bft: signing the proposal failed: %v", err)
+		return
+	}
+	p := Proposal{Vote: vote, Block: *block}
+	e.proposals[e.round] = p
+	e.broadcast("/v1/bft/proposal", p)
+}
+
+// onProposal takes a proposal, if it's from the round's proposer
+func (e *bftEngine) onProposal(p Proposal) {
+	v := p.Vote
+	if v.Type != blockchain.VoteProposal || v.Hash != p.Block.Hash || v.Height != p.Block.Index || !v.Verify() {
+		return
+	}
+	if v.Validator != blockchain.Proposer(e.n.chain.Validators(), v.Height, v.Round) {
+		return
+	}
+	if e.early(v.Height, p) {
+		return
+	}
+	if v.Height != e.height {
+		return
+	}
+	if first, ok := e.proposals[v.Round]; ok { // only the first proposal of a round counts, a different one is double signing
+		if evidence, ok := blockchain.DoubleVote(first.Vote, v); ok {
+			e.n.reportDoubleSign(evidence)
+		}
+		return
+	}
+	e.proposals[v.Round] = p
+	e.advance()
+}
+
+// onVote takes a prevote or precommit from a validator
+func (e *bftEngine) onVote(v blockchain.Vote) {
+	if v.Type != blockchain.VotePrevote && v.Type != blockchain.VotePrecommit {
+		return
+	}
+	if !e.n.chain.IsValidator(v.Validator) || !v.Verify() {
+		return
+	}
+	if e.early(v.Height, v) || v.Height != e.height {
+		return
+	}
+	if e.record(v) {
+		e.advance()
+	}
+}
+
+// early holds on to a message for the next height, returning if it was one
+func (e *bftEngine) early(height int, msg interface{}) bool {
+	if height != e.height+1 {
+		return false
+	}
+	if len(e.future) < maxFutureMessages {
+		e.future = append(e.future, msg)
+	}
+	return true
+}
+
+// record adds a vote, returning false if the validator already voted that way in the round. Only the first
+// vote counts, a second one for something else is the validator equivocating.
+func (e *bftEngine) record(v blockchain.Vote) bool {
+	key := voteKey{typ: v.Type, round: v.Round}
+	if e.votes[key] == nil {
+		e.votes[key] = make(map[string]blockchain.Vote)
+	}
+	if first, ok := e.votes[key][v.Validator]; ok { // a different vote in the same round is double signing
+		if evidence, ok := blockchain.DoubleVote(first, v); ok {
+			e.n.reportDoubleSign(evidence)
+		}
+		return false
+	}
+	e.votes[key][v.Validator] = v
+	return true
+}
+
+// cast signs, records and sends this node's vote in the current round
+func (e *bftEngine) cast(typ, hash string) {
+	if !e.n.chain.IsValidator(e.n.validatorID()) { // following along, not voting
+		return
+	}
+	v, err := blockchain.Vote{Type: typ, Height: e.height, Round: e.round, Hash: hash}.SignWith(e.n.signer)
+	if err != nil { // the round times out without it, like any other missing vote
+		e.n.logger.Printf("bft: signing a %s failed: %v", typ, err)
+		return
+	}
+	e.record(v)
+	e.broadcast("/v1/bft/vote", v)
+}
+
+// prevote votes for a block, or none, and waits for the others
+func (e *bftEngine) prevote(hash string) {
+	e.cast(blockchain.VotePrevote, hash)
+	e.step = stepPrevote
+	e.schedule(e.roundTimeout())
+}
+
+// precommit votes to commit a block, locking on to it, or for none, and waits for the others
+func (e *bftEngine) precommit(hash string) {
+	if block, ok := e.blockFor(hash); ok {
+		e.locked, e.lockedRound = &block, e.round
+	} else { // a block this node never got can't be voted for
+		hash = ""
+	}
+	e.cast(blockchain.VotePrecommit, hash)
+	e.step = stepPrecommit
+	e.schedule(e.roundTimeout())
+}
+
+// advance takes whatever steps the proposal and votes in hand allow
+func (e *bftEngine) advance() {
+	if e.step == stepPropose {
+		if p, ok := e.proposals[e.round]; ok {
+			e.prevote(e.prevoteFor(p.Block))
+		}
+	}
+	if e.step == stepPrevote {
+		if hash, ok := e.quorum(blockchain.VotePrevote, e.round); ok {
+			if hash == "" { // more than two thirds want no block this round, so the lock's off
+				e.locked, e.lockedRound = nil, -1
+			}
+			e.precommit(hash)
+		}
+	}
+	if e.tryCommit() {
+		return
+	}
+	e.skipAhead()
+}
+
+// prevoteFor returns the hash to prevote for a proposed block, "" if it's invalid or conflicts with the lock
+func (e *bftEngine) prevoteFor(block blockchain.Block) string {
+	if e.locked != nil && e.locked.Hash != block.Hash && !e.polka(block.Hash, e.lockedRound) {
+		return ""
+	}
+	if !e.n.chain.CanAdd(block) {
+		return ""
+	}
+	return block.Hash
+}
+
+// polka returns if more than two thirds prevoted for a block in a round after the given one and before this one
+func (e *bftEngine) polka(hash string, after int) bool {
+	for round := after + 1; round < e.round; round++ {
+		if got, ok := e.quorum(blockchain.VotePrevote, round); ok && got == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// quorum returns what more than two thirds of the validators voted for in a round, if they agree
+func (e *bftEngine) quorum(typ string, round int) (string, bool) {
+	need := blockchain.Quorum(len(e.n.chain.Validators()))
+	counts := make(map[string]int)
+	for _, v := range e.votes[voteKey{typ: typ, round: round}] {
+		if counts[v.Hash]++; counts[v.Hash] >= need {
+			return v.Hash, true
+		}
+	}
+	return "", false
+}
+
+// blockFor finds a block proposed at this height by its hash
+func (e *bftEngine) blockFor(hash string) (blockchain.Block, bool) {
+	if hash == "" {
+		return blockchain.Block{}, false
+	}
+	if e.locked != nil && e.locked.Hash == hash {
+		return *e.locked, true
+	}
+	for _, p := range e.proposals {
+		if p.Block.Hash == hash {
+			return p.Block, true
+		}
+	}
+	return blockchain.Block{}, false
+}
+
+// tryCommit commits a block once more than two thirds precommit it in any round, returning if it did
+func (e *bftEngine) tryCommit() bool {
+	for key := range e.votes {
+		if key.typ != blockchain.VotePrecommit {
+			continue
+		}
+		hash, ok := e.quorum(key.typ, key.round)
+		if !ok {
+			continue
+		}
+		if block, ok := e.blockFor(hash); ok {
+			e.commit(block, key.round)
+			return true
+		}
+	}
+	return false
+}
+
+// commit adds a block with the precommits for it as its commit, and starts on the next height
+func (e *bftEngine) commit(block blockchain.Block, round int) {
+	commit := &blockchain.Commit{Round: round}
+	for _, v := range e.votes[voteKey{typ: blockchain.VotePrecommit, round: round}] {
+		if v.Hash == block.Hash {
+			commit.Signatures = append(commit.Signatures, blockchain.CommitSig{Validator: v.Validator, Signature: v.Signature})
+		}
+	}
+	sort.Slice(commit.Signatures, func(i, j int) bool { return commit.Signatures[i].Validator < commit.Signatures[j].Validator })
+	block.Commit = commit
+
+	if added, err := e.n.acceptMined(context.Background(), block); err != nil {
+		e.n.logger.Printf("bft: committed block %d couldn't be stored: %v", block.Index, err)
+	} else if added {
+		e.n.logger.Printf("committed block %d in round %d with %d transactions", block.Index, round, len(block.Transactions))
+	}
+	e.newHeight()
+}
+
+// skipAhead jumps to a later round once more than a third of the validators are in it, since at least one
+// honest validator has moved on
+func (e *bftEngine) skipAhead() {
+	ahead := make(map[int]map[string]bool)
+	for key, votes := range e.votes {
+		if key.round <= e.round {
+			continue
+		}
+		if ahead[key.round] == nil {
+			ahead[key.round] = make(map[string]bool)
+		}
+		for validator := range votes {
+			ahead[key.round][validator] = true
+		}
+	}
+
+	skipTo := -1
+	for round, validators := range ahead {
+		if len(validators) > len(e.n.chain.Validators())/3 && round > skipTo {
+			skipTo = round
+		}
+	}
+	if skipTo > 0 {
+		e.startRound(skipTo)
+	}
+}
+
+// broadcast sends a proposal or vote to every peer, validators are expected to be peered with each other
+func (e *bftEngine) broadcast(path string, msg interface{}) {
+	for _, peer := range e.n.peers() {
+		go func(peer string) {
+			if err := postJSON(e.n.client, peer, path, msg, nil); err != nil {
+				e.n.debug("bft: sending to ", peer, " failed: ", err)
+			}
+		}(peer)
+	}
+}
+
+// status returns where the engine is
+func (e *bftEngine) status() BFTStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := BFTStatus{Height: e.height, Round: e.round, Step: e.step, Proposer: blockchain.Proposer(e.n.chain.Validators(), e.height, e.round)}
+	if e.locked != nil {
+		s.Locked = e.locked.Hash
+	}
+	return s
+}
+
+// BFTStatus returns where the node is in BFT consensus
+func (n *Node) BFTStatus() BFTStatus {
+	var s BFTStatus
+	if n.bft != nil {
+		s = n.bft.status()
+	}
+	s.Engine, s.Validator, s.Validators = n.chain.Params().Engine, n.chain.IsValidator(n.validatorID()), n.chain.Validators()
+	return s
+}
+
+// GetBFT handles the route reporting the validators and where this node is in consensus
+func (n *Node) GetBFT(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.BFTStatus())
+}
+
+// PostProposal handles the peer route validators send their proposals to
+func (n *Node) PostProposal(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var p Proposal
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		n.penalize(peerID(r.Context()), "malformed message", penaltyMalformed)
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid proposal: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	n.deliverBFT(w, r, p)
+}
+
+// PostVote handles the peer route validators send their prevotes and precommits to
+func (n *Node) PostVote(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var v blockchain.Vote
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		n.penalize(peerID(r.Context()), "malformed message", penaltyMalformed)
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid vote: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	n.deliverBFT(w, r, v)
+}
+
+// deliverBFT hands a proposal or vote to the engine, which checks it
+func (n *Node) deliverBFT(w http.ResponseWriter, r *http.Request, msg interface{}) {
+	if n.bft == nil {
+		RespondWithJSON(w, r, http.StatusConflict, "this isn't a BFT network")
+		return
+	}
+	select {
+	case n.bft.inbox <- msg:
+		RespondWithJSON(w, r, http.StatusAccepted, "ok")
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,161 @@
+package node
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// selectTransactions picks which pending transactions go in the next block, highest fee rate first.
+// A fraction of the block is reserved for priority classes (governance votes, oracle updates)
+// so user traffic can't crowd them out during congestion. Priority transactions fill their
+// reserved lane first, then everything left over competes for the rest of the block by fee rate.
+// Each sender's transactions end up in nonce order. A lane stops filling at the first transaction that
+// doesn't fit in the space left.
+func selectTransactions(pending []blockchain.Transaction, maxTxs int, space *blockSpace, priorityFraction float64, priority map[blockchain.TxClass]bool) []blockchain.Transaction {
+	reserved := int(float64(maxTxs) * priorityFraction)
+
+	pending = append([]blockchain.Transaction{}, pending...)
+	sort.SliceStable(pending, func(i, j int) bool { // ties keep arrival order
+		return pending[i].FeeRate() > pending[j].FeeRate()
+	})
+
+	selected := make([]blockchain.Transaction, 0, maxTxs)
+	taken := make([]bool, len(pending))
+
+	for i, tx := range pending { // priority lane
+		if len(selected) >= reserved {
+			break
+		}
+		if priority[tx.Class] {
+			if !space.take(tx) {
+				break
+			}
+			selected = append(selected, tx)
+			taken[i] = true
+		}
+	}
+
+	for i, tx := range pending { // general lane, open to everyone
+		if len(selected) >= maxTxs {
+			break
+		}
+		if !taken[i] {
+			if !space.take(tx) {
+				break
+			}
+			selected = append(selected, tx)
+		}
+	}
+
+	blockchain.SortByNonce(selected) // a sender's transactions have to go in nonce order
+	return selected
+}
+
+// blockSpace tracks how much of a block's size and cost limits the transactions picked for it use up
+type blockSpace struct {
+	params     blockchain.Params
+	size, cost int // used so far
+}
+
+// take uses up room for the transaction, returning false without taking any if it doesn't fit
+func (s *blockSpace) take(tx blockchain.Transaction) bool {
+	size, cost := s.size+len(tx.Encode()), s.cost+tx.Cost()
+	if (s.params.MaxBlockSize > 0 && size > s.params.MaxBlockSize) || (s.params.MaxBlockCost > 0 && cost > s.params.MaxBlockCost) {
+		return false
+	}
+	s.size, s.cost = size, cost
+	return true
+}
+
+// buildBlock creates the next block on top of the head with the given data, a coinbase paying
+// the block reward plus collected fees to miner, and as many pending transactions as fit.
+// It's at the difficulty the chain calls for, but the work still has to be done, see Header.Solve.
+func (n *Node) buildBlock(ctx context.Context, data int, miner string) (blockchain.Block, error) {
+	_, span := tracer.Start(ctx, "node.buildBlock", trace.WithAttributes(attribute.Int("mempool.pending", n.mempool.Len())))
+	defer span.End()
+
+	prev := n.chain.Last()
+	var pending []blockchain.Transaction
+	now := time.Now()
+	for _, tx := range n.mempool.Pending() { // only transactions whose timelocks have passed, and that haven't expired
+		if tx.Final(prev.Index+1, now) && !tx.Expired(prev.Index+1, now.Add(time.Second)) { // the block's timestamp is taken a little later
+			pending = append(pending, tx)
+		}
+	}
+
+	params, stakes, validators := n.chain.Params(), n.chain.Stakes(), n.chain.ValidatorSet()
+	active, staked := validators.Active(), stakes.Copy() // as of the head, what stakes are checked against
+	var sealer string
+	var slashes []blockchain.Transaction
+	if params.Engine == blockchain.EngineBFT || params.Engine == blockchain.EnginePoA { // validators share the reward with their delegators
+		sealer = n.validatorID()
+		slashes = n.slashes(staked, active, prev.Index, params) // and punish the ones that misbehaved
+	}
+	placeholders, _ := stakes.StakeRewards(sealer, prev.Index+1, params.Reward(prev.Index+1), params)
+	placeholder := blockchain.NewCoinbase(miner, prev.Index+1, 0) // the amount doesn't change its size or cost
+	made := append(append([]blockchain.Transaction{placeholder}, placeholders...), slashes...)
+	empty, err := blockchain.GenerateBlock(prev, data, made...)
+	if err != nil {
+		return blockchain.Block{}, err
+	}
+	space := &blockSpace{params: params.At(prev.Index + 1), size: empty.Size()}
+	for _, tx := range empty.Transactions {
+		space.cost += tx.Cost()
+	}
+	limit := n.cfg.MaxBlockTxs - len(empty.Transactions) // leave room for the coinbase, stake rewards and slashes
+	selected := selectTransactions(pending, limit, space, n.cfg.PriorityFraction, n.priority)
+
+	txs := selected[:0]
+	ledger, registry, governance := n.chain.Tokens(), n.chain.Assets(), n.chain.Governance()
+	funds, _ := n.chain.Funds()   // nil on a pruned node that's lost what its pruned blocks changed, it can't check
+	for _, tx := range selected { // drop payments, transfers, votes and stakes that pending transactions ahead of them made impossible
+		if (funds == nil || funds.Apply(tx) == nil) && ledger.Apply(tx) == nil && registry.Apply(tx) == nil && validators.Apply(tx, prev.Index+1) == nil && staked.Apply(tx, active, params) == nil &&
+			governance.Apply(tx, prev.Index+1) == nil {
+			txs = append(txs, tx)
+		}
+	}
+	span.SetAttributes(attribute.Int("block.transactions", len(txs)))
+
+	total := params.Reward(prev.Index+1) + blockchain.TotalFees(txs)
+	rewards, paid := stakes.StakeRewards(sealer, prev.Index+1, total, params) // out of the stakes as of the head
+	coinbase := blockchain.NewCoinbase(miner, prev.Index+1, total-paid)
+
+	made = append(append([]blockchain.Transaction{coinbase}, rewards...), slashes...)
+	block, err := blockchain.GenerateBlock(prev, data, append(made, txs...)...)
+	if err != nil {
+		return block, err
+	}
+	block.Difficulty = n.chain.NextDifficulty()
+	block.Sealer = sealer // the hash covers who made it, Solve works it out again
+
+	if _, newest := params.Versions(block.Index); newest < block.Version { // a fork that isn't here yet brings the current one in
+		block.Version = newest
+	}
+	if block.Version >= blockchain.SignalHeaderVersion { // older headers don't hash the signals
+		block.Signals = n.chain.SoftForks().Signals()
+	}
+	if block.Version >= blockchain.ChainHeaderVersion { // or the chain ID
+		block.ChainID = params.ChainID
+	}
+	if block.Version >= blockchain.StateHeaderVersion { // or the state root
+		if block.StateRoot, err = n.chain.StateRootAfter(block); err != nil {
+			return block, err
+		}
+	}
+	return block, nil
+}
+
+// minerAddress returns where this node's block rewards go
+func (n *Node) minerAddress() string {
+	n.settings.mu.RLock()
+	defer n.settings.mu.RUnlock()
+	if n.cfg.MinerAddress != "" {
+		return n.cfg.MinerAddress
+	}
+	return n.ID()
+}
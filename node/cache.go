@@ -0,0 +1,150 @@
+package node
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// defaultBlockCacheSize is how many encoded blocks are kept if BlockCacheSize isn't set
+const defaultBlockCacheSize = 1024
+
+// blockCache ... the blocks the API has sent lately, already encoded, most recently used kept longest.
+// The chain lives in memory, so what a read costs is encoding the block, and the same few blocks, the
+// ones around the head and whatever an explorer is showing, get asked for over and over. A block never
+// changes under its hash except to be pruned, which is part of the key, so entries don't go stale.
+type blockCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[blockCacheKey]*list.Element
+	order    *list.List // of *blockCacheEntry, most recently used at the front
+
+	hits, misses atomic.Int64
+}
+
+// blockCacheKey ... a block as sent one way
+type blockCacheKey struct {
+	hash   string
+	media  string
+	pruned bool
+}
+
+type blockCacheEntry struct {
+	key  blockCacheKey
+	body []byte
+}
+
+// CacheStats ... how well the block cache is doing, for /admin/cache
+type CacheStats struct {
+	Capacity int     // most blocks it holds, each way they're sent counting separately
+	Entries  int     // how many it holds now
+	Hits     int64   // requests answered from it
+	Misses   int64   // requests that had to encode the block
+	HitRate  float64 // hits as a fraction of requests, 0 before any
+}
+
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{capacity: capacity, entries: make(map[blockCacheKey]*list.Element), order: list.New()}
+}
+
+// get returns a cached block, marking it as just used
+func (c *blockCache) get(key blockCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	c.order.MoveToFront(el)
+	return el.Value.(*blockCacheEntry).body, true
+}
+
+// put caches an encoded block, evicting the least recently used one if it's full
+func (c *blockCache) put(key blockCacheKey, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok { // someone else encoded it at the same time
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&blockCacheEntry{key, body})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*blockCacheEntry).key)
+	}
+}
+
+// stats returns the cache's size and hit counts
+func (c *blockCache) stats() CacheStats {
+	c.mu.Lock()
+	stats := CacheStats{Capacity: c.capacity, Entries: c.order.Len(), Hits: c.hits.Load(), Misses: c.misses.Load()}
+	c.mu.Unlock()
+
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// encodeBlock encodes a block as one of the media types the API sends
+func encodeBlock(media string, block blockchain.Block) ([]byte, error) {
+	switch media {
+	case mediaCBOR:
+		return cbor.Marshal(block)
+	case mediaProtobuf:
+		return appendBlock(nil, block), nil
+	case mediaBinary:
+		return block.Encode(), nil
+	}
+	return json.MarshalIndent(block, "", "  ") // as RespondWithJSON would
+}
+
+// respondBlock sends a single block the way the client asked, from the block cache if it's been sent that way lately.
+// Picked fields and errors go the usual way, they're not worth caching.
+func (n *Node) respondBlock(w http.ResponseWriter, r *http.Request, block blockchain.Block) {
+	media, ok := negotiate(r)
+	if !ok || n.blockCache == nil || block.Hash == "" || requestedFields(r) != nil {
+		if !respondEncoded(w, r, http.StatusOK, block) {
+			RespondWithFields(w, r, http.StatusOK, block)
+		}
+		return
+	}
+
+	key := blockCacheKey{hash: block.Hash, media: media, pruned: block.Pruned}
+	body, ok := n.blockCache.get(key)
+	if !ok {
+		var err error
+		if body, err = encodeBlock(media, block); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		n.blockCache.put(key, body)
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if media != mediaJSON {
+		w.Header().Set("Content-Type", media)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// GetCache handles the admin route reporting how the block cache is doing
+func (n *Node) GetCache(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if n.blockCache == nil {
+		RespondWithJSON(w, r, http.StatusOK, CacheStats{})
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, n.blockCache.stats())
+}
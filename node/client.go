@@ -0,0 +1,206 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// FetchBlocks downloads the whole chain from the node at baseURL
+func FetchBlocks(client *http.Client, baseURL string) ([]blockchain.Block, error) {
+	return getBlocks(client, baseURL, "/v1/")
+}
+
+// FetchHead gets the header of the block at the head of the node's chain
+func FetchHead(client *http.Client, baseURL string) (blockchain.Header, error) {
+	var header blockchain.Header
+	err := getJSON(client, baseURL, "/v1/head", &header)
+	return header, err
+}
+
+// FetchBlockRange downloads up to limit blocks starting at index from
+func FetchBlockRange(client *http.Client, baseURL string, from, limit int) ([]blockchain.Block, error) {
+	return getBlocks(client, baseURL, fmt.Sprintf("/v1/blocks?from=%d&limit=%d", from, limit))
+}
+
+// FetchHeaderRange downloads up to limit headers starting at index from
+func FetchHeaderRange(client *http.Client, baseURL string, from, limit int) ([]blockchain.Header, error) {
+	var headers []blockchain.Header
+	err := getJSON(client, baseURL, fmt.Sprintf("/v1/headers?from=%d&limit=%d", from, limit), &headers)
+	return headers, err
+}
+
+// FetchProof gets the merkle proof that a transaction is in a block from the node at baseURL
+func FetchProof(client *http.Client, baseURL, txHash string) (Proof, error) {
+	var proof Proof
+	err := getJSON(client, baseURL, "/v1/proof/"+url.PathEscape(txHash), &proof)
+	return proof, err
+}
+
+// FetchAddressTxs gets an address's latest limit transactions from the node at baseURL
+func FetchAddressTxs(client *http.Client, baseURL, address string, limit int) (AddressHistory, error) {
+	var history AddressHistory
+	err := getJSON(client, baseURL, fmt.Sprintf("/v1/address/%s/txs?limit=%d", url.PathEscape(address), limit), &history)
+	return history, err
+}
+
+// FetchStateProof gets the proof of an address's account as of the block at height from the node at baseURL
+func FetchStateProof(client *http.Client, baseURL, address string, height int) (AccountProof, error) {
+	var proof AccountProof
+	err := getJSON(client, baseURL, fmt.Sprintf("/v1/state/proof/%s?height=%d", url.PathEscape(address), height), &proof)
+	return proof, err
+}
+
+// FetchStateSnapshot gets the state snapshot as of the block at height from the node at baseURL
+func FetchStateSnapshot(client *http.Client, baseURL string, height int) (StateSnapshot, error) {
+	var snapshot StateSnapshot
+	err := getJSON(client, baseURL, fmt.Sprintf("/v1/state/snapshots/%d", height), &snapshot)
+	return snapshot, err
+}
+
+// FetchBlock gets a block by hash from the node at baseURL
+func FetchBlock(client *http.Client, baseURL, hash string) (blockchain.Block, error) {
+	path := "/v1/block/" + hash
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(baseURL, "/")+path, nil)
+	if err != nil {
+		return blockchain.Block{}, err
+	}
+	req.Header.Set("Accept", mediaBinary+", "+mediaJSON+";q=0.5")
+	res, err := client.Do(req)
+	if err != nil {
+		return blockchain.Block{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return blockchain.Block{}, fmt.Errorf("fetching %s from %s: %s", path, baseURL, res.Status)
+	}
+
+	var block blockchain.Block
+	if media, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); media == mediaBinary {
+		data, err := io.ReadAll(res.Body)
+		if err == nil {
+			block, err = blockchain.DecodeBlock(data)
+		}
+		if err != nil {
+			return blockchain.Block{}, fmt.Errorf("decoding %s from %s: %v", path, baseURL, err)
+		}
+		return block, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(&block); err != nil {
+		return blockchain.Block{}, fmt.Errorf("decoding %s from %s: %v", path, baseURL, err)
+	}
+	return block, nil
+}
+
+// FetchNonce gets the nonce of an address's last confirmed transaction, 0 if it hasn't sent one
+func FetchNonce(client *http.Client, baseURL, address string) (int, error) {
+	var res struct {
+		Data struct {
+			Account struct {
+				Nonce *int `json:"nonce"`
+			} `json:"account"`
+		} `json:"data"`
+		Errors []GraphQLError `json:"errors"`
+	}
+	query := fmt.Sprintf("{account(address: %q) {nonce}}", address)
+	if err := getJSON(client, baseURL, "/v1/graphql?query="+url.QueryEscape(query), &res); err != nil {
+		return 0, err
+	}
+	if len(res.Errors) > 0 {
+		return 0, fmt.Errorf("looking up the nonce of %s on %s: %s", address, baseURL, res.Errors[0].Message)
+	}
+	if res.Data.Account.Nonce == nil {
+		return 0, nil
+	}
+	return *res.Data.Account.Nonce, nil
+}
+
+// SubmitRawTx sends a signed transaction, hex of its canonical encoding, to the node's mempool and returns its hash
+func SubmitRawTx(client *http.Client, baseURL, raw string) (string, error) {
+	body, err := json.Marshal(RawTx{Hex: raw})
+	if err != nil {
+		return "", err
+	}
+	res, err := client.Post(strings.TrimRight(baseURL, "/")+"/v1/tx/raw", mediaJSON, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		data, _ := io.ReadAll(res.Body)
+		var rejection TxRejection
+		var reason string // the node's reason, a TxRejection if the transaction decoded, a string if it didn't
+		if json.Unmarshal(data, &rejection) == nil && rejection.Code != "" {
+			reason = rejection.Code + ", " + rejection.Error
+		} else if json.Unmarshal(data, &reason) != nil {
+			reason = res.Status
+		}
+		return "", fmt.Errorf("sending a transaction to %s: %s", baseURL, reason)
+	}
+	var hash string
+	if err := json.NewDecoder(res.Body).Decode(&hash); err != nil {
+		return "", fmt.Errorf("decoding the hash from %s: %v", baseURL, err)
+	}
+	return hash, nil
+}
+
+// getBlocks fetches a list of blocks, in the canonical encoding if the node serves it, JSON if not
+func getBlocks(client *http.Client, baseURL, path string) ([]blockchain.Block, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(baseURL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", mediaBinary+", "+mediaJSON+";q=0.5")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s from %s: %s", path, baseURL, res.Status)
+	}
+
+	var blocks []blockchain.Block
+	if media, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); media == mediaBinary {
+		data, err := io.ReadAll(res.Body)
+		if err == nil {
+			blocks, err = blockchain.DecodeBlocks(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s from %s: %v", path, baseURL, err)
+		}
+		return blocks, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(&blocks); err != nil {
+		return nil, fmt.Errorf("decoding %s from %s: %v", path, baseURL, err)
+	}
+	return blocks, nil
+}
+
+// getJSON fetches path from the node at baseURL and decodes the JSON response into out
+func getJSON(client *http.Client, baseURL, path string, out interface{}) error {
+	res, err := client.Get(strings.TrimRight(baseURL, "/") + path)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s from %s: %s", path, baseURL, res.Status)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s from %s: %v", path, baseURL, err)
+	}
+	return nil
+}
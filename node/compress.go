@@ -0,0 +1,178 @@
+package node
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the smallest response worth compressing, anything shorter is sent as it is
+const minCompressSize = 1024
+
+// pools of compressors, so every response doesn't allocate a new one
+var (
+	gzipWriters  = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	flateWriters = sync.Pool{New: func() interface{} { w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression); return w }}
+)
+
+// compressor ... a gzip or flate writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressResponses gzips or deflates responses for clients that send Accept-Encoding, once they
+// pass minCompressSize. Streams that flush before then, like /events, go out uncompressed, and upgrades to a
+// WebSocket aren't touched.
+func compressResponses(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding picks gzip or deflate from an Accept-Encoding header, preferring gzip, or "" for neither
+func acceptedEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		coding, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if coding == "*" {
+			coding = "gzip"
+		}
+		if (coding == "gzip" || coding == "deflate") && (q > bestQ || (q == bestQ && coding == "gzip")) {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressWriter holds back the start of a response until it knows if it's big enough to compress
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool       // the header has been sent, compressed or not
+	z        compressor // nil if the response isn't being compressed
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < minCompressSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.z != nil {
+		return cw.z.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide sends the header, compressing the rest of the response if big says it's worth it and
+// the response can be compressed, then writes what's been held back
+func (cw *compressWriter) decide(big bool) error {
+	cw.decided = true
+	header := cw.Header()
+	if big && compressible(cw.status, header) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		if cw.encoding == "gzip" {
+			cw.z = gzipWriters.Get().(compressor)
+		} else {
+			cw.z = flateWriters.Get().(compressor)
+		}
+		cw.z.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.z != nil {
+		_, err := cw.z.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// compressible returns if a response can be compressed: it has a body, isn't already encoded and isn't
+// a format that's compressed anyway
+func compressible(status int, header http.Header) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified || header.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := header.Get("Content-Type")
+	return !strings.HasPrefix(ct, "image/") && !strings.Contains(ct, "zip")
+}
+
+// Flush sends what's been written so far, uncompressed if the response hasn't reached minCompressSize
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.z != nil {
+		cw.z.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the real writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close finishes the response, sending short ones as they are
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.z == nil {
+		return
+	}
+	cw.z.Close()
+	cw.z.Reset(io.Discard) // don't hold on to the response writer while pooled
+	if cw.encoding == "gzip" {
+		gzipWriters.Put(cw.z)
+	} else {
+		flateWriters.Put(cw.z)
+	}
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressResponses(t *testing.T) {
+	big := strings.Repeat("block ", minCompressSize)
+	small := "block"
+
+	tests := []struct {
+		name     string
+		method   string
+		accept   string
+		body     string
+		encoding string // Content-Encoding, "" for none
+	}{
+		{"gzip", http.MethodGet, "gzip", big, "gzip"},
+		{"deflate", http.MethodGet, "deflate", big, "deflate"},
+		{"gzip preferred", http.MethodGet, "deflate, gzip", big, "gzip"},
+		{"higher q wins", http.MethodGet, "gzip;q=0.5, deflate", big, "deflate"},
+		{"gzip refused", http.MethodGet, "gzip;q=0, deflate;q=0.1", big, "deflate"},
+		{"anything", http.MethodGet, "*", big, "gzip"},
+		{"nothing accepted", http.MethodGet, "", big, ""},
+		{"unknown encoding", http.MethodGet, "br", big, ""},
+		{"too small", http.MethodGet, "gzip", small, ""},
+		{"HEAD", http.MethodHead, "gzip", big, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := compressResponses(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				io.WriteString(w, tt.body)
+			}))
+			r := httptest.NewRequest(tt.method, "/blocks", nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			var body io.Reader = w.Body
+			switch tt.encoding {
+			case "gzip":
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			case "deflate":
+				body = flate.NewReader(w.Body)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.method != http.MethodHead && string(got) != tt.body {
+				t.Errorf("body is %d bytes, want the %d written", len(got), len(tt.body))
+			}
+		})
+	}
+}
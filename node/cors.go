@@ -0,0 +1,52 @@
+package node
+
+import (
+	"net/http"
+	"strings"
+)
+
+// the CORS defaults, when CORSOrigins is set but the methods and headers aren't
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", requestIDHeader, idempotencyHeader}
+)
+
+// cors lets browsers on the allowed origins call the API, answering preflight requests itself.
+// Requests from other origins are served as usual, browsers just won't let the page read the response.
+func (n *Node) cors(h http.Handler) http.Handler {
+	if len(n.cfg.CORSOrigins) == 0 {
+		return h
+	}
+	methods := strings.Join(n.cfg.CORSMethods, ", ")
+	headers := strings.Join(n.cfg.CORSHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !n.corsAllowed(origin) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", requestIDHeader)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" { // preflight
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// corsAllowed returns if an origin is in CORSOrigins, "*" allowing any
+func (n *Node) corsAllowed(origin string) bool {
+	for _, allowed := range n.cfg.CORSOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	methods := []string{http.MethodGet, http.MethodPost}
+	explorer := &Node{cfg: Config{CORSOrigins: []string{"https://explorer.example.com"}, CORSMethods: methods}}
+	anyone := &Node{cfg: Config{CORSOrigins: []string{"*"}, CORSMethods: methods}}
+	off := &Node{}
+
+	tests := []struct {
+		name        string
+		n           *Node
+		method      string
+		origin      string
+		preflight   bool
+		wantOrigin  string // Access-Control-Allow-Origin
+		wantMethods string // Access-Control-Allow-Methods
+		wantServed  bool   // the request reached the API
+	}{
+		{"same origin", explorer, http.MethodGet, "", false, "", "", true},
+		{"allowed origin", explorer, http.MethodGet, "https://explorer.example.com", false, "https://explorer.example.com", "", true},
+		{"allowed origin in another case", explorer, http.MethodPost, "https://Explorer.Example.com", false, "https://Explorer.Example.com", "", true},
+		{"other origin", explorer, http.MethodGet, "https://evil.example.com", false, "", "", true},
+		{"preflight", explorer, http.MethodOptions, "https://explorer.example.com", true, "https://explorer.example.com", "GET, POST", false},
+		{"preflight from another origin", explorer, http.MethodOptions, "https://evil.example.com", true, "", "", true},
+		{"any origin", anyone, http.MethodGet, "https://evil.example.com", false, "https://evil.example.com", "", true},
+		{"off", off, http.MethodGet, "https://explorer.example.com", false, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served := false
+			h := tt.n.cors(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { served = true }))
+			r := httptest.NewRequest(tt.method, "/blocks", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			origin, methods := w.Header().Get("Access-Control-Allow-Origin"), w.Header().Get("Access-Control-Allow-Methods")
+			if origin != tt.wantOrigin || methods != tt.wantMethods || served != tt.wantServed {
+				t.Errorf("got origin %q, methods %q, served %v, want %q, %q, %v", origin, methods, served, tt.wantOrigin, tt.wantMethods, tt.wantServed)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package node
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/glensargent/go-blockchain/blockchain"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the media types chain data can be sent as, picked from the request's Accept header
+const (
+	mediaJSON     = "application/json"
+	mediaCBOR     = "application/cbor"
+	mediaProtobuf = "application/x-protobuf"      // messages are laid out in chain.proto
+	mediaBinary   = "application/x-go-blockchain" // the canonical block encoding, see blockchain/encoding.go, what nodes use between themselves
+)
+
+// negotiate picks the media type to respond with from the Accept header, JSON if the client didn't say.
+// It returns false if the client won't take any of them.
+func negotiate(r *http.Request) (string, bool) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return mediaJSON, true
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		media, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch media {
+		case "*/*", "application/*":
+			media = mediaJSON
+		case "application/protobuf":
+			media = mediaProtobuf
+		case mediaJSON, mediaCBOR, mediaProtobuf, mediaBinary:
+		default:
+			continue
+		}
+		if q > bestQ { // ties go to whichever was listed first
+			best, bestQ = media, q
+		}
+	}
+	return best, best != ""
+}
+
+// respondEncoded sends chain data as CBOR, protobuf or the canonical encoding if the client asked for one,
+// returning false if it should get JSON instead. Protobuf covers blocks and headers, and lists of them,
+// the canonical encoding covers blocks and lists of blocks.
+func respondEncoded(w http.ResponseWriter, r *http.Request, code int, payload interface{}) bool {
+	w.Header().Add("Vary", "Accept")
+	media, ok := negotiate(r)
+	if !ok {
+		RespondWithJSON(w, r, http.StatusNotAcceptable, "this can be sent as "+mediaJSON+", "+mediaCBOR+", "+mediaProtobuf+" or "+mediaBinary)
+		return true
+	}
+
+	var body []byte
+	switch media {
+	case mediaJSON:
+		return false
+
+	case mediaCBOR:
+		if fields := requestedFields(r); fields != nil {
+			picked, err := selectFields(payload, fields)
+			if err != nil {
+				RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+				return true
+			}
+			payload = picked
+		}
+		var err error
+		if body, err = cbor.Marshal(payload); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return true
+		}
+
+	case mediaProtobuf, mediaBinary:
+		if requestedFields(r) != nil {
+			RespondWithJSON(w, r, http.StatusBadRequest, "fields can't be picked from binary responses")
+			return true
+		}
+		if media == mediaProtobuf {
+			body = appendProto(nil, payload)
+			break
+		}
+		switch v := payload.(type) {
+		case blockchain.Block:
+			body = v.Encode()
+		case []blockchain.Block:
+			body = blockchain.EncodeBlocks(v)
+		default:
+			RespondWithJSON(w, r, http.StatusNotAcceptable, "only blocks can be sent as "+mediaBinary)
+			return true
+		}
+	}
+
+	w.Header().Set("Content-Type", media)
+	w.WriteHeader(code)
+	w.Write(body)
+	return true
+}
+
+// appendProto encodes a block, header or list of either as the matching message in chain.proto
+func appendProto(b []byte, payload interface{}) []byte {
+	switch v := payload.(type) {
+	case blockchain.Header:
+		return appendHeader(b, v)
+	case blockchain.Block:
+		return appendBlock(b, v)
+	case []blockchain.Header: // Headers
+		for _, h := range v {
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendBytes(b, appendHeader(nil, h))
+		}
+	case []blockchain.Block: // Blocks
+		for _, block := range v {
+			b = protowire.AppendTag(b, 1, protowire.BytesType)
+			b = protowire.AppendBytes(b, appendBlock(nil, block))
+		}
+	}
+	return b
+}
+
+func appendHeader(b []byte, h blockchain.Header) []byte {
+	b = appendInt(b, 1, int64(h.Index))
+	b = appendString(b, 3, h.Hash)
+	b = appendString(b, 4, h.PrevHash)
+	b = appendString(b, 5, h.MerkleRoot)
+	b = appendInt(b, 6, int64(h.Version))
+	b = appendInt(b, 7, h.Timestamp)
+	b = appendInt(b, 8, h.Difficulty)
+	b = appendInt(b, 9, h.Nonce)
+	b = appendString(b, 10, h.Bloom)
+	b = appendString(b, 11, h.Sealer)
+	b = appendString(b, 12, h.Seal)
+	b = appendInt(b, 13, int64(h.Signals))
+	b = appendString(b, 14, h.ChainID)
+	return appendString(b, 15, h.StateRoot)
+}
+
+func appendBlock(b []byte, block blockchain.Block) []byte {
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, appendHeader(nil, block.Header))
+	b = appendInt(b, 2, int64(block.Data))
+	for _, tx := range block.Transactions {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendTx(nil, tx))
+	}
+	if block.Pruned {
+		b = appendInt(b, 4, 1)
+	}
+	if block.Commit != nil {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendCommit(nil, *block.Commit))
+	}
+	return b
+}
+
+func appendCommit(b []byte, commit blockchain.Commit) []byte {
+	b = appendInt(b, 1, int64(commit.Round))
+	for _, sig := range commit.Signatures {
+		var s []byte
+		s = appendString(s, 1, sig.Validator)
+		s = appendString(s, 2, sig.Signature)
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, s)
+	}
+	return b
+}
+
+func appendTx(b []byte, tx blockchain.Transaction) []byte {
+	b = appendString(b, 1, string(tx.Class))
+	b = appendString(b, 2, tx.From)
+	b = appendString(b, 3, tx.To)
+	b = appendInt(b, 4, int64(tx.Amount))
+	b = appendInt(b, 5, int64(tx.Fee))
+	b = appendInt(b, 6, int64(tx.Nonce))
+	b = appendString(b, 7, tx.Payload)
+	b = appendInt(b, 8, tx.Timestamp)
+	b = appendInt(b, 9, tx.LockTime)
+	b = appendString(b, 10, tx.Script)
+	for _, w := range tx.Witness {
+		b = protowire.AppendTag(b, 11, protowire.BytesType)
+		b = protowire.AppendString(b, w)
+	}
+	b = appendString(b, 12, tx.ChainID)
+	return appendInt(b, 13, tx.Expiry)
+}
+
+// appendInt writes an int64 or bool field, leaving it out if it's zero like proto3 does
+func appendInt(b []byte, field protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, field, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendString writes a string field, leaving it out if it's empty
+func appendString(b []byte, field protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
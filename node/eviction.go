@@ -0,0 +1,139 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The mempool is capped by how many transactions it holds and their size added up, so a burst of spam can't
+// run the node out of memory. When a transaction won't fit, room is made by evicting others: with the fee
+// policy the ones paying the lowest fee rate, newest first among equals, and only ones paying less than the
+// newcomer, so spam can't push out transactions worth more than it. Otherwise it's refused with
+// ErrMempoolFull. With the age policy the oldest go first, whatever they pay. An evicted transaction isn't
+// remembered, it can be sent again once there's room.
+
+// how the mempool makes room
+const (
+	EvictFee = "fee" // lowest fee rate first, the default
+	EvictAge = "age" // oldest first
+)
+
+// mempool limit defaults
+const (
+	defaultMempoolMaxTxs   = 50000
+	defaultMempoolMaxBytes = 64 << 20
+)
+
+// mempoolLimits ... how full the mempool gets, 0 for no limit
+type mempoolLimits struct {
+	maxTxs, maxBytes int
+	policy           string
+}
+
+// evictionStats ... what the limits have turned away, counted since the node started
+type evictionStats struct {
+	evicted, evictedBytes, refused int64
+}
+
+// MempoolStats ... how full the mempool is and what its limits have done, for /admin/mempool
+type MempoolStats struct {
+	Count        int
+	Bytes        int
+	MaxTxs       int    // 0 for no limit
+	MaxBytes     int    // 0 for no limit
+	Evict        string // EvictFee or EvictAge
+	Evicted      int64  // transactions pushed out to make room
+	EvictedBytes int64
+	Refused      int64 // transactions turned away because nothing cheaper could be pushed out
+}
+
+// ValidEvictPolicy returns an error unless policy is one the mempool knows
+func ValidEvictPolicy(policy string) error {
+	switch policy {
+	case EvictFee, EvictAge:
+		return nil
+	}
+	return fmt.Errorf("mempool eviction policy has to be %s or %s, not %q", EvictFee, EvictAge, policy)
+}
+
+// SetLimits caps the mempool, evicting by policy from then on, 0 or less for no limit. It doesn't evict
+// anything until the next transaction arrives.
+func (m *Mempool) SetLimits(maxTxs, maxBytes int, policy string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if maxTxs < 0 {
+		maxTxs = 0
+	}
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	m.limits = mempoolLimits{maxTxs: maxTxs, maxBytes: maxBytes, policy: policy}
+}
+
+// Stats returns how full the pool is and what its limits have done
+func (m *Mempool) Stats() MempoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MempoolStats{
+		Count: len(m.txs), Bytes: m.bytes,
+		MaxTxs: m.limits.maxTxs, MaxBytes: m.limits.maxBytes, Evict: m.limits.policy,
+		Evicted: m.stats.evicted, EvictedBytes: m.stats.evictedBytes, Refused: m.stats.refused,
+	}
+}
+
+// over returns if count more transactions of size bytes in all would take the pool past its limits, m.mu has
+// to be held
+func (m *Mempool) over(txs, bytes, count, size int) bool {
+	return (m.limits.maxTxs > 0 && txs+count > m.limits.maxTxs) || (m.limits.maxBytes > 0 && bytes+size > m.limits.maxBytes)
+}
+
+// makeRoom evicts transactions until count more, of size bytes in all and paying at least rate, fit. If they
+// can't, nothing's evicted and it returns ErrMempoolFull. m.mu has to be held.
+func (m *Mempool) makeRoom(count, size int, rate float64) error {
+	if !m.over(len(m.txs), m.bytes, count, size) {
+		return nil
+	}
+	if m.over(0, 0, count, size) { // wouldn't fit in an empty pool
+		m.stats.refused += int64(count)
+		return ErrMempoolFull
+	}
+
+	order := make([]int, len(m.txs)) // indexes of the transactions, in the order they go
+	for i := range order {
+		order[i] = i
+	}
+	if m.limits.policy != EvictAge { // they're already oldest first
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := m.meta[order[i]].rate, m.meta[order[j]].rate
+			return a < b || (a == b && order[i] > order[j])
+		})
+	}
+
+	evict := make(map[int]bool)
+	txs, bytes := len(m.txs), m.bytes
+	for _, i := range order {
+		if !m.over(txs, bytes, count, size) {
+			break
+		}
+		if m.limits.policy != EvictAge && m.meta[i].rate >= rate {
+			m.stats.refused += int64(count)
+			return ErrMempoolFull
+		}
+		evict[i] = true
+		txs, bytes = txs-1, bytes-m.meta[i].size
+	}
+
+	for _, meta := range m.drop(func(i int) bool { return evict[i] }) {
+		m.stats.evicted++
+		m.stats.evictedBytes += int64(meta.size)
+	}
+	return nil
+}
+
+// GetMempoolStats handles the admin route reporting how full the mempool is and what's been evicted
+func (n *Node) GetMempoolStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.mempool.Stats())
+}
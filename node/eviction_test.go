@@ -0,0 +1,73 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// feeTx returns a transfer from the i'th test sender paying fee, they're all the same size
+func feeTx(i, fee int) blockchain.Transaction {
+	return blockchain.Transaction{Class: blockchain.ClassUser, From: fmt.Sprintf("sender%02d", i), To: "bob", Amount: 1, Nonce: 1, Fee: fee}
+}
+
+// pendingFees returns the fees of the transactions in the pool, in its order
+func pendingFees(m *Mempool) []int {
+	var fees []int
+	for _, tx := range m.Pending() {
+		fees = append(fees, tx.Fee)
+	}
+	return fees
+}
+
+func TestMempoolEviction(t *testing.T) {
+	size := feeTx(0, 0).Size()
+
+	tests := []struct {
+		name     string
+		fees     []int // of what's pending, oldest first
+		maxTxs   int
+		maxBytes int
+		policy   string
+		fee      int // the newcomer's
+		wantErr  error
+		want     []int // the fees pending after
+	}{
+		{"room to spare", []int{1, 2}, 3, 0, EvictFee, 0, nil, []int{1, 2, 0}},
+		{"no limits", []int{1, 2, 3}, 0, 0, EvictFee, 0, nil, []int{1, 2, 3, 0}},
+		{"pays more than the cheapest", []int{2, 1, 3}, 3, 0, EvictFee, 5, nil, []int{2, 3, 5}},
+		{"pays the same as the cheapest", []int{2, 1, 3}, 3, 0, EvictFee, 1, ErrMempoolFull, []int{2, 1, 3}},
+		{"cheapest, newest goes first", []int{1, 2, 1}, 3, 0, EvictFee, 4, nil, []int{1, 2, 4}},
+		{"full by bytes", []int{2, 1, 3}, 0, 3 * size, EvictFee, 5, nil, []int{2, 3, 5}},
+		{"bigger than the whole pool", nil, 0, size - 1, EvictFee, 5, ErrMempoolFull, nil},
+		{"oldest goes whatever it pays", []int{3, 1, 2}, 3, 0, EvictAge, 0, nil, []int{1, 2, 0}},
+		{"oldest by bytes", []int{3, 1, 2}, 0, 3 * size, EvictAge, 0, nil, []int{1, 2, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMempool()
+			for i, fee := range tt.fees {
+				if err := m.Add(feeTx(i, fee)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m.SetLimits(tt.maxTxs, tt.maxBytes, tt.policy)
+
+			if err := m.Add(feeTx(len(tt.fees), tt.fee)); err != tt.wantErr {
+				t.Errorf("Add() = %v, want %v", err, tt.wantErr)
+			}
+			if got := pendingFees(m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pending fees = %v, want %v", got, tt.want)
+			}
+			stats := m.Stats()
+			if evicted := len(tt.fees) + 1 - len(tt.want); tt.wantErr == nil && stats.Evicted != int64(evicted) {
+				t.Errorf("Stats().Evicted = %d, want %d", stats.Evicted, evicted)
+			}
+			if tt.wantErr != nil && stats.Refused != 1 {
+				t.Errorf("Stats().Refused = %d, want 1", stats.Refused)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package node
+
+import (
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+)
+
+// A transaction can carry an Expiry, the last height it can go in a block at, or unix time like LockTime, so
+// one that's been stuck in mempools doesn't confirm long after its sender gave up on it. Nodes refuse ones
+// that couldn't go in the next block, miners leave them out and blocks with one are invalid. Pending ones
+// are dropped once they expire, when a block moves the height on and every expirySweep for time expiries,
+// and a TxExpired event is published for each.
+
+// expirySweep is how often the mempool is checked for transactions whose expiry time has passed
+const expirySweep = 10 * time.Second
+
+// RemoveExpired drops the transactions that can't go in a block at height made at blockTime, returning them
+func (m *Mempool) RemoveExpired(height int, blockTime time.Time) []blockchain.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []blockchain.Transaction
+	m.drop(func(i int) bool {
+		if m.txs[i].Expired(height, blockTime) {
+			expired = append(expired, m.txs[i])
+			return true
+		}
+		return false
+	})
+	return expired
+}
+
+// expireTxs drops pending transactions that can't go in the next block any more
+func (n *Node) expireTxs() {
+	for _, tx := range n.mempool.RemoveExpired(n.chain.Last().Index+1, time.Now()) {
+		n.bus.Publish(events.TxExpired{Tx: tx})
+	}
+}
+
+// expiryLoop drops transactions whose expiry time passes between blocks
+func (n *Node) expiryLoop() {
+	ticker := time.NewTicker(expirySweep)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+			n.expireTxs()
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package node
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strconv"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// explorerFiles is the block explorer web UI, built into the binary so there's nothing else to deploy
+//
+//go:embed explorer
+var explorerFiles embed.FS
+
+// explorerHandler serves the explorer's static files
+func explorerHandler() http.Handler {
+	files, _ := fs.Sub(explorerFiles, "explorer") // can't fail, the directory is embedded
+	return http.StripPrefix("/explorer", http.FileServer(http.FS(files)))
+}
+
+// GetBlock handles the route to view a single block by its index or hash
+func (n *Node) GetBlock(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var block blockchain.Block
+	var ok bool
+	id := ps.ByName("id")
+	if index, err := strconv.Atoi(id); err == nil {
+		if blocks := n.chain.Range(index, 1); len(blocks) == 1 {
+			block, ok = blocks[0], true
+		}
+	} else {
+		block, ok = n.chain.BlockByHash(id)
+	}
+	if !ok {
+		RespondWithJSON(w, r, http.StatusNotFound, "block not found")
+		return
+	}
+	n.respondBlock(w, r, block)
+}
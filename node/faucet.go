@@ -0,0 +1,127 @@
+package node
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// On a test network the node runs a faucet: POST /faucet/:address sends FaucetAmount coins to the address from
+// the node ID, signed with the node key, so it's funded by the blocks the node mines to its ID or by coins sent
+// there. Each address can only be sent coins once every FaucetInterval, and so can each client IP, so no one
+// drains it by asking for a new address every time.
+
+// FaucetDrip ... what POST /faucet/:address sent
+type FaucetDrip struct {
+	Hash   string // of the transaction sending the coins
+	To     string
+	Amount int
+	Next   time.Time // when the address can ask again
+}
+
+// faucet ... when each address and client IP was last sent coins
+type faucet struct {
+	mu      sync.Mutex // one drip at a time, so they don't race for the same nonce
+	last    map[string]time.Time
+	clients map[string]time.Time
+}
+
+func newFaucet() *faucet {
+	return &faucet{last: make(map[string]time.Time), clients: make(map[string]time.Time)}
+}
+
+// wait returns how long an address, or the client asking for it, has to wait before it can be sent coins again,
+// whichever's longer, f.mu has to be held
+func (f *faucet) wait(address, client string, interval time.Duration, now time.Time) time.Duration {
+	var wait time.Duration
+	for _, last := range []time.Time{f.last[address], f.clients[client]} {
+		if left := interval - now.Sub(last); !last.IsZero() && left > wait {
+			wait = left
+		}
+	}
+	return wait
+}
+
+// forget drops addresses and clients that can already ask again, f.mu has to be held
+func (f *faucet) forget(interval time.Duration, now time.Time) {
+	for _, sent := range []map[string]time.Time{f.last, f.clients} {
+		for key, last := range sent {
+			if now.Sub(last) >= interval {
+				delete(sent, key)
+			}
+		}
+	}
+}
+
+// clientIP returns the IP a request came from. Forwarding headers aren't trusted, anyone can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// drip sends the faucet's coins to an address for a client, or returns how long it has to wait
+func (n *Node) drip(ctx context.Context, address, client string) (FaucetDrip, time.Duration, error) {
+	n.faucet.mu.Lock()
+	defer n.faucet.mu.Unlock()
+
+	now := time.Now()
+	if wait := n.faucet.wait(address, client, n.cfg.FaucetInterval, now); wait > 0 {
+		return FaucetDrip{}, wait, nil
+	}
+	from := n.ID()
+	tx := blockchain.Transaction{
+		Class:     blockchain.ClassUser,
+		From:      from,
+		To:        address,
+		Amount:    n.cfg.FaucetAmount,
+		Nonce:     n.nextNonce(from),
+		Timestamp: now.UnixNano(),
+		ChainID:   n.chain.Params().ChainID,
+	}
+	tx.Witness = []string{tx.Sign(n.key)}
+	hash, err := n.submitRawTx(ctx, tx)
+	if err != nil {
+		return FaucetDrip{}, 0, err
+	}
+	n.faucet.forget(n.cfg.FaucetInterval, now)
+	n.faucet.last[address] = now
+	n.faucet.clients[client] = now
+	return FaucetDrip{Hash: hash, To: address, Amount: n.cfg.FaucetAmount, Next: now.Add(n.cfg.FaucetInterval)}, 0, nil
+}
+
+// PostFaucet handles the route sending test coins to an address
+func (n *Node) PostFaucet(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if n.cfg.FaucetAmount <= 0 {
+		RespondWithJSON(w, r, http.StatusNotFound, "this node doesn't run a faucet")
+		return
+	}
+	address, ok := pathAddress(w, r, ps)
+	if !ok {
+		return
+	}
+	if address == n.ID() {
+		RespondWithJSON(w, r, http.StatusBadRequest, "the faucet can't send coins to itself")
+		return
+	}
+
+	drip, wait, err := n.drip(r.Context(), address, clientIP(r))
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second))) // whole seconds, rounded up
+		RespondWithJSON(w, r, http.StatusTooManyRequests, "address or client was sent coins recently, try again in "+wait.Round(time.Second).String())
+		return
+	}
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusAccepted, drip)
+}
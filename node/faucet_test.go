@@ -0,0 +1,49 @@
+package node_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+func TestFaucet(t *testing.T) {
+	n := blockchaintest.NewNode(t, func(cfg *node.Config) {
+		cfg.Params = blockchain.TestnetParams
+		cfg.FaucetAmount = 10
+	})
+	n.MineBlocks(t, 1)
+	alice, bob := blockchaintest.Address(blockchaintest.NewKey(t)), blockchaintest.Address(blockchaintest.NewKey(t))
+
+	steps := []struct {
+		name    string
+		address string
+		status  int
+	}{
+		{"first ask", alice, http.StatusAccepted},
+		{"same address again", alice, http.StatusTooManyRequests},
+		{"another address from the same client", bob, http.StatusTooManyRequests},
+		{"the faucet itself", n.ID(), http.StatusBadRequest},
+	}
+	var drip node.FaucetDrip
+	for _, step := range steps {
+		var res json.RawMessage
+		if status := n.Do(t, http.MethodPost, "/v1/faucet/"+step.address, nil, &res); status != step.status {
+			t.Errorf("%s: status %d, want %d", step.name, status, step.status)
+		} else if status == http.StatusAccepted {
+			json.Unmarshal(res, &drip)
+		}
+	}
+
+	tx, ok := n.Mempool().Get(drip.Hash)
+	if !ok || tx.From != n.ID() || tx.Validate() != nil {
+		t.Fatalf("faucet sent %+v, want a transaction signed by the node key", tx)
+	}
+	n.MineBlock(t, 0)
+	if balance, _, _ := n.Chain().Balance(alice, 1); balance != 10 {
+		t.Errorf("alice has %d, want the faucet's 10", balance)
+	}
+}
@@ -0,0 +1,151 @@
+package node
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Blocks are filled highest fee rate first, so a fee rate is what gets a transaction in soon. Two things say
+// how high it has to be to get in within a target number of blocks. The mempool: everything pending that pays
+// more goes first, and target blocks only hold so many. And recent blocks: a full block's lowest fee rate is
+// what it took to get into it, a block with room to spare took nothing. Taking those as what the next blocks
+// will be like, a rate that beat fraction f of them gets into one of the next target blocks with a chance of
+// 1-(1-f)^target, so the rate picked is the one that beat enough of them to make that feeConfidence.
+
+// fee estimation defaults
+const (
+	defaultFeeTarget = 3    // blocks, when a request doesn't say
+	maxFeeTarget     = 100  // blocks
+	feeWindow        = 50   // recent blocks looked at
+	feeConfidence    = 0.95 // chance the estimated rate gets a transaction in within its target
+)
+
+// FeeEstimate ... what GET /fees/estimate suggests
+type FeeEstimate struct {
+	Target         int     // blocks the transaction should be in within
+	FeeRate        float64 // per byte, the higher of MempoolFeeRate and BlocksFeeRate
+	Fee            int     // for a transfer signed by a wallet key at FeeRate
+	MempoolFeeRate float64 // what beats enough of the mempool to fit in Target blocks
+	BlocksFeeRate  float64 // what would have got into enough of the recent blocks
+	Pending        int     // transactions in the mempool
+	Blocks         int     // recent blocks looked at
+	FullBlocks     int     // how many of them had no room left
+}
+
+// GetFeeEstimate handles the route suggesting a fee, ?target=3 for one that gets in within 3 blocks
+func (n *Node) GetFeeEstimate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	target := defaultFeeTarget
+	if v := r.URL.Query().Get("target"); v != "" {
+		var err error
+		if target, err = strconv.Atoi(v); err != nil || target < 1 || target > maxFeeTarget {
+			RespondWithJSON(w, r, http.StatusBadRequest, "target must be 1 to "+strconv.Itoa(maxFeeTarget)+" blocks")
+			return
+		}
+	}
+	estimate := n.estimateFee(target)
+	estimate.Fee = feeFor(sampleTransfer, estimate.FeeRate)
+	RespondWithJSON(w, r, http.StatusOK, estimate)
+}
+
+// sampleTransfer is the size of a transfer from a wallet key, signed
+var sampleTransfer = blockchain.Transaction{
+	Class:     blockchain.ClassUser,
+	From:      strings.Repeat("0", 64),
+	To:        strings.Repeat("0", 64),
+	Amount:    1000000,
+	Nonce:     1000,
+	Timestamp: math.MaxInt64,
+	Script:    strings.Repeat("0", 64) + " CHECKSIG",
+	Witness:   []string{placeholderSig},
+	ChainID:   "mainnet",
+}
+
+// estimateFee works out the fee rate that gets a transaction into one of the next target blocks
+func (n *Node) estimateFee(target int) FeeEstimate {
+	estimate := FeeEstimate{Target: target}
+	capacity := n.cfg.MaxBlockTxs - 1 // the coinbase takes a spot
+
+	pending := n.mempool.Pending()
+	estimate.Pending = len(pending)
+	if ahead := target * capacity; len(pending) >= ahead && ahead > 0 {
+		rates := make([]float64, len(pending))
+		for i, tx := range pending {
+			rates[i] = tx.FeeRate()
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(rates)))
+		estimate.MempoolFeeRate = rates[ahead-1] // the last one that still fits, ties go to whoever was first
+	}
+
+	from := n.chain.Last().Index - feeWindow + 1
+	if from < 0 {
+		from = 0
+	}
+	params := n.chain.Params()
+	var lowest []float64 // each recent block's lowest fee rate, 0 if it had room
+	for _, block := range n.chain.Range(from, feeWindow) {
+		if block.Index == 0 || block.Pruned {
+			continue
+		}
+		rate, full := blockFeeRate(block, n.cfg.MaxBlockTxs, params.At(block.Index))
+		if full {
+			estimate.FullBlocks++
+		}
+		lowest = append(lowest, rate)
+	}
+	estimate.Blocks = len(lowest)
+	if len(lowest) > 0 {
+		sort.Float64s(lowest)
+		beat := 1 - math.Pow(1-feeConfidence, 1/float64(target)) // the fraction of blocks the rate has to beat
+		i := int(math.Ceil(beat*float64(len(lowest)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		estimate.BlocksFeeRate = lowest[i]
+	}
+
+	estimate.FeeRate = math.Max(estimate.MempoolFeeRate, estimate.BlocksFeeRate)
+	return estimate
+}
+
+// blockFeeRate returns the lowest fee rate a block took, and whether it was full. One with room took anything,
+// so it's 0.
+func blockFeeRate(block blockchain.Block, maxTxs int, params blockchain.Params) (float64, bool) {
+	lowest, found := 0.0, false
+	for _, tx := range block.Transactions {
+		if tx.BlockMade() {
+			continue
+		}
+		if rate := tx.FeeRate(); !found || rate < lowest {
+			lowest, found = rate, true
+		}
+	}
+	size := block.Size()
+	full := len(block.Transactions) >= maxTxs || (params.MaxBlockSize > 0 && size+sampleTransfer.Size() > params.MaxBlockSize) ||
+		(params.MaxBlockCost > 0 && blockchain.TotalCost(block.Transactions)+sampleTransfer.Cost() > params.MaxBlockCost)
+	if !full || !found {
+		return 0, full
+	}
+	return lowest, true
+}
+
+// feeFor returns the fee that pays at least rate per byte for the transaction, with its fee in it. Ties go to
+// whoever was first, so any rate above nothing is beaten by a fee unit.
+func feeFor(tx blockchain.Transaction, rate float64) int {
+	if rate <= 0 {
+		return 0
+	}
+	for i := 0; i < 3; i++ { // the fee is part of the size, so go again till it stops changing
+		fee := int(rate*float64(tx.Size())) + 1
+		if fee == tx.Fee {
+			break
+		}
+		tx.Fee = fee
+	}
+	return tx.Fee
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// selectFields trims a JSON payload down to the requested fields (sparse fieldsets), eg ?fields=Index,Hash.
+// The payload can be a single object or an array of objects. It returns an error naming any
+// field that doesn't exist, so typos don't silently come back empty.
+func selectFields(payload interface{}, fields []string) (interface{}, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber() // keep big numbers like nano timestamps exact
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	switch v := decoded.(type) {
+	case map[string]interface{}:
+		return pickFields(v, fields)
+	case []interface{}:
+		picked := make([]interface{}, len(v))
+		for i, item := range v {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("fields can only be selected on objects")
+			}
+			if picked[i], err = pickFields(obj, fields); err != nil {
+				return nil, err
+			}
+		}
+		return picked, nil
+	default:
+		return nil, fmt.Errorf("fields can only be selected on objects")
+	}
+}
+
+// pickFields copies just the named fields out of obj
+func pickFields(obj map[string]interface{}, fields []string) (map[string]interface{}, error) {
+	picked := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, ok := obj[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		picked[field] = value
+	}
+	return picked, nil
+}
+
+// requestedFields returns the fields asked for with ?fields=, or nil if the whole object was asked for
+func requestedFields(r *http.Request) []string {
+	param := r.URL.Query().Get("fields")
+	if param == "" {
+		return nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(param, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// RespondWithFields sends the payload as JSON, trimmed to the fields the request asked for
+func RespondWithFields(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
+	fields := requestedFields(r)
+	if fields == nil {
+		RespondWithJSON(w, r, code, payload)
+		return
+	}
+
+	picked, err := selectFields(payload, fields)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	RespondWithJSON(w, r, code, picked)
+}
@@ -0,0 +1,35 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Finality ... how far the chain is settled, for /finalized
+type Finality struct {
+	Depth  int    // how far below the head blocks become final, 0 if the network has no finality rule
+	Height int    // the highest final block, -1 if there isn't one yet
+	Hash   string // its hash
+}
+
+// finality returns the highest final block
+func (n *Node) finality() Finality {
+	f := Finality{Depth: n.chain.Params().FinalityDepth, Height: -1}
+	if header, ok := n.chain.Finalized(); ok {
+		f.Height, f.Hash = header.Index, header.Hash
+	}
+	return f
+}
+
+// GetFinalized handles the route reporting the highest final block, anything at or below it can't be reorganized away
+func (n *Node) GetFinalized(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.finality())
+}
+
+// rpcFinalized returns the highest final block
+func (n *Node) rpcFinalized(context.Context, json.RawMessage) (interface{}, *RPCError) {
+	return n.finality(), nil
+}
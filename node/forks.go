@@ -0,0 +1,62 @@
+package node
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// ForkSchedule ... the network's hard forks and where the chain is with them, for /forks
+type ForkSchedule struct {
+	Height  int // of the head
+	Version int // the header version the next block gets
+	Forks   []ForkStatus
+}
+
+// ForkStatus ... a fork and whether the chain has reached it
+type ForkStatus struct {
+	blockchain.Fork
+	Active bool
+	In     int // blocks until it activates, 0 once it has
+}
+
+// forks returns the fork schedule as of the head
+func (n *Node) forks() ForkSchedule {
+	params, height := n.chain.Params(), n.chain.Last().Index
+	_, version := params.Versions(height + 1)
+	schedule := ForkSchedule{Height: height, Version: version, Forks: []ForkStatus{}}
+	for _, fork := range params.Forks {
+		status := ForkStatus{Fork: fork, Active: fork.Height <= height}
+		if !status.Active {
+			status.In = fork.Height - height
+		}
+		schedule.Forks = append(schedule.Forks, status)
+	}
+	sort.SliceStable(schedule.Forks, func(i, j int) bool { return schedule.Forks[i].Height < schedule.Forks[j].Height })
+	return schedule
+}
+
+// GetForks handles the route listing the hard forks the network is scheduled to upgrade with
+func (n *Node) GetForks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.forks())
+}
+
+// SoftForkStatus ... the network's soft forks and how signalling for them is going, for /softforks
+type SoftForkStatus struct {
+	Height    int    // of the head
+	Window    int    // how many blocks signals are counted over
+	Signals   uint32 // the bits the next block this node makes sets
+	SoftForks []blockchain.SoftFork
+}
+
+// GetSoftForks handles the route listing the soft forks miners can signal for and where each is at
+func (n *Node) GetSoftForks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	forks := n.chain.SoftForks()
+	status := SoftForkStatus{Height: n.chain.Last().Index, Window: n.chain.Params().SignalWindow, Signals: forks.Signals(), SoftForks: forks.List()}
+	if status.SoftForks == nil {
+		status.SoftForks = []blockchain.SoftFork{}
+	}
+	RespondWithJSON(w, r, http.StatusOK, status)
+}
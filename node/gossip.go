@@ -0,0 +1,311 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// the kinds of data that get gossiped
+const (
+	InvBlock = "block"
+	InvTx    = "tx"
+)
+
+// Inventory ... an announcement of blocks or transactions a node has, so peers can ask for just what they're missing
+type Inventory struct {
+	Type   string   // InvBlock or InvTx
+	Hashes []string // hashes of the blocks or transactions on offer
+}
+
+// seenSet remembers the most recent hashes a node has seen so it doesn't process or relay anything twice.
+// Once it's full the oldest hashes are forgotten.
+type seenSet struct {
+	mu    sync.Mutex
+	max   int
+	order []string
+	set   map[string]bool
+}
+
+func newSeenSet(max int) *seenSet {
+	return &seenSet{max: max, set: make(map[string]bool)}
+}
+
+// Add records a hash, returning false if it had already been seen
+func (s *seenSet) Add(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.set[hash] {
+		return false
+	}
+	if len(s.order) >= s.max { // forget the oldest
+		delete(s.set, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.order = append(s.order, hash)
+	s.set[hash] = true
+	return true
+}
+
+// Has returns if a hash has been seen
+func (s *seenSet) Has(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set[hash]
+}
+
+// gossipPeers picks a random subset of peers to announce to
+func (n *Node) gossipPeers() []string {
+	peers := n.peers()
+	if len(peers) <= n.cfg.GossipFanout {
+		return peers
+	}
+
+	picked := make([]string, 0, n.cfg.GossipFanout)
+	for _, i := range rand.Perm(len(peers))[:n.cfg.GossipFanout] {
+		picked = append(picked, peers[i])
+	}
+	return picked
+}
+
+// gossipEvent announces blocks and transactions to peers as they're accepted. Blocks fetched while
+// syncing aren't announced, the peer they came from already has them and so will everyone it gossiped to.
+func (n *Node) gossipEvent(e events.Event) {
+	switch e := e.(type) {
+	case events.BlockAdded:
+		if e.Origin != events.OriginSync {
+			n.announceBlock(e.Block)
+		}
+	case events.TxAdmitted:
+		n.announceTx(e.Tx)
+	}
+}
+
+// announceBlock gossips a newly accepted block to a random subset of peers
+func (n *Node) announceBlock(block blockchain.Block) {
+	n.seen.Add(block.Hash)
+	n.announce(Inventory{Type: InvBlock, Hashes: []string{block.Hash}})
+}
+
+// announceTx gossips a newly admitted transaction to a random subset of peers
+func (n *Node) announceTx(tx blockchain.Transaction) {
+	n.seen.Add(tx.Hash())
+	n.announce(Inventory{Type: InvTx, Hashes: []string{tx.Hash()}})
+}
+
+// announce sends an inventory message to a random subset of peers, then pushes them whatever they ask for
+func (n *Node) announce(inv Inventory) {
+	for _, peer := range n.gossipPeers() {
+		go func(peer string) {
+			if err := n.sendInventory(peer, inv); err != nil {
+				n.logger.Printf("gossip to %s failed: %v", peer, err)
+			}
+		}(peer)
+	}
+}
+
+// sendInventory offers the peer an inventory and sends over the items it wants
+func (n *Node) sendInventory(peer string, inv Inventory) error {
+	var wanted []string
+	if err := postJSON(n.client, peer, "/v1/inv", inv, &wanted); err != nil {
+		return err
+	}
+
+	for _, hash := range wanted {
+		switch inv.Type {
+		case InvBlock:
+			block, ok := n.chain.BlockByHash(hash)
+			if !ok {
+				continue
+			}
+			if err := postBlock(n.client, peer, block); err != nil {
+				return err
+			}
+		case InvTx:
+			tx, ok := n.mempool.Get(hash)
+			if !ok {
+				continue
+			}
+			if err := postJSON(n.client, peer, "/v1/gossip/tx", tx, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PostInventory handles a peer announcing blocks or transactions, replying with the hashes we want
+func (n *Node) PostInventory(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var inv Inventory
+	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+		n.penalize(peerID(r.Context()), "malformed message", penaltyMalformed)
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid inventory: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if len(inv.Hashes) > maxInvHashes {
+		n.penalize(peerID(r.Context()), "spam", penaltySpam)
+		RespondWithJSON(w, r, http.StatusBadRequest, "an inventory can't offer more than "+strconv.Itoa(maxInvHashes)+" hashes")
+		return
+	}
+
+	wanted := []string{}
+	for _, hash := range inv.Hashes {
+		if n.seen.Has(hash) {
+			continue // already have it
+		}
+		if inv.Type == InvBlock {
+			if _, ok := n.chain.BlockByHash(hash); ok {
+				continue
+			}
+		}
+		wanted = append(wanted, hash)
+	}
+
+	RespondWithJSON(w, r, http.StatusOK, wanted)
+}
+
+// PostGossipBlock handles a peer pushing a block we asked for, adding it and relaying it on.
+// The block can be in the canonical encoding or JSON, going by the Content-Type.
+func (n *Node) PostGossipBlock(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	block, err := decodeBlock(r)
+	if err != nil {
+		n.penalize(peerID(r.Context()), "malformed message", penaltyMalformed)
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid block: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	setAuditTarget(r.Context(), block.Hash)
+	if !n.seen.Add(block.Hash) {
+		RespondWithJSON(w, r, http.StatusOK, "already seen")
+		return
+	}
+
+	if !n.addBlock(r.Context(), block) {
+		if n.isOrphan(block) { // its parent hasn't reached us yet, so hold it and go get the parent
+			n.orphans.add(block)
+			setAuditRejected(r.Context(), "parent unknown, held as an orphan")
+			go n.fetchAncestors(context.Background(), block)
+			RespondWithJSON(w, r, http.StatusAccepted, "orphan")
+			return
+		}
+		if parent, ok := n.chain.BlockByHash(block.PrevHash); ok && !blockchain.ValidateBlock(parent, block) { // no honest node sends it
+			n.penalize(peerID(r.Context()), "invalid block", penaltyInvalidBlock)
+			RespondWithJSON(w, r, http.StatusBadRequest, "invalid block")
+			return
+		}
+		n.recordIfStale(block)
+		setAuditRejected(r.Context(), "doesn't build on the head, syncing with peers") // we're probably behind so catch up with peers
+		go n.syncWithPeers()
+		RespondWithJSON(w, r, http.StatusAccepted, "syncing")
+		return
+	}
+
+	n.mempool.RemoveIncluded(block)
+	if err := n.persistAdded(r.Context()); err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	n.bus.Publish(events.BlockAdded{Block: block, Origin: events.OriginGossip}) // flood it on through the network
+	n.connectOrphans(r.Context())                                               // and add any of its children that got here first
+	RespondWithJSON(w, r, http.StatusCreated, block.Hash)
+}
+
+// decodeBlock reads a block from a request body, in the canonical encoding if that's its Content-Type or else JSON
+func decodeBlock(r *http.Request) (block blockchain.Block, err error) {
+	if media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); media == mediaBinary {
+		var data []byte
+		if data, err = io.ReadAll(r.Body); err == nil {
+			block, err = blockchain.DecodeBlock(data)
+		}
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&block)
+	}
+	return block, err
+}
+
+// PostGossipTx handles a peer pushing a transaction we asked for, admitting it to the mempool and relaying it on
+func (n *Node) PostGossipTx(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var tx blockchain.Transaction
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		n.penalize(peerID(r.Context()), "malformed message", penaltyMalformed)
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid transaction: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	hash := tx.Hash()
+	setAuditTarget(r.Context(), hash)
+	if !n.seen.Add(hash) {
+		RespondWithJSON(w, r, http.StatusOK, "already seen")
+		return
+	}
+
+	if err := tx.Validate(); err != nil {
+		n.penalize(peerID(r.Context()), "invalid transaction", penaltyInvalidTx)
+		rejectTx(w, r, err)
+		return
+	}
+	if err := n.addTx(tx); err != nil { // relayed on once it's admitted
+		rejectTx(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, r, http.StatusAccepted, hash)
+}
+
+// postBlock pushes a block to a peer in the canonical encoding, falling back to JSON for
+// peers from before it that turn it away
+func postBlock(client *http.Client, baseURL string, block blockchain.Block) error {
+	url := strings.TrimRight(baseURL, "/") + "/v1/gossip/block"
+	res, err := client.Post(url, mediaBinary, bytes.NewReader(block.Encode()))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnsupportedMediaType {
+		return postJSON(client, baseURL, "/v1/gossip/block", block, nil)
+	}
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("posting /v1/gossip/block to %s: %s", baseURL, res.Status)
+	}
+	return nil
+}
+
+// postJSON sends payload to path on the node at baseURL and decodes the JSON response into out, if out isn't nil
+func postJSON(client *http.Client, baseURL, path string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Post(strings.TrimRight(baseURL, "/")+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("posting %s to %s: %s", path, baseURL, res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,95 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// ProposalRequest ... the body of POST /proposals, eg {"Proposer":"ab12...","Param":"block_reward","Value":25,"End":1200,"Activation":1500},
+// signed by the proposer, see TxSignature
+type ProposalRequest struct {
+	Proposer string
+	blockchain.ProposalTerms
+	Fee int
+	TxSignature
+}
+
+// VoteRequest ... the body of POST /proposals/:id/vote, eg {"Voter":"cd34...","Vote":"yes"}, signed by the voter
+type VoteRequest struct {
+	Voter string
+	Vote  string // "yes" or "no"
+	Fee   int
+	TxSignature
+}
+
+// proposal builds a proposal transaction, signed if the request is. Its hash is the proposal's ID.
+func (n *Node) proposal(req ProposalRequest) (blockchain.Transaction, error) {
+	terms, err := json.Marshal(req.ProposalTerms)
+	if err != nil {
+		return blockchain.Transaction{}, err
+	}
+	return n.signedTx(blockchain.Transaction{Class: blockchain.ClassProposal, From: req.Proposer, Payload: string(terms), Fee: req.Fee}, req.TxSignature)
+}
+
+// vote builds a vote on a proposal, signed if the request is
+func (n *Node) vote(id string, req VoteRequest) (blockchain.Transaction, error) {
+	if _, ok := n.chain.Governance().Proposal(id); !ok {
+		return blockchain.Transaction{}, blockchain.ErrUnknownProposal
+	}
+	return n.signedTx(blockchain.Transaction{Class: blockchain.ClassVote, From: req.Voter, To: id, Payload: req.Vote, Fee: req.Fee}, req.TxSignature)
+}
+
+// GetProposals handles the route listing every proposal, newest first
+func (n *Node) GetProposals(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.chain.Governance().Proposals())
+}
+
+// GetProposal handles the route reporting a proposal, its votes and how it went
+func (n *Node) GetProposal(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	proposal, ok := n.chain.Governance().Proposal(ps.ByName("id"))
+	if !ok {
+		RespondWithJSON(w, r, http.StatusNotFound, "proposal not found")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, proposal)
+}
+
+// PostParamProposal handles the route to propose changing a param
+func (n *Node) PostParamProposal(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req ProposalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid proposal: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	tx, err := n.proposal(req)
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	n.submitSigned(w, r, tx)
+}
+
+// PostProposalVote handles the route to vote on a proposal
+func (n *Node) PostProposalVote(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req VoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid vote: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	tx, err := n.vote(ps.ByName("id"), req)
+	if err == blockchain.ErrUnknownProposal {
+		RespondWithJSON(w, r, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	n.submitSigned(w, r, tx)
+}
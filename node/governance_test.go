@@ -0,0 +1,73 @@
+package node_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+func TestGovernanceSignatures(t *testing.T) {
+	n := newValidator(t)
+	alice, bob := blockchaintest.NewKey(t), blockchaintest.NewKey(t)
+	fund(t, n, blockchaintest.Address(alice), 10)
+
+	end := n.Chain().Last().Index + 1000 // well after the test's over
+	proposal := node.ProposalRequest{Proposer: blockchaintest.Address(alice), ProposalTerms: blockchain.ProposalTerms{Param: blockchain.ParamBlockReward, Value: 25, End: end, Activation: end + 1}}
+	var build node.TxBuild
+	if code := n.Do(t, http.MethodPost, "/v1/proposals", proposal, &build); code != http.StatusOK {
+		t.Fatalf("unsigned proposal: status %d, want the transaction to sign", code)
+	}
+	proposal.Nonce, proposal.Timestamp, proposal.Signature = build.Transaction.Nonce, build.Transaction.Timestamp, build.Transaction.Sign(alice)
+	var id string
+	if code := n.Do(t, http.MethodPost, "/v1/proposals", proposal, &id); code != http.StatusAccepted {
+		t.Fatalf("signed proposal: status %d", code)
+	}
+	waitFor(t, "the proposal", func() bool {
+		_, ok := n.Chain().Governance().Proposal(id)
+		return ok
+	})
+
+	// a vote with alice's address, signed by bob and by alice
+	vote := node.VoteRequest{Voter: blockchaintest.Address(alice), Vote: blockchain.VoteYes}
+	if code := n.Do(t, http.MethodPost, "/v1/proposals/"+id+"/vote", vote, &build); code != http.StatusOK {
+		t.Fatalf("unsigned vote: status %d, want the transaction to sign", code)
+	}
+	vote.Nonce, vote.Timestamp = build.Transaction.Nonce, build.Transaction.Timestamp
+	forged, signed := vote, vote
+	forged.Signature, signed.Signature = build.Transaction.Sign(bob), build.Transaction.Sign(alice)
+
+	tests := []struct {
+		name   string
+		id     string
+		req    node.VoteRequest
+		status int
+		code   string
+	}{
+		{"signed by someone else", id, forged, http.StatusBadRequest, "unsigned"},
+		{"no such proposal", "feed", signed, http.StatusNotFound, ""},
+		{"signed by the voter", id, signed, http.StatusAccepted, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res json.RawMessage
+			status := n.Do(t, http.MethodPost, "/v1/proposals/"+tt.id+"/vote", tt.req, &res)
+			var rejection node.TxRejection
+			json.Unmarshal(res, &rejection)
+			if status != tt.status || rejection.Code != tt.code {
+				t.Errorf("POST vote = %d %q, want %d %q", status, rejection.Code, tt.status, tt.code)
+			}
+		})
+	}
+
+	waitFor(t, "the vote", func() bool {
+		p, _ := n.Chain().Governance().Proposal(id)
+		return p.Votes[blockchaintest.Address(alice)] == blockchain.VoteYes
+	})
+	if p, _ := n.Chain().Governance().Proposal(id); len(p.Votes) != 1 {
+		t.Errorf("proposal has votes %v, want only alice's", p.Votes)
+	}
+}
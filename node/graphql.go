@@ -0,0 +1,402 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// This is a small GraphQL server covering the parts of the language chain queries need:
+// queries with nested selections, arguments, aliases and variables. Mutations, fragments,
+// directives and introspection aren't supported. The schema lives in graphql_schema.go.
+
+// GraphQLRequest ... the body of a POST to /graphql
+type GraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// GraphQLResponse ... the result of a query, as laid out by the GraphQL spec
+type GraphQLResponse struct {
+	Data   interface{}    `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// GraphQLError ... something that went wrong parsing or running a query
+type GraphQLError struct {
+	Message string `json:"message"`
+}
+
+// PostGraphQL handles the route to run a GraphQL query, also served on GET with ?query=
+func (n *Node) PostGraphQL(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				RespondWithJSON(w, r, http.StatusBadRequest, GraphQLResponse{Errors: []GraphQLError{{"invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	} else {
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			RespondWithJSON(w, r, http.StatusBadRequest, GraphQLResponse{Errors: []GraphQLError{{"invalid request: " + err.Error()}}})
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	selections, err := parseGraphQL(req.Query, req.Variables)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, GraphQLResponse{Errors: []GraphQLError{{err.Error()}}})
+		return
+	}
+
+	data, err := gqlSelect(selections, n.resolveQuery)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusOK, GraphQLResponse{Data: nil, Errors: []GraphQLError{{err.Error()}}})
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, GraphQLResponse{Data: data})
+}
+
+// gqlField ... a field selected in a query
+type gqlField struct {
+	Name       string
+	Alias      string // the key the field comes back under, the name unless it was aliased
+	Args       map[string]interface{}
+	Selections []gqlField // sub-fields, for fields that return objects
+}
+
+// gqlObject ... the fields resolved for an object, kept in the order they were asked for
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON writes the object's fields in query order, like the spec asks for
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// gqlSelect resolves each selected field into an object
+func gqlSelect(selections []gqlField, resolve func(gqlField) (interface{}, error)) (gqlObject, error) {
+	obj := gqlObject{values: make(map[string]interface{})}
+	for _, field := range selections {
+		value, err := resolve(field)
+		if err != nil {
+			return obj, err
+		}
+		if _, ok := obj.values[field.Alias]; !ok {
+			obj.keys = append(obj.keys, field.Alias)
+		}
+		obj.values[field.Alias] = value
+	}
+	return obj, nil
+}
+
+// argInt returns an integer argument, and if it was given
+func (f gqlField) argInt(name string) (int, bool, error) {
+	value, ok := f.Args[name]
+	if !ok || value == nil {
+		return 0, false, nil
+	}
+	switch v := value.(type) {
+	case int:
+		return v, true, nil
+	case float64:
+		return int(v), true, nil
+	case json.Number:
+		i, err := strconv.Atoi(v.String())
+		return i, err == nil, err
+	}
+	return 0, false, fmt.Errorf("argument %q of %s has to be an Int", name, f.Name)
+}
+
+// argString returns a string argument, and if it was given
+func (f gqlField) argString(name string) (string, bool, error) {
+	value, ok := f.Args[name]
+	if !ok || value == nil {
+		return "", false, nil
+	}
+	if s, ok := value.(string); ok {
+		return s, true, nil
+	}
+	return "", false, fmt.Errorf("argument %q of %s has to be a String", name, f.Name)
+}
+
+// parseGraphQL parses a query document down to its top level selections, filling in variables
+func parseGraphQL(query string, variables map[string]interface{}) ([]gqlField, error) {
+	tokens, err := lexGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens, variables: variables}
+
+	if p.peek() == "query" {
+		p.next()
+		if p.peek() != "{" && p.peek() != "(" { // operation name
+			p.next()
+		}
+		if p.peek() == "(" {
+			if err := p.variableDefinitions(); err != nil {
+				return nil, err
+			}
+		}
+	} else if p.peek() == "mutation" || p.peek() == "subscription" {
+		return nil, fmt.Errorf("only queries are supported")
+	}
+
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q after the query, only one operation is supported", p.peek())
+	}
+	return selections, nil
+}
+
+// lexGraphQL splits a query into tokens: punctuation, names, numbers and quoted strings
+func lexGraphQL(query string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',': // commas are insignificant in GraphQL
+			i++
+		case c == '#': // comment to the end of the line
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.IndexByte("{}():$!=[]", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(query) && query[j] != '"' {
+				if query[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(query) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, query[i:j+1])
+			i = j + 1
+		case c == '-' || c == '_' || c == '.' || isAlnum(c):
+			j := i + 1
+			for j < len(query) && (query[j] == '_' || query[j] == '.' || isAlnum(query[j])) {
+				j++
+			}
+			tokens = append(tokens, query[i:j])
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// gqlParser walks a list of tokens
+type gqlParser struct {
+	tokens    []string
+	pos       int
+	variables map[string]interface{}
+}
+
+func (p *gqlParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *gqlParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *gqlParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		if got == "" {
+			return fmt.Errorf("expected %q, got the end of the query", tok)
+		}
+		return fmt.Errorf("expected %q, got %q", tok, got)
+	}
+	return nil
+}
+
+// name reads a field, argument or variable name
+func (p *gqlParser) name() (string, error) {
+	tok := p.next()
+	if tok == "" || !(tok[0] == '_' || isAlnum(tok[0])) || (tok[0] >= '0' && tok[0] <= '9') {
+		return "", fmt.Errorf("expected a name, got %q", tok)
+	}
+	return tok, nil
+}
+
+// variableDefinitions reads ($name: Type = default, ...), filling in defaults for variables that weren't given
+func (p *gqlParser) variableDefinitions() error {
+	p.next() // (
+	if p.variables == nil {
+		p.variables = make(map[string]interface{})
+	}
+	for p.peek() != ")" {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		for p.peek() == "[" || p.peek() == "]" || p.peek() == "!" { // the type isn't checked
+			p.next()
+		}
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		for p.peek() == "]" || p.peek() == "!" {
+			p.next()
+		}
+		if p.peek() == "=" {
+			p.next()
+			value, err := p.value()
+			if err != nil {
+				return err
+			}
+			if _, ok := p.variables[name]; !ok {
+				p.variables[name] = value
+			}
+		}
+		if p.peek() == "" {
+			return fmt.Errorf("unterminated variable definitions")
+		}
+	}
+	p.next() // )
+	return nil
+}
+
+// selectionSet reads { field field ... }
+func (p *gqlParser) selectionSet() ([]gqlField, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []gqlField
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("unterminated selection set")
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	p.next() // }
+	return fields, nil
+}
+
+// field reads alias: name(args) { selections }, where only the name is required
+func (p *gqlParser) field() (gqlField, error) {
+	name, err := p.name()
+	if err != nil {
+		return gqlField{}, err
+	}
+	field := gqlField{Name: name, Alias: name, Args: make(map[string]interface{})}
+	if p.peek() == ":" {
+		p.next()
+		if field.Name, err = p.name(); err != nil {
+			return field, err
+		}
+	}
+
+	if p.peek() == "(" {
+		p.next()
+		for p.peek() != ")" {
+			arg, err := p.name()
+			if err != nil {
+				return field, err
+			}
+			if err := p.expect(":"); err != nil {
+				return field, err
+			}
+			if field.Args[arg], err = p.value(); err != nil {
+				return field, err
+			}
+		}
+		p.next() // )
+	}
+
+	if p.peek() == "{" {
+		if field.Selections, err = p.selectionSet(); err != nil {
+			return field, err
+		}
+	}
+	return field, nil
+}
+
+// value reads an argument value: a variable, number, string, boolean, null or enum
+func (p *gqlParser) value() (interface{}, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("expected a value, got the end of the query")
+	case tok == "$":
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		value, ok := p.variables[name]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s isn't defined", name)
+		}
+		return value, nil
+	case tok[0] == '"':
+		return strconv.Unquote(tok)
+	case tok == "true" || tok == "false":
+		return tok == "true", nil
+	case tok == "null":
+		return nil, nil
+	case tok[0] == '-' || tok[0] >= '0' && tok[0] <= '9':
+		if i, err := strconv.Atoi(tok); err == nil {
+			return i, nil
+		}
+		return strconv.ParseFloat(tok, 64)
+	case tok[0] == '_' || isAlnum(tok[0]):
+		return tok, nil // enum values come through as strings
+	}
+	return nil, fmt.Errorf("unexpected %q", tok)
+}
@@ -0,0 +1,315 @@
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// The GraphQL schema:
+//
+//	type Query {
+//	  head: Block
+//	  block(index: Int, hash: String): Block
+//	  blocks(from: Int, to: Int, after: String, before: String, limit: Int): [Block]
+//	  transaction(hash: String!): Transaction
+//	  account(address: String!): Account
+//	}
+//	type Block {
+//	  index: Int, hash: String, prevHash: String, merkleRoot: String, timestamp: Int,
+//	  difficulty: Int, nonce: Int, bloom: String, sealer: String, signals: Int, chainId: String, stateRoot: String, data: Int, pruned: Boolean, transactionCount: Int,
+//	  transactions(class: String, address: String, limit: Int): [Transaction]
+//	}
+//	type Transaction {
+//	  hash: String, class: String, from: String, to: String, amount: Int, fee: Int,
+//	  nonce: Int, payload: String, timestamp: Int, lockTime: Int, expiry: Int, chainId: String, block: Block
+//	}
+//	type Account {
+//	  address: String, nonce: Int, transactionCount: Int,
+//	  transactions(class: String, limit: Int): [Transaction]
+//	}
+//
+// after and before are RFC 3339 times, from and to are inclusive block indexes.
+
+// gqlTx ... a confirmed transaction and the block it's in
+type gqlTx struct {
+	tx    blockchain.Transaction
+	block blockchain.Block
+}
+
+// resolveQuery resolves a top level field
+func (n *Node) resolveQuery(f gqlField) (interface{}, error) {
+	switch f.Name {
+	case "head":
+		return n.resolveBlock(n.chain.Last(), f.Selections)
+
+	case "block":
+		index, hasIndex, err := f.argInt("index")
+		if err != nil {
+			return nil, err
+		}
+		hash, _, err := f.argString("hash")
+		if err != nil {
+			return nil, err
+		}
+		if hasIndex {
+			if blocks := n.chain.Range(index, 1); len(blocks) == 1 {
+				return n.resolveBlock(blocks[0], f.Selections)
+			}
+			return nil, nil
+		}
+		if block, ok := n.chain.BlockByHash(hash); ok {
+			return n.resolveBlock(block, f.Selections)
+		}
+		return nil, nil
+
+	case "blocks":
+		blocks, err := n.gqlBlocks(f)
+		if err != nil {
+			return nil, err
+		}
+		resolved := make([]interface{}, 0, len(blocks))
+		for _, block := range blocks {
+			obj, err := n.resolveBlock(block, f.Selections)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, obj)
+		}
+		return resolved, nil
+
+	case "transaction":
+		hash, _, err := f.argString("hash")
+		if err != nil {
+			return nil, err
+		}
+		if record, ok := n.chain.Tx(hash); ok {
+			if t, ok := n.gqlTxOf(record); ok {
+				return n.resolveTx(t, f.Selections)
+			}
+		}
+		return nil, nil
+
+	case "account":
+		address, ok, err := f.argAddress("address")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("account needs an address")
+		}
+		return n.resolveAccount(address, f.Selections)
+	}
+	return nil, fmt.Errorf("Query has no field %q", f.Name)
+}
+
+// gqlBlocks picks out the blocks a blocks(...) field asks for
+func (n *Node) gqlBlocks(f gqlField) ([]blockchain.Block, error) {
+	from, _, err := f.argInt("from")
+	if err != nil {
+		return nil, err
+	}
+	to, hasTo, err := f.argInt("to")
+	if err != nil {
+		return nil, err
+	}
+	limit, hasLimit, err := f.argInt("limit")
+	if err != nil {
+		return nil, err
+	}
+	if !hasLimit || limit > maxBlockBatch {
+		limit = maxBlockBatch
+	}
+
+	var after, before time.Time
+	for name, t := range map[string]*time.Time{"after": &after, "before": &before} {
+		s, ok, err := f.argString(name)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if *t, err = time.Parse(time.RFC3339, s); err != nil {
+			return nil, fmt.Errorf("argument %q of blocks has to be an RFC 3339 time", name)
+		}
+	}
+
+	var blocks []blockchain.Block
+	for _, block := range n.chain.Range(from, n.chain.Len()) {
+		if len(blocks) >= limit || (hasTo && block.Index > to) {
+			break
+		}
+		if !after.IsZero() || !before.IsZero() {
+			t := block.Time()
+			if (!after.IsZero() && !t.After(after)) || (!before.IsZero() && !t.Before(before)) {
+				continue
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// resolveBlock resolves the selected fields of a block
+func (n *Node) resolveBlock(block blockchain.Block, selections []gqlField) (interface{}, error) {
+	return gqlSelect(selections, func(f gqlField) (interface{}, error) {
+		switch f.Name {
+		case "index":
+			return block.Index, nil
+		case "hash":
+			return block.Hash, nil
+		case "prevHash":
+			return block.PrevHash, nil
+		case "merkleRoot":
+			return block.MerkleRoot, nil
+		case "timestamp":
+			return block.Timestamp, nil
+		case "difficulty":
+			return block.Difficulty, nil
+		case "nonce":
+			return block.Nonce, nil
+		case "bloom":
+			return block.Bloom, nil
+		case "sealer":
+			return block.Sealer, nil
+		case "signals":
+			return int(block.Signals), nil
+		case "chainId":
+			return block.ChainID, nil
+		case "stateRoot":
+			return block.StateRoot, nil
+		case "data":
+			return block.Data, nil
+		case "pruned":
+			return block.Pruned, nil
+		case "transactionCount":
+			return len(block.Transactions), nil
+		case "transactions":
+			var txs []gqlTx
+			for _, tx := range block.Transactions {
+				txs = append(txs, gqlTx{tx, block})
+			}
+			return n.resolveTxs(txs, f)
+		}
+		return nil, fmt.Errorf("Block has no field %q", f.Name)
+	})
+}
+
+// resolveTxs resolves a list of transactions, filtered by the field's class, address and limit arguments
+func (n *Node) resolveTxs(txs []gqlTx, f gqlField) (interface{}, error) {
+	class, _, err := f.argString("class")
+	if err != nil {
+		return nil, err
+	}
+	address, _, err := f.argAddress("address")
+	if err != nil {
+		return nil, err
+	}
+	limit, hasLimit, err := f.argInt("limit")
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]interface{}, 0, len(txs))
+	for _, t := range txs {
+		if hasLimit && len(resolved) >= limit {
+			break
+		}
+		if (class != "" && string(t.tx.Class) != class) || (address != "" && t.tx.From != address && t.tx.To != address) {
+			continue
+		}
+		obj, err := n.resolveTx(t, f.Selections)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, obj)
+	}
+	return resolved, nil
+}
+
+// resolveTx resolves the selected fields of a transaction
+func (n *Node) resolveTx(t gqlTx, selections []gqlField) (interface{}, error) {
+	tx := t.tx
+	return gqlSelect(selections, func(f gqlField) (interface{}, error) {
+		switch f.Name {
+		case "hash":
+			return tx.Hash(), nil
+		case "class":
+			return tx.Class, nil
+		case "from":
+			return tx.From, nil
+		case "to":
+			return tx.To, nil
+		case "amount":
+			return tx.Amount, nil
+		case "fee":
+			return tx.Fee, nil
+		case "nonce":
+			return tx.Nonce, nil
+		case "payload":
+			return tx.Payload, nil
+		case "timestamp":
+			return tx.Timestamp, nil
+		case "lockTime":
+			return tx.LockTime, nil
+		case "expiry":
+			return tx.Expiry, nil
+		case "chainId":
+			return tx.ChainID, nil
+		case "block":
+			return n.resolveBlock(t.block, f.Selections)
+		}
+		return nil, fmt.Errorf("Transaction has no field %q", f.Name)
+	})
+}
+
+// gqlTxOf looks up the block a transaction from the index is in, false if it's been pruned
+func (n *Node) gqlTxOf(record blockchain.TxRecord) (gqlTx, bool) {
+	blocks := n.chain.Range(record.Height, 1)
+	if record.Tx == nil || len(blocks) == 0 {
+		return gqlTx{}, false
+	}
+	return gqlTx{*record.Tx, blocks[0]}, true
+}
+
+// argAddress returns an address argument, decoded if it's encoded, and if it was given
+func (f gqlField) argAddress(name string) (string, bool, error) {
+	address, ok, err := f.argString(name)
+	if err != nil || !ok {
+		return address, ok, err
+	}
+	address, err = blockchain.ParseAddress(address)
+	return address, ok, err
+}
+
+// resolveAccount resolves the selected fields of an address
+func (n *Node) resolveAccount(address string, selections []gqlField) (interface{}, error) {
+	_, total := n.chain.History(address, 0, 0)
+	records, _ := n.chain.History(address, 0, total)
+	var txs []gqlTx // every confirmed transaction to or from the address, oldest first
+	for i := len(records) - 1; i >= 0; i-- {
+		if t, ok := n.gqlTxOf(records[i]); ok {
+			txs = append(txs, t)
+		}
+	}
+
+	return gqlSelect(selections, func(f gqlField) (interface{}, error) {
+		switch f.Name {
+		case "address":
+			return address, nil
+		case "nonce":
+			nonce, ok := n.chain.Nonce(address)
+			if !ok {
+				return nil, nil
+			}
+			return nonce, nil
+		case "transactionCount":
+			return len(txs), nil
+		case "transactions":
+			return n.resolveTxs(txs, f)
+		}
+		return nil, fmt.Errorf("Account has no field %q", f.Name)
+	})
+}
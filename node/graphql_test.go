@@ -0,0 +1,53 @@
+package node_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+func TestGraphQL(t *testing.T) {
+	n := blockchaintest.NewNode(t)
+	n.MineBlocks(t, 1)
+	alice := blockchaintest.Address(blockchaintest.NewKey(t))
+	n.Fund(t, alice, 10)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		status    int
+		want      string // the data, compact
+		wantErr   bool
+	}{
+		{"head", `{ head { index transactionCount } }`, nil, http.StatusOK, `{"head":{"index":2,"transactionCount":2}}`, false},
+		{"aliases and arguments", `{ first: block(index: 1) { index } second: block(index: 2) { index } }`, nil, http.StatusOK,
+			`{"first":{"index":1},"second":{"index":2}}`, false},
+		{"variables", `query Block($i: Int) { block(index: $i) { index } }`, map[string]interface{}{"i": 2}, http.StatusOK, `{"block":{"index":2}}`, false},
+		{"no such block", `{ block(index: 99) { index } }`, nil, http.StatusOK, `{"block":null}`, false},
+		{"nested lists", `query ($a: String!) { account(address: $a) { transactionCount transactions { to amount } } }`, map[string]interface{}{"a": alice}, http.StatusOK,
+			`{"account":{"transactionCount":1,"transactions":[{"to":"` + alice + `","amount":10}]}}`, false},
+		{"unknown field", `{ head { height } }`, nil, http.StatusOK, `null`, true},
+		{"account without an address", `{ account { nonce } }`, nil, http.StatusOK, `null`, true},
+		{"unclosed selection", `{ head { index }`, nil, http.StatusBadRequest, `null`, true},
+		{"mutation", `mutation { head { index } }`, nil, http.StatusBadRequest, `null`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res struct {
+				Data   json.RawMessage
+				Errors []node.GraphQLError
+			}
+			status := n.Do(t, http.MethodPost, "/v1/graphql", node.GraphQLRequest{Query: tt.query, Variables: tt.variables}, &res)
+			var data bytes.Buffer
+			json.Compact(&data, res.Data)
+			if status != tt.status || data.String() != tt.want || (len(res.Errors) > 0) != tt.wantErr {
+				t.Errorf("POST /graphql = %d %s %v, want %d %s, errors %v", status, data.String(), res.Errors, tt.status, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
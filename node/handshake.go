@@ -0,0 +1,340 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// the headers used to carry peer sessions
+const (
+	sessionHeader           = "X-Node-Session"
+	handshakeRequiredHeader = "X-Handshake-Required"
+)
+
+// how long a handshake stays valid for
+const (
+	handshakeMaxSkew = time.Minute
+	sessionTTL       = time.Hour
+)
+
+// Handshake ... what a node sends to prove who it is when it connects to a peer
+type Handshake struct {
+	NodeID    string // hex encoded ed25519 public key of the connecting node
+	Timestamp int64  // unix time the handshake was made, stale handshakes are refused
+	Nonce     string // random hex string, so a handshake can't be replayed
+	URL       string `json:",omitempty"` // where the node can be reached from outside, if it knows, eg from port mapping
+	Signature string // hex signature over the fields above
+}
+
+// HandshakeAck ... the peer's reply, proving its own identity and handing back a session
+type HandshakeAck struct {
+	NodeID    string // hex encoded ed25519 public key of the peer
+	Signature string // hex signature over the connecting node's nonce
+	Session   string // token to send in the X-Node-Session header on peer requests
+}
+
+func handshakeMessage(h Handshake) []byte {
+	msg := "go-blockchain handshake|" + h.NodeID + "|" + strconv.FormatInt(h.Timestamp, 10) + "|" + h.Nonce
+	if h.URL != "" { // left off without one, so handshakes from nodes that never send it still check out
+		msg += "|" + h.URL
+	}
+	return []byte(msg)
+}
+
+func handshakeAckMessage(nonce, nodeID string) []byte {
+	return []byte("go-blockchain handshake ack|" + nonce + "|" + nodeID)
+}
+
+// verifySignature checks a hex signature by a hex encoded node ID
+func verifySignature(nodeID string, message []byte, signature string) bool {
+	pub, err := hex.DecodeString(nodeID)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pub), message, sig)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// peerSession ... an authenticated peer connection
+type peerSession struct {
+	NodeID  string
+	Expires time.Time
+}
+
+// identity keeps track of sessions and which peers are allowed in
+type identity struct {
+	mu       sync.Mutex
+	inbound  map[string]peerSession // session token -> the peer that holds it
+	outbound map[string]string      // peer base URL -> our session token on that peer
+	peerIDs  map[string]string      // peer base URL -> the node ID it proved in its handshake
+	banned   map[string]time.Time   // node IDs we refuse to talk to, till when or the zero time for good
+	scores   map[string]*peerScore  // node ID -> its misbehavior, see scoring.go
+	nonces   *seenSet               // handshake nonces we've already accepted
+}
+
+func newIdentity(banned []string) *identity {
+	id := &identity{
+		inbound:  make(map[string]peerSession),
+		outbound: make(map[string]string),
+		peerIDs:  make(map[string]string),
+		banned:   make(map[string]time.Time),
+		scores:   make(map[string]*peerScore),
+		nonces:   newSeenSet(10000),
+	}
+	for _, nodeID := range banned {
+		id.banned[nodeID] = time.Time{}
+	}
+	return id
+}
+
+// ID returns the node's identity, the hex encoded public half of its key
+func (n *Node) ID() string {
+	return hex.EncodeToString(n.key.Public().(ed25519.PublicKey))
+}
+
+// allowedPeer returns if we're willing to talk to a node, checking the ban list and the allowlist if there is one
+func (n *Node) allowedPeer(nodeID string) bool {
+	n.identity.mu.Lock()
+	until, banned := n.identity.banned[nodeID]
+	if banned && !until.IsZero() && time.Now().After(until) { // the ban's over
+		delete(n.identity.banned, nodeID)
+		banned = false
+	}
+	n.identity.mu.Unlock()
+	if banned {
+		return false
+	}
+
+	if len(n.cfg.AllowedPeers) == 0 {
+		return true
+	}
+	for _, allowed := range n.cfg.AllowedPeers {
+		if allowed == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// BanPeer stops a node from talking to us till it's unbanned, dropping any sessions it has
+func (n *Node) BanPeer(nodeID string) {
+	n.BanPeerFor(nodeID, 0)
+}
+
+// BanPeerFor stops a node from talking to us for a while, for good if it's 0, dropping the sessions it has
+// with us and we have with it
+func (n *Node) BanPeerFor(nodeID string, d time.Duration) {
+	n.identity.mu.Lock()
+	defer n.identity.mu.Unlock()
+
+	until := time.Time{}
+	if d > 0 {
+		until = time.Now().Add(d)
+	}
+	n.identity.banned[nodeID] = until
+	for token, session := range n.identity.inbound {
+		if session.NodeID == nodeID {
+			delete(n.identity.inbound, token)
+		}
+	}
+	for peer, id := range n.identity.peerIDs { // signing in again fails while it's banned
+		if id == nodeID {
+			delete(n.identity.outbound, peer)
+		}
+	}
+}
+
+// UnbanPeer lets a banned node talk to us again, with a clean score
+func (n *Node) UnbanPeer(nodeID string) {
+	n.identity.mu.Lock()
+	defer n.identity.mu.Unlock()
+	delete(n.identity.banned, nodeID)
+	delete(n.identity.scores, nodeID)
+}
+
+// BannedPeers returns the IDs of every banned node
+func (n *Node) BannedPeers() []string {
+	n.identity.mu.Lock()
+	defer n.identity.mu.Unlock()
+
+	banned := []string{}
+	for nodeID, until := range n.identity.banned {
+		if until.IsZero() || time.Now().Before(until) {
+			banned = append(banned, nodeID)
+		}
+	}
+	return banned
+}
+
+// PostHandshake handles a peer proving its identity, replying with our own proof and a session token
+func (n *Node) PostHandshake(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var h Handshake
+	if err := json.NewDecoder(r.Body).Decode(&h); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid handshake: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	skew := time.Since(time.Unix(h.Timestamp, 0))
+	if skew > handshakeMaxSkew || skew < -handshakeMaxSkew {
+		RespondWithJSON(w, r, http.StatusUnauthorized, "handshake is stale")
+		return
+	}
+	if !verifySignature(h.NodeID, handshakeMessage(h), h.Signature) {
+		RespondWithJSON(w, r, http.StatusUnauthorized, "bad handshake signature")
+		return
+	}
+	if n.peerTLS != nil && certNodeID(r.TLS) != h.NodeID { // so the session can't be used by anyone in between
+		RespondWithJSON(w, r, http.StatusUnauthorized, "peers have to shake hands over TLS with their node key as the certificate, turn on encrypt peers")
+		return
+	}
+	if !n.allowedPeer(h.NodeID) {
+		RespondWithJSON(w, r, http.StatusForbidden, "peer not allowed")
+		return
+	}
+	if !n.identity.nonces.Add(h.Nonce) {
+		RespondWithJSON(w, r, http.StatusUnauthorized, "handshake replayed")
+		return
+	}
+
+	if h.URL != "" && validPeerURL(h.URL) && n.addPeer(h.URL) { // it can be reached, so talk to it too
+		n.logger.Printf("added peer %s (%s), which it advertised", h.URL, h.NodeID)
+	}
+
+	token := randomHex(32)
+	n.identity.mu.Lock()
+	n.identity.inbound[token] = peerSession{NodeID: h.NodeID, Expires: time.Now().Add(sessionTTL)}
+	n.identity.mu.Unlock()
+
+	RespondWithJSON(w, r, http.StatusOK, HandshakeAck{
+		NodeID:    n.ID(),
+		Signature: hex.EncodeToString(ed25519.Sign(n.key, handshakeAckMessage(h.Nonce, n.ID()))),
+		Session:   token,
+	})
+}
+
+// peerOnly wraps a handler so it's only reachable by peers holding a session from a handshake
+func (n *Node) peerOnly(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token := r.Header.Get(sessionHeader)
+
+		n.identity.mu.Lock()
+		session, ok := n.identity.inbound[token]
+		if ok && time.Now().After(session.Expires) {
+			delete(n.identity.inbound, token)
+			ok = false
+		}
+		n.identity.mu.Unlock()
+
+		if !ok || !n.allowedPeer(session.NodeID) {
+			w.Header().Set(handshakeRequiredHeader, "1")
+			RespondWithJSON(w, r, http.StatusUnauthorized, "handshake required")
+			return
+		}
+		if n.peerTLS != nil && certNodeID(r.TLS) != session.NodeID {
+			RespondWithJSON(w, r, http.StatusUnauthorized, "the session has to be used over TLS with the node key it belongs to")
+			return
+		}
+		setAuditActor(r.Context(), "peer:"+session.NodeID)
+		handle(w, r.WithContext(context.WithValue(r.Context(), peerKey{}, session.NodeID)), ps)
+	}
+}
+
+// handshake proves who we are to a peer and checks who it is, returning the session token it gave us and
+// its node ID
+func (n *Node) handshake(base http.RoundTripper, peer string) (string, string, error) {
+	h := Handshake{NodeID: n.ID(), Timestamp: time.Now().Unix(), Nonce: randomHex(16), URL: n.externalURL()}
+	h.Signature = hex.EncodeToString(ed25519.Sign(n.key, handshakeMessage(h)))
+
+	body, _ := json.Marshal(h)
+	req, err := http.NewRequest(http.MethodPost, peer+"/v1/handshake", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("handshake with %s: %s", peer, res.Status)
+	}
+
+	var ack HandshakeAck
+	if err := json.NewDecoder(res.Body).Decode(&ack); err != nil {
+		return "", "", err
+	}
+	if !verifySignature(ack.NodeID, handshakeAckMessage(h.Nonce, ack.NodeID), ack.Signature) {
+		return "", "", errors.New("handshake with " + peer + ": bad signature from peer")
+	}
+	if n.peerTLS != nil && certNodeID(res.TLS) != ack.NodeID {
+		return "", "", errors.New("handshake with " + peer + ": its TLS certificate isn't for the key it signed with")
+	}
+	if ack.NodeID == n.ID() { // eg a DNS seed listing this node
+		return "", "", errors.New("handshake with " + peer + ": that's this node")
+	}
+	if !n.allowedPeer(ack.NodeID) {
+		return "", "", errors.New("handshake with " + peer + ": peer " + ack.NodeID + " is not allowed")
+	}
+	return ack.Session, ack.NodeID, nil
+}
+
+// handshakeTransport signs in to peers before talking to them and attaches the session to every request
+type handshakeTransport struct {
+	node *Node
+	base http.RoundTripper
+}
+
+func (t *handshakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	peer := req.URL.Scheme + "://" + req.URL.Host
+	id := t.node.identity
+
+	id.mu.Lock()
+	token, ok := id.outbound[peer]
+	id.mu.Unlock()
+
+	if !ok {
+		var nodeID string
+		var err error
+		if token, nodeID, err = t.node.handshake(t.base, peer); err != nil {
+			return nil, err
+		}
+		id.mu.Lock()
+		id.outbound[peer], id.peerIDs[peer] = token, nodeID
+		id.mu.Unlock()
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(sessionHeader, token)
+
+	res, err := t.base.RoundTrip(req)
+	if err == nil && res.Header.Get(handshakeRequiredHeader) != "" { // the session expired, sign in again next time
+		id.mu.Lock()
+		delete(id.outbound, peer)
+		id.mu.Unlock()
+	}
+	return res, err
+}
@@ -0,0 +1,271 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// A node more than a sync batch behind a peer, a new one most of all, syncs headers first: it downloads the
+// peer's headers from its own head on, up to maxHeaderBatch at a time, and checks they link up, carry their
+// work, are for this network and match the checkpoints before it asks for a single body. Headers are a
+// fraction of the size of blocks, so a peer claiming a chain it doesn't have is found out quickly and cheaply,
+// and once they check out the node knows exactly which blocks it's after. If the peer's headers don't build
+// on our head it's on another branch, and that's left to the usual fork handling.
+//
+// Each body has to be the block its header says, by hash, before it's validated in full and added, so a body
+// can come from any peer without being trusted. The headers are split into ranges of SyncBatchSize and handed
+// out to up to SyncPeers peers at once, the one the headers came from first, each getting another range as
+// soon as it's sent one. Ranges arrive in any order and are added in order, and peers only run so far ahead
+// of what's been added, so a slow range doesn't leave the rest piling up in memory. A peer that fails, sends
+// nothing, or doesn't send its range within SyncTimeout is dropped for the rest of the sync and its range
+// goes back in the queue for the others, along with the rest of any range that was only partly sent.
+
+// defaults for downloading blocks from several peers
+const (
+	defaultSyncPeers   = 8
+	defaultSyncTimeout = 30 * time.Second
+	syncRangesAhead    = 2 // how many ranges per peer can be downloaded ahead of the one to be added next
+)
+
+// errHeadersFork means a peer's headers don't carry on from our head
+var errHeadersFork = errors.New("headers don't build on our head")
+
+// errHeadersStalled means a peer sent a batch of headers that didn't carry on from the last one
+var errHeadersStalled = errors.New("headers don't carry on from the last batch")
+
+// errBodyMismatch means a peer sent a block that isn't the one its header says
+var errBodyMismatch = errors.New("block doesn't match its header")
+
+// bodyResult ... what came back from asking a peer for the blocks of a range of headers
+type bodyResult struct {
+	peer   string
+	want   []blockchain.Header
+	blocks []blockchain.Block
+	err    error
+}
+
+// syncHeadersFirst catches up with a peer that's far ahead, validating its headers before fetching bodies
+func (n *Node) syncHeadersFirst(ctx context.Context, peer string, head blockchain.Header) (err error) {
+	ctx, span := tracer.Start(ctx, "node.syncHeadersFirst", trace.WithAttributes(attribute.Int("peer.head", head.Index)))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	headers, err := n.fetchHeaders(peer, n.chain.Last().Header, head.Index)
+	if errors.Is(err, errHeadersFork) {
+		return n.syncFork(ctx, peer)
+	}
+	if err != nil {
+		return err
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	n.logger.Printf("validated %d headers from %s in %s, fetching their blocks", len(headers), peer, time.Since(start).Round(time.Millisecond))
+
+	added, err := n.downloadBodies(ctx, peer, headers)
+	if added > 0 {
+		n.persistAdded(ctx) // a sync that fails part way keeps what it got
+	}
+	return err
+}
+
+// fetchHeaders downloads and validates a peer's headers after prev, up to index to
+func (n *Node) fetchHeaders(peer string, prev blockchain.Header, to int) ([]blockchain.Header, error) {
+	var headers []blockchain.Header
+	for prev.Index < to {
+		batch, err := FetchHeaderRange(n.client, peer, prev.Index+1, maxHeaderBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 { // the peer has nothing more for us
+			break
+		}
+		if len(headers) == 0 && !n.chain.ValidateNextHeaders(prev, batch[:1]) { // another branch, or another genesis block
+			return nil, errHeadersFork
+		}
+		if !n.chain.ValidateNextHeaders(prev, batch) {
+			n.penalizeURL(peer, "invalid headers", penaltyInvalidBlock)
+			return nil, fmt.Errorf("invalid headers from %s after block %d", peer, prev.Index)
+		}
+		headers = append(headers, batch...)
+		prev = batch[len(batch)-1]
+	}
+	return headers, nil
+}
+
+// fetchChainHeaders downloads and validates a peer's headers from its genesis block up to index to, its
+// advertised head, for starting over on its chain, refusing them unless they start from our genesis block. It stops at to whatever the peer has past it, and gives up
+// on a batch that doesn't carry on from the last, so a peer can't keep it downloading forever.
+func (n *Node) fetchChainHeaders(peer string, to int) ([]blockchain.Header, error) {
+	var headers []blockchain.Header
+	for len(headers) <= to {
+		batch, err := FetchHeaderRange(n.client, peer, len(headers), maxHeaderBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if batch[0].Index != len(headers) {
+			n.penalizeURL(peer, "invalid headers", penaltyInvalidBlock)
+			return nil, fmt.Errorf("%w from %s after block %d", errHeadersStalled, peer, len(headers)-1)
+		}
+		if len(headers)+len(batch) > to+1 { // more than it advertised
+			batch = batch[:to+1-len(headers)]
+		}
+		headers = append(headers, batch...)
+	}
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	genesis := headers[0]
+	if genesis.Index != 0 || genesis.Hash != n.chain.Genesis().Hash || !n.chain.ValidateNextHeaders(genesis, headers[1:]) { // another genesis block is another network
+		n.penalizeURL(peer, "invalid headers", penaltyInvalidBlock)
+		return nil, fmt.Errorf("invalid headers from %s", peer)
+	}
+	return headers, nil
+}
+
+// bodyPeers returns the peers to download blocks from index from on from, the one the headers came from
+// first, leaving out banned ones and any that were behind that the last time they were synced with
+func (n *Node) bodyPeers(source string, from int) []string {
+	peers := []string{source}
+	for _, status := range n.peerStatus.list(n.peers()) {
+		if len(peers) >= n.cfg.SyncPeers {
+			break
+		}
+		if status.URL == source || (status.Head >= 0 && status.Head < from) {
+			continue
+		}
+		n.identity.mu.Lock()
+		nodeID := n.identity.peerIDs[status.URL]
+		n.identity.mu.Unlock()
+		if nodeID != "" && !n.allowedPeer(nodeID) {
+			continue
+		}
+		peers = append(peers, status.URL)
+	}
+	return peers
+}
+
+// downloadBodies downloads the blocks for validated headers from several peers at once, adding them in
+// order and storing them a range at a time. It returns how many were added, with an error if it couldn't
+// get them all.
+func (n *Node) downloadBodies(ctx context.Context, source string, headers []blockchain.Header) (int, error) {
+	peers := n.bodyPeers(source, headers[0].Index)
+	client := *n.client
+	client.Timeout = n.cfg.SyncTimeout
+
+	var pending [][]blockchain.Header // ranges waiting for a peer, in order
+	for i := 0; i < len(headers); i += n.cfg.SyncBatchSize {
+		end := i + n.cfg.SyncBatchSize
+		if end > len(headers) {
+			end = len(headers)
+		}
+		pending = append(pending, headers[i:end])
+	}
+
+	results := make(chan bodyResult, len(peers)) // room for one each, so none are left waiting if this returns early
+	idle := peers
+	inFlight := 0
+	done := make(map[int]bodyResult) // ranges that arrived before the ones ahead of them, by first index
+	next, last := headers[0].Index, headers[len(headers)-1].Index
+	ahead := syncRangesAhead * len(peers) * n.cfg.SyncBatchSize
+	added := 0
+
+	for next <= last {
+		for len(idle) > 0 && len(pending) > 0 && pending[0][0].Index < next+ahead {
+			peer, want := idle[0], pending[0]
+			idle, pending = idle[1:], pending[1:]
+			inFlight++
+			go func() {
+				blocks, err := n.fetchBodies(&client, peer, want)
+				results <- bodyResult{peer: peer, want: want, blocks: blocks, err: err}
+			}()
+		}
+		if inFlight == 0 { // every peer's been dropped
+			return added, fmt.Errorf("no peer could send the blocks from %d on", next)
+		}
+
+		res := <-results
+		inFlight--
+		if res.err != nil {
+			n.logger.Printf("not syncing any more blocks from %s, blocks %d to %d failed: %v", res.peer, res.want[0].Index, res.want[len(res.want)-1].Index, res.err)
+			if errors.Is(res.err, errBodyMismatch) && res.peer == source { // one on another branch could be honest
+				n.penalizeURL(res.peer, "block doesn't match its header", penaltyInvalidBlock)
+			}
+			pending = requeueRange(pending, res.want)
+			continue
+		}
+		idle = append(idle, res.peer)
+		if len(res.blocks) < len(res.want) {
+			pending = requeueRange(pending, res.want[len(res.blocks):])
+		}
+		done[res.want[0].Index] = res
+
+		for res, ok := done[next]; ok; res, ok = done[next] {
+			delete(done, next)
+			for _, block := range res.blocks {
+				if !n.addBlock(ctx, block) {
+					if n.chain.Last().Hash != block.PrevHash { // gossip or another sync got there first, the next sync carries on
+						return added, nil
+					}
+					n.penalizeURL(res.peer, "invalid block", penaltyInvalidBlock) // its header was fine, so it's the body that's wrong
+					return added, fmt.Errorf("block %d from %s is invalid", block.Index, res.peer)
+				}
+				n.mempool.RemoveIncluded(block)
+				n.bus.Publish(events.BlockAdded{Block: block, Origin: events.OriginSync})
+				added++
+			}
+			next += len(res.blocks)
+			if err := n.persistAdded(ctx); err != nil {
+				return added, err
+			}
+			n.logger.Printf("synced %d of %d blocks, from %d peers", added, len(headers), len(peers))
+		}
+	}
+	return added, nil
+}
+
+// requeueRange puts a range back in the queue, keeping it in order
+func requeueRange(pending [][]blockchain.Header, r []blockchain.Header) [][]blockchain.Header {
+	i := sort.Search(len(pending), func(i int) bool { return pending[i][0].Index > r[0].Index })
+	pending = append(pending, nil)
+	copy(pending[i+1:], pending[i:])
+	pending[i] = r
+	return pending
+}
+
+// fetchBodies downloads the blocks for a run of validated headers from a peer, checking each is the block
+// its header says. It can return fewer than asked for if the peer sends fewer, but never any that don't match.
+func (n *Node) fetchBodies(client *http.Client, peer string, headers []blockchain.Header) ([]blockchain.Block, error) {
+	blocks, err := FetchBlockRange(client, peer, headers[0].Index, len(headers))
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%s sent no blocks from %d", peer, headers[0].Index)
+	}
+	if len(blocks) > len(headers) {
+		blocks = blocks[:len(headers)]
+	}
+	for i, block := range blocks {
+		if block.Pruned { // not its fault, it just doesn't keep them
+			return nil, fmt.Errorf("%s has pruned block %d", peer, block.Index)
+		}
+		if block.Hash != headers[i].Hash || blockchain.GenerateHeaderHash(block.Header) != block.Hash {
+			return nil, fmt.Errorf("block %d: %w", headers[i].Index, errBodyMismatch)
+		}
+	}
+	return blocks, nil
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// headerPeer serves GET /v1/headers from headers, passing the range asked for through serve first
+func headerPeer(t *testing.T, headers []blockchain.Header, serve func(from, limit int) (int, int)) string {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		from, limit = serve(from, limit)
+		batch := []blockchain.Header{}
+		for i := from; i < len(headers) && len(batch) < limit; i++ {
+			batch = append(batch, headers[i])
+		}
+		json.NewEncoder(w).Encode(batch)
+	}))
+	t.Cleanup(peer.Close)
+	return peer.URL
+}
+
+func TestFetchChainHeaders(t *testing.T) {
+	n, err := New(Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.client = &http.Client{} // the fake peers don't shake hands
+	// chain builds 10 headers on top of genesis
+	chain := func(genesis blockchain.Block) []blockchain.Header {
+		headers := []blockchain.Header{genesis.Header}
+		for prev := genesis; len(headers) < 10; {
+			prev, _ = blockchain.GenerateBlock(prev, 0)
+			headers = append(headers, prev.Header)
+		}
+		return headers
+	}
+	headers := chain(n.chain.Genesis())
+	other := blockchain.NewGenesisBlockAt(time.Unix(0, 0))
+	other.Hash = blockchain.GenerateHash(other)
+	otherNetwork := chain(other)
+	asked := func(from, limit int) (int, int) { return from, 3 }  // small batches
+	fromStart := func(from, limit int) (int, int) { return 0, 3 } // the same batch every time
+	skipping := func(from, limit int) (int, int) { return from + 1, 3 }
+
+	tests := []struct {
+		name    string
+		headers []blockchain.Header
+		serve   func(from, limit int) (int, int)
+		head    int
+		want    int
+		wantErr bool
+		err     error
+	}{
+		{"up to its head", headers, asked, 9, 10, false, nil},
+		{"no further than it advertised", headers, asked, 4, 5, false, nil},
+		{"fewer than it advertised", headers, asked, 20, 10, false, nil},
+		{"the same batch again", headers, fromStart, 9, 0, true, errHeadersStalled},
+		{"skipping headers", headers, skipping, 9, 0, true, errHeadersStalled},
+		{"another genesis block", otherNetwork, asked, 9, 0, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := n.fetchChainHeaders(headerPeer(t, tt.headers, tt.serve), tt.head)
+			if len(got) != tt.want || (err != nil) != tt.wantErr || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("fetchChainHeaders() = %d headers, %v, want %d, %v", len(got), err, tt.want, tt.err)
+			}
+		})
+	}
+}
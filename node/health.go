@@ -0,0 +1,159 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// HealthReport ... the response from /healthz and /readyz
+type HealthReport struct {
+	Status string                 // "ok" or "unavailable"
+	Checks map[string]HealthCheck // by name: storage, sync, peers, last_block
+}
+
+// HealthCheck ... the result of one check
+type HealthCheck struct {
+	OK     bool
+	Detail string `json:",omitempty"`
+}
+
+// PeerStatus ... what the node last heard from a peer while syncing
+type PeerStatus struct {
+	URL       string
+	Head      int       // index of the peer's head block, -1 if it's never answered
+	LastSeen  time.Time // when it last answered, zero if never
+	LastError string    `json:",omitempty"` // why the last attempt failed, empty if it succeeded
+	Latency   string    `json:",omitempty"` // how long it took to answer the last time it did, eg "3ms"
+}
+
+// peerStatuses tracks every peer the node has tried to sync with
+type peerStatuses struct {
+	mu    sync.Mutex
+	peers map[string]PeerStatus
+}
+
+// record notes the outcome of asking a peer for its head, and how long it took
+func (s *peerStatuses) record(url string, head int, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.peers == nil {
+		s.peers = make(map[string]PeerStatus)
+	}
+
+	status, ok := s.peers[url]
+	if !ok {
+		status = PeerStatus{URL: url, Head: -1}
+	}
+	if err != nil {
+		status.LastError = err.Error()
+	} else {
+		status.Head, status.LastSeen, status.LastError = head, time.Now(), ""
+		status.Latency = latency.Round(time.Microsecond).String()
+	}
+	s.peers[url] = status
+}
+
+// list returns the status of each of the given peers, sorted by URL
+func (s *peerStatuses) list(urls []string) []PeerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]PeerStatus, 0, len(urls))
+	for _, url := range urls {
+		status, ok := s.peers[url]
+		if !ok {
+			status = PeerStatus{URL: url, Head: -1}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses
+}
+
+// Writable checks the data directory can still be written to, by creating and removing a file in it
+func (s *Store) Writable() error {
+	f, err := os.CreateTemp(filepath.Dir(s.path), "probe-*.tmp") // a leftover gets cleaned up by compaction
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// GetHealth handles the liveness probe, failing only when the node can't do its job at all
+func (n *Node) GetHealth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	n.respondWithHealth(w, r, map[string]HealthCheck{"storage": n.checkStorage()})
+}
+
+// GetReady handles the readiness probe, which also fails while the node is behind its peers,
+// can't reach any of them, or hasn't seen a block in MaxBlockAge
+func (n *Node) GetReady(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	n.respondWithHealth(w, r, map[string]HealthCheck{
+		"storage":    n.checkStorage(),
+		"sync":       n.checkSync(),
+		"peers":      n.checkPeers(),
+		"last_block": n.checkLastBlock(),
+	})
+}
+
+func (n *Node) respondWithHealth(w http.ResponseWriter, r *http.Request, checks map[string]HealthCheck) {
+	report := HealthReport{Status: "ok", Checks: checks}
+	code := http.StatusOK
+	for _, check := range checks {
+		if !check.OK {
+			report.Status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	RespondWithJSON(w, r, code, report)
+}
+
+func (n *Node) checkStorage() HealthCheck {
+	if n.store == nil {
+		return HealthCheck{OK: true, Detail: "in memory"}
+	}
+	if err := n.store.Writable(); err != nil {
+		return HealthCheck{OK: false, Detail: err.Error()}
+	}
+	return HealthCheck{OK: true}
+}
+
+// checkSync fails if any peer reported a head past ours the last time we asked
+func (n *Node) checkSync() HealthCheck {
+	head := n.chain.Last().Index
+	for _, peer := range n.peerStatus.list(n.peers()) {
+		if peer.Head > head {
+			return HealthCheck{OK: false, Detail: fmt.Sprintf("at block %d, %s is at %d", head, peer.URL, peer.Head)}
+		}
+	}
+	return HealthCheck{OK: true, Detail: fmt.Sprintf("at block %d", head)}
+}
+
+// checkPeers fails if there are peers and none of them answered the last time we asked
+func (n *Node) checkPeers() HealthCheck {
+	peers := n.peerStatus.list(n.peers())
+	if len(peers) == 0 {
+		return HealthCheck{OK: true, Detail: "no peers configured"}
+	}
+	reachable := 0
+	for _, peer := range peers {
+		if !peer.LastSeen.IsZero() && peer.LastError == "" {
+			reachable++
+		}
+	}
+	return HealthCheck{OK: reachable > 0, Detail: fmt.Sprintf("%d of %d reachable", reachable, len(peers))}
+}
+
+// checkLastBlock reports how long ago the head block was made, failing past MaxBlockAge if it's set
+func (n *Node) checkLastBlock() HealthCheck {
+	age := time.Since(n.chain.Last().Time())
+	if n.cfg.MaxBlockAge > 0 && age > n.cfg.MaxBlockAge {
+		return HealthCheck{OK: false, Detail: fmt.Sprintf("%s ago, more than %s", age.Round(time.Second), n.cfg.MaxBlockAge)}
+	}
+	return HealthCheck{OK: true, Detail: fmt.Sprintf("%s ago", age.Round(time.Second))}
+}
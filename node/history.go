@@ -0,0 +1,50 @@
+package node
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxHistoryPage caps how many transactions one request to /address/:address/txs can return
+const maxHistoryPage = 500
+
+// AddressHistory ... a page of the transactions an address has sent or received
+type AddressHistory struct {
+	Address string
+	Total   int // transactions in all, page through them with offset
+	Offset  int
+	Txs     []blockchain.TxRecord // newest first
+}
+
+// GetAddressTxs handles the route listing an address's transactions, newest first, eg /address/bob/txs?offset=50&limit=50
+func (n *Node) GetAddressTxs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	query := r.URL.Query()
+	offset, limit := 0, 50
+	if v := query.Get("offset"); v != "" {
+		var err error
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "offset must be a positive number")
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "limit must be at least 1")
+			return
+		}
+	}
+	if limit > maxHistoryPage {
+		limit = maxHistoryPage
+	}
+
+	address, ok := pathAddress(w, r, ps)
+	if !ok {
+		return
+	}
+	txs, total := n.chain.History(address, offset, limit)
+	RespondWithJSON(w, r, http.StatusOK, AddressHistory{Address: address, Total: total, Offset: offset, Txs: txs})
+}
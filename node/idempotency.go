@@ -0,0 +1,178 @@
+package node
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idempotencyHeader is the header clients send to make a POST safe to retry
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeys caps how many responses are remembered, the oldest are forgotten first
+const maxIdempotencyKeys = 10000
+
+// idempotentResponse ... a response remembered for a key, or a request with that key still being handled
+type idempotentResponse struct {
+	request [32]byte // hash of the method, path and body, so a key can't be reused for a different request
+	done    bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// idempotencyCache remembers the responses to POSTs with an Idempotency-Key, for IdempotencyWindow
+type idempotencyCache struct {
+	mu    sync.Mutex
+	keys  map[string]*idempotentResponse
+	order []idempotencyKey // oldest first, for expiring them
+}
+
+// idempotencyKey ... a key and the response it was claimed for, in case it's been forgotten and claimed again since
+type idempotencyKey struct {
+	key string
+	res *idempotentResponse
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{keys: make(map[string]*idempotentResponse)}
+}
+
+// start claims a key for a request, or returns a copy of what's already there for it
+func (c *idempotencyCache) start(key string, request [32]byte, expires time.Time) (idempotentResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for len(c.order) > 0 { // drop expired keys, and the oldest ones once there are too many
+		oldest := c.order[0]
+		if c.keys[oldest.key] == oldest.res {
+			if !oldest.res.done || (oldest.res.expires.After(now) && len(c.order) < maxIdempotencyKeys) {
+				break // still being handled, or still fresh
+			}
+			delete(c.keys, oldest.key)
+		}
+		c.order = c.order[1:]
+	}
+
+	if res, ok := c.keys[key]; ok {
+		return *res, false
+	}
+	res := &idempotentResponse{request: request, expires: expires}
+	c.keys[key] = res
+	c.order = append(c.order, idempotencyKey{key, res})
+	return *res, true
+}
+
+// finish remembers the response to a claimed key. Server errors aren't remembered, the key is
+// forgotten so the request can be retried for real.
+func (c *idempotencyCache) finish(key string, status int, header http.Header, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if status >= 500 {
+		delete(c.keys, key) // its entry in order is skipped when it comes up
+		return
+	}
+	res := c.keys[key]
+	res.status, res.header, res.body, res.done = status, header, body, true
+}
+
+// idempotent replays the original response to a POST retried with the same Idempotency-Key, so a
+// client that timed out waiting can safely try again without mining a second block or sending a
+// transaction twice. Keys are per credential, so one client can't claim or replay another's, a request
+// with a credential has to pass it before anything's replayed to it, and the admin and wallet routes,
+// whose responses can carry secrets like a backup phrase, are never remembered.
+func (n *Node) idempotent(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || r.Method != http.MethodPost || n.cfg.IdempotencyWindow < 0 || neverReplayed(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > 255 {
+			RespondWithJSON(w, r, http.StatusBadRequest, idempotencyHeader+" can be at most 255 characters")
+			return
+		}
+		if r.Header.Get("Authorization") != "" && !n.adminAuthorized(r) {
+			RespondWithJSON(w, r, http.StatusUnauthorized, "admin token required")
+			return
+		}
+		credential := sha256.Sum256([]byte(r.Header.Get("Authorization") + "\n" + r.Header.Get(sessionHeader)))
+		key = hex.EncodeToString(credential[:]) + " " + key
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			RespondWithJSON(w, r, http.StatusBadRequest, "reading the body: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		request := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+
+		res, fresh := n.idempotency.start(key, request, time.Now().Add(n.cfg.IdempotencyWindow))
+		switch {
+		case !fresh && res.request != request:
+			RespondWithJSON(w, r, http.StatusUnprocessableEntity, idempotencyHeader+" was already used for a different request")
+			return
+		case !fresh && !res.done:
+			RespondWithJSON(w, r, http.StatusConflict, "a request with this "+idempotencyHeader+" is still being handled")
+			return
+		case !fresh:
+			for name, values := range res.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(res.status)
+			w.Write(res.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		handled := false
+		defer func() {
+			if !handled { // the handler panicked, forget the key rather than leave it claimed
+				rec.status = http.StatusInternalServerError
+			}
+			header := w.Header().Clone()
+			header.Del(requestIDHeader) // the replay gets its own request ID
+			n.idempotency.finish(key, rec.status, header, rec.body.Bytes())
+		}()
+		h.ServeHTTP(rec, r)
+		handled = true
+	})
+}
+
+// neverReplayed returns if a path is an admin or wallet route, versioned or not
+func neverReplayed(path string) bool {
+	path = strings.TrimPrefix(path, "/v1")
+	for _, prefix := range []string{"/admin/", "/wallet/"} {
+		if path+"/" == prefix || strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder keeps a copy of everything a handler writes
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
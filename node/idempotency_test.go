@@ -0,0 +1,53 @@
+package node_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	n := blockchaintest.NewNode(t, func(cfg *node.Config) { cfg.AdminToken = "secret" })
+
+	// post sends an empty body with an Idempotency-Key, returning the status and if it was a replay
+	post := func(t *testing.T, path, key, authorization string) (int, bool) {
+		req, _ := http.NewRequest(http.MethodPost, n.URL()+path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		res, err := n.Client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode, res.Header.Get("Idempotent-Replayed") == "true"
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		retryAuth    string
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{"same credential", "/v1/tx", "Bearer secret", http.StatusBadRequest, true},
+		{"no credential", "/v1/tx", "", http.StatusBadRequest, false},
+		{"wrong admin token", "/v1/tx", "Bearer guess", http.StatusUnauthorized, false},
+		{"admin route", "/v1/admin/mining", "Bearer secret", http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "key-" + tt.name
+			post(t, tt.path, key, "Bearer secret")
+			status, replayed := post(t, tt.path, key, tt.retryAuth)
+			if status != tt.wantStatus || replayed != tt.wantReplayed {
+				t.Errorf("retry = %d, replayed %v, want %d, replayed %v", status, replayed, tt.wantStatus, tt.wantReplayed)
+			}
+		})
+	}
+}
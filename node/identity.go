@@ -0,0 +1,41 @@
+package node
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// loadOrCreateKey reads the node's ed25519 identity key from the data directory, creating one the first
+// time the node runs. Nodes without a data directory get a fresh key every start.
+func loadOrCreateKey(dataDir string) (ed25519.PrivateKey, error) {
+	if dataDir == "" {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+
+	path := filepath.Join(dataDir, "node.key")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(string(data))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("identity: " + path + " isn't a valid node key")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key.Seed())), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
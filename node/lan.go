@@ -0,0 +1,126 @@
+package node
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+)
+
+// With LAN discovery on, a node announces itself to a UDP multicast group every lanAnnounceInterval and
+// listens there for other nodes, adding any on its network as peers, so a classroom or a cluster of local
+// nodes finds each other without anyone editing peer lists. An announcement only says which port the API is
+// on, the address comes from where it was sent from, and nothing's taken on trust: the node still proves who
+// it is in the handshake, and banned nodes, itself and nodes on another chain are ignored. Multicast doesn't
+// usually cross routers, so it only finds nodes on the same network. A node listening on just one address,
+// eg 127.0.0.1 for several nodes on one machine, announces that instead, and loopback ones are only taken from
+// the same machine.
+
+// lan discovery defaults
+const (
+	defaultLANGroup     = "239.255.42.99:9999"
+	lanAnnounceInterval = 5 * time.Second
+)
+
+// LANAnnouncement ... what a node multicasts to say it's there
+type LANAnnouncement struct {
+	NodeID  string
+	ChainID string
+	Host    string `json:",omitempty"` // the address the API listens on, if it's only one, otherwise it's where the announcement came from
+	Port    int    // the API's port
+	TLS     bool   // if the API is served over https
+}
+
+// startLANDiscovery joins the multicast group and starts announcing the node and listening for others
+func (n *Node) startLANDiscovery() error {
+	group, err := net.ResolveUDPAddr("udp4", n.cfg.LANGroup)
+	if err != nil {
+		return err
+	}
+	listen, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return err
+	}
+	send, err := net.DialUDP("udp4", nil, group)
+	if err != nil {
+		listen.Close()
+		return err
+	}
+	go func() { // stop both when the node does
+		<-n.done
+		listen.Close()
+		send.Close()
+	}()
+	go n.lanListen(listen)
+	go n.lanAnnounce(send)
+	return nil
+}
+
+// lanAnnounce multicasts the node's announcement every lanAnnounceInterval
+func (n *Node) lanAnnounce(conn *net.UDPConn) {
+	ticker := time.NewTicker(lanAnnounceInterval)
+	defer ticker.Stop()
+
+	for {
+		host, port, _ := net.SplitHostPort(n.Addr())
+		if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() { // listening on every address
+			host = ""
+		}
+		p, _ := strconv.Atoi(port)
+		msg, _ := json.Marshal(LANAnnouncement{NodeID: n.ID(), ChainID: n.chain.Params().ChainID, Host: host, Port: p, TLS: n.certs != nil})
+		if _, err := conn.Write(msg); err != nil {
+			n.logger.Printf("LAN announcement failed: %v", err)
+		}
+
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lanListen adds the nodes announcing themselves on the group as peers, until the connection's closed
+func (n *Node) lanListen(conn *net.UDPConn) {
+	buf := make([]byte, 1024)
+	for {
+		size, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		var a LANAnnouncement
+		if json.Unmarshal(buf[:size], &a) != nil || a.NodeID == n.ID() || a.ChainID != n.chain.Params().ChainID || a.Port <= 0 || !n.allowedPeer(a.NodeID) {
+			continue
+		}
+		host := from.IP.String()
+		if a.Host != "" {
+			if ip := net.ParseIP(a.Host); ip == nil || (ip.IsLoopback() && !localIP(from.IP)) { // loopback only reaches the same machine
+				continue
+			}
+			host = a.Host
+		}
+		scheme := "http://"
+		if a.TLS {
+			scheme = "https://"
+		}
+		peer := scheme + net.JoinHostPort(host, strconv.Itoa(a.Port))
+		if n.addPeer(peer) {
+			n.logger.Printf("found peer %s (%s) on the LAN", peer, a.NodeID)
+			go n.SyncWithPeer(peer)
+		}
+	}
+}
+
+// localIP returns if ip is one of this machine's addresses
+func localIP(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
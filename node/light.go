@@ -0,0 +1,292 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// A light node keeps just the chain's headers, for somewhere without the disk, bandwidth or CPU for the
+// bodies. It syncs them from its peers the way a full node syncs headers first, checking they link up, carry
+// their work, are for this network and match the checkpoints, and follows the headers with the most work
+// behind them, but never fetches a body. Transactions it's asked about are checked with merkle proofs from
+// full-node peers instead: the proof has to lead from the transaction's hash up to the merkle root in a header
+// the node already has, so a peer can't make a transaction up or put it in the wrong block, though it can
+// leave one out. That's why an address's transactions are asked of every peer.
+//
+// With no bodies there are no balances, mempool or mining, so a light node only serves the routes that make
+// sense without them, and proves balances with peers' state proofs, see stateproof.go. Transactions sent to it
+// are passed on to a full-node peer.
+
+// penaltyBadProof is for a merkle proof that doesn't check out against a header both nodes have
+const penaltyBadProof = 50
+
+// VerifiedTx ... a transaction a light node has checked is in its chain with a merkle proof
+type VerifiedTx struct {
+	Transaction   blockchain.Transaction
+	BlockIndex    int
+	BlockHash     string
+	Confirmations int    // blocks from the transaction's up to the head, 1 if it's in the head
+	Peer          string // the peer the proof came from
+}
+
+// LightAddressTxs ... an address's transactions a light node has verified, from GET /verify/address/:address
+type LightAddressTxs struct {
+	Address string
+	Txs     []VerifiedTx // newest first
+}
+
+// routesLight registers the routes a light node serves, the ones that don't need block bodies
+func (n *Node) routesLight(r apiRoutes) {
+	r.GET("/", n.GetBlockchain)
+	r.GET("/headers", n.GetHeaders)
+	r.GET("/head", n.GetHead)
+	r.GET("/forks", n.GetForks)
+	r.GET("/blocks", n.GetBlocks)
+	r.GET("/block/:id", n.GetBlock)
+	r.GET("/blocks/at", n.GetBlockAt)
+	r.GET("/profile", n.GetProfile)
+	r.GET("/verify/tx/:txhash", n.GetVerifyTx)
+	r.GET("/verify/address/:address", n.GetVerifyAddress)
+	r.GET("/verify/balance/:address", n.GetVerifyBalance)
+	r.POST("/tx", n.PostLightTx)
+	r.GET("/events", n.GetEvents)
+	r.GET("/events/ws", n.GetEventsWebSocket)
+	r.GET("/peers", n.GetPeers)
+	r.POST("/handshake", n.PostHandshake)
+	r.POST("/inv", n.peerOnly(n.PostLightInventory))
+
+	r.GET("/admin/maintenance", n.adminOnly(n.GetMaintenance))
+	r.POST("/admin/maintenance/:task", n.adminOnly(n.TriggerMaintenance))
+	r.POST("/admin/snapshot", n.adminOnly(n.PostSnapshot))
+	r.GET("/admin/bans", n.adminOnly(n.GetBans))
+	r.POST("/admin/bans/:id", n.adminOnly(n.PostBan))
+	r.DELETE("/admin/bans/:id", n.adminOnly(n.DeleteBan))
+	r.GET("/admin/scores", n.adminOnly(n.GetScores))
+	r.GET("/admin/nat", n.adminOnly(n.GetNAT))
+	r.POST("/admin/peers", n.adminOnly(n.PostPeers))
+	r.GET("/admin/settings", n.adminOnly(n.GetSettings))
+	r.POST("/admin/settings", n.adminOnly(n.PostSettings))
+}
+
+// syncLight catches up with a peer's headers, adopting all of them if they're on another branch with more work
+func (n *Node) syncLight(ctx context.Context, peer string, head blockchain.Header) error {
+	headers, err := n.fetchHeaders(peer, n.chain.Last().Header, head.Index)
+	if errors.Is(err, errHeadersFork) {
+		return n.lightFork(ctx, peer, head.Index)
+	}
+	if err != nil {
+		return err
+	}
+
+	added := 0
+	for _, header := range headers {
+		if !n.chain.AddHeader(header) { // gossip or another sync got there first, the next sync carries on
+			break
+		}
+		n.bus.Publish(events.BlockAdded{Block: blockchain.Block{Header: header, Pruned: true}, Origin: events.OriginSync})
+		added++
+	}
+	if added == 0 {
+		return nil
+	}
+	n.logger.Printf("synced %d headers from %s", added, peer)
+	return n.persistAdded(ctx)
+}
+
+// lightFork fetches a peer's headers up to its head and switches to them if they check out and have more work
+// behind them than ours
+func (n *Node) lightFork(ctx context.Context, peer string, head int) error {
+	headers, err := n.fetchChainHeaders(peer, head)
+	if err != nil || len(headers) == 0 {
+		return err
+	}
+
+	blocks := make([]blockchain.Block, len(headers))
+	for i, header := range headers {
+		blocks[i] = blockchain.Block{Header: header, Pruned: true}
+	}
+	reorg, ok := n.chain.ReplaceChain(blocks)
+	if !ok {
+		return nil
+	}
+	n.logger.Printf("replaced chain with %d headers from %s, forking at %d and dropping %d", len(headers), peer, reorg.Fork, len(reorg.Removed))
+	n.bus.Publish(events.ChainReorg{Head: n.chain.Last().Header, Length: n.chain.Len(), Fork: reorg.Fork, Removed: len(reorg.Removed)})
+	return n.persist(ctx)
+}
+
+// PostLightInventory handles a peer announcing blocks or transactions to a light node. It never wants them,
+// a new block just means there are headers to sync.
+func (n *Node) PostLightInventory(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var inv Inventory
+	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+		n.penalize(peerID(r.Context()), "malformed message", penaltyMalformed)
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid inventory: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if inv.Type == InvBlock {
+		for _, hash := range inv.Hashes {
+			if _, ok := n.chain.BlockByHash(hash); !ok && n.seen.Add(hash) {
+				go n.syncWithPeers()
+				break
+			}
+		}
+	}
+	RespondWithJSON(w, r, http.StatusOK, []string{})
+}
+
+// PostLightTx handles a transaction sent to a light node, checking what it can and passing it on to a peer.
+// A peer that turns it down has the last word, one that can't be reached is skipped.
+func (n *Node) PostLightTx(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var tx blockchain.Transaction
+	if err := json.NewDecoder(r.Body).Decode(&tx); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid transaction: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	tx, err := parseTxAddresses(withTxDefaults(tx, n.chain.Params().ChainID))
+	if err == nil {
+		err = tx.Validate()
+	}
+	setAuditTarget(r.Context(), tx.Hash())
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+
+	payload, err := json.Marshal(tx)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, peer := range n.peers() {
+		res, err := n.client.Post(strings.TrimRight(peer, "/")+"/v1/tx", "application/json", bytes.NewReader(payload))
+		if err != nil {
+			n.logger.Printf("passing transaction %s to %s failed: %v", tx.Hash(), peer, err)
+			continue
+		}
+		var body json.RawMessage
+		json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if res.StatusCode >= 500 {
+			n.logger.Printf("passing transaction %s to %s failed: %s", tx.Hash(), peer, res.Status)
+			continue
+		}
+		RespondWithJSON(w, r, res.StatusCode, body) // accepted, or why not
+		return
+	}
+	RespondWithJSON(w, r, http.StatusServiceUnavailable, "no peer took the transaction")
+}
+
+// verifyTx asks a peer for a transaction's merkle proof and checks it against the header the node has for its
+// block. A proof for a block the node doesn't have isn't the peer's fault, it may be on another branch.
+func (n *Node) verifyTx(peer, hash string) (VerifiedTx, error) {
+	proof, err := FetchProof(n.client, peer, hash)
+	if err != nil {
+		return VerifiedTx{}, err
+	}
+	blocks := n.chain.Range(proof.BlockIndex, 1)
+	if len(blocks) == 0 || blocks[0].Hash != proof.BlockHash {
+		return VerifiedTx{}, fmt.Errorf("%s proved %s is in block %s, which isn't in our chain", peer, hash, proof.BlockHash)
+	}
+	header := blocks[0].Header
+	if proof.Tx == nil || proof.Tx.Hash() != hash || proof.TxHash != hash || !blockchain.VerifyProof(hash, proof.Branch, header.MerkleRoot) {
+		n.penalizeURL(peer, "bad merkle proof", penaltyBadProof)
+		return VerifiedTx{}, fmt.Errorf("%s sent a proof for %s that doesn't check out", peer, hash)
+	}
+	return VerifiedTx{
+		Transaction:   *proof.Tx,
+		BlockIndex:    header.Index,
+		BlockHash:     header.Hash,
+		Confirmations: n.chain.Last().Index - header.Index + 1,
+		Peer:          peer,
+	}, nil
+}
+
+// GetVerifyTx handles the route checking a transaction is in the chain, with a merkle proof from the first peer
+// that has one
+func (n *Node) GetVerifyTx(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	hash := ps.ByName("txhash")
+	for _, peer := range n.peers() {
+		verified, err := n.verifyTx(peer, hash)
+		if err != nil {
+			n.debug(err)
+			continue
+		}
+		RespondWithJSON(w, r, http.StatusOK, verified)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusNotFound, "no peer could prove the transaction is in the chain")
+}
+
+// GetVerifyAddress handles the route listing an address's latest transactions, eg /verify/address/bob?limit=20.
+// Every peer's asked, so one leaving a transaction out isn't enough to hide it, and only the ones proved to be
+// in the chain are listed.
+func (n *Node) GetVerifyAddress(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "limit must be at least 1")
+			return
+		}
+	}
+	if limit > maxHistoryPage {
+		limit = maxHistoryPage
+	}
+	address, ok := pathAddress(w, r, ps)
+	if !ok {
+		return
+	}
+
+	verified := make(map[string]VerifiedTx)
+	for _, peer := range n.peers() {
+		history, err := FetchAddressTxs(n.client, peer, address, limit)
+		if err != nil {
+			n.debug(err)
+			continue
+		}
+		for _, record := range history.Txs {
+			if _, ok := verified[record.Hash]; ok {
+				continue
+			}
+			tx, err := n.verifyTx(peer, record.Hash)
+			if err != nil {
+				n.debug(err)
+				continue
+			}
+			if tx.Transaction.From != address && tx.Transaction.To != address { // proved, but not the address's
+				continue
+			}
+			verified[record.Hash] = tx
+		}
+	}
+
+	txs := make([]VerifiedTx, 0, len(verified))
+	for _, tx := range verified {
+		txs = append(txs, tx)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].BlockIndex != txs[j].BlockIndex {
+			return txs[i].BlockIndex > txs[j].BlockIndex
+		}
+		return txs[i].Transaction.Hash() > txs[j].Transaction.Hash()
+	})
+	if len(txs) > limit {
+		txs = txs[:limit]
+	}
+	RespondWithJSON(w, r, http.StatusOK, LightAddressTxs{Address: address, Txs: txs})
+}
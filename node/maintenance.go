@@ -0,0 +1,213 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// MaintenanceRun ... the record of one run of a maintenance task
+type MaintenanceRun struct {
+	Task     string
+	Started  time.Time
+	Finished time.Time
+	Trigger  string // "schedule" or "manual"
+	Error    string // empty if the run succeeded
+}
+
+// maintenanceTask is a named job the scheduler runs
+type maintenanceTask struct {
+	name    string
+	run     func() error
+	lastRun time.Time
+}
+
+// maintenance runs housekeeping tasks (compaction, index rebuilds, snapshots, cleanup)
+// inside a configured window and keeps a history of runs
+type maintenance struct {
+	mu      sync.Mutex
+	tasks   []*maintenanceTask
+	history []MaintenanceRun
+	running map[string]bool
+}
+
+// maxMaintenanceHistory caps how many runs are remembered
+const maxMaintenanceHistory = 100
+
+// RegisterMaintenance adds a task for the scheduler to run once per maintenance interval
+func (n *Node) RegisterMaintenance(name string, run func() error) {
+	n.maintenance.mu.Lock()
+	defer n.maintenance.mu.Unlock()
+	n.maintenance.tasks = append(n.maintenance.tasks, &maintenanceTask{name: name, run: run})
+}
+
+// registerMaintenanceTasks sets up the built in housekeeping tasks
+func (n *Node) registerMaintenanceTasks() {
+	n.RegisterMaintenance("compact", n.compactStore)
+	n.RegisterMaintenance("verify", n.verifyChain)
+	if n.cfg.SnapshotDir != "" || n.cfg.DataDir != "" {
+		n.RegisterMaintenance("snapshot", func() error {
+			_, err := n.WriteSnapshot()
+			return err
+		})
+	}
+}
+
+// compactStore rewrites the stored chain from memory and clears out leftover temp files
+func (n *Node) compactStore() error {
+	if n.store == nil {
+		return nil
+	}
+	n.storeMu.Lock() // a save or append landing in between would be overwritten with the older snapshot
+	defer n.storeMu.Unlock()
+	if n.closed() {
+		return nil
+	}
+	return n.store.Compact(n.chain.Blocks(), n.chain.EncodeIndex())
+}
+
+// verifyChain re-validates the whole chain so corruption is caught early
+func (n *Node) verifyChain() error {
+	if !n.chain.Validate(n.chain.Blocks()) {
+		return fmt.Errorf("chain failed validation")
+	}
+	return nil
+}
+
+// inMaintenanceWindow returns if t falls in the configured window, eg "02:00-04:00".
+// An empty window means any time is fine, and windows can wrap past midnight.
+func inMaintenanceWindow(window string, t time.Time) bool {
+	if window == "" {
+		return true
+	}
+
+	parts := strings.SplitN(window, "-", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	start, err1 := time.Parse("15:04", parts[0])
+	end, err2 := time.Parse("15:04", parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+	if from <= to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to // wraps past midnight
+}
+
+// maintenanceLoop checks every minute for tasks that are due and inside the window
+func (n *Node) maintenanceLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case now := <-ticker.C:
+			if !inMaintenanceWindow(n.cfg.MaintenanceWindow, now) {
+				continue
+			}
+
+			n.maintenance.mu.Lock()
+			var due []string
+			for _, task := range n.maintenance.tasks {
+				if now.Sub(task.lastRun) >= n.cfg.MaintenanceInterval {
+					due = append(due, task.name)
+				}
+			}
+			n.maintenance.mu.Unlock()
+
+			for _, name := range due {
+				n.runMaintenance(name, "schedule")
+			}
+		}
+	}
+}
+
+// runMaintenance runs a task by name and records the run, returning false if there's no such task
+// or it's already running
+func (n *Node) runMaintenance(name, trigger string) (MaintenanceRun, bool) {
+	m := &n.maintenance
+
+	m.mu.Lock()
+	var task *maintenanceTask
+	for _, t := range m.tasks {
+		if t.name == name {
+			task = t
+		}
+	}
+	if task == nil || m.running[name] {
+		m.mu.Unlock()
+		return MaintenanceRun{}, false
+	}
+	m.running[name] = true
+	m.mu.Unlock()
+
+	run := MaintenanceRun{Task: name, Started: time.Now(), Trigger: trigger}
+	if err := task.run(); err != nil {
+		run.Error = err.Error()
+		n.logger.Printf("maintenance task %s failed: %v", name, err)
+	}
+	run.Finished = time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.running, name)
+	task.lastRun = run.Started
+	m.history = append(m.history, run)
+	if len(m.history) > maxMaintenanceHistory {
+		m.history = m.history[len(m.history)-maxMaintenanceHistory:]
+	}
+	return run, true
+}
+
+// MaintenanceStatus ... what the maintenance scheduler is up to
+type MaintenanceStatus struct {
+	Window   string           // when scheduled runs happen, empty for any time
+	Interval string           // how often each task runs
+	Tasks    []string         // every registered task
+	Running  []string         // tasks running right now
+	History  []MaintenanceRun // recent runs, oldest first
+}
+
+// GetMaintenance handles the admin route to inspect maintenance tasks and their recent runs
+func (n *Node) GetMaintenance(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	m := &n.maintenance
+	m.mu.Lock()
+	status := MaintenanceStatus{
+		Window:   n.cfg.MaintenanceWindow,
+		Interval: n.cfg.MaintenanceInterval.String(),
+		Tasks:    []string{},
+		Running:  []string{},
+		History:  append([]MaintenanceRun{}, m.history...),
+	}
+	for _, task := range m.tasks {
+		status.Tasks = append(status.Tasks, task.name)
+		if m.running[task.name] {
+			status.Running = append(status.Running, task.name)
+		}
+	}
+	m.mu.Unlock()
+
+	RespondWithJSON(w, r, http.StatusOK, status)
+}
+
+// TriggerMaintenance handles the admin route to run a maintenance task right now
+func (n *Node) TriggerMaintenance(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	run, ok := n.runMaintenance(ps.ByName("task"), "manual")
+	if !ok {
+		RespondWithJSON(w, r, http.StatusConflict, "no such task, or it's already running")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, run)
+}
@@ -0,0 +1,432 @@
+package node
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// the reasons a transaction can be turned away from the mempool
+var (
+	ErrDuplicateTx = errors.New("transaction already in mempool")
+	ErrCoinbaseTx  = errors.New("coinbase, stake reward and slash transactions can only be created by a block's miner")
+	ErrDoubleSpend = errors.New("transaction spends a nonce that's already been spent")
+	ErrStaleNonce  = errors.New("transaction nonce has to be higher than the sender's last confirmed nonce")
+	ErrTimelocked  = errors.New("transaction is timelocked until a later block")
+	ErrExpired     = errors.New("transaction has expired, it can't go in the next block")
+	ErrTxTooLarge  = errors.New("transaction is too big or costs too much to ever fit in a block")
+	ErrMempoolFull = errors.New("mempool is full, and the transaction doesn't pay a high enough fee rate to push any out")
+)
+
+// Mempool holds transactions waiting to be put in a block, in the order they arrived
+type Mempool struct {
+	mu     sync.Mutex
+	txs    []blockchain.Transaction
+	meta   []txMeta          // what's been worked out about each of txs, in the same order
+	seen   map[string]bool   // hashes of every transaction in the pool
+	spends map[string]string // spend key -> hash of the pending transaction spending it
+	bytes  int               // the transactions' sizes added up
+
+	limits mempoolLimits
+	stats  evictionStats
+}
+
+// txMeta ... a pending transaction's hash, size and fee rate, so they aren't worked out every time they're needed
+type txMeta struct {
+	hash    string
+	size    int
+	rate    float64
+	arrived time.Time
+}
+
+func newTxMeta(tx blockchain.Transaction, arrived time.Time) txMeta {
+	return txMeta{hash: tx.Hash(), size: tx.Size(), rate: tx.FeeRate(), arrived: arrived}
+}
+
+// NewMempool returns an empty mempool
+func NewMempool() *Mempool {
+	return &Mempool{seen: make(map[string]bool), spends: make(map[string]string)}
+}
+
+// push appends a transaction to the pool, m.mu has to be held
+func (m *Mempool) push(tx blockchain.Transaction, meta txMeta) {
+	m.txs = append(m.txs, tx)
+	m.meta = append(m.meta, meta)
+	m.seen[meta.hash] = true
+	if key := tx.SpendKey(); key != "" {
+		m.spends[key] = meta.hash
+	}
+	m.bytes += meta.size
+}
+
+// drop removes the transactions remove picks by index, returning them. m.mu has to be held.
+func (m *Mempool) drop(remove func(i int) bool) []txMeta {
+	var dropped []txMeta
+	txs, meta := m.txs[:0], m.meta[:0]
+	for i, tx := range m.txs {
+		if !remove(i) {
+			txs, meta = append(txs, tx), append(meta, m.meta[i])
+			continue
+		}
+		delete(m.seen, m.meta[i].hash)
+		delete(m.spends, tx.SpendKey())
+		m.bytes -= m.meta[i].size
+		dropped = append(dropped, m.meta[i])
+	}
+	m.txs, m.meta = txs, meta
+	return dropped
+}
+
+// Add puts a transaction in the pool
+func (m *Mempool) Add(tx blockchain.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tx.BlockMade() {
+		return ErrCoinbaseTx
+	}
+
+	meta := newTxMeta(tx, time.Now())
+	if m.seen[meta.hash] {
+		return ErrDuplicateTx
+	}
+	key := tx.SpendKey()
+	if _, ok := m.spends[key]; ok && key != "" {
+		return ErrDoubleSpend
+	}
+	if err := m.makeRoom(1, meta.size, meta.rate); err != nil {
+		return err
+	}
+	m.push(tx, meta)
+	return nil
+}
+
+// Get finds a pending transaction by hash
+func (m *Mempool) Get(hash string) (blockchain.Transaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.seen[hash] {
+		return blockchain.Transaction{}, false
+	}
+	for i, meta := range m.meta {
+		if meta.hash == hash {
+			return m.txs[i], true
+		}
+	}
+	return blockchain.Transaction{}, false
+}
+
+// Pending returns a copy of every transaction in the pool, oldest first
+func (m *Mempool) Pending() []blockchain.Transaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	txs := make([]blockchain.Transaction, len(m.txs))
+	copy(txs, m.txs)
+	return txs
+}
+
+// Entries returns every transaction in the pool with when it arrived, oldest first
+func (m *Mempool) Entries() []MempoolTx {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := make([]MempoolTx, len(m.txs))
+	for i, meta := range m.meta {
+		entries[i] = MempoolTx{Hash: meta.hash, Tx: m.txs[i], Arrived: meta.arrived, Size: meta.size, FeeRate: meta.rate}
+	}
+	return entries
+}
+
+// Remove drops a pending transaction by hash, returning it if it was there
+func (m *Mempool) Remove(hash string) (blockchain.Transaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.seen[hash] {
+		return blockchain.Transaction{}, false
+	}
+	for i, meta := range m.meta {
+		if meta.hash == hash {
+			tx := m.txs[i]
+			m.drop(func(j int) bool { return j == i })
+			return tx, true
+		}
+	}
+	return blockchain.Transaction{}, false
+}
+
+// Len returns the number of transactions in the pool
+func (m *Mempool) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.txs)
+}
+
+// RemoveIncluded drops any transactions a block has confirmed, along with any that conflict with them
+func (m *Mempool) RemoveIncluded(block blockchain.Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	included := make(map[string]bool, len(block.Transactions))
+	nonces := make(map[string]int) // highest nonce the block confirmed for each sender
+	for _, tx := range block.Transactions {
+		included[tx.Hash()] = true
+		if tx.From != "" {
+			nonces[tx.From] = tx.Nonce
+		}
+	}
+
+	m.drop(func(i int) bool {
+		tx := m.txs[i]
+		last, ok := nonces[tx.From]
+		return included[m.meta[i].hash] || (ok && tx.Nonce <= last) // confirmed, or its nonce is now spent or stale
+	})
+}
+
+// AddAll puts a batch of transactions in the pool, either all of them or, if any can't go in, none.
+// It returns the index of the first one that couldn't, and why.
+func (m *Mempool) AddAll(txs []blockchain.Transaction) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hashes := make(map[string]bool, len(txs))
+	keys := make(map[string]bool, len(txs))
+	metas := make([]txMeta, len(txs))
+	now := time.Now()
+	size, cheapest := 0, 0
+	for i, tx := range txs { // check the batch against the pool and itself first
+		if tx.BlockMade() {
+			return i, ErrCoinbaseTx
+		}
+		meta, key := newTxMeta(tx, now), tx.SpendKey()
+		if m.seen[meta.hash] || hashes[meta.hash] {
+			return i, ErrDuplicateTx
+		}
+		if _, ok := m.spends[key]; key != "" && (ok || keys[key]) {
+			return i, ErrDoubleSpend
+		}
+		hashes[meta.hash], keys[key] = true, true
+		metas[i] = meta
+		size += meta.size
+		if meta.rate < metas[cheapest].rate {
+			cheapest = i
+		}
+	}
+	lowest := 0.0
+	if len(txs) > 0 {
+		lowest = metas[cheapest].rate
+	}
+	if err := m.makeRoom(len(txs), size, lowest); err != nil { // every one of them has to be worth more than what makes room
+		return cheapest, err
+	}
+
+	for i, tx := range txs {
+		m.push(tx, metas[i])
+	}
+	return 0, nil
+}
+
+// addTx puts a transaction in the mempool, unless it isn't signed, sends coins its sender doesn't have, spends something
+// a confirmed transaction already has or its nonce is behind the sender's, or it's still timelocked or has expired, or it could never fit in a block
+func (n *Node) addTx(tx blockchain.Transaction) error {
+	if err := n.checkTx(tx); err != nil {
+		return err
+	}
+	if n.cfg.RBFBump < 0 { // replacing is off
+		if err := n.mempool.Add(tx); err != nil {
+			return err
+		}
+		n.bus.Publish(events.TxAdmitted{Tx: tx})
+		return nil
+	}
+	old, replaced, err := n.mempool.Replace(tx, n.cfg.RBFBump)
+	if err != nil {
+		return err
+	}
+	if replaced {
+		n.bus.Publish(events.TxReplaced{Old: old, New: tx})
+	}
+	n.bus.Publish(events.TxAdmitted{Tx: tx})
+	return nil
+}
+
+// checkTx checks a transaction is well formed and signed by its sender, then against the chain, for addTx
+func (n *Node) checkTx(tx blockchain.Transaction) error {
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+	if _, ok := n.chain.Spent(tx.SpendKey()); ok {
+		return ErrDoubleSpend
+	}
+	if last, ok := n.chain.Nonce(tx.From); ok && tx.From != "" && tx.Nonce <= last { // a replay, or the sender has moved past it
+		return ErrStaleNonce
+	}
+	if tx.ChainID != n.chain.Params().ChainID { // signed for another network
+		return blockchain.ErrWrongChain
+	}
+	if sent := tx.Spends(); sent > 0 && tx.From != "" {
+		balance, err := n.spendable(tx.From, tx.SpendKey())
+		if err == nil && balance < sent { // a pruned node that's lost what its pruned blocks changed can't tell
+			return blockchain.ErrInsufficientFunds
+		}
+	}
+	if !tx.Final(n.chain.Last().Index+1, time.Now()) { // has to be able to go in the next block
+		return ErrTimelocked
+	}
+	if tx.Expired(n.chain.Last().Index+1, time.Now()) {
+		return ErrExpired
+	}
+	if params := n.chain.Params().At(n.chain.Last().Index + 1); (params.MaxBlockSize > 0 && len(tx.Encode()) > params.MaxBlockSize) || (params.MaxBlockCost > 0 && tx.Cost() > params.MaxBlockCost) {
+		return ErrTxTooLarge
+	}
+	if err := n.chain.Tokens().Apply(tx); err != nil { // token transfers need the sender to hold the tokens
+		return err
+	}
+	if err := n.chain.Assets().Apply(tx); err != nil { // and asset transfers need them to own the asset
+		return err
+	}
+	if err := n.chain.ValidatorSet().Apply(tx, n.chain.Last().Index+1); err != nil { // and validator votes need them to be a validator
+		return err
+	}
+	if err := n.chain.Stakes().Apply(tx, n.chain.Validators(), n.chain.Params()); err != nil { // and undelegating needs the stake to be there
+		return err
+	}
+	if err := n.chain.Governance().Apply(tx, n.chain.Last().Index+1); err != nil { // and votes need an open proposal
+		return err
+	}
+	return nil
+}
+
+// nextNonce returns the nonce a sender's next transaction needs, after its confirmed and pending ones
+func (n *Node) nextNonce(sender string) int {
+	next := 1
+	if last, ok := n.chain.Nonce(sender); ok {
+		next = last + 1
+	}
+	for _, tx := range n.mempool.Pending() {
+		if tx.From == sender && tx.Nonce >= next {
+			next = tx.Nonce + 1
+		}
+	}
+	return next
+}
+
+// maxMempoolPage caps how many transactions one request to /mempool can return
+const maxMempoolPage = 500
+
+// MempoolTx ... a pending transaction and how it's placed
+type MempoolTx struct {
+	Hash     string
+	Tx       blockchain.Transaction
+	Arrived  time.Time
+	Size     int     // bytes, what FeeRate is per
+	FeeRate  float64 // fee per byte, what blocks are filled by
+	Position int     // how many pending transactions pay a higher fee rate or got there first at the same one, so go in a block ahead of it
+}
+
+// MempoolPage ... a page of the pending transactions
+type MempoolPage struct {
+	Total  int // pending transactions in all, or from the address asked for
+	Bytes  int // their size in all
+	Offset int
+	Txs    []MempoolTx
+}
+
+// positions fills in where each entry is in line for a block, entries have to be oldest first
+func positions(entries []MempoolTx) {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return entries[order[i]].FeeRate > entries[order[j]].FeeRate })
+	for position, i := range order {
+		entries[i].Position = position
+	}
+}
+
+// GetMempool handles the route listing pending transactions, oldest first or with ?sort=fee highest fee rate
+// first, eg /mempool?sort=fee&from=2h...&offset=50&limit=50
+func (n *Node) GetMempool(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+	offset, limit := 0, 50
+	if v := query.Get("offset"); v != "" {
+		var err error
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "offset must be a positive number")
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "limit must be at least 1")
+			return
+		}
+	}
+	if limit > maxMempoolPage {
+		limit = maxMempoolPage
+	}
+	order := query.Get("sort")
+	if order != "" && order != "age" && order != "fee" {
+		RespondWithJSON(w, r, http.StatusBadRequest, "sort must be age or fee")
+		return
+	}
+	from, err := blockchain.ParseAddress(query.Get("from"))
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries := n.mempool.Entries()
+	positions(entries)
+	if order == "fee" {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Position < entries[j].Position })
+	}
+	page := MempoolPage{Offset: offset, Txs: []MempoolTx{}}
+	for _, entry := range entries {
+		if from != "" && entry.Tx.From != from {
+			continue
+		}
+		if page.Total >= offset && len(page.Txs) < limit {
+			page.Txs = append(page.Txs, entry)
+		}
+		page.Total++
+		page.Bytes += entry.Size
+	}
+	RespondWithJSON(w, r, http.StatusOK, page)
+}
+
+// GetMempoolTx handles the route to look up a pending transaction
+func (n *Node) GetMempoolTx(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	entries := n.mempool.Entries()
+	positions(entries)
+	for _, entry := range entries {
+		if entry.Hash == ps.ByName("txhash") {
+			RespondWithJSON(w, r, http.StatusOK, entry)
+			return
+		}
+	}
+	RespondWithJSON(w, r, http.StatusNotFound, "transaction isn't pending")
+}
+
+// DeleteMempoolTx handles the admin route evicting a pending transaction, eg one stuck behind a nonce that
+// never arrived. It isn't remembered, so it can be sent again.
+func (n *Node) DeleteMempoolTx(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	hash := ps.ByName("txhash")
+	setAuditTarget(r.Context(), hash)
+	tx, ok := n.mempool.Remove(hash)
+	if !ok {
+		RespondWithJSON(w, r, http.StatusNotFound, "transaction isn't pending")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, tx)
+}
@@ -0,0 +1,90 @@
+package node_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// postTx submits a transaction, returning the status and the rejection code if it was turned away
+func postTx(t *testing.T, n *blockchaintest.Node, tx blockchain.Transaction) (int, string) {
+	t.Helper()
+
+	var res json.RawMessage // the hash, or the rejection
+	status := n.Do(t, http.MethodPost, "/v1/tx", tx, &res)
+	var rejection node.TxRejection
+	json.Unmarshal(res, &rejection)
+	return status, rejection.Code
+}
+
+func TestCheckTxSignatures(t *testing.T) {
+	n := blockchaintest.NewNode(t)
+	n.MineBlocks(t, 1)
+	miner, bob := blockchaintest.Address(n.Key), blockchaintest.NewKey(t)
+
+	unsigned := n.Sign(t, n.Key, blockchain.Transaction{To: blockchaintest.Address(bob), Amount: 1, Nonce: 5})
+	unsigned.Witness = nil
+	forged := unsigned
+	forged.Witness = []string{forged.Sign(bob)}
+	tampered := n.Sign(t, n.Key, blockchain.Transaction{To: blockchaintest.Address(bob), Amount: 1, Nonce: 6})
+	tampered.Amount = 40
+	named := blockchain.Transaction{Class: blockchain.ClassUser, From: "alice", To: miner, Amount: 1, Nonce: 1}
+
+	tests := []struct {
+		name   string
+		tx     blockchain.Transaction
+		status int
+		code   string
+	}{
+		{"signed by the sender", n.Sign(t, n.Key, blockchain.Transaction{To: blockchaintest.Address(bob), Amount: 1}), http.StatusAccepted, ""},
+		{"no signature", unsigned, http.StatusBadRequest, "unsigned"},
+		{"signed by someone else", forged, http.StatusBadRequest, "unsigned"},
+		{"changed after signing", tampered, http.StatusBadRequest, "unsigned"},
+		{"sender isn't a key", named, http.StatusBadRequest, "unsigned"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := postTx(t, n, tt.tx)
+			if status != tt.status || (tt.code != "" && code != tt.code) {
+				t.Errorf("POST /tx = %d %q, want %d %q", status, code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestCheckTxBalances(t *testing.T) {
+	n := blockchaintest.NewNode(t)
+	n.MineBlocks(t, 1)
+	alice, bob := blockchaintest.NewKey(t), blockchaintest.Address(blockchaintest.NewKey(t))
+	n.Fund(t, blockchaintest.Address(alice), 40)
+
+	steps := []struct {
+		name   string
+		amount int
+		fee    int
+		mine   bool // mine what's pending first
+		status int
+		code   string
+	}{
+		{"within the balance", 30, 0, false, http.StatusAccepted, ""},
+		{"overspent with the pending one", 11, 0, false, http.StatusConflict, "insufficient_funds"},
+		{"the rest of it, fee included", 8, 2, false, http.StatusAccepted, ""},
+		{"overspent once they're confirmed", 1, 0, true, http.StatusConflict, "insufficient_funds"},
+	}
+	for _, step := range steps {
+		if step.mine {
+			n.MineBlock(t, 0)
+		}
+		tx := n.Sign(t, alice, blockchain.Transaction{To: bob, Amount: step.amount, Fee: step.fee})
+		if status, code := postTx(t, n, tx); status != step.status || code != step.code {
+			t.Errorf("%s: POST /tx = %d %q, want %d %q", step.name, status, code, step.status, step.code)
+		}
+	}
+	if balance, _ := n.Chain().Spendable(blockchaintest.Address(alice)); balance != 0 {
+		t.Errorf("alice has %d left, want 0", balance)
+	}
+}
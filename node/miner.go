@@ -0,0 +1,245 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// mineBlock builds a block with the given data on top of the head and adds it to the chain,
+// returning the block and if it was accepted
+func (n *Node) mineBlock(ctx context.Context, data int) (newBlock blockchain.Block, added bool, err error) {
+	ctx, span := tracer.Start(ctx, "node.mineBlock")
+	defer func() { endSpan(span, err) }()
+
+	if !n.mined() {
+		return newBlock, false, ErrNotMined
+	}
+	newBlock, err = n.buildBlock(ctx, data, n.minerAddress()) // create a new block with the data and pending transactions
+	if err != nil {
+		return newBlock, false, err
+	}
+	if err = n.solve(ctx, &newBlock); err != nil {
+		return newBlock, false, err
+	}
+
+	added, err = n.acceptMined(ctx, newBlock)
+	return newBlock, added, err
+}
+
+// acceptMined adds a block built by this node, and solved by it or an external miner, to the chain and
+// lets everyone know. It returns false if the block isn't valid on top of the head, like when someone
+// else extended the chain first.
+func (n *Node) acceptMined(ctx context.Context, block blockchain.Block) (bool, error) {
+	if !n.addBlock(ctx, block) {
+		n.recordIfStale(block) // another block got there first
+		return false, nil
+	}
+	n.mempool.RemoveIncluded(block)
+	if err := n.persistAdded(ctx); err != nil {
+		return true, err
+	}
+	n.debug(fmt.Sprintf("mined block %d %s", block.Index, block.Hash))
+	n.bus.Publish(events.BlockAdded{Block: block, Origin: events.OriginLocal}) // let the network, webhooks and /events know
+	return true, nil
+}
+
+// solve does the block's proof of work across MineThreads goroutines. It gives up with ErrStaleBlock as soon
+// as another block is added, from a peer or a stratum worker, since this one can't go on top any more.
+func (n *Node) solve(ctx context.Context, block *blockchain.Block) (err error) {
+	threads := n.mineThreads()
+	ctx, span := tracer.Start(ctx, "block.Solve", trace.WithAttributes(
+		attribute.Int64("block.difficulty", block.Difficulty),
+		attribute.Int("mine.threads", threads),
+	))
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := n.bus.Handle(func(events.Event) { cancel() }, events.NameBlockAdded, events.NameChainReorg)
+	defer stop()
+
+	defer n.hashMeter.begin()()
+	if err = block.SolveParallel(ctx, threads, &n.hashMeter.hashes); err != nil && block.PrevHash != n.chain.Last().Hash {
+		return ErrStaleBlock
+	}
+	return err
+}
+
+// mineThreads returns how many goroutines search for nonces
+func (n *Node) mineThreads() int {
+	n.settings.mu.RLock()
+	defer n.settings.mu.RUnlock()
+	if n.cfg.MineThreads > 0 {
+		return n.cfg.MineThreads
+	}
+	return runtime.NumCPU()
+}
+
+// mineLoop mines a block every mine interval while there are pending transactions, until the node shuts down
+// or stop is closed
+func (n *Node) mineLoop(stop chan struct{}) {
+	ticker := time.NewTicker(n.cfg.MineInterval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background()) // so stopping doesn't wait for the block being solved
+	defer cancel()
+	go func() {
+		select {
+		case <-n.done:
+		case <-stop:
+		}
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if n.mempool.Len() == 0 { // no empty blocks
+			continue
+		}
+		if block, ok, err := n.mineBlock(ctx, 0); err == ErrNotMined {
+			n.logger.Printf("mining stopped: %v", err)
+			return
+		} else if err == ErrStaleBlock {
+			n.debug("another block came in while mining, starting over")
+		} else if err != nil && ctx.Err() == nil {
+			n.logger.Printf("mining failed: %v", err)
+		} else if ok {
+			n.logger.Printf("mined block %d with %d transactions", block.Index, len(block.Transactions))
+		}
+	}
+}
+
+// hashMeter measures how fast the node hashes while it solves blocks
+type hashMeter struct {
+	hashes atomic.Int64 // every nonce tried since the node started
+
+	mu          sync.Mutex
+	solving     int       // blocks being solved right now
+	start       time.Time // when solving started
+	startHashes int64     // hashes then
+	last        float64   // hashes a second over the last stretch of solving
+}
+
+// begin marks a block being solved, returning a function to call once it's done
+func (m *hashMeter) begin() (end func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.solving == 0 {
+		m.start, m.startHashes = time.Now(), m.hashes.Load()
+	}
+	m.solving++
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.solving--; m.solving == 0 {
+			m.last = m.current()
+		}
+	}
+}
+
+// rate returns the hash rate while solving, or over the last block solved if nothing is being solved now
+func (m *hashMeter) rate() (hashrate float64, solving bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.solving > 0 {
+		return m.current(), true
+	}
+	return m.last, false
+}
+
+// current is the hash rate since solving started, m.mu has to be held
+func (m *hashMeter) current() float64 {
+	elapsed := time.Since(m.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(m.hashes.Load()-m.startHashes) / elapsed
+}
+
+// MiningStatus ... what the node's miner is up to
+type MiningStatus struct {
+	Mining       bool    // if blocks are being mined in the background
+	Solving      bool    // if a block is being solved right now
+	MinerAddress string  // where block rewards go
+	Threads      int     // goroutines solving blocks
+	Hashrate     float64 // hashes a second over the block being solved, or the last one solved
+	Hashes       int64   // nonces tried since the node started
+	Difficulty   int64   // what the next block needs
+	Workers      int     // stratum workers connected
+	PoolHashrate float64 // the stratum workers' hash rates added up
+}
+
+// MiningSettings ... the mining settings that can be changed on the fly
+type MiningSettings struct {
+	Mine         bool
+	MinerAddress string // empty pays the node's ID
+	Threads      int    // 0 for one per CPU
+}
+
+// MiningStatus returns what the node's miner is up to
+func (n *Node) MiningStatus() MiningStatus {
+	s := n.Settings()
+	status := MiningStatus{
+		Mining:       s.Mine,
+		MinerAddress: n.minerAddress(),
+		Threads:      n.mineThreads(),
+		Hashes:       n.hashMeter.hashes.Load(),
+		Difficulty:   n.chain.NextDifficulty(),
+	}
+	status.Hashrate, status.Solving = n.hashMeter.rate()
+	if n.stratum != nil {
+		for _, w := range n.stratum.workers() {
+			if w.Connected {
+				status.Workers++
+				status.PoolHashrate += w.Hashrate
+			}
+		}
+	}
+	return status
+}
+
+// GetMining handles the admin route reporting the miner's status and hash rate
+func (n *Node) GetMining(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.MiningStatus())
+}
+
+// PostMining handles the admin route to start and stop mining, change where rewards go and how many threads
+// solve blocks, eg {"Mine":true,"MinerAddress":"..."}. Fields left out keep their current values.
+func (n *Node) PostMining(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	s := n.Settings()
+	m := MiningSettings{Mine: s.Mine, MinerAddress: s.MinerAddress, Threads: s.MineThreads}
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid mining settings: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	s.Mine, s.MinerAddress, s.MineThreads = m.Mine, m.MinerAddress, m.Threads
+	if err := n.Reload(s); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := n.MiningStatus()
+	n.logger.Printf("mining %v, paying %s with %d threads", status.Mining, status.MinerAddress, status.Threads)
+	RespondWithJSON(w, r, http.StatusOK, status)
+}
@@ -0,0 +1,151 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// BlockTemplate ... the next block for an external miner to solve, like bitcoind's getblocktemplate.
+// Block is ready to go: vary its Nonce, hashing with blockchain.GenerateHeaderHash, until the hash is below
+// Target, then submit it. Its first transaction is the coinbase, paying CoinbaseValue to the node's
+// miner address or the one asked for. Changing the coinbase or the transactions means recomputing the
+// merkle root, and the coinbase has to keep paying exactly CoinbaseValue.
+type BlockTemplate struct {
+	Block         blockchain.Block
+	Target        string // hex, the hash read as a number has to be below this
+	CoinbaseValue int    // the block reward plus every fee in the block
+	MinTimestamp  int64  // the block's timestamp has to be after this, its parent's
+}
+
+// the reasons a submitted block is turned away
+var (
+	ErrStaleBlock   = errors.New("block doesn't build on the head, get a new template")
+	ErrInvalidBlock = errors.New("block isn't valid on top of the head")
+	ErrNotMined     = errors.New("blocks on this network are made by its validators, not mined")
+	ErrMiningBusy   = errors.New("a block posted to the node is already being mined, try again once it's added")
+)
+
+// blockTemplate builds a template paying the coinbase to miner, or this node's miner address if it's empty
+func (n *Node) blockTemplate(ctx context.Context, miner string) (BlockTemplate, error) {
+	if !n.mined() {
+		return BlockTemplate{}, ErrNotMined
+	}
+	if miner == "" {
+		miner = n.minerAddress()
+	}
+	prev := n.chain.Last()
+	block, err := n.buildBlock(ctx, 0, miner)
+	if err != nil {
+		return BlockTemplate{}, err
+	}
+	block.Hash = "" // not solved yet
+
+	target := hexTarget(blockchain.Target(block.Difficulty))
+	return BlockTemplate{Block: block, Target: target, CoinbaseValue: block.Transactions[0].Amount, MinTimestamp: prev.Timestamp}, nil
+}
+
+// mined returns if the network's blocks are mined, rather than agreed on or sealed by validators
+func (n *Node) mined() bool {
+	engine := n.chain.Params().Engine
+	return engine == "" || engine == blockchain.EnginePoW
+}
+
+// hexTarget writes a target as 64 hex digits, how hashes are written
+func hexTarget(target *big.Int) string {
+	return fmt.Sprintf("%064x", target)
+}
+
+// submitBlock adds a block solved from a template
+func (n *Node) submitBlock(ctx context.Context, block blockchain.Block) error {
+	if !n.mined() {
+		return ErrNotMined
+	}
+	if block.PrevHash != n.chain.Last().Hash {
+		return ErrStaleBlock
+	}
+	added, err := n.acceptMined(ctx, block)
+	if err != nil {
+		return err
+	}
+	if !added {
+		if block.PrevHash != n.chain.Last().Hash { // someone beat them to it
+			return ErrStaleBlock
+		}
+		return ErrInvalidBlock
+	}
+	return nil
+}
+
+// GetBlockTemplate handles the route external miners get work from, ?address= sets who the coinbase pays
+func (n *Node) GetBlockTemplate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	template, err := n.blockTemplate(r.Context(), r.URL.Query().Get("address"))
+	if err == ErrNotMined {
+		RespondWithJSON(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, template)
+}
+
+// PostSolvedBlock handles the route external miners hand solved blocks back to, as JSON or the canonical encoding
+func (n *Node) PostSolvedBlock(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	block, err := decodeBlock(r)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid block: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	setAuditTarget(r.Context(), block.Hash)
+	switch err := n.submitBlock(r.Context(), block); err {
+	case nil:
+		RespondWithJSON(w, r, http.StatusCreated, block.Hash)
+	case ErrStaleBlock, ErrNotMined:
+		setAuditRejected(r.Context(), err.Error())
+		RespondWithJSON(w, r, http.StatusConflict, err.Error())
+	case ErrInvalidBlock:
+		setAuditRejected(r.Context(), err.Error())
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+	default: // added, but couldn't be stored
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// rpcGetBlockTemplate returns a block template, params [address]
+func (n *Node) rpcGetBlockTemplate(ctx context.Context, params json.RawMessage) (interface{}, *RPCError) {
+	var address string
+	if err := rpcParams(params, []string{"address"}, &address); err != nil {
+		return nil, err
+	}
+	template, err := n.blockTemplate(ctx, address)
+	if err != nil {
+		return nil, &RPCError{Code: rpcInternalError, Message: err.Error()}
+	}
+	return template, nil
+}
+
+// rpcSubmitBlock adds a block solved from a template, params [block], returning its hash
+func (n *Node) rpcSubmitBlock(ctx context.Context, params json.RawMessage) (interface{}, *RPCError) {
+	var block blockchain.Block
+	if err := rpcParams(params, []string{"block"}, &block); err != nil {
+		return nil, err
+	}
+	switch err := n.submitBlock(ctx, block); err {
+	case nil:
+	case ErrStaleBlock, ErrInvalidBlock, ErrNotMined:
+		return nil, &RPCError{Code: rpcBlockRejected, Message: err.Error()}
+	default:
+		return nil, &RPCError{Code: rpcInternalError, Message: err.Error()}
+	}
+	return block.Hash, nil
+}
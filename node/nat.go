@@ -0,0 +1,202 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// With port mapping on, a node behind a home router asks the router to forward a port to its API, over UPnP
+// or NAT-PMP, whichever it speaks, so peers outside can connect in. The mapping's leased for natLease and
+// renewed halfway through, and removed when the node closes. The router's external address and the mapped
+// port make the URL the node advertises in its handshakes, and a peer it handshakes with adds that URL to its
+// own peers, so either side can start talking.
+
+// the port mapping protocols
+const (
+	NATAuto   = "auto"   // UPnP, then NAT-PMP if no UPnP router answers
+	NATUPnP   = "upnp"   // UPnP internet gateway devices, most home routers
+	NATNATPMP = "natpmp" // NAT-PMP, Apple routers and some others
+)
+
+// port mapping timings
+const (
+	natLease   = time.Hour
+	natRenew   = natLease / 2
+	natRetry   = time.Minute // after a failed attempt
+	natTimeout = 3 * time.Second
+)
+
+// portMapper ... a router that forwards ports
+type portMapper interface {
+	protocol() string
+	gateway() string
+	externalIP() (net.IP, error)
+	addMapping(internal, external int, lease time.Duration) (int, error) // returns the external port it got
+	deleteMapping(internal, external int) error
+}
+
+// NATStatus ... the node's port mapping, for /admin/nat
+type NATStatus struct {
+	Mode         string // NATAuto, NATUPnP or NATNATPMP
+	Protocol     string // the one the router spoke, empty until one's found
+	Gateway      string
+	ExternalIP   string
+	ExternalPort int
+	InternalPort int
+	URL          string // what the node advertises to peers
+	Renewed      time.Time
+	Error        string `json:",omitempty"` // why the last attempt failed
+}
+
+// natState ... the router the node's mapped a port on, and the mapping
+type natState struct {
+	mu     sync.Mutex
+	mapper portMapper // nil until a router's found
+	status NATStatus
+}
+
+// ValidNATMode returns an error unless mode is a port mapping protocol, or empty for none
+func ValidNATMode(mode string) error {
+	switch mode {
+	case "", NATAuto, NATUPnP, NATNATPMP:
+		return nil
+	}
+	return fmt.Errorf("port mapping has to be %s, %s or %s, not %q", NATAuto, NATUPnP, NATNATPMP, mode)
+}
+
+// discoverMapper finds the router to map ports on, gateway overriding where NAT-PMP looks for it
+func discoverMapper(mode, gateway string) (portMapper, error) {
+	var errs []string
+	if mode == NATAuto || mode == NATUPnP {
+		m, err := discoverUPnP()
+		if err == nil {
+			return m, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	if mode == NATAuto || mode == NATNATPMP {
+		m, err := discoverNATPMP(gateway)
+		if err == nil {
+			return m, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, errors.New(strings.Join(errs, ", "))
+}
+
+// natLoop keeps a port mapped until the node shuts down, then removes it
+func (n *Node) natLoop() {
+	for {
+		wait := natRenew
+		if err := n.mapPort(); err != nil {
+			n.logger.Printf("port mapping failed: %v", err)
+			wait = natRetry
+		}
+
+		select {
+		case <-n.done:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// mapPort maps the API's port on the router, or renews the mapping, finding the router first if need be
+func (n *Node) mapPort() error {
+	s := n.nat
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, port, _ := net.SplitHostPort(n.Addr())
+	internal, _ := strconv.Atoi(port)
+	fail := func(err error) error {
+		s.status.Error = err.Error()
+		s.mapper = nil // look for it again next time, it may have changed
+		return err
+	}
+	if s.mapper == nil {
+		m, err := discoverMapper(n.cfg.PortMapping, n.cfg.NATGateway)
+		if err != nil {
+			return fail(err)
+		}
+		s.mapper = m
+	}
+
+	want := s.status.ExternalPort // keep the same one when renewing
+	if want == 0 {
+		want = internal
+	}
+	external, err := s.mapper.addMapping(internal, want, natLease)
+	if err != nil {
+		return fail(err)
+	}
+	ip, err := s.mapper.externalIP()
+	if err != nil {
+		return fail(err)
+	}
+	scheme := "http://"
+	if n.certs != nil {
+		scheme = "https://"
+	}
+	if s.status.ExternalPort != external || s.status.ExternalIP != ip.String() {
+		n.logger.Printf("mapped port %d on the router to %d over %s, reachable at %s", external, internal, s.mapper.protocol(), ip)
+	}
+	s.status = NATStatus{
+		Protocol: s.mapper.protocol(), Gateway: s.mapper.gateway(),
+		ExternalIP: ip.String(), ExternalPort: external, InternalPort: internal,
+		URL: scheme + net.JoinHostPort(ip.String(), strconv.Itoa(external)), Renewed: time.Now(),
+	}
+	return nil
+}
+
+// unmapPort removes the mapping, if there is one
+func (n *Node) unmapPort() {
+	s := n.nat
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mapper == nil || s.status.ExternalPort == 0 {
+		return
+	}
+	if err := s.mapper.deleteMapping(s.status.InternalPort, s.status.ExternalPort); err != nil {
+		n.logger.Printf("removing the port mapping failed: %v", err)
+	}
+	s.status.ExternalPort, s.status.URL = 0, ""
+}
+
+// externalURL returns the URL the node can be reached at from outside, empty if it doesn't know one
+func (n *Node) externalURL() string {
+	if n.nat == nil {
+		return ""
+	}
+	n.nat.mu.Lock()
+	defer n.nat.mu.Unlock()
+	return n.nat.status.URL
+}
+
+// validPeerURL returns if a peer advertised something that looks like a node's base URL
+func validPeerURL(peer string) bool {
+	u, err := url.Parse(peer)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/")
+}
+
+// GetNAT handles the admin route showing the port mapping
+func (n *Node) GetNAT(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if n.nat == nil {
+		RespondWithJSON(w, r, http.StatusNotFound, "port mapping is off")
+		return
+	}
+	n.nat.mu.Lock()
+	status := n.nat.status
+	n.nat.mu.Unlock()
+	status.Mode = n.cfg.PortMapping
+	RespondWithJSON(w, r, http.StatusOK, status)
+}
@@ -0,0 +1,131 @@
+package node
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// NAT-PMP (RFC 6886) is a few fixed size UDP messages to the router on port 5351: one asks for its external
+// address, the other maps a port for a while, or unmaps it with a lifetime of 0.
+
+// natpmpPort is where routers listen for NAT-PMP
+const natpmpPort = "5351"
+
+// natpmpGateway ... a router that speaks NAT-PMP
+type natpmpGateway struct {
+	addr *net.UDPAddr
+}
+
+// discoverNATPMP checks the router at gateway, or the default route's if it's empty, answers NAT-PMP
+func discoverNATPMP(gateway string) (*natpmpGateway, error) {
+	if gateway == "" {
+		ip, err := defaultGateway()
+		if err != nil {
+			return nil, err
+		}
+		gateway = ip.String()
+	}
+	if _, _, err := net.SplitHostPort(gateway); err != nil {
+		gateway = net.JoinHostPort(gateway, natpmpPort)
+	}
+	addr, err := net.ResolveUDPAddr("udp4", gateway)
+	if err != nil {
+		return nil, err
+	}
+	g := &natpmpGateway{addr: addr}
+	if _, err := g.externalIP(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *natpmpGateway) protocol() string { return NATNATPMP }
+func (g *natpmpGateway) gateway() string  { return g.addr.String() }
+
+func (g *natpmpGateway) externalIP() (net.IP, error) {
+	res, err := g.call([]byte{0, 0}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IP(res[8:12]), nil
+}
+
+func (g *natpmpGateway) addMapping(internal, external int, lease time.Duration) (int, error) {
+	res, err := g.call(natpmpMapRequest(internal, external, lease), 16)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(res[10:12])), nil
+}
+
+func (g *natpmpGateway) deleteMapping(internal, external int) error {
+	_, err := g.call(natpmpMapRequest(internal, 0, 0), 16)
+	return err
+}
+
+// natpmpMapRequest asks for a TCP mapping, or for it to be removed with a lease of 0
+func natpmpMapRequest(internal, external int, lease time.Duration) []byte {
+	req := []byte{0, 2, 0, 0} // version 0, map TCP, reserved
+	req = binary.BigEndian.AppendUint16(req, uint16(internal))
+	req = binary.BigEndian.AppendUint16(req, uint16(external))
+	return binary.BigEndian.AppendUint32(req, uint32(lease/time.Second))
+}
+
+// call sends a request and waits for its answer of size bytes, trying again after 250ms then twice as long
+// each time like the RFC says, though it gives up sooner
+func (g *natpmpGateway) call(req []byte, size int) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, g.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 16)
+	wait := 250 * time.Millisecond
+	for try := 0; try < 4; try++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(wait))
+		got, err := conn.Read(buf)
+		wait *= 2
+		if err != nil || got < size || buf[1] != req[1]+128 { // answers have the request's opcode plus 128
+			continue
+		}
+		if code := binary.BigEndian.Uint16(buf[2:4]); code != 0 {
+			return nil, fmt.Errorf("NAT-PMP router %s refused, result code %d", g.addr, code)
+		}
+		return buf[:got], nil
+	}
+	return nil, errors.New("no NAT-PMP router answered at " + g.addr.String())
+}
+
+// defaultGateway returns the router the default route goes through, from the kernel's routing table
+func defaultGateway() (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, errors.New("can't find the default gateway, set nat_gateway")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() { // Iface Destination Gateway Flags ..., addresses in little endian hex
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		return net.IPv4(b[3], b[2], b[1], b[0]), nil
+	}
+	return nil, errors.New("no default route, set nat_gateway")
+}
@@ -0,0 +1,709 @@
+package node
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Config ... everything needed to start a node
+type Config struct {
+	Addr     string      // the address the HTTP API listens on, eg ":8080"
+	DataDir  string      // the directory the chain is stored in, leave empty to keep it in memory
+	Logger   *log.Logger // where the node logs to, defaults to the standard logger
+	LogLevel string      // "info" (the default), or "debug" which also logs every block the node mines and dumps its genesis block
+	TLS      *TLSConfig  // if set, every listener requires mutual TLS with certs signed by the cluster CA
+
+	Peers         []string      // base URLs of other nodes to sync with, eg "http://10.0.0.2:8080"
+	SyncInterval  time.Duration // how often to compare heads with peers, defaults to 10 seconds
+	SyncBatchSize int           // how many blocks to request from a peer at a time, defaults to 100
+	GossipFanout  int           // how many random peers new blocks and transactions are announced to, defaults to 3
+	MaxBlockAge   time.Duration // if set, /readyz fails when the head block is older than this
+
+	AllowedPeers []string // node IDs allowed to connect, empty allows anyone who isn't banned
+	BannedPeers  []string // node IDs that are never allowed to connect
+	LANDiscovery bool     // if set, the node announces itself on the LAN by multicast and adds nodes it hears there as peers, see lan.go
+	LANGroup     string   // the multicast group and port announcements go to, defaults to 239.255.42.99:9999
+	PortMapping  string   // if set, NATAuto, NATUPnP or NATNATPMP, the router's asked to forward a port to the API and the address it gives is advertised to peers, see nat.go
+	NATGateway   string   // where NAT-PMP looks for the router, defaults to the default route's gateway
+	DNSSeeds     []string // hostnames whose A records are nodes on SeedPort and TXT records node URLs, asked for peers whenever the node has none, see seeds.go
+	SeedPort     int      // the port nodes found by a seed's A records listen on, defaults to the node's own
+	DNSServer    string   // if set, seeds are looked up on this DNS server, eg "1.1.1.1:53", instead of the system's
+
+	BanScore int           // the misbehavior score a peer's banned at, defaults to 100, negative never bans, see scoring.go
+	BanTime  time.Duration // how long a peer's banned for reaching BanScore, defaults to 24h
+
+	EncryptPeers bool // if set, node-to-node traffic goes over TLS with certificates made from node keys, see p2ptls.go
+
+	SyncPeers   int           // how many peers blocks are downloaded from at once when syncing headers first, defaults to 8, see headersync.go
+	SyncTimeout time.Duration // how long a peer gets to send a batch of blocks before it's given to another, defaults to 30 seconds
+
+	Light bool // if set, the node keeps only block headers and checks transactions with merkle proofs from its peers, see light.go
+
+	Profile string // archive, full or pruned, how much history the node keeps and so answers for, defaults to full, or pruned with PruneDepth set, see profile.go
+
+	StateInterval    int                    // how often, in blocks, the state's snapshotted to DataDir/state, defaults to 1000 or a pruned node's PruneDepth if that's less, -1 for never, see statesync.go
+	StateCheckpoints blockchain.Checkpoints // trusted state snapshot digests by height, a new pruned node starts from the highest one its peers have reached
+
+	Checkpoints  blockchain.Checkpoints // trusted block hashes by height, on top of the hardcoded ones
+	Params       blockchain.Params      // consensus rules, defaults to blockchain.DefaultParams
+	MinerAddress string                 // address block rewards are paid to, defaults to the node's ID
+	PruneDepth   int                    // if set, only the last PruneDepth block bodies are kept, older blocks keep just their headers
+	GenesisTime  time.Time              // if set, the genesis block is made at this time instead of now, so every node of a new network starts from the same one
+
+	Validators []string      // on a BFT or PoA network, the node IDs of the validators in the order they take turns proposing or sealing
+	BlockTime  time.Duration // on a BFT or PoA network, how long after a block the next one is made, defaults to 5 seconds
+	BFTTimeout time.Duration // how long each step of a BFT round waits for the proposal or votes, longer every round, defaults to 3 seconds
+
+	SnapshotDir string // where snapshots are written, defaults to DataDir/snapshots
+	RestoreFrom string // if set, the node starts from this snapshot instead of its stored chain
+
+	AdminToken          string        // if set, admin routes need "Authorization: Bearer <token>"
+	DebugAddr           string        // if set, pprof is served on this separate address, eg "127.0.0.1:6060"
+	AuditLog            string        // file every mutating request is appended to, defaults to DataDir/audit.log, "-" turns it off
+	IdempotencyWindow   time.Duration // how long responses to POSTs with an Idempotency-Key are replayed, defaults to 24 hours, negative turns it off
+	BlockCacheSize      int           // how many encoded blocks are kept for repeat requests, defaults to 1024, negative turns it off
+	MaintenanceWindow   string        // when scheduled maintenance may run, eg "02:00-04:00" local time, empty for any time
+	MaintenanceInterval time.Duration // how often each maintenance task runs, defaults to 24 hours
+
+	CORSOrigins []string // origins browsers may call the API from, eg "https://explorer.example.com" or "*", empty turns CORS off
+	CORSMethods []string // methods allowed cross origin, defaults to GET, POST and DELETE
+	CORSHeaders []string // request headers allowed cross origin, defaults to Content-Type, Authorization, X-Request-ID and Idempotency-Key
+
+	Webhooks       []string      // URLs every accepted block is POSTed to, more can be added through /admin/webhooks
+	WebhookSecret  string        // if set, webhook payloads are signed with HMAC-SHA256 using this key
+	WebhookBackoff time.Duration // how long to wait before retrying a failed delivery, doubling each time, defaults to 1 second
+
+	Mine         bool          // if set, the node mines a block of pending transactions every MineInterval
+	MineInterval time.Duration // defaults to 10 seconds
+	MineThreads  int           // goroutines searching for a block's nonce, defaults to the number of CPUs
+
+	StratumAddr       string // if set, external miners can connect here for work, see stratum.go
+	StratumDifficulty int64  // how hard a stratum share is, defaults to 2^20, never harder than a block
+
+	SignerURL        string // if set, validator signatures come from the remote signer here instead of the node key, see the signer package
+	SignerToken      string // sent to the remote signer as "Authorization: Bearer <token>"
+	SignerKeystore   string // if set and there's no SignerURL, validator signatures come from the key in this keystore file
+	SignerPassphrase string // decrypts SignerKeystore
+
+	WalletDir string // if set, the node keeps wallets for frontends here, served under /wallet, see wallets.go, needs an AdminToken
+
+	FaucetAmount   int           // coins POST /faucet/:address sends, only on test networks, 0 turns the faucet off
+	FaucetInterval time.Duration // how long an address or client IP waits between drips, defaults to an hour
+
+	MaxBlockTxs      int                  // most transactions a block can hold, defaults to 100
+	PriorityFraction float64              // fraction of each block reserved for priority classes, defaults to 0.25
+	PriorityClasses  []blockchain.TxClass // classes that get the reserved space, defaults to governance, proposals, votes and oracle
+	MempoolMaxTxs    int                  // most transactions the mempool holds, defaults to 50000, negative for no limit
+	MempoolMaxBytes  int                  // most bytes of transactions the mempool holds, defaults to 64MB, negative for no limit
+	MempoolEvict     string               // what goes when the mempool's full, EvictFee (the default) or EvictAge
+	RBFBump          int                  // percent more a transaction has to pay to replace a pending one with its nonce, defaults to 10, negative turns replacing off
+}
+
+// Node wires a blockchain up to its storage and HTTP API
+type Node struct {
+	cfg      Config
+	logger   *log.Logger
+	chain    *blockchain.Chain
+	mempool  *Mempool
+	seen     *seenSet    // recently seen block and transaction hashes, so gossip isn't processed twice
+	orphans  *orphanPool // gossiped blocks waiting for their parents
+	stale    *staleBlocks
+	evidence *evidencePool // validators caught double signing, waiting to be slashed
+	faucet   *faucet
+	wallets  *walletStore
+	bus      *events.Bus // where chain activity is published for gossip, webhooks and /events
+
+	priority map[blockchain.TxClass]bool // set of PriorityClasses
+	store    *Store
+	storeMu  sync.Mutex // one save or append at a time, so batches go in the order their blocks were added
+	certs    *certReloader
+	peerTLS  *tls.Config // what peers' encrypted connections are served with, when EncryptPeers is set
+	server   *http.Server
+	listener net.Listener
+	client   *http.Client // for calling peers
+	key      ed25519.PrivateKey
+	signer   blockchain.Signer // signs as a validator, the node key unless Signer* say otherwise
+	identity *identity
+	done     chan struct{} // closed when the node shuts down
+
+	maintenance maintenance
+	settings    settings
+	peerStatus  peerStatuses // what each peer last said its head was, for /readyz
+	debugServer *http.Server // serves pprof when DebugAddr is set
+	audit       *auditLog    // nil without an audit log
+	idempotency *idempotencyCache
+	blockCache  *blockCache // nil when BlockCacheSize turns it off
+	webhooks    *webhookSet
+	stratum     *stratumServer // nil unless StratumAddr is set
+	nat         *natState      // nil unless PortMapping is set
+	bft         *bftEngine     // nil unless the node is a validator on a BFT network
+	hashMeter   hashMeter
+	posted      atomic.Bool // a block posted to / is being mined
+
+	stateWritten string // hash of the block the last state snapshot written is as of, guarded by storeMu
+}
+
+// New creates a node from config, loading its chain from storage or creating a genesis block
+func New(cfg Config) (*Node, error) {
+	n := &Node{cfg: cfg, logger: cfg.Logger, mempool: NewMempool(), seen: newSeenSet(10000), orphans: newOrphanPool(), stale: newStaleBlocks(), evidence: newEvidencePool(), faucet: newFaucet(), wallets: newWalletStore(), bus: events.NewBus(), idempotency: newIdempotencyCache(), done: make(chan struct{})}
+	if n.logger == nil {
+		n.logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	if n.cfg.SyncInterval == 0 {
+		n.cfg.SyncInterval = 10 * time.Second
+	}
+	if n.cfg.SyncBatchSize == 0 {
+		n.cfg.SyncBatchSize = 100
+	}
+	if n.cfg.SyncPeers == 0 {
+		n.cfg.SyncPeers = defaultSyncPeers
+	}
+	if n.cfg.SyncTimeout == 0 {
+		n.cfg.SyncTimeout = defaultSyncTimeout
+	}
+	if reflect.DeepEqual(n.cfg.Params, blockchain.Params{}) { // not comparable, it has the governance changes
+		n.cfg.Params = blockchain.DefaultParams
+	}
+	if n.cfg.FaucetAmount > 0 && n.cfg.Params.ChainID == blockchain.DefaultParams.ChainID {
+		return nil, fmt.Errorf("the faucet is only for test networks, not %s", n.cfg.Params.ChainID)
+	}
+	if n.cfg.FaucetInterval == 0 {
+		n.cfg.FaucetInterval = time.Hour
+	}
+	var err error
+	if n.cfg.MinerAddress, err = blockchain.ParseAddress(n.cfg.MinerAddress); err != nil {
+		return nil, fmt.Errorf("miner address: %w", err)
+	}
+	var validators []string // a copy, the config's slice is the caller's
+	for _, v := range n.cfg.Validators {
+		id, err := blockchain.ParseAddress(v)
+		if err != nil {
+			return nil, fmt.Errorf("validator %s: %w", v, err)
+		}
+		validators = append(validators, id)
+	}
+	n.cfg.Validators = validators
+	if n.cfg.MaintenanceInterval == 0 {
+		n.cfg.MaintenanceInterval = 24 * time.Hour
+	}
+	if n.cfg.IdempotencyWindow == 0 {
+		n.cfg.IdempotencyWindow = 24 * time.Hour
+	}
+	if n.cfg.BlockCacheSize == 0 {
+		n.cfg.BlockCacheSize = defaultBlockCacheSize
+	}
+	if n.cfg.BlockCacheSize > 0 {
+		n.blockCache = newBlockCache(n.cfg.BlockCacheSize)
+	}
+	switch n.cfg.LogLevel {
+	case "":
+		n.cfg.LogLevel = "info"
+	case "debug", "info":
+	default:
+		return nil, fmt.Errorf("unknown log level %q, has to be debug or info", n.cfg.LogLevel)
+	}
+	switch n.cfg.Params.Engine {
+	case "", blockchain.EnginePoW:
+	case blockchain.EngineBFT, blockchain.EnginePoA:
+		if len(n.cfg.Validators) == 0 {
+			return nil, fmt.Errorf("a %s network needs validators", n.cfg.Params.Engine)
+		}
+	default:
+		return nil, fmt.Errorf("unknown consensus engine %q, has to be pow, bft or poa", n.cfg.Params.Engine)
+	}
+	n.maintenance.running = make(map[string]bool)
+	n.registerMaintenanceTasks()
+	if n.cfg.WebhookBackoff == 0 {
+		n.cfg.WebhookBackoff = time.Second
+	}
+	n.webhooks = newWebhookSet(cfg.Webhooks)
+	n.bus.Handle(n.gossipEvent, events.NameBlockAdded, events.NameTxAdmitted)
+	n.bus.Handle(n.webhookEvent, events.NameBlockAdded)
+	n.bus.Handle(func(events.Event) { n.expireTxs() }, events.NameBlockAdded, events.NameChainReorg)
+	if n.cfg.GossipFanout == 0 {
+		n.cfg.GossipFanout = 3
+	}
+	if n.cfg.CORSMethods == nil {
+		n.cfg.CORSMethods = defaultCORSMethods
+	}
+	if n.cfg.CORSHeaders == nil {
+		n.cfg.CORSHeaders = defaultCORSHeaders
+	}
+	if n.cfg.MineInterval == 0 {
+		n.cfg.MineInterval = 10 * time.Second
+	}
+	if n.cfg.BlockTime == 0 {
+		n.cfg.BlockTime = 5 * time.Second
+	}
+	if n.cfg.BFTTimeout == 0 {
+		n.cfg.BFTTimeout = 3 * time.Second
+	}
+	if n.cfg.MaxBlockTxs == 0 {
+		n.cfg.MaxBlockTxs = 100
+	}
+	if n.cfg.LANGroup == "" {
+		n.cfg.LANGroup = defaultLANGroup
+	}
+	if n.cfg.BanScore == 0 {
+		n.cfg.BanScore = defaultBanScore
+	}
+	if n.cfg.BanTime == 0 {
+		n.cfg.BanTime = defaultBanTime
+	}
+	if n.cfg.EncryptPeers && n.cfg.TLS != nil {
+		return nil, fmt.Errorf("peer traffic is already encrypted with mutual TLS, leave encrypt peers off")
+	}
+	if n.cfg.Light && (n.cfg.Mine || n.cfg.StratumAddr != "" || n.cfg.FaucetAmount > 0 || n.cfg.Params.Engine == blockchain.EngineBFT || n.cfg.Params.Engine == blockchain.EnginePoA) {
+		return nil, fmt.Errorf("a light node only follows the headers of a mined chain, it can't mine, run a faucet or follow BFT or PoA")
+	}
+	if err := n.resolveProfile(); err != nil {
+		return nil, err
+	}
+	if len(n.cfg.StateCheckpoints) > 0 && n.profile() != ProfilePruned {
+		return nil, fmt.Errorf("a node starting from a state snapshot doesn't have the blocks before it, use the %s profile", ProfilePruned)
+	}
+	if n.cfg.StateInterval == 0 {
+		n.cfg.StateInterval = defaultStateInterval
+		if n.cfg.Profile == ProfilePruned && n.cfg.PruneDepth < n.cfg.StateInterval { // so there's always one the bodies it keeps carry on from
+			n.cfg.StateInterval = n.cfg.PruneDepth
+		}
+	}
+	if n.cfg.WalletDir != "" && n.cfg.AdminToken == "" { // anyone who can reach the API could sign with them
+		return nil, fmt.Errorf("the wallet routes need an admin token, set one to keep wallets")
+	}
+	if err := ValidNATMode(n.cfg.PortMapping); err != nil {
+		return nil, err
+	}
+	if n.cfg.PortMapping != "" {
+		n.nat = &natState{}
+	}
+	if n.cfg.PriorityFraction == 0 {
+		n.cfg.PriorityFraction = 0.25
+	}
+	if n.cfg.MempoolMaxTxs == 0 {
+		n.cfg.MempoolMaxTxs = defaultMempoolMaxTxs
+	}
+	if n.cfg.MempoolMaxBytes == 0 {
+		n.cfg.MempoolMaxBytes = defaultMempoolMaxBytes
+	}
+	if n.cfg.MempoolEvict == "" {
+		n.cfg.MempoolEvict = EvictFee
+	}
+	if n.cfg.RBFBump == 0 {
+		n.cfg.RBFBump = defaultRBFBump
+	}
+	if err := ValidEvictPolicy(n.cfg.MempoolEvict); err != nil {
+		return nil, err
+	}
+	n.mempool.SetLimits(n.cfg.MempoolMaxTxs, n.cfg.MempoolMaxBytes, n.cfg.MempoolEvict)
+	if n.cfg.PriorityClasses == nil {
+		n.cfg.PriorityClasses = []blockchain.TxClass{blockchain.ClassGovernance, blockchain.ClassProposal, blockchain.ClassVote, blockchain.ClassOracle}
+	}
+	n.priority = make(map[blockchain.TxClass]bool)
+	for _, class := range n.cfg.PriorityClasses {
+		n.priority[class] = true
+	}
+
+	if cfg.DataDir != "" {
+		store, err := OpenStore(cfg.DataDir)
+		if err != nil {
+			return nil, err
+		}
+		n.store = store
+	}
+	if n.cfg.AuditLog == "" && cfg.DataDir != "" {
+		n.cfg.AuditLog = filepath.Join(cfg.DataDir, "audit.log")
+	}
+	if n.cfg.AuditLog != "" && n.cfg.AuditLog != "-" {
+		audit, err := openAuditLog(n.cfg.AuditLog)
+		if err != nil {
+			return nil, err
+		}
+		n.audit = audit
+	}
+
+	if cfg.TLS != nil {
+		certs, err := newCertReloader(*cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		n.certs = certs
+	}
+
+	var blocks []blockchain.Block
+	var pending []blockchain.Transaction // a restored snapshot's mempool, checked once there's a chain
+	if n.cfg.RestoreFrom != "" {
+		blocks, pending, err = n.restoreSnapshot(n.cfg.RestoreFrom)
+	} else {
+		blocks, err = n.loadBlocks()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if genesis := blocks[0]; genesis.Version >= blockchain.ChainHeaderVersion && genesis.ChainID != n.cfg.Params.ChainID {
+		return nil, fmt.Errorf("the chain in %s is for network %q, not %q", cfg.DataDir, genesis.ChainID, n.cfg.Params.ChainID)
+	}
+	if (n.cfg.Profile == ProfileFull || n.cfg.Profile == ProfileArchive) && hasPrunedBlocks(blocks) {
+		return nil, fmt.Errorf("the chain in %s has been pruned, a %s node needs every block so start it with an empty data dir", cfg.DataDir, n.cfg.Profile)
+	}
+	if hasPrunedBlocks(blocks) && !n.cfg.Light { // the pruned bodies can't give the state back, a snapshot of it can
+		n.chain = n.chainFromState(blocks)
+	} else {
+		n.chain = n.newChain(blocks...)
+	}
+	n.loadIndex(blocks)
+	if n.cfg.StateInterval > 0 && !n.cfg.Light { // a light node has no state
+		n.chain.SetStateInterval(n.cfg.StateInterval)
+	}
+	for _, tx := range pending {
+		if err := n.addTx(tx); err != nil {
+			n.logger.Printf("left %s out of the restored mempool: %v", tx.Hash(), err)
+		}
+	}
+
+	n.server = &http.Server{ // http config
+		Addr:           cfg.Addr,
+		Handler:        traceRequests(compressResponses(n.auditRequests(n.cors(n.idempotent(n.MakeRouter()))))), // use httprouter instead of mux bcus we all about that dynamic trie structure
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	if n.certs != nil {
+		n.server.TLSConfig = n.certs.ServerTLSConfig()
+	}
+	n.client = n.HTTPClient()
+
+	if n.key, err = loadOrCreateKey(cfg.DataDir); err != nil { // the node's identity
+		return nil, err
+	}
+	if n.signer, err = n.newSigner(); err != nil {
+		return nil, err
+	}
+	n.identity = newIdentity(cfg.BannedPeers)
+	if n.cfg.Params.Engine == blockchain.EngineBFT { // every node follows along, it might be voted in as a validator
+		n.bft = newBFTEngine(n)
+	}
+
+	if err := n.loadPeers(); err != nil {
+		return nil, err
+	}
+
+	base := n.client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if n.cfg.EncryptPeers {
+		cert, err := peerCertificate(n.key)
+		if err != nil {
+			return nil, err
+		}
+		n.peerTLS = peerServerTLS(cert)
+		base = newEncryptedTransport(n, cert)
+	}
+	n.client.Transport = &handshakeTransport{node: n, base: base} // sign in to peers before talking to them
+
+	return n, nil
+}
+
+// newChain creates a chain following the node's checkpoints and consensus rules
+func (n *Node) newChain(blocks ...blockchain.Block) *blockchain.Chain {
+	chain := blockchain.NewChain(blocks...)
+	n.followRules(chain)
+	return chain
+}
+
+// followRules sets a chain's checkpoints and consensus rules to the node's
+func (n *Node) followRules(chain *blockchain.Chain) {
+	chain.SetCheckpoints(blockchain.DefaultCheckpoints.Merge(n.cfg.Checkpoints))
+	chain.SetParams(n.cfg.Params)
+	chain.SetValidators(n.cfg.Validators)
+}
+
+// loadIndex swaps in the stored index, if there's one for the chain. It matters on pruned nodes, which can't
+// index the blocks they've pruned from the blocks alone.
+func (n *Node) loadIndex(blocks []blockchain.Block) {
+	if n.store == nil {
+		return
+	}
+	ix, err := n.store.LoadIndex(blocks)
+	switch {
+	case err != nil:
+		n.logger.Printf("stored index can't be used, reindexed the chain: %v", err)
+	case ix != nil:
+		n.chain.UseIndex(ix)
+	}
+}
+
+// loadBlocks reads the chain from storage, or creates a new one starting with a genesis block
+func (n *Node) loadBlocks() ([]blockchain.Block, error) {
+	if n.store != nil {
+		blocks, err := n.store.Load()
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) > 0 {
+			if migrated, ok := blockchain.MigrateTimestamps(blocks); ok { // stored by a node from before unix timestamps
+				n.logger.Printf("moved the stored chain to unix timestamps, every block hash has changed so set any checkpoints again")
+				if err := n.store.Save(migrated, nil); err != nil {
+					return nil, err
+				}
+				blocks = migrated
+			}
+			return blocks, nil
+		}
+	}
+
+	genesisBlock := blockchain.NewGenesisBlock()
+	if !n.cfg.GenesisTime.IsZero() {
+		genesisBlock = blockchain.NewGenesisBlockAt(n.cfg.GenesisTime)
+	}
+	if _, newest := n.cfg.Params.Versions(0); newest < genesisBlock.Version { // start on the version before the first fork
+		genesisBlock.Version = newest
+	}
+	genesisBlock.ChainID = n.cfg.Params.ChainID
+	n.debug(spew.Sdump(genesisBlock)) // log the first block
+
+	if n.store != nil {
+		if err := n.store.Save([]blockchain.Block{genesisBlock}, nil); err != nil {
+			return nil, err
+		}
+	}
+	return []blockchain.Block{genesisBlock}, nil
+}
+
+// Chain returns the node's blockchain
+func (n *Node) Chain() *blockchain.Chain {
+	return n.chain
+}
+
+// Events returns the bus the node publishes its activity on, for hooking integrations into the node
+func (n *Node) Events() *events.Bus {
+	return n.bus
+}
+
+// debug logs only when the log level is debug
+func (n *Node) debug(v ...interface{}) {
+	if n.logLevel() == "debug" {
+		n.logger.Print(v...)
+	}
+}
+
+// Mempool returns the node's pool of pending transactions
+func (n *Node) Mempool() *Mempool {
+	return n.mempool
+}
+
+// Start listens on the configured address and serves the API in the background
+func (n *Node) Start() error {
+	ln, err := net.Listen("tcp", n.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if n.certs != nil {
+		ln = tls.NewListener(ln, n.server.TLSConfig)
+	}
+	if n.peerTLS != nil {
+		ln = newSniffListener(ln, n.peerTLS)
+	}
+	n.listener = ln
+	if err := n.startDebugServer(); err != nil {
+		ln.Close()
+		return err
+	}
+	if err := n.startStratum(); err != nil {
+		ln.Close()
+		return err
+	}
+
+	go n.server.Serve(ln)
+	n.startBackground()
+	return nil
+}
+
+// ListenAndServe runs the HTTP server, blocking until it stops
+func (n *Node) ListenAndServe() error {
+	n.logger.Println("API listening on ", n.cfg.Addr)
+	if err := n.startDebugServer(); err != nil {
+		return err
+	}
+	if err := n.startStratum(); err != nil {
+		return err
+	}
+	n.startBackground()
+	if n.certs != nil {
+		return n.server.ListenAndServeTLS("", "") // certs come from the tls config
+	}
+	if n.peerTLS != nil {
+		ln, err := net.Listen("tcp", n.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		return n.server.Serve(newSniffListener(ln, n.peerTLS))
+	}
+	return n.server.ListenAndServe()
+}
+
+// Addr returns the address the node is listening on once started
+func (n *Node) Addr() string {
+	if n.listener == nil {
+		return n.cfg.Addr
+	}
+	return n.listener.Addr().String()
+}
+
+// URL returns the base URL of the node's API once started
+func (n *Node) URL() string {
+	if n.certs != nil {
+		return "https://" + n.Addr()
+	}
+	return "http://" + n.Addr()
+}
+
+// HTTPClient returns a client for calling other nodes, presenting this node's certificate when mutual TLS is on
+func (n *Node) HTTPClient() *http.Client {
+	if n.certs == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: n.certs.ClientTLSConfig()},
+	}
+}
+
+// startBackground kicks off the node's background work, like syncing with peers
+func (n *Node) startBackground() {
+	go n.syncLoop() // always running, peers can be added later
+	go n.maintenanceLoop()
+	go n.expiryLoop()
+	if n.cfg.LANDiscovery {
+		if err := n.startLANDiscovery(); err != nil { // the node works without it
+			n.logger.Printf("LAN discovery failed to start: %v", err)
+		}
+	}
+	if n.nat != nil {
+		go n.natLoop()
+	}
+	if len(n.cfg.DNSSeeds) > 0 {
+		go n.seedLoop()
+	}
+	n.settings.mu.Lock()
+	n.setMining(n.cfg.Mine)
+	n.settings.mu.Unlock()
+	if n.bft != nil {
+		go n.bft.run()
+	}
+	if n.cfg.Params.Engine == blockchain.EnginePoA { // it only seals while it's an authority
+		go n.sealLoop()
+	}
+}
+
+// Close stops the HTTP server and any background work
+func (n *Node) Close() error {
+	select {
+	case <-n.done: // already closed
+	default:
+		close(n.done)
+	}
+	if n.debugServer != nil {
+		n.debugServer.Close()
+	}
+	if n.stratum != nil {
+		n.stratum.close()
+	}
+	if n.nat != nil {
+		n.unmapPort()
+	}
+	if n.audit != nil {
+		n.audit.Close()
+	}
+	n.storeMu.Lock() // let a write that's under way finish, the ones after it see the node's closed
+	n.storeMu.Unlock()
+	return n.server.Close()
+}
+
+// closed returns if the node's been closed
+func (n *Node) closed() bool {
+	select {
+	case <-n.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// persist writes the current chain to storage, if the node has any, pruning old bodies first in pruned mode, and
+// writes any state snapshot taken since the last time
+func (n *Node) persist(ctx context.Context) error {
+	n.storeMu.Lock()
+	defer n.storeMu.Unlock()
+	if n.closed() { // background work finishing up, the data directory may be gone
+		return nil
+	}
+	defer n.writeState()
+	return n.save(ctx)
+}
+
+// persistAdded stores the blocks added on top of the stored head since the last time, appending them to the
+// stored chain as one batch instead of rewriting it. The chain's written whole instead if it's moved to another
+// branch since, or once the appended blocks outgrow it, which is also when pruned bodies leave the disk.
+func (n *Node) persistAdded(ctx context.Context) (err error) {
+	n.storeMu.Lock()
+	defer n.storeMu.Unlock()
+	if n.closed() {
+		return nil
+	}
+	defer n.writeState()
+
+	if n.store == nil {
+		return n.save(ctx)
+	}
+	stored, ok := n.store.Head()
+	if !ok || n.store.NeedsRewrite() {
+		return n.save(ctx)
+	}
+	if n.cfg.PruneDepth > 0 {
+		n.chain.Prune(n.cfg.PruneDepth)
+	}
+	added := n.chain.Range(stored.Index+1, n.chain.Len())
+	if len(added) == 0 {
+		return nil
+	}
+	if added[0].PrevHash != stored.Hash {
+		return n.save(ctx)
+	}
+
+	_, span := tracer.Start(ctx, "store.Append", trace.WithAttributes(attribute.Int("store.blocks", len(added))))
+	defer func() { endSpan(span, err) }()
+	return n.store.Append(added, n.chain.EncodeIndex())
+}
+
+// save is persist for callers holding storeMu
+func (n *Node) save(ctx context.Context) (err error) {
+	_, span := tracer.Start(ctx, "store.Save")
+	defer func() { endSpan(span, err) }()
+
+	if n.cfg.PruneDepth > 0 {
+		n.chain.Prune(n.cfg.PruneDepth)
+	}
+	if n.store == nil {
+		return nil
+	}
+	return n.store.Save(n.chain.Blocks(), n.chain.EncodeIndex())
+}
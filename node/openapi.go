@@ -0,0 +1,458 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/wallet"
+	"github.com/julienschmidt/httprouter"
+)
+
+// The OpenAPI document at /openapi.json is built from the routes as they're registered, the
+// operations below, and the Go types the API decodes and encodes. Request bodies are checked
+// against the same schemas before they reach a handler, so what the spec says is what's enforced.
+
+// apiOperation ... what the spec says about one route
+type apiOperation struct {
+	Summary  string
+	Query    []apiParam  // query string parameters
+	Body     interface{} // a value of the request body's type, nil if there's no body or it isn't checked
+	Status   int         // the success status, defaults to 200
+	Response interface{} // a value of the response's type, nil if it's not JSON
+	Encoded  bool        // the response can also be CBOR or protobuf, see encoding.go
+}
+
+// apiParam ... a query string parameter
+type apiParam struct {
+	Name string
+	Type string // "string", "integer" or "boolean"
+}
+
+// apiOperations documents the v1 routes, by method and path
+var apiOperations = map[string]apiOperation{
+	"GET /":                         {Summary: "The whole chain", Query: []apiParam{{"fields", "string"}}, Response: []blockchain.Block{}, Encoded: true},
+	"POST /":                        {Summary: "Mine a block with the given data and pending transactions", Body: Message{}, Status: http.StatusCreated, Response: blockchain.Block{}},
+	"GET /headers":                  {Summary: "Every block header, or a range of them ?from= an index", Query: []apiParam{{"from", "integer"}, {"limit", "integer"}}, Response: []blockchain.Header{}, Encoded: true},
+	"GET /head":                     {Summary: "The header of the latest block", Response: blockchain.Header{}, Encoded: true},
+	"GET /finalized":                {Summary: "The highest final block, which no reorg can replace", Response: Finality{}},
+	"GET /forks":                    {Summary: "The hard forks the network upgrades with, and which are active", Response: ForkSchedule{}},
+	"GET /softforks":                {Summary: "The soft forks miners can signal for, and where each is at", Response: SoftForkStatus{}},
+	"GET /blocks":                   {Summary: "A range of blocks", Query: []apiParam{{"from", "integer"}, {"limit", "integer"}, {"fields", "string"}}, Response: []blockchain.Block{}, Encoded: true},
+	"GET /block/:id":                {Summary: "A block by index or hash", Response: blockchain.Block{}, Encoded: true},
+	"GET /block/:id/uncles":         {Summary: "Stale blocks seen at a block's height", Response: []blockchain.Header{}},
+	"GET /stale":                    {Summary: "How often blocks lose the race for their height", Response: StaleStats{}},
+	"GET /bft":                      {Summary: "The validators and where this node is in BFT consensus", Response: BFTStatus{}},
+	"GET /validators":               {Summary: "The active validators, votes to change them and every change so far", Response: ValidatorsInfo{}},
+	"GET /stakes":                   {Summary: "What's staked with each validator and by whom", Response: []ValidatorStake{}},
+	"GET /delegations/:address":     {Summary: "What an address has staked, by validator", Response: []blockchain.Delegation{}},
+	"GET /proposals":                {Summary: "Every proposal to change a param, newest first", Response: []blockchain.ParamProposal{}},
+	"GET /proposals/:id":            {Summary: "A proposal, its votes and how it went", Response: blockchain.ParamProposal{}},
+	"GET /blocks/at":                {Summary: "The last block made at or before a time, RFC 3339 or unix nanoseconds", Query: []apiParam{{"time", "string"}}, Response: blockchain.Block{}, Encoded: true},
+	"GET /proof/:txhash":            {Summary: "A merkle proof that a transaction is in a block", Response: Proof{}},
+	"GET /verify/tx/:txhash":        {Summary: "On a light node, a transaction proved to be in the chain by a peer's merkle proof", Response: VerifiedTx{}},
+	"GET /verify/balance/:address":  {Summary: "On a light node, an address's balance and nonce proved by a peer's state proof against the node's own header", Query: []apiParam{{"height", "integer"}}, Response: VerifiedBalance{}},
+	"GET /verify/address/:address":  {Summary: "On a light node, an address's latest transactions that peers proved are in the chain", Query: []apiParam{{"limit", "integer"}}, Response: LightAddressTxs{}},
+	"POST /tx":                      {Summary: "Submit a transaction to the mempool, returning its hash", Body: blockchain.Transaction{}, Status: http.StatusAccepted, Response: ""},
+	"GET /mempool":                  {Summary: "A page of pending transactions, oldest first or ?sort=fee, ?from= one address's", Query: []apiParam{{"sort", "string"}, {"from", "string"}, {"offset", "integer"}, {"limit", "integer"}}, Response: MempoolPage{}},
+	"GET /mempool/:txhash":          {Summary: "A pending transaction, when it arrived and how many are ahead of it", Response: MempoolTx{}},
+	"GET /fees/estimate":            {Summary: "Suggest a fee rate that gets a transaction into one of the next ?target= blocks, from the mempool and recent blocks", Query: []apiParam{{"target", "integer"}}, Response: FeeEstimate{}},
+	"POST /tx/build":                {Summary: "Build an unsigned transfer with the sender's next nonce and a suggested fee, ready to sign", Body: TxBuildRequest{}, Response: TxBuild{}},
+	"POST /tx/raw":                  {Summary: "Submit a transaction signed offline, hex of its canonical encoding, returning its hash", Body: RawTx{}, Status: http.StatusAccepted, Response: ""},
+	"POST /txs":                     {Summary: "Submit a batch of transactions, all or nothing if atomic is true", Query: []apiParam{{"atomic", "boolean"}}, Body: []blockchain.Transaction{}, Response: BatchResult{}},
+	"POST /multisig":                {Summary: "Derive an M-of-N multisig address", Body: MultisigRequest{}, Response: MultisigAddress{}},
+	"POST /faucet/:address":         {Summary: "Send test coins to an address, on test networks, once an interval per address and client IP", Status: http.StatusAccepted, Response: FaucetDrip{}},
+	"POST /delegate":                {Summary: "Stake coins with a validator, returning the transaction's hash once it's signed, the transaction to sign before", Body: DelegationRequest{}, Status: http.StatusAccepted, Response: ""},
+	"POST /undelegate":              {Summary: "Take staked coins back from a validator, returning the transaction's hash once it's signed, the transaction to sign before", Body: DelegationRequest{}, Status: http.StatusAccepted, Response: ""},
+	"POST /proposals":               {Summary: "Propose changing a param, returning the transaction's hash, the proposal's ID, once it's signed, the transaction to sign before", Body: ProposalRequest{}, Status: http.StatusAccepted, Response: ""},
+	"POST /proposals/:id/vote":      {Summary: "Vote on a proposal, returning the transaction's hash once it's signed, the transaction to sign before", Body: VoteRequest{}, Status: http.StatusAccepted, Response: ""},
+	"GET /mining/template":          {Summary: "The next block for an external miner to solve", Query: []apiParam{{"address", "string"}}, Response: BlockTemplate{}},
+	"POST /mining/submit":           {Summary: "Hand back a block solved from a template, returning its hash", Body: blockchain.Block{}, Status: http.StatusCreated, Response: ""},
+	"GET /mining/workers":           {Summary: "Stratum workers and their share counts and hash rates", Response: []WorkerStats{}},
+	"GET /tokens":                   {Summary: "Every token issued on the chain", Response: []blockchain.Token{}},
+	"GET /token/:id/balances":       {Summary: "How much of a token each address holds", Response: map[string]int{}},
+	"GET /asset/:id":                {Summary: "A unique asset and who owns it", Response: blockchain.Asset{}},
+	"GET /balance/:address":         {Summary: "An address's coin balance, counting only blocks with enough confirmations or as of a height", Query: []apiParam{{"confirmations", "integer"}, {"height", "integer"}}, Response: AddressBalance{}},
+	"GET /address/:address/txs":     {Summary: "Transactions an address has sent or received, newest first", Query: []apiParam{{"offset", "integer"}, {"limit", "integer"}}, Response: AddressHistory{}},
+	"GET /supply":                   {Summary: "Coins minted so far, the most there will ever be and the current block reward", Response: Supply{}},
+	"GET /state/proof/:address":     {Summary: "A merkle proof of an address's account against a block's state root, the head's by default", Query: []apiParam{{"height", "integer"}}, Response: AccountProof{}},
+	"GET /state/snapshots":          {Summary: "The signed state snapshots the node has, newest first", Response: []StateSnapshotInfo{}},
+	"GET /state/snapshots/:height":  {Summary: "The signed snapshot of the chain's state as of a block, for a new node to start from", Response: StateSnapshot{}},
+	"GET /profile":                  {Summary: "The node's profile, archive, full, pruned or light, and how far back it keeps blocks and state", Response: NodeProfile{}},
+	"GET /graphql":                  {Summary: "Run a GraphQL query", Query: []apiParam{{"query", "string"}, {"variables", "string"}}, Response: GraphQLResponse{}},
+	"POST /graphql":                 {Summary: "Run a GraphQL query", Body: GraphQLRequest{}, Response: GraphQLResponse{}},
+	"POST /rpc":                     {Summary: "JSON-RPC 2.0, a single call or a batch", Response: RPCResponse{}}, // the body is a call or an array of them, see rpc.go
+	"GET /events":                   {Summary: "Chain events as server-sent events", Query: []apiParam{{"types", "string"}}},
+	"GET /events/ws":                {Summary: "Chain events over a WebSocket, one message each", Query: []apiParam{{"types", "string"}}, Response: WebSocketEvent{}},
+	"GET /peers":                    {Summary: "The node's peers, where each came from, its head and how long it took to answer", Response: []PeerInfo{}},
+	"POST /handshake":               {Summary: "Authenticate a peer", Body: Handshake{}, Response: HandshakeAck{}},
+	"POST /inv":                     {Summary: "Offer a peer blocks or transactions, returning the hashes it wants", Body: Inventory{}, Response: []string{}},
+	"POST /gossip/block":            {Summary: "Push a block to a peer", Body: blockchain.Block{}, Status: http.StatusCreated, Response: ""},
+	"POST /gossip/tx":               {Summary: "Push a transaction to a peer", Body: blockchain.Transaction{}, Status: http.StatusAccepted, Response: ""},
+	"POST /bft/proposal":            {Summary: "Send a validator the round's proposed block", Body: Proposal{}, Status: http.StatusAccepted, Response: ""},
+	"POST /bft/vote":                {Summary: "Send a validator a prevote or precommit", Body: blockchain.Vote{}, Status: http.StatusAccepted, Response: ""},
+	"GET /admin/maintenance":        {Summary: "Maintenance tasks and their recent runs", Response: MaintenanceStatus{}},
+	"POST /admin/maintenance/:task": {Summary: "Run a maintenance task now", Response: MaintenanceRun{}},
+	"POST /admin/snapshot":          {Summary: "Write a snapshot", Status: http.StatusCreated, Response: SnapshotInfo{}},
+	"GET /admin/bans":               {Summary: "Banned node IDs", Response: []string{}},
+	"POST /admin/bans/:id":          {Summary: "Ban a node, till it's unbanned or ?for= a while", Query: []apiParam{{"for", "string"}}, Response: []string{}},
+	"GET /admin/scores":             {Summary: "Peers' misbehavior scores and bans, worst first", Response: []PeerScore{}},
+	"GET /admin/nat":                {Summary: "The port mapping on the router, and the URL advertised to peers", Response: NATStatus{}},
+	"POST /admin/peers":             {Summary: "Add and remove peers, the ones added are kept across restarts, returning the peers after", Body: PeersRequest{}, Response: []string{}},
+	"GET /admin/mempool":            {Summary: "How full the mempool is, its limits and how many transactions they've evicted or refused", Response: MempoolStats{}},
+	"DELETE /admin/mempool/:txhash": {Summary: "Evict a pending transaction", Response: blockchain.Transaction{}},
+	"DELETE /admin/bans/:id":        {Summary: "Lift a ban and clear the node's score", Response: []string{}},
+	"GET /admin/webhooks":           {Summary: "Webhook URLs", Response: []string{}},
+	"POST /admin/webhooks":          {Summary: "Register a webhook", Body: WebhookRequest{}, Response: []string{}},
+	"DELETE /admin/webhooks":        {Summary: "Remove a webhook", Query: []apiParam{{"url", "string"}}, Response: []string{}},
+	"GET /admin/settings":           {Summary: "The settings that can change while the node runs", Response: Settings{}},
+	"POST /admin/settings":          {Summary: "Change settings, fields left out keep their values", Body: Settings{}, Response: Settings{}},
+	"GET /admin/mining":             {Summary: "Whether the node is mining, where rewards go and its hash rate", Response: MiningStatus{}},
+	"POST /admin/mining":            {Summary: "Start or stop mining, or change the reward address or threads, fields left out keep their values", Body: MiningSettings{}, Response: MiningStatus{}},
+	"GET /admin/cache":              {Summary: "How many block requests the block cache has answered and missed", Response: CacheStats{}},
+	"GET /admin/orphans":            {Summary: "Gossiped blocks held until their parents arrive", Response: []OrphanInfo{}},
+	"POST /admin/validators":        {Summary: "Vote, as a validator, to add or remove a validator, returning the vote's transaction hash", Body: ValidatorVoteRequest{}, Status: http.StatusAccepted, Response: ""},
+	"GET /wallet":                   {Summary: "The wallets the node keeps and their addresses", Response: []WalletInfo{}},
+	"POST /wallet":                  {Summary: "Create a wallet, returning its backup phrase, the only time it's sent", Body: WalletCreateRequest{}, Status: http.StatusCreated, Response: WalletCreated{}},
+	"GET /wallet/:name":             {Summary: "A wallet's addresses and whether it's unlocked", Response: WalletInfo{}},
+	"POST /wallet/:name/unlock":     {Summary: "Unlock a wallet with its passphrase for a while", Body: WalletUnlockRequest{}, Response: WalletInfo{}},
+	"POST /wallet/:name/lock":       {Summary: "Lock a wallet again", Response: WalletInfo{}},
+	"POST /wallet/:name/addresses":  {Summary: "Hand out an unlocked wallet's next address", Status: http.StatusCreated, Response: wallet.Address{}},
+	"POST /wallet/:name/sign":       {Summary: "Sign a hex payload or a transaction with one of an unlocked wallet's keys", Body: WalletSignRequest{}, Response: WalletSignature{}},
+}
+
+// RequestRejection ... the response when a request body doesn't match the API spec
+type RequestRejection struct {
+	Code   string       // always "invalid_request"
+	Error  string       // human readable summary
+	Fields []FieldError `json:",omitempty"` // each problem found, by field
+}
+
+// FieldError ... one thing wrong with a request body
+type FieldError struct {
+	Field string // path to the field, eg "Transactions[2].Amount", empty for the body itself
+	Error string
+}
+
+// apiSchema ... an OpenAPI schema, just the parts needed to describe the API's Go types
+type apiSchema struct {
+	Ref                  string                `json:"$ref,omitempty"`
+	Type                 string                `json:"type,omitempty"`
+	Format               string                `json:"format,omitempty"`
+	Enum                 []string              `json:"enum,omitempty"`
+	Items                *apiSchema            `json:"items,omitempty"`
+	Properties           map[string]*apiSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}           `json:"additionalProperties,omitempty"` // false, or the schema of map values
+	Nullable             bool                  `json:"nullable,omitempty"`
+}
+
+// openAPI collects the routes of the versioned API into a spec
+type openAPI struct {
+	paths   map[string]map[string]interface{} // path -> method -> operation
+	schemas map[string]*apiSchema             // components, by type name
+	invalid func(r *http.Request)             // called with each request whose body fails validation, if set
+}
+
+func newOpenAPI() *openAPI {
+	return &openAPI{
+		paths:   make(map[string]map[string]interface{}),
+		schemas: make(map[string]*apiSchema),
+	}
+}
+
+// add documents a route, returning the schema its body has to match, nil if it isn't checked
+func (spec *openAPI) add(method, path string) *apiSchema {
+	op := apiOperations[method+" "+path]
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := map[string]interface{}{"description": http.StatusText(status)}
+	if op.Response != nil {
+		content := map[string]interface{}{mediaJSON: map[string]interface{}{"schema": spec.schema(reflect.TypeOf(op.Response))}}
+		if op.Encoded {
+			content[mediaCBOR] = content[mediaJSON]
+			content[mediaProtobuf] = map[string]interface{}{} // messages are in chain.proto
+		}
+		response["content"] = content
+	}
+	responses := map[string]interface{}{fmt.Sprint(status): response}
+
+	var params []map[string]interface{}
+	openPath := path
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ":") {
+			name := part[1:]
+			openPath = strings.Replace(openPath, part, "{"+name+"}", 1)
+			params = append(params, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": apiSchema{Type: "string"}})
+		}
+	}
+	for _, q := range op.Query {
+		params = append(params, map[string]interface{}{"name": q.Name, "in": "query", "schema": apiSchema{Type: q.Type}})
+	}
+
+	operation := map[string]interface{}{"summary": op.Summary, "responses": responses}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	var body *apiSchema
+	if op.Body != nil {
+		body = spec.schema(reflect.TypeOf(op.Body))
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": body}},
+		}
+		responses["400"] = map[string]interface{}{
+			"description": "the body doesn't match the schema",
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": spec.schema(reflect.TypeOf(RequestRejection{}))}},
+		}
+	}
+	if strings.HasPrefix(path, "/admin/") {
+		operation["security"] = []map[string][]string{{"adminToken": {}}}
+	}
+
+	if spec.paths[openPath] == nil {
+		spec.paths[openPath] = make(map[string]interface{})
+	}
+	spec.paths[openPath][strings.ToLower(method)] = operation
+	return body
+}
+
+// schema describes a Go type, adding named structs to the components and referring to them
+func (spec *openAPI) schema(t reflect.Type) *apiSchema {
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &apiSchema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(blockchain.TxClass("")):
+		return &apiSchema{Type: "string", Enum: txClasses()}
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return &apiSchema{} // any JSON
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := spec.schema(t.Elem())
+		return &apiSchema{Ref: s.Ref, Type: s.Type, Format: s.Format, Enum: s.Enum, Items: s.Items, Properties: s.Properties, AdditionalProperties: s.AdditionalProperties, Nullable: true}
+	case reflect.String:
+		return &apiSchema{Type: "string"}
+	case reflect.Bool:
+		return &apiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &apiSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &apiSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &apiSchema{Type: "array", Items: spec.schema(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &apiSchema{Type: "object", AdditionalProperties: spec.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if _, ok := spec.schemas[t.Name()]; !ok && t.Name() != "" {
+			spec.schemas[t.Name()] = &apiSchema{} // placeholder while it's built, for types that refer to themselves
+			*spec.schemas[t.Name()] = *spec.object(t)
+		}
+		if t.Name() == "" {
+			return spec.object(t)
+		}
+		return &apiSchema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return &apiSchema{} // interfaces can be anything
+}
+
+// object describes a struct the way encoding/json writes it, with embedded structs' fields pulled up
+func (spec *openAPI) object(t reflect.Type) *apiSchema {
+	s := &apiSchema{Type: "object", Properties: make(map[string]*apiSchema), AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for prop, ps := range spec.object(f.Type).Properties {
+				s.Properties[prop] = ps
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = spec.schema(f.Type)
+	}
+	return s
+}
+
+// txClasses lists every transaction class, for the TxClass enum. Empty is allowed too, it means user.
+func txClasses() []string {
+	return []string{
+		"", string(blockchain.ClassUser), string(blockchain.ClassGovernance), string(blockchain.ClassOracle), string(blockchain.ClassCoinbase),
+		string(blockchain.ClassTokenIssue), string(blockchain.ClassTokenTransfer), string(blockchain.ClassAssetMint), string(blockchain.ClassAssetTransfer),
+		string(blockchain.ClassValidator), string(blockchain.ClassDelegate), string(blockchain.ClassUndelegate), string(blockchain.ClassStakeReward),
+		string(blockchain.ClassSlash), string(blockchain.ClassProposal), string(blockchain.ClassVote),
+	}
+}
+
+// document returns the OpenAPI 3 document
+func (spec *openAPI) document() map[string]interface{} {
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": "go-blockchain node API", "version": Version},
+		"servers": []map[string]string{{"url": "/v1"}},
+		"paths":   spec.paths,
+		"components": map[string]interface{}{
+			"schemas":         spec.schemas,
+			"securitySchemes": map[string]interface{}{"adminToken": map[string]string{"type": "http", "scheme": "bearer"}},
+		},
+	}
+}
+
+// serve handles the route serving the OpenAPI document
+func (spec *openAPI) serve(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, spec.document())
+}
+
+// validateBody wraps a handler so bodies that don't match the schema are turned away with a RequestRejection.
+// Blocks gossiped in the canonical encoding are left to the handler.
+func (spec *openAPI) validateBody(schema *apiSchema, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); media == mediaBinary {
+			handle(w, r, ps)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			RespondWithJSON(w, r, http.StatusBadRequest, RequestRejection{Code: "invalid_request", Error: "reading the body: " + err.Error()})
+			return
+		}
+
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			spec.rejected(r)
+			RespondWithJSON(w, r, http.StatusBadRequest, RequestRejection{Code: "invalid_request", Error: "the body has to be JSON: " + err.Error()})
+			return
+		}
+
+		var problems []FieldError
+		spec.validate(value, schema, "", &problems)
+		if len(problems) > 0 {
+			spec.rejected(r)
+			RespondWithJSON(w, r, http.StatusBadRequest, RequestRejection{Code: "invalid_request", Error: problems[0].Field + ": " + problems[0].Error, Fields: problems})
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handle(w, r, ps)
+	}
+}
+
+// rejected passes a request with an invalid body on to the invalid hook
+func (spec *openAPI) rejected(r *http.Request) {
+	if spec.invalid != nil {
+		spec.invalid(r)
+	}
+}
+
+// validate checks a decoded JSON value against a schema, adding anything wrong to problems.
+// Field names match case insensitively, like encoding/json.
+func (spec *openAPI) validate(value interface{}, s *apiSchema, path string, problems *[]FieldError) {
+	if s.Ref != "" {
+		s = spec.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	if value == nil || s.Type == "" { // null decodes to the zero value, and untyped schemas take anything
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, FieldError{Field: path, Error: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("has to be a string")
+		} else if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			fail("has to be one of %s", strings.TrimPrefix(strings.Join(s.Enum, ", "), ", ")) // an empty value isn't worth listing
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("has to be true or false")
+		}
+	case "integer":
+		if num, ok := value.(json.Number); !ok {
+			fail("has to be an integer")
+		} else if _, err := num.Int64(); err != nil {
+			fail("has to be a whole number that fits in 64 bits")
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			fail("has to be a number")
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("has to be an array")
+			return
+		}
+		for i, item := range items {
+			spec.validate(item, s.Items, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("has to be an object")
+			return
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // report problems in a stable order
+		for _, key := range keys {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			if prop := s.property(key); prop != nil {
+				spec.validate(obj[key], prop, fieldPath, problems)
+			} else if values, ok := s.AdditionalProperties.(*apiSchema); ok {
+				spec.validate(obj[key], values, fieldPath, problems)
+			} else {
+				*problems = append(*problems, FieldError{Field: fieldPath, Error: "unknown field"})
+			}
+		}
+	}
+}
+
+// property finds a property by name, falling back to a case insensitive match
+func (s *apiSchema) property(name string) *apiSchema {
+	if prop, ok := s.Properties[name]; ok {
+		return prop
+	}
+	for prop, ps := range s.Properties {
+		if strings.EqualFold(prop, name) {
+			return ps
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// the limits on orphans
+const (
+	maxOrphans     = 100 // most blocks with unknown parents held at once, the oldest go first
+	maxOrphanDepth = 16  // how many missing ancestors are fetched one by one before syncing by range instead
+)
+
+// orphanPool ... gossiped blocks whose parents we don't have yet, held until their parents arrive
+type orphanPool struct {
+	mu       sync.Mutex
+	blocks   map[string]blockchain.Block // by hash
+	children map[string][]string         // parent hash -> hashes of orphans building on it
+	order    []string                    // oldest first
+}
+
+// OrphanInfo ... a held orphan, for /admin/orphans
+type OrphanInfo struct {
+	Hash     string
+	Index    int
+	PrevHash string // the missing parent
+}
+
+func newOrphanPool() *orphanPool {
+	return &orphanPool{blocks: make(map[string]blockchain.Block), children: make(map[string][]string)}
+}
+
+// add holds a block until its parent arrives, dropping the oldest orphan if the pool is full.
+// It returns false if the block was already held.
+func (p *orphanPool) add(block blockchain.Block) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.blocks[block.Hash]; ok {
+		return false
+	}
+	if len(p.order) >= maxOrphans {
+		p.remove(p.order[0])
+	}
+	p.blocks[block.Hash] = block
+	p.children[block.PrevHash] = append(p.children[block.PrevHash], block.Hash)
+	p.order = append(p.order, block.Hash)
+	return true
+}
+
+// take removes and returns the orphans building on a block
+func (p *orphanPool) take(parent string) []blockchain.Block {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var blocks []blockchain.Block
+	for _, hash := range p.children[parent] {
+		blocks = append(blocks, p.blocks[hash])
+	}
+	for _, block := range blocks {
+		p.remove(block.Hash)
+	}
+	return blocks
+}
+
+// dropBelow removes orphans at or below a height, they can't build on a head that's past them
+func (p *orphanPool) dropBelow(height int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, hash := range append([]string(nil), p.order...) {
+		if p.blocks[hash].Index <= height {
+			p.remove(hash)
+		}
+	}
+}
+
+// list returns the held orphans, oldest first
+func (p *orphanPool) list() []OrphanInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	orphans := make([]OrphanInfo, 0, len(p.order))
+	for _, hash := range p.order {
+		block := p.blocks[hash]
+		orphans = append(orphans, OrphanInfo{Hash: hash, Index: block.Index, PrevHash: block.PrevHash})
+	}
+	return orphans
+}
+
+// remove drops a held block, p.mu has to be held
+func (p *orphanPool) remove(hash string) {
+	block, ok := p.blocks[hash]
+	if !ok {
+		return
+	}
+	delete(p.blocks, hash)
+
+	siblings := p.children[block.PrevHash]
+	for i, h := range siblings {
+		if h == hash {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(p.children, block.PrevHash)
+	} else {
+		p.children[block.PrevHash] = siblings
+	}
+
+	for i, h := range p.order {
+		if h == hash {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// isOrphan returns if a block that didn't build on the head is ahead of it with a parent we've never seen,
+// rather than being invalid or on a branch we know about
+func (n *Node) isOrphan(block blockchain.Block) bool {
+	if block.Index <= n.chain.Last().Index {
+		return false
+	}
+	_, known := n.chain.BlockByHash(block.PrevHash)
+	return !known
+}
+
+// fetchAncestors asks peers for an orphan's missing parent, then that block's parent if it's missing too,
+// until one connects to the chain. Anything deeper than maxOrphanDepth, or on another branch, is left
+// to a sync.
+func (n *Node) fetchAncestors(ctx context.Context, orphan blockchain.Block) {
+	missing := orphan.PrevHash
+	for i := 0; i < maxOrphanDepth; i++ {
+		block, err := n.fetchFromPeers(missing)
+		if err != nil {
+			n.logger.Printf("fetching orphan parent %s: %v", missing, err)
+			break
+		}
+		n.seen.Add(block.Hash)
+		if !n.isOrphan(block) {
+			n.orphans.add(block) // connects it along with its children, if it builds on the head
+			if n.connectOrphans(ctx) > 0 {
+				return
+			}
+			break
+		}
+		n.orphans.add(block)
+		missing = block.PrevHash
+	}
+	n.syncWithPeers()
+}
+
+// fetchFromPeers gets a block by hash from the first peer that has it
+func (n *Node) fetchFromPeers(hash string) (blockchain.Block, error) {
+	err := fmt.Errorf("no peers")
+	for _, peer := range n.peers() {
+		var block blockchain.Block
+		if block, err = FetchBlock(n.client, peer, hash); err == nil {
+			if block.Hash != hash || blockchain.GenerateHash(block) != hash {
+				err = fmt.Errorf("%s sent a block that isn't %s", peer, hash)
+				continue
+			}
+			return block, nil
+		}
+	}
+	return blockchain.Block{}, err
+}
+
+// connectOrphans adds every held orphan that builds on the head, then the ones building on those, storing and
+// announcing them, and returns how many it added. Orphans the head has moved past are dropped.
+func (n *Node) connectOrphans(ctx context.Context) int {
+	var added []blockchain.Block
+	for connected := true; connected; {
+		connected = false
+		for _, block := range n.orphans.take(n.chain.Last().Hash) {
+			if !connected && n.addBlock(ctx, block) { // any others were building a branch off the same parent
+				n.mempool.RemoveIncluded(block)
+				added = append(added, block)
+				connected = true
+			} else {
+				n.recordIfStale(block)
+			}
+		}
+	}
+	n.orphans.dropBelow(n.chain.Last().Index)
+	if len(added) == 0 {
+		return 0
+	}
+
+	if err := n.persistAdded(ctx); err != nil {
+		n.logger.Printf("storing connected orphans: %v", err)
+	}
+	for _, block := range added {
+		n.bus.Publish(events.BlockAdded{Block: block, Origin: events.OriginGossip})
+	}
+	n.logger.Printf("connected %d orphan blocks", len(added))
+	return len(added)
+}
+
+// GetOrphans handles the admin route listing the blocks held waiting for their parents
+func (n *Node) GetOrphans(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.orphans.list())
+}
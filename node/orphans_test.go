@@ -0,0 +1,82 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// testNode starts a node with its own data directory, nodes started at the same genesis time share a chain
+func testNode(t *testing.T, genesis time.Time) *Node {
+	t.Helper()
+
+	n, err := New(Config{DataDir: t.TempDir(), GenesisTime: genesis})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestConnectOrphans(t *testing.T) {
+	genesis := time.Now()
+	src := testNode(t, genesis)
+	blocks := []blockchain.Block{src.chain.Last()}
+	for i := 1; i <= 3; i++ {
+		block, _, err := src.mineBlock(context.Background(), i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	tests := []struct {
+		name       string
+		held       []int // indexes of the blocks gossiped, in the order they arrive
+		wantHeight int
+		wantHeld   int
+	}{
+		{"parent never arrives", []int{2, 3}, 0, 2},
+		{"parent arrives last", []int{3, 2, 1}, 3, 0},
+		{"in order", []int{1, 2, 3}, 3, 0},
+		{"gap", []int{1, 3}, 1, 1},
+		{"already past it", []int{1, 1}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := testNode(t, genesis)
+			for _, i := range tt.held {
+				n.orphans.add(blocks[i])
+				n.connectOrphans(context.Background())
+			}
+			if height, held := n.chain.Last().Index, len(n.orphans.list()); height != tt.wantHeight || held != tt.wantHeld {
+				t.Errorf("height %d with %d orphans held, want %d with %d", height, held, tt.wantHeight, tt.wantHeld)
+			}
+		})
+	}
+}
+
+func TestOrphanPool(t *testing.T) {
+	p := newOrphanPool()
+	genesis := blockchain.NewGenesisBlock()
+	var blocks []blockchain.Block
+	for i := 0; i < maxOrphans+1; i++ {
+		block, _ := blockchain.GenerateBlock(genesis, i) // all siblings, they differ by their data
+		blocks = append(blocks, block)
+		if !p.add(block) {
+			t.Fatalf("add() refused orphan %d", i)
+		}
+	}
+	if p.add(blocks[len(blocks)-1]) {
+		t.Error("add() took an orphan it already held")
+	}
+	held := p.list()
+	if len(held) != maxOrphans || held[0].Hash != blocks[1].Hash {
+		t.Errorf("holding %d orphans starting with %s, want %d starting with %s, the oldest dropped", len(held), held[0].Hash, maxOrphans, blocks[1].Hash)
+	}
+	if taken := p.take(genesis.Hash); len(taken) != maxOrphans || len(p.list()) != 0 {
+		t.Errorf("take() returned %d orphans leaving %d, want %d leaving none", len(taken), len(p.list()), maxOrphans)
+	}
+}
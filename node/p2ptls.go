@@ -0,0 +1,226 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// With EncryptPeers set, everything nodes send each other goes over TLS, with each side's certificate made
+// from its node key rather than signed by a CA, so there's nothing to hand out: a node's ID is its
+// certificate's public key. Handshakes and peer routes are only accepted over a connection whose client
+// certificate is the key of the node holding the session, and a node only believes a peer's handshake if it
+// came over a connection whose server certificate is the key the peer signed it with. So once two nodes have
+// shaken hands nobody in between can read what they gossip, or change it, without their keys. Peer URLs stay
+// http://, the connection's upgraded underneath, and the API keeps answering plain HTTP on the same port for
+// clients, telling the two apart by the first byte. Every node on the network has to turn it on, a node that
+// hasn't can't be talked to.
+
+// how long a connection gets to send its first byte before it's dropped
+const sniffTimeout = 10 * time.Second
+
+// peerCertificate makes a self-signed certificate from the node key
+func peerCertificate(key ed25519.PrivateKey) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hex.EncodeToString(key.Public().(ed25519.PublicKey))},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0), // made afresh each start, the key is what's checked
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// verifyNodeCert checks a peer presented one certificate, signed by its own ed25519 key
+func verifyNodeCert(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) != 1 {
+		return errors.New("tls: a node presents just its own certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	}
+	if _, ok := cert.PublicKey.(ed25519.PublicKey); !ok {
+		return errors.New("tls: a node's certificate has to be for its ed25519 node key")
+	}
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) // it's no CA, so not CheckSignatureFrom
+}
+
+// certNodeID returns the node ID a connection's peer certificate is for, empty if it isn't TLS or the peer
+// didn't present one
+func certNodeID(cs *tls.ConnectionState) string {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return ""
+	}
+	pub, ok := cs.PeerCertificates[0].PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return ""
+	}
+	return hex.EncodeToString(pub)
+}
+
+// peerServerTLS returns the tls config the API serves encrypted connections with. Clients that aren't nodes
+// needn't present a certificate.
+func peerServerTLS(cert tls.Certificate) *tls.Config {
+	return &tls.Config{
+		MinVersion:            tls.VersionTLS13,
+		Certificates:          []tls.Certificate{cert},
+		ClientAuth:            tls.RequestClientCert,
+		VerifyPeerCertificate: verifyNodeCert,
+		NextProtos:            []string{"http/1.1"},
+	}
+}
+
+type expectedPeerKey struct{}
+
+// encryptedTransport sends requests to peers over TLS, whatever their URL's scheme, and checks a peer that's
+// been shaken hands with is still the node that did
+type encryptedTransport struct {
+	node  *Node
+	inner *http.Transport
+}
+
+func newEncryptedTransport(n *Node, cert tls.Certificate) *encryptedTransport {
+	t := &encryptedTransport{node: n}
+	config := &tls.Config{
+		MinVersion:            tls.VersionTLS13,
+		Certificates:          []tls.Certificate{cert},
+		InsecureSkipVerify:    true, // there's no CA, the certificate's key is checked against the node ID instead
+		VerifyPeerCertificate: verifyNodeCert,
+		NextProtos:            []string{"http/1.1"},
+	}
+	t.inner = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&tls.Dialer{Config: config}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			state := conn.(*tls.Conn).ConnectionState()
+			if want, _ := ctx.Value(expectedPeerKey{}).(string); want != "" && certNodeID(&state) != want {
+				conn.Close()
+				return nil, errors.New("tls: " + addr + " isn't node " + want + ", its key has changed or someone's in the way")
+			}
+			return conn, nil
+		},
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return t
+}
+
+func (t *encryptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	peer := req.URL.Scheme + "://" + req.URL.Host
+	id := t.node.identity
+	id.mu.Lock()
+	want := id.peerIDs[peer] // empty till the handshake, which checks the key itself
+	id.mu.Unlock()
+
+	req = req.Clone(context.WithValue(req.Context(), expectedPeerKey{}, want))
+	req.URL.Scheme = "https"
+	return t.inner.RoundTrip(req)
+}
+
+// sniffListener hands out connections that start with a TLS handshake as TLS connections, and the rest as
+// they are, so peers and plain HTTP clients can share the API's port
+type sniffListener struct {
+	net.Listener
+	config *tls.Config
+
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+	err   error // why accepting stopped, set before done's closed
+}
+
+func newSniffListener(ln net.Listener, config *tls.Config) *sniffListener {
+	l := &sniffListener{Listener: ln, config: config, conns: make(chan net.Conn), done: make(chan struct{})}
+	go l.acceptLoop()
+	return l
+}
+
+func (l *sniffListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.close(err)
+			return
+		}
+		go l.sniff(conn) // so a client that never sends anything doesn't hold up the rest
+	}
+}
+
+// sniff looks at a connection's first byte, 0x16 starts a TLS handshake
+func (l *sniffListener) sniff(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	r := bufio.NewReader(conn)
+	first, err := r.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+	var c net.Conn = &peekedConn{Conn: conn, r: r}
+	if first[0] == 0x16 {
+		c = tls.Server(c, l.config)
+	}
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		c.Close()
+	}
+}
+
+func (l *sniffListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *sniffListener) Close() error {
+	return l.close(net.ErrClosed)
+}
+
+func (l *sniffListener) close(reason error) error {
+	var err error
+	l.once.Do(func() {
+		l.err = reason
+		close(l.done)
+		err = l.Listener.Close()
+	})
+	return err
+}
+
+// peekedConn is a connection with its first bytes already read into r
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
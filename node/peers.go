@@ -0,0 +1,178 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Peers come from three places: the config, which Reload can swap out, POST /admin/peers, and finding them
+// on the LAN, from DNS seeds or in handshakes. The ones added over the API are saved to peers.json in the
+// data directory and added back when the node starts, so they stay peers until they're removed, whatever
+// the config says. A reload keeps them too. Removing a peer that came from the config only lasts until the
+// next reload or restart, take it out of the config to get rid of it for good. GET /peers lists them all
+// with what each said its head was the last time it was synced with, and how long it took to answer.
+
+// peersFile is where the peers added over the API are kept, in the data directory
+const peersFile = "peers.json"
+
+// peer sources, for PeerInfo
+const (
+	PeerConfig = "config" // in the config, or set with /admin/settings
+	PeerAdded  = "added"  // added with POST /admin/peers
+	PeerFound  = "found"  // found on the LAN, from DNS seeds or by a peer advertising itself
+)
+
+// PeersRequest ... the body of POST /admin/peers, eg {"Add":["http://10.0.0.5:8080"],"Remove":["http://10.0.0.6:8080"]}
+type PeersRequest struct {
+	Add    []string `json:",omitempty"`
+	Remove []string `json:",omitempty"`
+}
+
+// PeerInfo ... a peer in GET /peers
+type PeerInfo struct {
+	PeerStatus
+	NodeID    string `json:",omitempty"` // what it proved it is in its handshake, empty till it's been signed in to
+	Source    string // PeerConfig, PeerAdded or PeerFound
+	Connected bool   // it answered the last time it was synced with
+}
+
+// loadPeers adds back the peers added over the API the last time the node ran, and notes which came from
+// the config
+func (n *Node) loadPeers() error {
+	n.settings.static = append([]string{}, n.cfg.Peers...)
+	if n.cfg.DataDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(n.cfg.DataDir, peersFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &n.settings.added); err != nil {
+		return errors.New("peers: " + peersFile + " isn't a list of peer URLs: " + err.Error())
+	}
+	n.cfg.Peers = mergePeers(n.cfg.Peers, n.settings.added)
+	return nil
+}
+
+// savePeers writes out the peers added over the API, the settings lock has to be held
+func (n *Node) savePeers() error {
+	if n.cfg.DataDir == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(n.settings.added, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(n.cfg.DataDir, peersFile)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// mergePeers returns the peers in a followed by the ones in b that aren't in a
+func mergePeers(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, peer := range b {
+		if !containsString(merged, peer) {
+			merged = append(merged, peer)
+		}
+	}
+	return merged
+}
+
+// without returns list less the strings in drop
+func without(list, drop []string) []string {
+	kept := make([]string, 0, len(list))
+	for _, v := range list {
+		if !containsString(drop, v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// editPeers adds and removes peers, saving the ones added so they're peers again after a restart. It returns
+// the peers that weren't ones before.
+func (n *Node) editPeers(add, remove []string) ([]string, error) {
+	n.settings.mu.Lock()
+	defer n.settings.mu.Unlock()
+
+	var added []string
+	for _, peer := range add {
+		if !containsString(n.cfg.Peers, peer) && !containsString(added, peer) {
+			added = append(added, peer)
+		}
+	}
+	n.settings.added = without(mergePeers(n.settings.added, add), remove)
+	n.settings.static = without(n.settings.static, remove)
+	n.cfg.Peers = without(mergePeers(n.cfg.Peers, add), remove) // a new slice, peers() hands the old one out
+	return added, n.savePeers()
+}
+
+// peerSource returns where a peer came from, the settings lock has to be held
+func (n *Node) peerSource(peer string) string {
+	switch {
+	case containsString(n.settings.added, peer):
+		return PeerAdded
+	case containsString(n.settings.static, peer):
+		return PeerConfig
+	}
+	return PeerFound
+}
+
+// GetPeers handles the route listing the node's peers, their heads and how fast they answer
+func (n *Node) GetPeers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	statuses := n.peerStatus.list(n.peers())
+	infos := make([]PeerInfo, len(statuses))
+
+	n.identity.mu.Lock()
+	for i, status := range statuses {
+		infos[i] = PeerInfo{PeerStatus: status, NodeID: n.identity.peerIDs[status.URL], Connected: !status.LastSeen.IsZero() && status.LastError == ""}
+	}
+	n.identity.mu.Unlock()
+
+	n.settings.mu.RLock()
+	for i := range infos {
+		infos[i].Source = n.peerSource(infos[i].URL)
+	}
+	n.settings.mu.RUnlock()
+
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Connected && !infos[j].Connected })
+	RespondWithJSON(w, r, http.StatusOK, infos)
+}
+
+// PostPeers handles the admin route to add and remove peers, returning the node's peers after
+func (n *Node) PostPeers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req PeersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	for _, peer := range req.Add {
+		if !validPeerURL(peer) {
+			RespondWithJSON(w, r, http.StatusBadRequest, "a peer is an http or https base URL, eg http://10.0.0.5:8080, not "+peer)
+			return
+		}
+	}
+
+	added, err := n.editPeers(req.Add, req.Remove)
+	if err != nil { // they're peers till the node stops, they just won't be after a restart
+		n.logger.Printf("saving the peers failed: %v", err)
+	}
+	for _, peer := range added {
+		go n.SyncWithPeer(peer)
+	}
+	n.logger.Printf("peers changed: %d added, %d removed", len(req.Add), len(req.Remove))
+	RespondWithJSON(w, r, http.StatusOK, n.Settings().Peers)
+}
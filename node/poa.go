@@ -0,0 +1,107 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+)
+
+// sealLoop seals a block of pending transactions whenever this node's turn as an authority on a PoA network
+// comes round, until the node shuts down
+func (n *Node) sealLoop() {
+	heads := make(chan struct{}, 1)
+	stop := n.bus.Handle(func(events.Event) {
+		select {
+		case heads <- struct{}{}:
+		default:
+		}
+	}, events.NameBlockAdded, events.NameChainReorg)
+	defer stop()
+
+	since := time.Now() // when this node got the head, if that's later than the head was made
+	for {
+		wait, ok := n.sealDelay(since)
+		if !ok { // this node sealed one of the last few blocks, so it's another authority's turn, or it isn't one
+			wait = time.Hour
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-n.done:
+			timer.Stop()
+			return
+		case <-heads: // a new head, start counting again from it
+			timer.Stop()
+			since = time.Now()
+			continue
+		case <-timer.C:
+		}
+		if !ok {
+			continue
+		}
+
+		if block, err := n.sealBlock(context.Background()); err != nil {
+			n.logger.Printf("sealing failed: %v", err)
+		} else {
+			n.logger.Printf("sealed block %d with %d transactions", block.Index, len(block.Transactions))
+		}
+	}
+}
+
+// sealDelay returns how long until this node should seal the next block, false if it can't or isn't an
+// authority. The authority in turn seals BlockTime after the head, counting from when it got here if that's
+// later, and the others wait a BlockTime longer for every place they come after it, so if it's down the next
+// one along steps in.
+func (n *Node) sealDelay(since time.Time) (time.Duration, bool) {
+	if !n.chain.IsValidator(n.validatorID()) {
+		return 0, false
+	}
+	authorities := n.chain.Validators()
+	head := n.chain.Last()
+	limit := blockchain.SealLimit(authorities)
+	from := head.Index - limit + 1
+	if from < 0 {
+		from = 0
+	}
+	for _, block := range n.chain.Range(from, limit) {
+		if block.Sealer == n.validatorID() {
+			return 0, false
+		}
+	}
+
+	place := 0
+	for i := range authorities {
+		if authorities[(head.Index+1+i)%len(authorities)] == n.validatorID() {
+			place = i
+			break
+		}
+	}
+	start := head.Time()
+	if since.After(start) {
+		start = since
+	}
+	due := start.Add(n.cfg.BlockTime * time.Duration(place+1))
+	return time.Until(due), true
+}
+
+// sealBlock builds a block on top of the head and seals it as this node
+func (n *Node) sealBlock(ctx context.Context) (blockchain.Block, error) {
+	block, err := n.buildBlock(ctx, 0, n.minerAddress())
+	if err != nil {
+		return block, err
+	}
+	if err := block.Solve(ctx); err != nil { // at the network's difficulty, normally 1 so it's instant
+		return block, err
+	}
+	if err := block.SealWith(n.signer); err != nil {
+		return block, err
+	}
+
+	if added, err := n.acceptMined(ctx, block); err != nil {
+		return block, err
+	} else if !added {
+		return block, ErrStaleBlock
+	}
+	return block, nil
+}
@@ -0,0 +1,38 @@
+package node
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// startDebugServer serves the pprof profiles on DebugAddr, kept off the main API so it's never exposed by accident.
+// The admin token is required here too, if one is set.
+func (n *Node) startDebugServer() error {
+	if n.cfg.DebugAddr == "" {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index) // also serves heap, goroutine, block, mutex, allocs and threadcreate
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	ln, err := net.Listen("tcp", n.cfg.DebugAddr)
+	if err != nil {
+		return err
+	}
+	n.debugServer = &http.Server{Handler: n.adminOnlyHandler(mux)}
+	n.logger.Println("pprof listening on", ln.Addr())
+	go n.debugServer.Serve(ln)
+	return nil
+}
+
+// adminOnlyHandler is adminOnly for plain handlers
+func (n *Node) adminOnlyHandler(h http.Handler) http.Handler {
+	handle := n.adminOnly(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) { h.ServeHTTP(w, r) })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handle(w, r, nil) })
+}
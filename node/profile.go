@@ -0,0 +1,122 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// A node's profile says how much of the past it keeps, and so which questions about it the node can answer:
+//
+//   - archive keeps every block and can give the state as of any of them, eg an address's balance at block 10
+//   - full, the default, keeps every block but only the state it can roll back to, as of the last UndoDepth blocks
+//   - pruned keeps the bodies of just the last PruneDepth blocks, headers for the rest, and the state only as far
+//     back as both its bodies and its undo data reach
+//
+// A light node, see light.go, keeps headers and nothing else. Asking a node for history its profile doesn't keep
+// gets 410 Gone saying what it does keep and who to ask instead, rather than a 404 suggesting the block or
+// transaction doesn't exist. GET /profile says up front. Profiles only ever keep less than every block, so a node
+// can't be turned into a full or archive node over a chain it's already pruned, it has to sync again.
+
+// node profiles, Config.Profile
+const (
+	ProfileArchive = "archive"
+	ProfileFull    = "full"
+	ProfilePruned  = "pruned"
+	ProfileLight   = "light" // reported by light nodes, set with Config.Light rather than the profile
+)
+
+// defaultPruneDepth is how many block bodies a pruned node keeps if PruneDepth isn't set, as deep as the
+// state can be rolled back
+const defaultPruneDepth = 1000
+
+// NodeProfile ... the response from /profile, how much history the node keeps
+type NodeProfile struct {
+	Profile string
+	Head    int // the head's index, the others are as of it
+	Bodies  int // the oldest block whose body's kept, -1 for none
+	State   int // the oldest block the state can be asked for as of, eg with /balance/:address?height=, -1 for none
+}
+
+// ValidProfile returns an error unless profile is archive, full or pruned, or empty for the default
+func ValidProfile(profile string) error {
+	switch profile {
+	case "", ProfileArchive, ProfileFull, ProfilePruned:
+		return nil
+	}
+	return fmt.Errorf("profile has to be %s, %s or %s, not %q", ProfileArchive, ProfileFull, ProfilePruned, profile)
+}
+
+// resolveProfile checks the profile against the rest of the config and fills in its default, and the prune
+// depth of a pruned node that didn't set one. A prune depth on its own makes a pruned node, as it always has.
+func (n *Node) resolveProfile() error {
+	if err := ValidProfile(n.cfg.Profile); err != nil {
+		return err
+	}
+	switch {
+	case n.cfg.Light && n.cfg.Profile != "":
+		return fmt.Errorf("a light node keeps just headers, leave the profile unset")
+	case n.cfg.Light:
+	case n.cfg.Profile == "" && n.cfg.PruneDepth > 0:
+		n.cfg.Profile = ProfilePruned
+	case n.cfg.Profile == "":
+		n.cfg.Profile = ProfileFull
+	case n.cfg.Profile != ProfilePruned && n.cfg.PruneDepth > 0:
+		return fmt.Errorf("%s nodes keep every block, leave the prune depth unset or use the %s profile", n.cfg.Profile, ProfilePruned)
+	case n.cfg.Profile == ProfilePruned && n.cfg.PruneDepth == 0:
+		n.cfg.PruneDepth = defaultPruneDepth
+	}
+	return nil
+}
+
+// profile returns the node's profile
+func (n *Node) profile() string {
+	if n.cfg.Light {
+		return ProfileLight
+	}
+	return n.cfg.Profile
+}
+
+// history returns what the node's profile keeps as of the current head
+func (n *Node) history() NodeProfile {
+	p := NodeProfile{Profile: n.profile(), Head: n.chain.Last().Index}
+	switch p.Profile {
+	case ProfileLight:
+		p.Bodies, p.State = -1, -1
+	case ProfileArchive:
+		p.Bodies, p.State = 0, 0
+	default:
+		depth := blockchain.UndoDepth
+		if p.Profile == ProfilePruned {
+			if p.Bodies = p.Head - n.cfg.PruneDepth + 1; p.Bodies < 0 {
+				p.Bodies = 0
+			}
+			if n.cfg.PruneDepth < depth { // the state's worked back from the head with the bodies
+				depth = n.cfg.PruneDepth
+			}
+		}
+		if p.State = p.Head - depth; p.State < 0 {
+			p.State = 0
+		}
+	}
+	return p
+}
+
+// GetProfile handles the route reporting the node's profile and how far back it can answer for blocks and state
+func (n *Node) GetProfile(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.history())
+}
+
+// respondStateGone tells a client the node doesn't keep the state as of a block any more
+func (n *Node) respondStateGone(w http.ResponseWriter, r *http.Request, height int) {
+	p := n.history()
+	RespondWithJSON(w, r, http.StatusGone, fmt.Sprintf("this %s node keeps the state as of block %d on, ask an %s node for it as of block %d", p.Profile, p.State, ProfileArchive, height))
+}
+
+// respondBodyGone tells a client the node has pruned a block's body
+func (n *Node) respondBodyGone(w http.ResponseWriter, r *http.Request, height int) {
+	p := n.history()
+	RespondWithJSON(w, r, http.StatusGone, fmt.Sprintf("this %s node keeps block bodies from block %d on, ask a %s or %s node for block %d's", p.Profile, p.Bodies, ProfileFull, ProfileArchive, height))
+}
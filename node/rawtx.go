@@ -0,0 +1,47 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// A raw transaction is the hex of its canonical encoding, see blockchain/encoding.go, signed somewhere else,
+// eg with `tx sign --offline` on a machine that's never online. The node takes it exactly as it is: nothing's
+// filled in and no addresses are decoded, anything changed would break the signature.
+
+// RawTx ... the body of POST /tx/raw, eg {"Hex":"00000004757365..."}
+type RawTx struct {
+	Hex string
+}
+
+// PostRawTx handles the route to send a transaction signed offline to the mempool
+func (n *Node) PostRawTx(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var raw RawTx
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid raw transaction: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	data, err := hex.DecodeString(raw.Hex)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "raw transaction isn't hex")
+		return
+	}
+	tx, err := blockchain.DecodeTransaction(data)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "raw transaction doesn't decode: "+err.Error())
+		return
+	}
+
+	hash, err := n.submitRawTx(r.Context(), tx)
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusAccepted, hash)
+}
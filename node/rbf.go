@@ -0,0 +1,72 @@
+package node
+
+import (
+	"errors"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// A pending transaction can be replaced by another from the same sender with the same nonce, so spending the
+// same coins, that pays more, eg to unstick one sent with too low a fee. The replacement has to pay at least
+// RBFBump percent more than the one it replaces, both its fee and its fee rate, so replacing something
+// over and over by a fee unit can't be used to flood the network with transactions that never confirm. The
+// old one is dropped from the mempool and a TxReplaced event published, peers replace it the same way once
+// the new one is gossiped to them. A transaction in an atomic batch can't replace anything.
+
+// defaultRBFBump is how much more a replacement pays, in percent, if RBFBump isn't set
+const defaultRBFBump = 10
+
+// ErrReplacementFee is returned for a transaction with the same nonce as a pending one that doesn't pay enough more to replace it
+var ErrReplacementFee = errors.New("a transaction with that nonce is pending, a replacement has to pay a higher fee and fee rate by the replacement bump")
+
+// Replace puts a transaction in the pool in place of the pending one with the same spend key, if it pays at
+// least bump percent more in fee and in fee rate, returning the one it replaced. If nothing's pending with
+// that key it's added like Add does, and replaced is false.
+func (m *Mempool) Replace(tx blockchain.Transaction, bump int) (old blockchain.Transaction, replaced bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tx.BlockMade() {
+		return old, false, ErrCoinbaseTx
+	}
+	meta := newTxMeta(tx, time.Now())
+	if m.seen[meta.hash] {
+		return old, false, ErrDuplicateTx
+	}
+	hash, ok := m.spends[tx.SpendKey()]
+	if !ok || tx.SpendKey() == "" {
+		if err := m.makeRoom(1, meta.size, meta.rate); err != nil {
+			return old, false, err
+		}
+		m.push(tx, meta)
+		return old, false, nil
+	}
+
+	i := 0
+	for i < len(m.meta) && m.meta[i].hash != hash {
+		i++
+	}
+	old, oldMeta := m.txs[i], m.meta[i]
+	if !bumped(tx.Fee, meta.size, old.Fee, oldMeta.size, bump) {
+		return old, false, ErrReplacementFee
+	}
+
+	m.drop(func(j int) bool { return j == i })
+	if err := m.makeRoom(1, meta.size, meta.rate); err != nil { // nothing was evicted, so put the old one back where it was
+		m.txs = append(m.txs[:i], append([]blockchain.Transaction{old}, m.txs[i:]...)...)
+		m.meta = append(m.meta[:i], append([]txMeta{oldMeta}, m.meta[i:]...)...)
+		m.seen[oldMeta.hash], m.spends[old.SpendKey()] = true, oldMeta.hash
+		m.bytes += oldMeta.size
+		return old, false, err
+	}
+	m.push(tx, meta)
+	return old, true, nil
+}
+
+// bumped returns if a fee of paid for size bytes is more than was for wasSize, by at least bump percent both
+// in all and per byte. It's worked out in whole numbers so exactly bump percent more is enough.
+func bumped(paid, size, was, wasSize, bump int) bool {
+	p, s, w, ws, b := int64(paid), int64(size), int64(was), int64(wasSize), int64(100+bump)
+	return p > w && p*100 >= w*b && p*ws*100 >= w*s*b
+}
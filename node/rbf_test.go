@@ -0,0 +1,58 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+func TestMempoolReplace(t *testing.T) {
+	pending := feeTx(1, 100)
+	withFee := func(tx blockchain.Transaction, fee int) blockchain.Transaction {
+		tx.Fee = fee
+		return tx
+	}
+	bigger := withFee(pending, 110)
+	bigger.Payload = "a payload that makes it a good deal bigger, so its fee rate is lower"
+	sameFee := pending
+	sameFee.Amount = 2
+	nextNonce := withFee(pending, 50)
+	nextNonce.Nonce = 2
+
+	tests := []struct {
+		name         string
+		tx           blockchain.Transaction
+		wantReplaced bool
+		wantErr      error
+		want         []int // the fees pending after
+	}{
+		{"pays the bump", withFee(pending, 110), true, nil, []int{110}},
+		{"pays more than the bump", withFee(pending, 500), true, nil, []int{500}},
+		{"just short of the bump", withFee(pending, 109), false, ErrReplacementFee, []int{100}},
+		{"pays the same", sameFee, false, ErrReplacementFee, []int{100}},
+		{"pays less", withFee(pending, 10), false, ErrReplacementFee, []int{100}},
+		{"higher fee, lower fee rate", bigger, false, ErrReplacementFee, []int{100}},
+		{"the same transaction", pending, false, ErrDuplicateTx, []int{100}},
+		{"another nonce", nextNonce, false, nil, []int{100, 50}},
+		{"coinbase", blockchain.NewCoinbase("miner", 1, 50), false, ErrCoinbaseTx, []int{100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMempool()
+			if err := m.Add(pending); err != nil {
+				t.Fatal(err)
+			}
+			old, replaced, err := m.Replace(tt.tx, defaultRBFBump)
+			if replaced != tt.wantReplaced || err != tt.wantErr {
+				t.Errorf("Replace() = %v, %v, want %v, %v", replaced, err, tt.wantReplaced, tt.wantErr)
+			}
+			if replaced && old.Hash() != pending.Hash() {
+				t.Errorf("Replace() replaced %s, want %s", old.Hash(), pending.Hash())
+			}
+			if got := pendingFees(m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pending fees = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcTxRejected     = -32000 // the transaction wasn't accepted, data is the TxRejection
+	rpcBlockRejected  = -32001 // the solved block wasn't accepted, it's stale or invalid
+)
+
+// RPCRequest ... a JSON-RPC 2.0 call, requests without an ID are notifications and get no response
+type RPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// RPCResponse ... the result of a JSON-RPC call, exactly one of Result and Error is set
+type RPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON leaves out the result of failed calls, a null result is still a result
+func (res RPCResponse) MarshalJSON() ([]byte, error) {
+	type response RPCResponse // without the MarshalJSON method
+	if res.Error == nil {
+		return json.Marshal(response(res))
+	}
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Error   *RPCError       `json:"error"`
+		ID      json.RawMessage `json:"id"`
+	}{res.JSONRPC, res.Error, res.ID})
+}
+
+// RPCError ... why a JSON-RPC call failed
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcMethods are the methods /rpc serves, each takes the raw params and returns a result or an error
+var rpcMethods = map[string]func(n *Node, ctx context.Context, params json.RawMessage) (interface{}, *RPCError){
+	"chain_head":      (*Node).rpcHead,
+	"chain_finalized": (*Node).rpcFinalized,
+	"chain_getBlock":  (*Node).rpcGetBlock,
+	"chain_getBlocks": (*Node).rpcGetBlocks,
+	"tx_submit":       (*Node).rpcSubmitTx,
+
+	"mining_getBlockTemplate": (*Node).rpcGetBlockTemplate,
+	"mining_submitBlock":      (*Node).rpcSubmitBlock,
+}
+
+// PostRPC handles the JSON-RPC 2.0 endpoint, taking a single request or a batch
+func (n *Node) PostRPC(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusOK, RPCResponse{JSONRPC: "2.0", Error: &RPCError{Code: rpcParseError, Message: err.Error()}, ID: json.RawMessage("null")})
+		return
+	}
+	defer r.Body.Close()
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' { // a single call
+		if res, ok := n.rpcCall(r.Context(), body); ok {
+			RespondWithJSON(w, r, http.StatusOK, res)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		RespondWithJSON(w, r, http.StatusOK, RPCResponse{JSONRPC: "2.0", Error: &RPCError{Code: rpcParseError, Message: err.Error()}, ID: json.RawMessage("null")})
+		return
+	}
+	if len(batch) == 0 {
+		RespondWithJSON(w, r, http.StatusOK, RPCResponse{JSONRPC: "2.0", Error: &RPCError{Code: rpcInvalidRequest, Message: "empty batch"}, ID: json.RawMessage("null")})
+		return
+	}
+
+	responses := []RPCResponse{}
+	for _, call := range batch {
+		if res, ok := n.rpcCall(r.Context(), call); ok {
+			responses = append(responses, res)
+		}
+	}
+	if len(responses) == 0 { // the batch was all notifications
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, responses)
+}
+
+// rpcCall runs a single call, returning false if it was a notification that needs no response
+func (n *Node) rpcCall(ctx context.Context, raw json.RawMessage) (RPCResponse, bool) {
+	res := RPCResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+
+	var req RPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		if _, ok := err.(*json.SyntaxError); ok {
+			res.Error = &RPCError{Code: rpcParseError, Message: err.Error()}
+		} else {
+			res.Error = &RPCError{Code: rpcInvalidRequest, Message: err.Error()}
+		}
+		return res, true
+	}
+	if req.ID != nil {
+		res.ID = req.ID
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		res.Error = &RPCError{Code: rpcInvalidRequest, Message: `requests need "jsonrpc": "2.0" and a method`}
+		return res, true
+	}
+
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		res.Error = &RPCError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+	} else {
+		res.Result, res.Error = method(n, ctx, req.Params)
+	}
+	return res, req.ID != nil
+}
+
+// rpcParams decodes params given either by position, as an array, or by name, as an object
+func rpcParams(params json.RawMessage, names []string, out ...interface{}) *RPCError {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 {
+		return nil
+	}
+
+	values := make([]json.RawMessage, len(names))
+	if params[0] == '[' {
+		var positional []json.RawMessage
+		if err := json.Unmarshal(params, &positional); err != nil || len(positional) > len(names) {
+			return &RPCError{Code: rpcInvalidParams, Message: "invalid params"}
+		}
+		copy(values, positional)
+	} else {
+		var named map[string]json.RawMessage
+		if err := json.Unmarshal(params, &named); err != nil {
+			return &RPCError{Code: rpcInvalidParams, Message: "params have to be an array or an object"}
+		}
+		for i, name := range names {
+			values[i] = named[name]
+		}
+	}
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if err := json.Unmarshal(value, out[i]); err != nil {
+			return &RPCError{Code: rpcInvalidParams, Message: "invalid " + names[i] + ": " + err.Error()}
+		}
+	}
+	return nil
+}
+
+// rpcHead returns the header of the latest block
+func (n *Node) rpcHead(context.Context, json.RawMessage) (interface{}, *RPCError) {
+	return n.chain.Last().Header, nil
+}
+
+// rpcGetBlock returns a block by its index or hash, or null if there isn't one, params [id]
+func (n *Node) rpcGetBlock(_ context.Context, params json.RawMessage) (interface{}, *RPCError) {
+	var id interface{}
+	if err := rpcParams(params, []string{"id"}, &id); err != nil {
+		return nil, err
+	}
+
+	switch id := id.(type) {
+	case float64:
+		if blocks := n.chain.Range(int(id), 1); len(blocks) == 1 {
+			return blocks[0], nil
+		}
+	case string:
+		if block, ok := n.chain.BlockByHash(id); ok {
+			return block, nil
+		}
+	default:
+		return nil, &RPCError{Code: rpcInvalidParams, Message: "id has to be a block index or hash"}
+	}
+	return nil, nil // no such block
+}
+
+// rpcGetBlocks returns a batch of blocks, params [from, limit]
+func (n *Node) rpcGetBlocks(_ context.Context, params json.RawMessage) (interface{}, *RPCError) {
+	from, limit := 0, maxBlockBatch
+	if err := rpcParams(params, []string{"from", "limit"}, &from, &limit); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxBlockBatch {
+		limit = maxBlockBatch
+	}
+	return n.chain.Range(from, limit), nil
+}
+
+// rpcSubmitTx sends a transaction to the mempool, params [tx], returning its hash
+func (n *Node) rpcSubmitTx(ctx context.Context, params json.RawMessage) (interface{}, *RPCError) {
+	var tx blockchain.Transaction
+	if err := rpcParams(params, []string{"tx"}, &tx); err != nil {
+		return nil, err
+	}
+
+	hash, err := n.submitTx(ctx, tx)
+	if err != nil {
+		_, rejection := txRejection(err)
+		return nil, &RPCError{Code: rpcTxRejected, Message: err.Error(), Data: rejection}
+	}
+	return hash, nil
+}
@@ -0,0 +1,81 @@
+package node_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// postRPC posts a raw body to /rpc, returning the status and the responses, one for a single call
+func postRPC(t *testing.T, n *blockchaintest.Node, body string) (int, []node.RPCResponse) {
+	t.Helper()
+
+	res, err := n.Client.Post(n.URL()+"/v1/rpc", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return res.StatusCode, nil
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		t.Fatal(err)
+	}
+	var responses []node.RPCResponse
+	if strings.HasPrefix(string(raw), "[") {
+		json.Unmarshal(raw, &responses)
+	} else {
+		var single node.RPCResponse
+		json.Unmarshal(raw, &single)
+		responses = append(responses, single)
+	}
+	return res.StatusCode, responses
+}
+
+func TestRPC(t *testing.T) {
+	n := blockchaintest.NewNode(t)
+	n.MineBlocks(t, 2)
+
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		wantCodes []int // the error code of each response, 0 for a result
+	}{
+		{"call", `{"jsonrpc":"2.0","method":"chain_head","id":1}`, http.StatusOK, []int{0}},
+		{"params by position", `{"jsonrpc":"2.0","method":"chain_getBlock","params":[1],"id":1}`, http.StatusOK, []int{0}},
+		{"params by name", `{"jsonrpc":"2.0","method":"chain_getBlock","params":{"id":1},"id":1}`, http.StatusOK, []int{0}},
+		{"not JSON", `{"jsonrpc":`, http.StatusOK, []int{-32700}},
+		{"wrong version", `{"jsonrpc":"1.0","method":"chain_head","id":1}`, http.StatusOK, []int{-32600}},
+		{"no method", `{"jsonrpc":"2.0","id":1}`, http.StatusOK, []int{-32600}},
+		{"unknown method", `{"jsonrpc":"2.0","method":"chain_nope","id":1}`, http.StatusOK, []int{-32601}},
+		{"too many params", `{"jsonrpc":"2.0","method":"chain_getBlock","params":[1,2],"id":1}`, http.StatusOK, []int{-32602}},
+		{"params that aren't a list or object", `{"jsonrpc":"2.0","method":"chain_getBlock","params":1,"id":1}`, http.StatusOK, []int{-32602}},
+		{"notification", `{"jsonrpc":"2.0","method":"chain_head"}`, http.StatusNoContent, nil},
+		{"batch", `[{"jsonrpc":"2.0","method":"chain_head","id":1},{"jsonrpc":"2.0","method":"chain_head"},{"jsonrpc":"2.0","method":"chain_nope","id":2}]`, http.StatusOK, []int{0, -32601}},
+		{"batch of notifications", `[{"jsonrpc":"2.0","method":"chain_head"}]`, http.StatusNoContent, nil},
+		{"empty batch", `[]`, http.StatusOK, []int{-32600}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, responses := postRPC(t, n, tt.body)
+			var codes []int
+			for _, res := range responses {
+				code := 0
+				if res.Error != nil {
+					code = res.Error.Code
+				}
+				codes = append(codes, code)
+			}
+			if status != tt.status || !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("POST /rpc = %d %v, want %d %v", status, codes, tt.status, tt.wantCodes)
+			}
+		})
+	}
+}
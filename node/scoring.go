@@ -0,0 +1,153 @@
+package node
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Every peer has a misbehavior score, by node ID, that goes up each time it sends something no honest node
+// would: a block that fails validation, a chain that does when syncing, a transaction that's invalid on its
+// own, a request that can't be decoded, or an inventory too big to be anything but spam. Honest nodes can
+// race or disagree on policy, so things like sending a transaction that's already pending, or paying too
+// little for this node's mempool, don't count. Scores halve every scoreHalfLife, so a peer that slips now and
+// then never gets anywhere, and one that reaches BanScore is banned for BanTime: its sessions are dropped, so
+// it's disconnected both ways, and handshakes with it are refused till the ban's over. Bans from the config
+// or POST /admin/bans last till they're lifted, unless ?for= says how long.
+
+// misbehavior penalties, and the score defaults
+const (
+	penaltyInvalidBlock = 50 // a block or chain that fails validation
+	penaltyInvalidTx    = 10 // a transaction that fails validation on its own
+	penaltyMalformed    = 20 // a request body that can't be decoded
+	penaltySpam         = 20 // an inventory with more than maxInvHashes
+
+	maxInvHashes    = 1000
+	defaultBanScore = 100
+	defaultBanTime  = 24 * time.Hour
+	scoreHalfLife   = time.Hour
+)
+
+// PeerScore ... a peer's misbehavior, for /admin/scores
+type PeerScore struct {
+	NodeID      string
+	Score       float64        // decayed to now, the peer's banned when it reaches BanScore
+	Offenses    map[string]int // how many times for each reason, since the node started
+	Banned      bool
+	BannedUntil *time.Time `json:",omitempty"` // when the ban ends, left out for one that lasts till it's lifted
+}
+
+// peerScore ... what a peer's done, kept in identity
+type peerScore struct {
+	score    float64
+	updated  time.Time
+	offenses map[string]int
+}
+
+// decayed returns the score as of now, to a hundredth so points sent in quick succession add up exactly
+func (s *peerScore) decayed(now time.Time) float64 {
+	return math.Round(s.score*math.Pow(0.5, float64(now.Sub(s.updated))/float64(scoreHalfLife))*100) / 100
+}
+
+type peerKey struct{}
+
+// peerID returns the node ID of the peer that made a request, empty if it wasn't a peer route
+func peerID(ctx context.Context) string {
+	id, _ := ctx.Value(peerKey{}).(string)
+	return id
+}
+
+// penalize adds points to a peer's score for reason, banning it for BanTime if that takes it to BanScore
+func (n *Node) penalize(nodeID, reason string, points int) {
+	if nodeID == "" {
+		return
+	}
+	now := time.Now()
+	n.identity.mu.Lock()
+	s, ok := n.identity.scores[nodeID]
+	if !ok {
+		s = &peerScore{offenses: make(map[string]int)}
+		n.identity.scores[nodeID] = s
+	}
+	s.score, s.updated = s.decayed(now)+float64(points), now
+	s.offenses[reason]++
+	score := s.score
+	n.identity.mu.Unlock()
+
+	n.logger.Printf("peer %s misbehaved, %s, score %.0f", nodeID, reason, score)
+	if n.cfg.BanScore > 0 && score >= float64(n.cfg.BanScore) {
+		n.logger.Printf("banning peer %s for %s, its score reached %d", nodeID, n.cfg.BanTime, n.cfg.BanScore)
+		n.BanPeerFor(nodeID, n.cfg.BanTime)
+	}
+}
+
+// penalizeMalformed penalizes the peer that sent a request with a body that can't be decoded, if it was sent
+// with a session
+func (n *Node) penalizeMalformed(r *http.Request) {
+	n.identity.mu.Lock()
+	session, ok := n.identity.inbound[r.Header.Get(sessionHeader)]
+	n.identity.mu.Unlock()
+	if ok && time.Now().Before(session.Expires) {
+		n.penalize(session.NodeID, "malformed message", penaltyMalformed)
+	}
+}
+
+// penalizeURL penalizes the peer at a URL, if it's been signed in to so its node ID is known
+func (n *Node) penalizeURL(peer, reason string, points int) {
+	n.identity.mu.Lock()
+	nodeID := n.identity.peerIDs[peer]
+	n.identity.mu.Unlock()
+	n.penalize(nodeID, reason, points)
+}
+
+// PeerScores returns every peer that's misbehaved or is banned, worst first
+func (n *Node) PeerScores() []PeerScore {
+	now := time.Now()
+	n.identity.mu.Lock()
+	defer n.identity.mu.Unlock()
+
+	byID := make(map[string]*PeerScore)
+	for nodeID, s := range n.identity.scores {
+		offenses := make(map[string]int, len(s.offenses))
+		for reason, count := range s.offenses {
+			offenses[reason] = count
+		}
+		byID[nodeID] = &PeerScore{NodeID: nodeID, Score: s.decayed(now), Offenses: offenses}
+	}
+	for nodeID, until := range n.identity.banned {
+		if !until.IsZero() && now.After(until) {
+			continue
+		}
+		score, ok := byID[nodeID]
+		if !ok {
+			score = &PeerScore{NodeID: nodeID, Offenses: map[string]int{}}
+			byID[nodeID] = score
+		}
+		score.Banned = true
+		if !until.IsZero() {
+			until := until
+			score.BannedUntil = &until
+		}
+	}
+
+	scores := make([]PeerScore, 0, len(byID))
+	for _, score := range byID {
+		scores = append(scores, *score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].NodeID < scores[j].NodeID
+	})
+	return scores
+}
+
+// GetScores handles the admin route listing peers' misbehavior scores and bans
+func (n *Node) GetScores(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.PeerScores())
+}
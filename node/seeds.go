@@ -0,0 +1,108 @@
+package node
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A node with no peers asks the DNS seeds for some, so joining a public network doesn't need anyone's address
+// written down, just the seeds' hostnames, which whoever runs them can point at whichever nodes are up. A
+// seed's A and AAAA records are nodes listening on SeedPort, and its TXT records can list node URLs
+// outright, eg "http://203.0.113.7:8080", for nodes on other ports. Up to maxSeedPeers of what they return
+// are picked at random. Nothing a seed says is trusted, a node still proves its ID in the handshake.
+
+// dns seed defaults
+const (
+	maxSeedPeers = 8
+	seedRetry    = time.Minute // how often a node with no peers asks again
+)
+
+// seedLoop asks the DNS seeds for peers whenever the node has none, until it shuts down
+func (n *Node) seedLoop() {
+	for {
+		if len(n.peers()) == 0 {
+			n.bootstrapFromSeeds()
+		}
+
+		select {
+		case <-n.done:
+			return
+		case <-time.After(seedRetry):
+		}
+	}
+}
+
+// bootstrapFromSeeds adds peers from the DNS seeds, returning how many
+func (n *Node) bootstrapFromSeeds() int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	found := n.lookupSeeds(ctx)
+	rand.Shuffle(len(found), func(i, j int) { found[i], found[j] = found[j], found[i] })
+	added := 0
+	for _, peer := range found {
+		if added == maxSeedPeers {
+			break
+		}
+		if n.addPeer(peer) {
+			added++
+		}
+	}
+	n.logger.Printf("DNS seeds gave %d nodes, added %d as peers", len(found), added)
+	if added > 0 {
+		go n.syncWithPeers()
+	}
+	return added
+}
+
+// lookupSeeds returns the node URLs every seed lists, without duplicates
+func (n *Node) lookupSeeds(ctx context.Context) []string {
+	resolver := net.DefaultResolver
+	if n.cfg.DNSServer != "" {
+		resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, n.cfg.DNSServer)
+		}}
+	}
+	scheme := "http://"
+	if n.certs != nil {
+		scheme = "https://"
+	}
+	port := n.cfg.SeedPort
+	if port == 0 {
+		_, p, _ := net.SplitHostPort(n.Addr())
+		port, _ = strconv.Atoi(p)
+	}
+
+	seen := make(map[string]bool)
+	var found []string
+	add := func(peer string) {
+		if !seen[peer] && validPeerURL(peer) {
+			seen[peer] = true
+			found = append(found, peer)
+		}
+	}
+	for _, seed := range n.cfg.DNSSeeds {
+		ips, ipErr := resolver.LookupIPAddr(ctx, seed)
+		for _, ip := range ips {
+			add(scheme + net.JoinHostPort(ip.IP.String(), strconv.Itoa(port)))
+		}
+		txts, txtErr := resolver.LookupTXT(ctx, seed)
+		for _, txt := range txts {
+			for _, field := range strings.Fields(txt) {
+				if !strings.Contains(field, "://") { // host:port
+					field = scheme + field
+				}
+				add(field)
+			}
+		}
+		if ipErr != nil && txtErr != nil {
+			n.logger.Printf("DNS seed %s: %v", seed, ipErr)
+		}
+	}
+	return found
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Settings ... the parts of the config that can be changed while the node is running
+type Settings struct {
+	Peers        []string
+	LogLevel     string // "debug" or "info"
+	Mine         bool
+	MinerAddress string // empty pays block rewards to the node's ID
+	MineThreads  int    // 0 for one per CPU
+}
+
+// Settings returns the settings in a config, to pass to Node.Reload
+func (c Config) Settings() Settings {
+	return Settings{Peers: c.Peers, LogLevel: c.LogLevel, Mine: c.Mine, MinerAddress: c.MinerAddress, MineThreads: c.MineThreads}
+}
+
+// settings guards the reloadable fields of the node's config, and the mining loop they switch on and off
+type settings struct {
+	mu     sync.RWMutex
+	mining chan struct{} // closed to stop the mining loop, nil when it isn't running
+	static []string      // the peers the config or the last reload gave, see peers.go
+	added  []string      // peers added with POST /admin/peers, kept across reloads and restarts
+}
+
+// Settings returns the node's current settings
+func (n *Node) Settings() Settings {
+	n.settings.mu.RLock()
+	defer n.settings.mu.RUnlock()
+	return Settings{
+		Peers:        append([]string{}, n.cfg.Peers...),
+		LogLevel:     n.cfg.LogLevel,
+		Mine:         n.cfg.Mine,
+		MinerAddress: n.cfg.MinerAddress,
+		MineThreads:  n.cfg.MineThreads,
+	}
+}
+
+// Reload swaps in new settings without restarting the node, so the chain and mempool are kept
+func (n *Node) Reload(s Settings) error {
+	if s.LogLevel == "" {
+		s.LogLevel = "info"
+	}
+	if s.LogLevel != "debug" && s.LogLevel != "info" {
+		return fmt.Errorf("unknown log level %q, has to be debug or info", s.LogLevel)
+	}
+	if s.Mine && n.cfg.Light {
+		return fmt.Errorf("a light node can't mine, it doesn't have the blocks' bodies")
+	}
+	if s.MineThreads < 0 {
+		return fmt.Errorf("mine threads can't be negative, got %d", s.MineThreads)
+	}
+	minerAddress, err := blockchain.ParseAddress(s.MinerAddress)
+	if err != nil {
+		return fmt.Errorf("miner address: %w", err)
+	}
+	s.MinerAddress = minerAddress
+
+	n.settings.mu.Lock()
+	defer n.settings.mu.Unlock()
+	n.settings.static = append([]string{}, s.Peers...)
+	n.cfg.Peers = mergePeers(s.Peers, n.settings.added)
+	n.cfg.LogLevel = s.LogLevel
+	if s.MinerAddress != n.cfg.MinerAddress && n.stratum != nil {
+		go n.stratum.newJob(false) // so workers start paying the new address, once the lock's released
+	}
+	n.cfg.MinerAddress = s.MinerAddress
+	n.cfg.MineThreads = s.MineThreads // from the next block
+	n.cfg.Mine = s.Mine
+	n.setMining(s.Mine)
+	return nil
+}
+
+// setMining starts or stops the mining loop, the settings lock has to be held
+func (n *Node) setMining(on bool) {
+	switch {
+	case on && n.settings.mining == nil:
+		n.settings.mining = make(chan struct{})
+		go n.mineLoop(n.settings.mining)
+	case !on && n.settings.mining != nil:
+		close(n.settings.mining)
+		n.settings.mining = nil
+	}
+}
+
+// peers returns the peers to sync and gossip with
+func (n *Node) peers() []string {
+	n.settings.mu.RLock()
+	defer n.settings.mu.RUnlock()
+	return n.cfg.Peers
+}
+
+// addPeer adds a peer to sync and gossip with, returning false if it's already one
+func (n *Node) addPeer(peer string) bool {
+	n.settings.mu.Lock()
+	defer n.settings.mu.Unlock()
+	for _, p := range n.cfg.Peers {
+		if p == peer {
+			return false
+		}
+	}
+	n.cfg.Peers = append(append([]string{}, n.cfg.Peers...), peer) // a copy, peers() hands the old slice out
+	return true
+}
+
+// logLevel returns the current log level
+func (n *Node) logLevel() string {
+	n.settings.mu.RLock()
+	defer n.settings.mu.RUnlock()
+	return n.cfg.LogLevel
+}
+
+// GetSettings handles the admin route to view the reloadable settings
+func (n *Node) GetSettings(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.Settings())
+}
+
+// PostSettings handles the admin route to change settings on the fly, fields left out keep their current values
+func (n *Node) PostSettings(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	s := n.Settings()
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid settings: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if err := n.Reload(s); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	n.logger.Printf("settings reloaded: %d peers, log level %s, mining %v", len(s.Peers), n.logLevel(), s.Mine)
+	RespondWithJSON(w, r, http.StatusOK, n.Settings())
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// maxEvidence caps how much double signing evidence is held waiting to go in a block
+const maxEvidence = 1000
+
+// evidencePool ... the double signing this node has caught, kept until a block it makes slashes the validator for it
+type evidencePool struct {
+	mu      sync.Mutex
+	pending map[string]blockchain.SlashEvidence // by validator/height
+}
+
+func newEvidencePool() *evidencePool {
+	return &evidencePool{pending: make(map[string]blockchain.SlashEvidence)}
+}
+
+func evidenceKey(evidence blockchain.SlashEvidence) string {
+	return evidence.Offender() + "/" + strconv.Itoa(evidence.Height)
+}
+
+// add holds on to evidence, returning false if there's already some for that validator and height
+func (p *evidencePool) add(evidence blockchain.SlashEvidence) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := evidenceKey(evidence)
+	if _, ok := p.pending[key]; ok || len(p.pending) >= maxEvidence {
+		return false
+	}
+	p.pending[key] = evidence
+	return true
+}
+
+// remove drops evidence that's been used
+func (p *evidencePool) remove(evidence blockchain.SlashEvidence) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, evidenceKey(evidence))
+}
+
+// list returns the evidence held, oldest height first
+func (p *evidencePool) list() []blockchain.SlashEvidence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := make([]blockchain.SlashEvidence, 0, len(p.pending))
+	for _, evidence := range p.pending {
+		list = append(list, evidence)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Height != list[j].Height {
+			return list[i].Height < list[j].Height
+		}
+		return list[i].Offender() < list[j].Offender()
+	})
+	return list
+}
+
+// reportDoubleSign holds on to evidence of a validator double signing, so the next block this node makes slashes it
+func (n *Node) reportDoubleSign(evidence blockchain.SlashEvidence) {
+	if n.chain.Params().SlashPercent > 0 && n.evidence.add(evidence) {
+		n.logger.Printf("validator %s double signed at height %d", evidence.Offender(), evidence.Height)
+	}
+}
+
+// checkDoubleSeal looks for another block sealed by the same authority at a stale block's height
+func (n *Node) checkDoubleSeal(header blockchain.Header) {
+	if header.Sealer == "" {
+		return
+	}
+	others := n.stale.at(header.Index)
+	for _, block := range n.chain.Range(header.Index, 1) {
+		others = append(others, block.Header)
+	}
+	for _, other := range others {
+		if evidence, ok := blockchain.DoubleSeal(other, header); ok {
+			n.reportDoubleSign(evidence)
+			return
+		}
+	}
+}
+
+// slashes returns the slash transactions for a block on top of height: every validator caught double signing
+// that hasn't been slashed for it yet, and every one that's missed too many turns. They're applied to stakes
+// as they go, so they're checked in the order the block has them.
+func (n *Node) slashes(stakes *blockchain.StakeLedger, validators []string, height int, params blockchain.Params) []blockchain.Transaction {
+	if params.SlashPercent <= 0 {
+		return nil
+	}
+	var slashes []blockchain.Transaction
+	for _, evidence := range n.evidence.list() {
+		tx := blockchain.NewSlash(evidence.Offender(), evidence, stakes.Burned(evidence.Offender(), params))
+		switch err := stakes.Apply(tx, validators, params); err {
+		case nil:
+			slashes = append(slashes, tx)
+		case blockchain.ErrAlreadySlashed: // by someone else's block
+			n.evidence.remove(evidence)
+		}
+	}
+	for _, validator := range stakes.Downtimes(height, params) {
+		evidence := blockchain.SlashEvidence{Reason: blockchain.SlashDowntime, Height: height}
+		tx := blockchain.NewSlash(validator, evidence, stakes.Burned(validator, params))
+		if stakes.Apply(tx, validators, params) == nil {
+			slashes = append(slashes, tx)
+		}
+	}
+	return slashes
+}
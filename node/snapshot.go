@@ -0,0 +1,150 @@
+package node
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Snapshot ... a consistent copy of a node's chain and pending transactions, used to back up and re-seed nodes
+type Snapshot struct {
+	Created time.Time
+	Height  int    // index of the head block
+	Hash    string // hash of the head block
+	Blocks  []blockchain.Block
+	Mempool []blockchain.Transaction
+}
+
+// SnapshotInfo ... where a snapshot was written and what it holds
+type SnapshotInfo struct {
+	Path   string
+	Height int
+	Hash   string
+}
+
+// snapshotDir returns where snapshots are written
+func (n *Node) snapshotDir() (string, error) {
+	if n.cfg.SnapshotDir != "" {
+		return n.cfg.SnapshotDir, nil
+	}
+	if n.cfg.DataDir != "" {
+		return filepath.Join(n.cfg.DataDir, "snapshots"), nil
+	}
+	return "", errors.New("snapshot: no snapshot or data directory configured")
+}
+
+// WriteSnapshot writes a gzipped snapshot of the chain and mempool into the snapshot directory
+func (n *Node) WriteSnapshot() (SnapshotInfo, error) {
+	dir, err := n.snapshotDir()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	blocks := n.chain.Blocks() // a copy taken under the chain lock, so it's consistent
+	head := blocks[len(blocks)-1]
+	snapshot := Snapshot{
+		Created: time.Now(),
+		Height:  head.Index,
+		Hash:    head.Hash,
+		Blocks:  blocks,
+		Mempool: n.mempool.Pending(),
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("snapshot-%d-%d.json.gz", head.Index, snapshot.Created.Unix()))
+	if err := writeSnapshotFile(path, snapshot); err != nil {
+		return SnapshotInfo{}, err
+	}
+	return SnapshotInfo{Path: path, Height: snapshot.Height, Hash: snapshot.Hash}, nil
+}
+
+// writeSnapshotFile gzips a snapshot, or a state snapshot, to disk through a temp file, so a crash can't leave
+// half a snapshot behind
+func writeSnapshotFile(path string, snapshot interface{}) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	err = json.NewEncoder(gz).Encode(snapshot)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadSnapshot loads a snapshot written by WriteSnapshot
+func ReadSnapshot(path string) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer gz.Close()
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// restoreSnapshot loads the chain and mempool from a snapshot at startup, after checking the chain's valid.
+// The mempool's transactions are handed back to go through addTx like any others once the node has the chain,
+// so one the snapshot's been tampered with to slip in isn't taken on trust.
+func (n *Node) restoreSnapshot(path string) ([]blockchain.Block, []blockchain.Transaction, error) {
+	snapshot, err := ReadSnapshot(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("restoring snapshot %s: %v", path, err)
+	}
+	if len(snapshot.Blocks) == 0 {
+		return nil, nil, fmt.Errorf("restoring snapshot %s: no blocks in it", path)
+	}
+
+	validator := n.newChain(snapshot.Blocks...)
+	if !validator.Validate(snapshot.Blocks) {
+		return nil, nil, fmt.Errorf("restoring snapshot %s: chain is not valid", path)
+	}
+
+	if n.store != nil {
+		if err := n.store.Save(snapshot.Blocks, nil); err != nil { // indexed when it's loaded
+			return nil, nil, err
+		}
+	}
+	n.logger.Printf("restored %d blocks from snapshot %s", len(snapshot.Blocks), path)
+	return snapshot.Blocks, snapshot.Mempool, nil
+}
+
+// PostSnapshot handles the admin route to write a snapshot to disk
+func (n *Node) PostSnapshot(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	info, err := n.WriteSnapshot()
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, r, http.StatusCreated, info)
+}
@@ -0,0 +1,55 @@
+package node_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+func TestRestoreSnapshotChecksMempool(t *testing.T) {
+	n := blockchaintest.NewNode(t)
+	n.MineBlocks(t, 1)
+	valid := n.Sign(t, n.Key, blockchain.Transaction{To: blockchaintest.Address(blockchaintest.NewKey(t)), Amount: 1})
+	n.SubmitTx(t, valid)
+	info, err := n.WriteSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := node.ReadSnapshot(info.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := blockchain.Transaction{From: valid.From, To: valid.To, Amount: 1000, Nonce: valid.Nonce + 1, ChainID: valid.ChainID}
+	snapshot.Mempool = append(snapshot.Mempool, unsigned)
+	path := filepath.Join(t.TempDir(), "tampered.json.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	json.NewEncoder(gz).Encode(snapshot)
+	gz.Close()
+	f.Close()
+
+	restored := blockchaintest.NewNode(t, func(cfg *node.Config) { cfg.RestoreFrom = path })
+	tests := []struct {
+		name string
+		tx   blockchain.Transaction
+		want bool
+	}{
+		{"valid", valid, true},
+		{"unsigned", unsigned, false},
+	}
+	for _, tt := range tests {
+		if _, ok := restored.Mempool().Get(tt.tx.Hash()); ok != tt.want {
+			t.Errorf("%s transaction in the restored mempool = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// sseHeartbeat is how often an idle /events stream gets a comment, so proxies don't time it out
+const sseHeartbeat = 15 * time.Second
+
+// GetEvents handles the route streaming chain events as server-sent events, eg /events?types=block-added,tx-received
+func (n *Node) GetEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		RespondWithJSON(w, r, http.StatusInternalServerError, "streaming isn't supported")
+		return
+	}
+
+	var names []string // empty for every event
+	if t := r.URL.Query().Get("types"); t != "" {
+		for _, name := range strings.Split(t, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{}) // streams outlive the server's write timeout
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sub := n.bus.Subscribe(64, names...)
+	defer sub.Close()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e := <-sub.C:
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Name(), data)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-n.done:
+			return
+		}
+	}
+}
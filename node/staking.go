@@ -0,0 +1,108 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// ValidatorStake ... what's staked with a validator, for /stakes
+type ValidatorStake struct {
+	Validator   string
+	Active      bool // if it's still a validator, stake with one that's been removed can only be taken back
+	Stake       int  // the total
+	Missed      int  // turns it missed in the downtime window up to the head, see slashing
+	Delegations []blockchain.Delegation
+}
+
+// DelegationRequest ... the body of POST /delegate and /undelegate, eg {"Delegator":"ab12...","Validator":"cd34...","Amount":100},
+// signed by the delegator, see TxSignature
+type DelegationRequest struct {
+	Delegator string
+	Validator string // its node ID
+	Amount    int
+	Fee       int
+	TxSignature
+}
+
+// stakes returns what's staked with every validator, and any former validator that still has stake
+func (n *Node) stakes() []ValidatorStake {
+	ledger, active, params, head := n.chain.Stakes(), n.chain.Validators(), n.chain.Params(), n.chain.Last().Index
+	totals := ledger.Stakes()
+	var stakes []ValidatorStake
+	for _, v := range active {
+		stakes = append(stakes, ValidatorStake{
+			Validator: v, Active: true, Stake: totals[v], Missed: ledger.Missed(v, head, params), Delegations: ledger.Delegations(v),
+		})
+		delete(totals, v)
+	}
+	var former []string
+	for v := range totals {
+		former = append(former, v)
+	}
+	sort.Strings(former)
+	for _, v := range former {
+		stakes = append(stakes, ValidatorStake{Validator: v, Stake: totals[v], Delegations: ledger.Delegations(v)})
+	}
+	return stakes
+}
+
+// delegation builds the transaction staking coins with a validator, or taking them back, signed if the request is.
+// The delegator has to have the coins it stakes, and the fee, on top of what its pending transactions send.
+func (n *Node) delegation(class blockchain.TxClass, req DelegationRequest) (blockchain.Transaction, error) {
+	tx, err := n.signedTx(blockchain.Transaction{Class: class, From: req.Delegator, To: req.Validator, Amount: req.Amount, Fee: req.Fee}, req.TxSignature)
+	if err != nil {
+		return tx, err
+	}
+	if balance, err := n.spendable(tx.From, tx.SpendKey()); err == nil && balance < tx.Spends() {
+		return tx, blockchain.ErrInsufficientFunds
+	}
+	return tx, nil
+}
+
+// GetStakes handles the route listing what's staked with each validator and by whom
+func (n *Node) GetStakes(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.stakes())
+}
+
+// GetDelegations handles the route listing what an address has staked, by validator
+func (n *Node) GetDelegations(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	address, ok := pathAddress(w, r, ps)
+	if !ok {
+		return
+	}
+	delegations := n.chain.Stakes().DelegationsFrom(address)
+	if delegations == nil {
+		delegations = []blockchain.Delegation{}
+	}
+	RespondWithJSON(w, r, http.StatusOK, delegations)
+}
+
+// PostDelegate handles the route to stake coins with a validator
+func (n *Node) PostDelegate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	n.postDelegation(w, r, blockchain.ClassDelegate)
+}
+
+// PostUndelegate handles the route to take staked coins back from a validator
+func (n *Node) PostUndelegate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	n.postDelegation(w, r, blockchain.ClassUndelegate)
+}
+
+func (n *Node) postDelegation(w http.ResponseWriter, r *http.Request, class blockchain.TxClass) {
+	var req DelegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid delegation: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	tx, err := n.delegation(class, req)
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	n.submitSigned(w, r, tx)
+}
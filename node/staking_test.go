@@ -0,0 +1,107 @@
+package node_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// waitFor fails the test if ok doesn't become true within a few seconds
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !ok(); time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// newValidator starts a node that's the only validator of a PoA network
+func newValidator(t *testing.T) *blockchaintest.Node {
+	key := blockchaintest.NewKey(t)
+	return blockchaintest.NewNode(t, blockchaintest.WithKey(key), func(cfg *node.Config) {
+		cfg.Params = blockchain.DefaultParams
+		cfg.Params.Engine = blockchain.EnginePoA
+		cfg.Validators = []string{blockchaintest.Address(key)}
+		cfg.BlockTime = 50 * time.Millisecond
+	})
+}
+
+// fund sends coins from the node's key to an address and waits for them to be confirmed
+func fund(t *testing.T, n *blockchaintest.Node, address string, amount int) {
+	t.Helper()
+
+	miner := blockchaintest.Address(n.Key)
+	waitFor(t, "block rewards", func() bool {
+		balance, _, _ := n.Chain().Balance(miner, 1)
+		return balance >= amount
+	})
+	n.SubmitTx(t, n.Sign(t, n.Key, blockchain.Transaction{To: address, Amount: amount}))
+	waitFor(t, "the coins", func() bool {
+		balance, _, _ := n.Chain().Balance(address, 1)
+		return balance == amount
+	})
+}
+
+func TestDelegationSignatures(t *testing.T) {
+	n := newValidator(t)
+	validator := blockchaintest.Address(n.Key)
+	alice, bob := blockchaintest.NewKey(t), blockchaintest.NewKey(t)
+	fund(t, n, blockchaintest.Address(alice), 30)
+
+	req := node.DelegationRequest{Delegator: blockchaintest.Address(alice), Validator: validator, Amount: 20, Fee: 1}
+	var build node.TxBuild
+	if code := n.Do(t, http.MethodPost, "/v1/delegate", req, &build); code != http.StatusOK {
+		t.Fatalf("unsigned delegation: status %d, want the transaction to sign", code)
+	}
+	if build.Transaction.Class != blockchain.ClassDelegate || build.Transaction.From != req.Delegator || n.Mempool().Len() != 0 {
+		t.Fatalf("unsigned delegation built %+v", build.Transaction)
+	}
+	req.Nonce, req.Timestamp = build.Transaction.Nonce, build.Transaction.Timestamp
+
+	tooMuch := req
+	tooMuch.Amount = 30 // the fee tips it over
+	tooMuch.Signature = build.Transaction.Sign(alice)
+	forged := req
+	forged.Signature = build.Transaction.Sign(bob)
+	undelegate := req
+	undelegate.Signature = build.Transaction.Sign(alice)
+	signed := req
+	signed.Signature = build.Transaction.Sign(alice)
+
+	tests := []struct {
+		name   string
+		path   string
+		req    node.DelegationRequest
+		status int
+		code   string
+	}{
+		{"more than the delegator has", "/v1/delegate", tooMuch, http.StatusConflict, "insufficient_funds"},
+		{"signed by someone else", "/v1/delegate", forged, http.StatusBadRequest, "unsigned"},
+		{"signature over a delegation, undelegating", "/v1/undelegate", undelegate, http.StatusBadRequest, "unsigned"},
+		{"signed by the delegator", "/v1/delegate", signed, http.StatusAccepted, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rejection node.TxRejection
+			var hash string
+			var out interface{} = &rejection
+			if tt.status == http.StatusAccepted {
+				out = &hash
+			}
+			if code := n.Do(t, http.MethodPost, tt.path, tt.req, out); code != tt.status || rejection.Code != tt.code {
+				t.Errorf("POST %s = %d %q, want %d %q", tt.path, code, rejection.Code, tt.status, tt.code)
+			}
+		})
+	}
+
+	waitFor(t, "the stake", func() bool { return n.Chain().Stakes().Stakes()[validator] == 20 })
+	if balance, _, _ := n.Chain().Balance(blockchaintest.Address(alice), 1); balance != 9 {
+		t.Errorf("alice has %d left after staking 20 and paying 1, want 9", balance)
+	}
+}
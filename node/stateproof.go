@@ -0,0 +1,157 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// GET /state/proof/:address proves an address's account, its balance and nonce, against the state root in a
+// block's header, the head's unless ?height= says otherwise. A node can prove it as of any block its profile
+// keeps the state for. The proof's only as good as the header it's checked against, so it's for a client that
+// already has the chain's headers, like a light node, which checks it at GET /verify/balance/:address against
+// its own header for the block and penalizes a peer whose proof doesn't lead up to it. A peer can't make a
+// balance up that way, it can only refuse to answer.
+
+// penaltyBadStateProof is for a state proof that doesn't lead up to the state root in a header both nodes have
+const penaltyBadStateProof = 50
+
+// AccountProof ... the response from /state/proof/:address
+type AccountProof struct {
+	Height    int
+	BlockHash string
+	StateRoot string // the block's, what the proof leads up to
+	Proof     blockchain.StateProof
+}
+
+// VerifiedBalance ... an address's account a light node has checked with a state proof, from GET /verify/balance/:address
+type VerifiedBalance struct {
+	Address       string
+	Balance       int
+	Nonce         int
+	Height        int // the account's as of this block
+	BlockHash     string
+	Confirmations int
+	Peer          string // the peer the proof came from
+}
+
+// queryHeight returns the block ?height= asks for, the head if it doesn't, responding and returning false if
+// it isn't a block the node has
+func queryHeight(w http.ResponseWriter, r *http.Request, head int) (int, bool) {
+	v := r.URL.Query().Get("height")
+	if v == "" {
+		return head, true
+	}
+	height, err := strconv.Atoi(v)
+	if err != nil || height < 0 {
+		RespondWithJSON(w, r, http.StatusBadRequest, "height must be a block index")
+		return 0, false
+	}
+	if height > head {
+		RespondWithJSON(w, r, http.StatusNotFound, "block not found")
+		return 0, false
+	}
+	return height, true
+}
+
+// GetStateProof handles the route proving an address's account against a block's state root, eg
+// /state/proof/bob?height=1200
+func (n *Node) GetStateProof(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	profile := n.history()
+	height, ok := queryHeight(w, r, profile.Head)
+	if !ok {
+		return
+	}
+	address, ok := pathAddress(w, r, ps)
+	if !ok {
+		return
+	}
+	if height < profile.State {
+		n.respondStateGone(w, r, height)
+		return
+	}
+
+	blocks := n.chain.Range(height, 1)
+	if len(blocks) == 0 { // the chain's just been reorganized shorter
+		RespondWithJSON(w, r, http.StatusNotFound, "block not found")
+		return
+	}
+	proof, err := n.chain.ProveAccount(address, height)
+	switch {
+	case errors.Is(err, blockchain.ErrNoStateRoot):
+		RespondWithJSON(w, r, http.StatusNotFound, fmt.Sprintf("block %d is from before state roots, there's nothing to prove against", height))
+		return
+	case err != nil: // pruned, or a pruned node that's lost what its pruned blocks changed
+		n.respondStateGone(w, r, height)
+		return
+	}
+	block := blocks[0]
+	RespondWithJSON(w, r, http.StatusOK, AccountProof{Height: block.Index, BlockHash: block.Hash, StateRoot: block.StateRoot, Proof: proof})
+}
+
+// verifyBalance asks a peer for the proof of an address's account and checks it against the header the node has
+// for the block. A proof for a block the node doesn't have isn't the peer's fault, it may be on another branch.
+func (n *Node) verifyBalance(peer, address string, header blockchain.Header) (VerifiedBalance, error) {
+	proof, err := FetchStateProof(n.client, peer, address, header.Index)
+	if err != nil {
+		return VerifiedBalance{}, err
+	}
+	if proof.BlockHash != header.Hash {
+		return VerifiedBalance{}, fmt.Errorf("%s proved %s's account as of block %s, which isn't in our chain", peer, address, proof.BlockHash)
+	}
+	if proof.Proof.Account.Address != address {
+		n.penalizeURL(peer, "bad state proof", penaltyBadStateProof)
+		return VerifiedBalance{}, fmt.Errorf("%s sent a proof of %s's account instead of %s's", peer, proof.Proof.Account.Address, address)
+	}
+	if err := blockchain.VerifyStateProof(proof.Proof, header.StateRoot); err != nil {
+		n.penalizeURL(peer, "bad state proof", penaltyBadStateProof)
+		return VerifiedBalance{}, fmt.Errorf("%s sent a proof of %s's account that doesn't check out: %w", peer, address, err)
+	}
+	return VerifiedBalance{
+		Address:       address,
+		Balance:       proof.Proof.Account.Balance,
+		Nonce:         proof.Proof.Account.Nonce,
+		Height:        header.Index,
+		BlockHash:     header.Hash,
+		Confirmations: n.chain.Last().Index - header.Index + 1,
+		Peer:          peer,
+	}, nil
+}
+
+// GetVerifyBalance handles the route checking an address's balance and nonce with a state proof from the first
+// peer that has one, as of the head or ?height=
+func (n *Node) GetVerifyBalance(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	height, ok := queryHeight(w, r, n.chain.Last().Index)
+	if !ok {
+		return
+	}
+	address, ok := pathAddress(w, r, ps)
+	if !ok {
+		return
+	}
+	blocks := n.chain.Range(height, 1)
+	if len(blocks) == 0 {
+		RespondWithJSON(w, r, http.StatusNotFound, "block not found")
+		return
+	}
+	header := blocks[0].Header
+	if header.Version < blockchain.StateHeaderVersion {
+		RespondWithJSON(w, r, http.StatusNotFound, fmt.Sprintf("block %d is from before state roots, there's nothing to prove against", height))
+		return
+	}
+
+	for _, peer := range n.peers() {
+		verified, err := n.verifyBalance(peer, address, header)
+		if err != nil {
+			n.debug(err)
+			continue
+		}
+		RespondWithJSON(w, r, http.StatusOK, verified)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusNotFound, "no peer could prove the account as of that block")
+}
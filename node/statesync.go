@@ -0,0 +1,326 @@
+package node
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Every StateInterval blocks the chain takes its state as the block at that height is added, and the node
+// writes it to DataDir/state as a snapshot signed with its node key, keeping the latest few and serving them
+// at GET /state/snapshots. Heights are multiples of the interval, so nodes with the same one snapshot the same
+// blocks and get the same digest for each.
+//
+// A new pruned node given StateCheckpoints, trusted digests by height, starts from the state as of the highest
+// one a peer has reached instead of from the genesis block. It fetches and checks the peer's headers the way a
+// light node does, gets the snapshot at the checkpoint from whichever peer has it, and checks its digest is the
+// checkpoint's and that it's as of the header at that height, with the accounts the header's state root says.
+// Then it starts its chain there, headers before it and state as of it, and downloads only the blocks after
+// it. The checkpoint is what makes the snapshot trustworthy, its signature says which node made it, and a
+// peer sending one that doesn't match is penalized. If no peer has it the node syncs from the genesis block
+// as usual.
+//
+// A pruned node that restarts starts from its newest snapshot that the bodies it still has carry on from too,
+// since the bodies it's pruned can't give it the state back.
+
+// defaults for state snapshots
+const (
+	defaultStateInterval = 1000
+	stateSnapshotsKept   = 3 // the latest few are kept, so a peer syncing to one that's just been passed can still get it
+)
+
+// penaltyBadState is for a state snapshot that doesn't match its own digest or the checkpoint's
+const penaltyBadState = 50
+
+// errNoStateSnapshot means no peer had a state snapshot the node could start from
+var errNoStateSnapshot = errors.New("no peer has a state snapshot matching the state checkpoint")
+
+// StateSnapshot ... a chain's state as of a block, signed by the node that took it
+type StateSnapshot struct {
+	State     blockchain.State
+	Digest    string // the state's digest, what a state checkpoint names
+	Signer    string // ID of the node that took it
+	Signature string // the signer's signature of the digest
+	Created   time.Time
+}
+
+// StateSnapshotInfo ... a state snapshot a node has, from GET /state/snapshots
+type StateSnapshotInfo struct {
+	Height  int
+	Hash    string // the block it's as of
+	Digest  string
+	Signer  string
+	Created time.Time
+}
+
+// stateMessage is what a state snapshot's signer signs
+func stateMessage(digest string) []byte {
+	return []byte("go-blockchain state|" + digest)
+}
+
+// verify checks a state snapshot is the state its digest says, signed by its signer
+func (s StateSnapshot) verify() error {
+	if s.State.Digest() != s.Digest {
+		return errors.New("state snapshot doesn't match its digest")
+	}
+	if !verifySignature(s.Signer, stateMessage(s.Digest), s.Signature) {
+		return fmt.Errorf("state snapshot isn't signed by %s", s.Signer)
+	}
+	return nil
+}
+
+// info returns what's listed about a state snapshot
+func (s StateSnapshot) info() StateSnapshotInfo {
+	return StateSnapshotInfo{Height: s.State.Height, Hash: s.State.Hash, Digest: s.Digest, Signer: s.Signer, Created: s.Created}
+}
+
+// stateDir returns where state snapshots are kept, empty for a node without a data directory
+func (n *Node) stateDir() string {
+	if n.cfg.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(n.cfg.DataDir, "state")
+}
+
+// stateHeights returns the heights of the state snapshots the node has, newest first
+func (n *Node) stateHeights() []int {
+	dir := n.stateDir()
+	if dir == "" {
+		return nil
+	}
+	entries, _ := os.ReadDir(dir) // none yet
+	var heights []int
+	for _, entry := range entries {
+		var height int
+		if _, err := fmt.Sscanf(entry.Name(), "state-%d.json.gz", &height); err == nil && entry.Name() == statePath("", height) {
+			heights = append(heights, height)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(heights)))
+	return heights
+}
+
+// statePath returns where the state snapshot at height is kept in dir
+func statePath(dir string, height int) string {
+	return filepath.Join(dir, fmt.Sprintf("state-%d.json.gz", height))
+}
+
+// readStateSnapshot loads a state snapshot the node has
+func (n *Node) readStateSnapshot(height int) (StateSnapshot, error) {
+	f, err := os.Open(statePath(n.stateDir(), height))
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+	defer gz.Close()
+
+	var snapshot StateSnapshot
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return StateSnapshot{}, err
+	}
+	return snapshot, nil
+}
+
+// saveStateSnapshot writes a state snapshot to the state directory, dropping all but the latest few.
+// n.storeMu has to be held.
+func (n *Node) saveStateSnapshot(snapshot StateSnapshot) error {
+	dir := n.stateDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeSnapshotFile(statePath(dir, snapshot.State.Height), snapshot); err != nil {
+		return err
+	}
+	n.stateWritten = snapshot.State.Hash
+	if heights := n.stateHeights(); len(heights) > stateSnapshotsKept {
+		for _, height := range heights[stateSnapshotsKept:] {
+			os.Remove(statePath(dir, height))
+		}
+	}
+	return nil
+}
+
+// writeState signs and writes the state the chain last took, if it hasn't been already. n.storeMu has to be held.
+func (n *Node) writeState() {
+	if n.stateDir() == "" {
+		return
+	}
+	state, ok := n.chain.LatestState()
+	if !ok || state.Hash == n.stateWritten {
+		return
+	}
+	digest := state.Digest()
+	snapshot := StateSnapshot{
+		State:     state,
+		Digest:    digest,
+		Signer:    n.ID(),
+		Signature: hex.EncodeToString(ed25519.Sign(n.key, stateMessage(digest))),
+		Created:   time.Now(),
+	}
+	if err := n.saveStateSnapshot(snapshot); err != nil {
+		n.logger.Printf("writing the state snapshot at %d failed: %v", state.Height, err)
+		return
+	}
+	n.debug(fmt.Sprintf("wrote the state snapshot at %d, %s", state.Height, digest))
+}
+
+// chainFromState creates a chain from stored blocks that have been pruned, starting from the newest state
+// snapshot the node has that's as of one of them and that the rest have their bodies to carry on from. Without
+// one the state's worked out from the bodies that are left.
+func (n *Node) chainFromState(blocks []blockchain.Block) *blockchain.Chain {
+	for _, height := range n.stateHeights() {
+		snapshot, err := n.readStateSnapshot(height)
+		if err == nil {
+			err = snapshot.verify()
+		}
+		var chain *blockchain.Chain
+		if err == nil {
+			chain, err = blockchain.NewChainFromState(blocks, snapshot.State)
+		}
+		if err != nil {
+			n.debug(fmt.Sprintf("not starting from the state snapshot at %d: %v", height, err))
+			continue
+		}
+		n.followRules(chain)
+		n.stateWritten = snapshot.State.Hash
+		n.logger.Printf("started from the state snapshot at %d", height)
+		return chain
+	}
+	n.logger.Printf("no state snapshot the stored chain carries on from, its state is only what the unpruned bodies give")
+	return n.newChain(blocks...)
+}
+
+// GetStateSnapshots handles the route listing the state snapshots the node has, newest first
+func (n *Node) GetStateSnapshots(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	infos := []StateSnapshotInfo{}
+	for _, height := range n.stateHeights() {
+		if snapshot, err := n.readStateSnapshot(height); err == nil {
+			infos = append(infos, snapshot.info())
+		}
+	}
+	RespondWithJSON(w, r, http.StatusOK, infos)
+}
+
+// GetStateSnapshot handles the route to download the state snapshot as of a block, eg /state/snapshots/1000
+func (n *Node) GetStateSnapshot(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	height, err := strconv.Atoi(ps.ByName("height"))
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "height must be a block index")
+		return
+	}
+	snapshot, err := n.readStateSnapshot(height)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusNotFound, "no state snapshot at that height")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, snapshot)
+}
+
+// stateCheckpoint returns the height of the highest state checkpoint at or below head, -1 if there isn't one
+func (n *Node) stateCheckpoint(head int) int {
+	best := -1
+	for height := range n.cfg.StateCheckpoints {
+		if height > best && height <= head {
+			best = height
+		}
+	}
+	return best
+}
+
+// syncFromState starts a new node's chain from the state snapshot at the highest state checkpoint a peer has
+// reached, downloading the headers before it and the blocks after it
+func (n *Node) syncFromState(ctx context.Context, peer string, head blockchain.Header) error {
+	height := n.stateCheckpoint(head.Index)
+	if height <= 0 {
+		return errNoStateSnapshot
+	}
+	headers, err := n.fetchChainHeaders(peer, head.Index)
+	if err != nil {
+		return err
+	}
+	if len(headers) <= height { // it doesn't have the headers it said it had
+		return errNoStateSnapshot
+	}
+	snapshot, from, err := n.findStateSnapshot(peer, headers[height])
+	if err != nil {
+		return err
+	}
+
+	blocks := make([]blockchain.Block, height+1)
+	for i, header := range headers[:height+1] {
+		blocks[i] = blockchain.Block{Header: header, Pruned: true}
+	}
+	n.storeMu.Lock()
+	err = n.saveStateSnapshot(snapshot) // first, so the chain can start from it again if the node restarts
+	n.storeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := n.chain.ReplaceWithState(blocks, snapshot.State); err != nil {
+		return err
+	}
+	n.logger.Printf("started from the state as of block %d from %s, signed by %s", height, from, snapshot.Signer)
+	if err := n.persist(ctx); err != nil {
+		return err
+	}
+
+	if rest := headers[height+1:]; len(rest) > 0 {
+		added, err := n.downloadBodies(ctx, peer, rest)
+		if added > 0 {
+			n.persistAdded(ctx)
+		}
+		return err
+	}
+	return nil
+}
+
+// findStateSnapshot gets the state snapshot as of a block from the first peer that has one matching the state
+// checkpoint at its height, asking peer first
+func (n *Node) findStateSnapshot(peer string, header blockchain.Header) (StateSnapshot, string, error) {
+	client := *n.client
+	client.Timeout = n.cfg.SyncTimeout
+	peers := []string{peer}
+	for _, p := range n.peers() {
+		if p != peer {
+			peers = append(peers, p)
+		}
+	}
+
+	want := n.cfg.StateCheckpoints[header.Index]
+	for _, p := range peers {
+		snapshot, err := FetchStateSnapshot(&client, p, header.Index)
+		if err != nil {
+			n.debug(err)
+			continue
+		}
+		if snapshot.State.Height != header.Index || snapshot.State.Hash != header.Hash { // it may be on another branch
+			n.debug(fmt.Sprintf("the state snapshot at %d from %s isn't as of block %s", header.Index, p, header.Hash))
+			continue
+		}
+		if err := snapshot.verify(); err != nil || snapshot.Digest != want {
+			n.logger.Printf("ignoring the state snapshot at %d from %s, it doesn't match the state checkpoint", header.Index, p)
+			n.penalizeURL(p, "bad state snapshot", penaltyBadState)
+			continue
+		}
+		return snapshot, p, nil
+	}
+	return StateSnapshot{}, "", errNoStateSnapshot
+}
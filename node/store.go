@@ -0,0 +1,249 @@
+package node
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// Store keeps a chain on disk in a data directory, in the canonical binary encoding, along with its index.
+// Blocks added on top of the stored chain are appended to a log in batches rather than rewriting the chain,
+// and folded into it the next time it's saved whole.
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	log    string // blocks appended since the chain was last saved, see Append
+	index  string // the chain's index, see blockchain.Index
+	legacy string // the JSON file chains were stored in before, read if there's no binary file yet
+
+	head          *blockchain.Header // the last block in chain.bin and the log, nil if they haven't been read or written
+	size, logSize int64              // bytes in the chain file and the log
+}
+
+// OpenStore creates the data directory if needed and returns a store for it
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{path: filepath.Join(dir, "chain.bin"), log: filepath.Join(dir, "chain.log"), index: filepath.Join(dir, "index.bin"), legacy: filepath.Join(dir, "chain.json")}, nil
+}
+
+// Load reads the stored chain, with any blocks appended to it since, returning nothing if the chain hasn't
+// been saved yet
+func (s *Store) Load() ([]blockchain.Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s.loadLegacy()
+	}
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := blockchain.DecodeBlocks(data)
+	if err != nil || len(blocks) == 0 {
+		return blocks, err
+	}
+	s.size = int64(len(data))
+	if blocks, err = s.loadLog(blocks); err != nil {
+		return nil, err
+	}
+	head := blocks[len(blocks)-1].Header
+	s.head = &head
+	return blocks, nil
+}
+
+// loadLog adds the blocks in the log to the chain. A batch a crash cut short is cut off the log, and blocks that
+// don't build on the chain are skipped: ones it already has, from a crash between saving the chain whole and
+// clearing the log, and ones from a branch it's since left.
+func (s *Store) loadLog(blocks []blockchain.Block) ([]blockchain.Block, error) {
+	data, err := os.ReadFile(s.log)
+	if os.IsNotExist(err) {
+		return blocks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var good int64
+	for len(data) >= 8 {
+		n, sum := binary.BigEndian.Uint32(data), binary.BigEndian.Uint32(data[4:])
+		if uint64(n) > uint64(len(data)-8) || crc32.ChecksumIEEE(data[8:8+n]) != sum {
+			break
+		}
+		batch, err := blockchain.DecodeBlocks(data[8 : 8+n])
+		if err != nil {
+			break
+		}
+		for _, block := range batch {
+			if last := blocks[len(blocks)-1]; block.Index == last.Index+1 && block.PrevHash == last.Hash {
+				blocks = append(blocks, block)
+			}
+		}
+		data = data[8+n:]
+		good += 8 + int64(n)
+	}
+	if len(data) > 0 { // appends go after the last whole batch
+		if err := os.Truncate(s.log, good); err != nil {
+			return nil, err
+		}
+	}
+	s.logSize = good
+	return blocks, nil
+}
+
+// Head returns the header of the last block stored, false if the store hasn't read or written a chain yet
+func (s *Store) Head() (blockchain.Header, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.head == nil {
+		return blockchain.Header{}, false
+	}
+	return *s.head, true
+}
+
+// NeedsRewrite returns if the log has grown bigger than the chain it's appended to, so the chain is due to be
+// saved whole
+func (s *Store) NeedsRewrite() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logSize > s.size
+}
+
+// LoadIndex reads the stored index of blocks, the stored chain, returning nil if there isn't one. It's an error
+// if the index isn't for those blocks, in which case it should be rebuilt.
+func (s *Store) LoadIndex(blocks []blockchain.Block) (*blockchain.Index, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.index)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blockchain.DecodeIndex(data, blocks)
+}
+
+// loadLegacy reads a chain stored as JSON, it's rewritten in the binary encoding the next time it's saved
+func (s *Store) loadLegacy() ([]blockchain.Block, error) {
+	data, err := os.ReadFile(s.legacy)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return blockchain.DecodeLegacyJSON(data)
+}
+
+// Compact rewrites the stored chain and removes any temp files a crash left behind
+func (s *Store) Compact(blocks []blockchain.Block, index []byte) error {
+	if err := s.Save(blocks, index); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	leftovers, err := filepath.Glob(filepath.Join(filepath.Dir(s.path), "*.tmp"))
+	if err != nil {
+		return err
+	}
+	for _, leftover := range leftovers {
+		if err := os.Remove(leftover); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the chain and its encoded index to disk, going through temp files so a crash can't leave half a
+// chain behind, and clears the log since the chain has everything in it. The index is written last and records
+// the head it's up to, so one left behind by a crash in between is noticed when it's loaded. Without an index,
+// any stored one is removed, to be rebuilt.
+func (s *Store) Save(blocks []blockchain.Block, index []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp := s.path + ".tmp"
+	data := blockchain.EncodeBlocks(blocks)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	if err := os.Remove(s.log); err != nil && !os.IsNotExist(err) { // it's all in the chain now
+		return err
+	}
+	s.size, s.logSize = int64(len(data)), 0
+	if len(blocks) > 0 {
+		head := blocks[len(blocks)-1].Header
+		s.head = &head
+	}
+	if err := s.saveIndex(index); err != nil {
+		return err
+	}
+	if err := os.Remove(s.legacy); err != nil && !os.IsNotExist(err) { // it's been migrated
+		return err
+	}
+	return nil
+}
+
+// Append adds blocks that build on the stored head to the stored chain as one batch, a single write to the log
+// that's synced to disk before it returns, then writes the index like Save. A batch is checksummed, so one a
+// crash cuts short is dropped as a whole when the chain is loaded.
+func (s *Store) Append(blocks []blockchain.Block, index []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payload := blockchain.EncodeBlocks(blocks)
+	record := make([]byte, 8, 8+len(payload)) // length and checksum, then the batch
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+
+	f, err := os.OpenFile(s.log, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(record); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.logSize += int64(len(record))
+	if len(blocks) > 0 {
+		head := blocks[len(blocks)-1].Header
+		s.head = &head
+	}
+	return s.saveIndex(index)
+}
+
+// saveIndex writes the encoded index, or removes the stored one if there isn't one, s.mu has to be held
+func (s *Store) saveIndex(index []byte) error {
+	if index == nil {
+		if err := os.Remove(s.index); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.WriteFile(s.index+".tmp", index, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(s.index+".tmp", s.index)
+}
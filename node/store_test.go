@@ -0,0 +1,61 @@
+package node_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// BenchmarkStore stores the same synthetic blocks rewriting the whole chain for each one, like nodes used to,
+// appended one at a time, and appended in batches
+func BenchmarkStore(b *testing.B) {
+	const count, batch, txs = 1000, 100, 20
+	blocks := []blockchain.Block{blockchain.NewGenesisBlock()}
+	for i := 1; i <= count; i++ {
+		body := make([]blockchain.Transaction, txs)
+		for j := range body {
+			body[j] = blockchain.Transaction{Class: blockchain.ClassUser, From: fmt.Sprintf("sender%d", j), To: fmt.Sprintf("recipient%d", i), Amount: j, Nonce: i}
+		}
+		block, _ := blockchain.GenerateBlock(blocks[i-1], i, body...)
+		blocks = append(blocks, block)
+	}
+
+	runs := []struct {
+		name  string
+		store func(store *node.Store, added []blockchain.Block) error
+		batch int
+	}{
+		{"rewrite per block", func(store *node.Store, added []blockchain.Block) error {
+			return store.Save(blocks[:added[len(added)-1].Index+1], nil)
+		}, 1},
+		{"append per block", func(store *node.Store, added []blockchain.Block) error { return store.Append(added, nil) }, 1},
+		{fmt.Sprintf("append per %d blocks", batch), func(store *node.Store, added []blockchain.Block) error { return store.Append(added, nil) }, batch},
+	}
+	for _, run := range runs {
+		b.Run(run.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				store, err := node.OpenStore(b.TempDir())
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := store.Save(blocks[:1], nil); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+
+				for from := 1; from < len(blocks); from += run.batch {
+					to := from + run.batch
+					if to > len(blocks) {
+						to = len(blocks)
+					}
+					if err := run.store(store, blocks[from:to]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,467 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// A stratum-like protocol for pools of external miners, served on StratumAddr. It's JSON-RPC 2.0, one message
+// per line over plain TCP. A worker calls mining.subscribe, then gets a mining.notify notification with a job
+// whenever there's new work, and calls mining.submit with every nonce whose hash meets the job's share
+// target. Shares are far easier than blocks, so they show how hard each worker is hashing, and the node
+// submits any share that also meets the block target as a block.
+
+const (
+	defaultStratumDifficulty = 1 << 20          // about a share a second for a worker doing a million hashes a second
+	stratumRefresh           = time.Second      // new transactions are put in a new job at most this often
+	stratumMaxJobs           = 32               // older jobs on the same head are dropped, shares for them are stale
+	stratumMaxLine           = 64 << 10         // longest message a worker can send
+	stratumNonceSpace        = int64(1) << 40   // nonces each worker gets to itself, starting at its NonceStart
+	stratumIdle              = 10 * time.Minute // workers that don't send anything for this long are disconnected
+)
+
+// stratum error codes, the ones stratum v1 pools use
+const (
+	stratumOther        = 20
+	stratumStale        = 21 // the job isn't for the current head any more
+	stratumDuplicate    = 22
+	stratumLowDiff      = 23 // the hash doesn't meet the share target
+	stratumUnsubscribed = 25
+)
+
+// StratumJob ... work sent to stratum workers. Set Header.Nonce, starting from NonceStart, hash it with
+// blockchain.GenerateHeaderHash and submit the nonce if the hash is below ShareTarget. CleanJobs means the
+// head moved and shares for earlier jobs won't be taken.
+type StratumJob struct {
+	JobID       string
+	Header      blockchain.Header
+	Target      string // hex, what the hash has to be below for a block
+	ShareTarget string // hex, what the hash has to be below for a share
+	NonceStart  int64  // where this worker's nonces start, so workers don't hash the same ones
+	CleanJobs   bool
+}
+
+// WorkerStats ... how a stratum worker has been doing, kept by worker name across reconnects
+type WorkerStats struct {
+	Name      string
+	Addr      string // where it last connected from
+	Connected bool
+	Accepted  int // shares that met the share target
+	Rejected  int // shares that didn't, or were sent twice
+	Stale     int // shares for jobs on an old head
+	Blocks    int // shares that were also blocks and got added to the chain
+	LastShare time.Time
+	Hashrate  float64 // hashes a second, estimated from the shares accepted since it connected
+
+	since time.Time // when it connected
+	work  float64   // hashes the shares accepted since then stand for
+}
+
+// stratumJob is a job and the block it was made from
+type stratumJob struct {
+	id          string
+	block       blockchain.Block
+	shareTarget *big.Int
+	shareWork   float64        // how many hashes a share takes on average
+	shares      map[int64]bool // nonces already submitted
+	clean       bool           // it's the first job on its head
+}
+
+// stratumServer keeps the current jobs and the connected workers
+type stratumServer struct {
+	n  *Node
+	ln net.Listener
+
+	mu      sync.Mutex
+	jobs    []*stratumJob // on the current head, newest last
+	nextJob int
+	nextID  int64 // the next worker's nonce space
+	conns   map[*stratumConn]bool
+	stats   map[string]*WorkerStats
+}
+
+// stratumConn is a connected worker
+type stratumConn struct {
+	conn       net.Conn
+	mu         sync.Mutex // writes
+	enc        *json.Encoder
+	name       string // empty until it subscribes
+	nonceStart int64
+}
+
+// startStratum serves the stratum protocol on StratumAddr, if it's set
+func (n *Node) startStratum() error {
+	if n.cfg.StratumAddr == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", n.cfg.StratumAddr)
+	if err != nil {
+		return err
+	}
+	s := &stratumServer{n: n, ln: ln, conns: make(map[*stratumConn]bool), stats: make(map[string]*WorkerStats)}
+	n.stratum = s
+	n.logger.Println("stratum listening on", ln.Addr())
+
+	sub := n.bus.Subscribe(64, events.NameBlockAdded, events.NameChainReorg, events.NameTxAdmitted)
+	go s.refreshLoop(sub)
+	go s.serve()
+	return nil
+}
+
+// StratumAddr returns the address stratum workers connect to, empty if it's not served
+func (n *Node) StratumAddr() string {
+	if n.stratum == nil {
+		return ""
+	}
+	return n.stratum.ln.Addr().String()
+}
+
+func (s *stratumServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return // closed
+		}
+		c := &stratumConn{conn: conn, enc: json.NewEncoder(conn)}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+// close stops listening and disconnects every worker
+func (s *stratumServer) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+}
+
+// refreshLoop sends new jobs when the head moves, straight away, and when transactions arrive, at most
+// every stratumRefresh so a busy mempool doesn't flood workers
+func (s *stratumServer) refreshLoop(sub *events.Subscription) {
+	defer sub.Close()
+	ticker := time.NewTicker(stratumRefresh)
+	defer ticker.Stop()
+
+	pending := false
+	for {
+		select {
+		case <-s.n.done:
+			return
+		case e := <-sub.C:
+			if e.Name() == events.NameTxAdmitted {
+				pending = true
+				continue
+			}
+			pending = false
+			s.newJob(true)
+		case <-ticker.C:
+			if pending {
+				pending = false
+				s.newJob(false)
+			}
+		}
+	}
+}
+
+// newJob builds a job from a fresh template and sends it to every subscribed worker
+func (s *stratumServer) newJob(clean bool) {
+	job, err := s.makeJob(clean)
+	if err != nil {
+		s.n.logger.Printf("stratum: building a job: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	conns := make([]*stratumConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		if c.subscribed() {
+			c.notify(s.jobFor(job, c, job.clean))
+		}
+	}
+}
+
+// makeJob builds a job and makes it the current one, forgetting jobs on older heads
+func (s *stratumServer) makeJob(clean bool) (*stratumJob, error) {
+	template, err := s.n.blockTemplate(context.Background(), "")
+	if err != nil {
+		return nil, err
+	}
+	shareDifficulty := s.n.cfg.StratumDifficulty
+	if shareDifficulty <= 0 {
+		shareDifficulty = defaultStratumDifficulty
+	}
+	if blockDifficulty := template.Block.Difficulty; blockDifficulty < shareDifficulty { // every share would be a block anyway
+		shareDifficulty = blockDifficulty
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextJob++
+	job := &stratumJob{
+		id:          fmt.Sprintf("%x", s.nextJob),
+		block:       template.Block,
+		shareTarget: blockchain.Target(shareDifficulty),
+		shareWork:   float64(shareDifficulty),
+		shares:      make(map[int64]bool),
+	}
+	if clean || len(s.jobs) > 0 && s.jobs[0].block.PrevHash != job.block.PrevHash {
+		job.clean = true
+		s.jobs = nil
+	}
+	s.jobs = append(s.jobs, job)
+	if len(s.jobs) > stratumMaxJobs {
+		s.jobs = s.jobs[1:]
+	}
+	return job, nil
+}
+
+// currentJob returns the newest job, building one if there isn't one yet
+func (s *stratumServer) currentJob() (*stratumJob, error) {
+	s.mu.Lock()
+	if len(s.jobs) > 0 {
+		job := s.jobs[len(s.jobs)-1]
+		s.mu.Unlock()
+		return job, nil
+	}
+	s.mu.Unlock()
+	return s.makeJob(true)
+}
+
+// jobFor is how a job is sent to a worker
+func (s *stratumServer) jobFor(job *stratumJob, c *stratumConn, clean bool) StratumJob {
+	return StratumJob{
+		JobID:       job.id,
+		Header:      job.block.Header,
+		Target:      hexTarget(blockchain.Target(job.block.Difficulty)),
+		ShareTarget: hexTarget(job.shareTarget),
+		NonceStart:  c.nonceStart,
+		CleanJobs:   clean,
+	}
+}
+
+// handle reads a worker's calls until it disconnects
+func (s *stratumServer) handle(c *stratumConn) {
+	defer func() {
+		c.conn.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		if stats := s.stats[c.name]; stats != nil {
+			stats.Connected = false
+		}
+		s.mu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 4096), stratumMaxLine)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(stratumIdle))
+		if !scanner.Scan() {
+			return
+		}
+		var req RPCRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			c.reply(json.RawMessage("null"), nil, &RPCError{Code: rpcParseError, Message: "parse error"})
+			return
+		}
+
+		var result interface{}
+		var rpcErr *RPCError
+		switch req.Method {
+		case "mining.subscribe":
+			result, rpcErr = s.subscribe(c, req.Params)
+		case "mining.submit":
+			result, rpcErr = s.submit(c, req.Params)
+		default:
+			rpcErr = &RPCError{Code: rpcMethodNotFound, Message: "method not found"}
+		}
+		if len(req.ID) > 0 {
+			c.reply(req.ID, result, rpcErr)
+		}
+		if req.Method == "mining.subscribe" && rpcErr == nil { // work straight away
+			if job, err := s.currentJob(); err == nil {
+				c.notify(s.jobFor(job, c, true))
+			}
+		}
+	}
+}
+
+// subscribe registers a worker, params [worker], returning where its nonces start
+func (s *stratumServer) subscribe(c *stratumConn, params json.RawMessage) (interface{}, *RPCError) {
+	var name string
+	if err := rpcParams(params, []string{"worker"}, &name); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, &RPCError{Code: rpcInvalidParams, Message: "worker name is required"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.name != "" {
+		return nil, &RPCError{Code: stratumOther, Message: "already subscribed"}
+	}
+	stats := s.stats[name]
+	if stats == nil {
+		stats = &WorkerStats{Name: name}
+		s.stats[name] = stats
+	}
+	stats.Addr = c.conn.RemoteAddr().String()
+	stats.Connected = true
+	stats.since, stats.work = time.Now(), 0
+
+	c.mu.Lock()
+	c.name = name
+	c.nonceStart = s.nextID * stratumNonceSpace
+	c.mu.Unlock()
+	s.nextID++
+	return map[string]interface{}{"NonceStart": c.nonceStart}, nil
+}
+
+// submit takes a share, params [job, nonce], submitting it as a block if it's good enough for one
+func (s *stratumServer) submit(c *stratumConn, params json.RawMessage) (interface{}, *RPCError) {
+	var jobID string
+	var nonce int64
+	if err := rpcParams(params, []string{"job", "nonce"}, &jobID, &nonce); err != nil {
+		return nil, err
+	}
+	if !c.subscribed() {
+		return nil, &RPCError{Code: stratumUnsubscribed, Message: "not subscribed"}
+	}
+
+	s.mu.Lock()
+	stats := s.stats[c.name]
+	var job *stratumJob
+	for _, j := range s.jobs {
+		if j.id == jobID {
+			job = j
+		}
+	}
+	if job == nil {
+		stats.Stale++
+		s.mu.Unlock()
+		return nil, &RPCError{Code: stratumStale, Message: "job not found, it's stale"}
+	}
+	if job.shares[nonce] {
+		stats.Rejected++
+		s.mu.Unlock()
+		return nil, &RPCError{Code: stratumDuplicate, Message: "duplicate share"}
+	}
+	job.shares[nonce] = true
+	block := job.block
+	s.mu.Unlock()
+
+	block.Nonce = nonce
+	block.Hash = blockchain.GenerateHeaderHash(block.Header)
+	hashed, _ := new(big.Int).SetString(block.Hash, 16)
+	if hashed == nil || hashed.Cmp(job.shareTarget) >= 0 {
+		s.record(stats, func() { stats.Rejected++ })
+		return nil, &RPCError{Code: stratumLowDiff, Message: "share is above the target"}
+	}
+
+	found := false
+	if block.MeetsDifficulty() {
+		switch err := s.n.submitBlock(context.Background(), block); err {
+		case nil:
+			found = true
+			s.n.logger.Printf("stratum: %s found block %d", c.name, block.Index)
+		case ErrStaleBlock:
+			s.record(stats, func() { stats.Stale++ })
+			return nil, &RPCError{Code: stratumStale, Message: err.Error()}
+		case ErrInvalidBlock:
+			s.record(stats, func() { stats.Rejected++ })
+			return nil, &RPCError{Code: stratumOther, Message: err.Error()}
+		default: // added, but couldn't be stored
+			found = true
+			s.n.logger.Printf("stratum: storing block %d from %s: %v", block.Index, c.name, err)
+		}
+	}
+
+	s.record(stats, func() {
+		stats.Accepted++
+		stats.LastShare = time.Now()
+		stats.work += job.shareWork
+		if found {
+			stats.Blocks++
+		}
+	})
+	return true, nil
+}
+
+// record updates a worker's stats under the lock
+func (s *stratumServer) record(stats *WorkerStats, update func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update()
+}
+
+// workers returns every worker's stats, sorted by name
+func (s *stratumServer) workers() []WorkerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workers := make([]WorkerStats, 0, len(s.stats))
+	for _, stats := range s.stats {
+		w := *stats
+		if elapsed := time.Since(w.since).Seconds(); w.Connected && elapsed > 0 {
+			w.Hashrate = w.work / elapsed
+		}
+		workers = append(workers, w)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Name < workers[j].Name })
+	return workers
+}
+
+func (c *stratumConn) subscribed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name != ""
+}
+
+func (c *stratumConn) reply(id json.RawMessage, result interface{}, err *RPCError) {
+	c.write(RPCResponse{JSONRPC: "2.0", Result: result, Error: err, ID: id})
+}
+
+func (c *stratumConn) notify(job StratumJob) {
+	params, _ := json.Marshal([]StratumJob{job})
+	c.write(RPCRequest{JSONRPC: "2.0", Method: "mining.notify", Params: params})
+}
+
+// write sends a message on its own line, giving up on workers that aren't reading
+func (c *stratumConn) write(msg interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := c.enc.Encode(msg); err != nil { // Encode ends it with a newline
+		c.conn.Close()
+	}
+}
+
+// GetStratumWorkers handles the route listing stratum workers and how they're doing
+func (n *Node) GetStratumWorkers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if n.stratum == nil {
+		RespondWithJSON(w, r, http.StatusNotFound, "stratum isn't turned on, set STRATUM_ADDR")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, n.stratum.workers())
+}
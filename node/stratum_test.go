@@ -0,0 +1,131 @@
+package node_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+// stratumWorker ... a test connection to a node's stratum server
+type stratumWorker struct {
+	conn   net.Conn
+	lines  *bufio.Scanner
+	nextID int
+	jobs   []node.StratumJob // notified so far
+}
+
+func dialStratum(t *testing.T, n *blockchaintest.Node) *stratumWorker {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", n.StratumAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &stratumWorker{conn: conn, lines: bufio.NewScanner(conn)}
+}
+
+// call sends a request and returns its response, keeping any jobs notified in the meantime
+func (w *stratumWorker) call(t *testing.T, method string, params ...interface{}) node.RPCResponse {
+	t.Helper()
+
+	w.nextID++
+	raw, _ := json.Marshal(params)
+	json.NewEncoder(w.conn).Encode(node.RPCRequest{JSONRPC: "2.0", Method: method, Params: raw, ID: json.RawMessage(jsonInt(w.nextID))})
+	for w.lines.Scan() {
+		var msg struct {
+			node.RPCResponse
+			Method string
+			Params []node.StratumJob
+		}
+		if err := json.Unmarshal(w.lines.Bytes(), &msg); err != nil {
+			t.Fatalf("stratum sent %s: %v", w.lines.Bytes(), err)
+		}
+		if msg.Method == "mining.notify" {
+			w.jobs = append(w.jobs, msg.Params...)
+			continue
+		}
+		if string(msg.ID) == jsonInt(w.nextID) {
+			return msg.RPCResponse
+		}
+	}
+	t.Fatalf("stratum connection ended waiting for the response to %s: %v", method, w.lines.Err())
+	return node.RPCResponse{}
+}
+
+// job waits for the first job the worker's notified of
+func (w *stratumWorker) job(t *testing.T) node.StratumJob {
+	t.Helper()
+
+	for len(w.jobs) == 0 && w.lines.Scan() {
+		var msg struct {
+			Method string
+			Params []node.StratumJob
+		}
+		if json.Unmarshal(w.lines.Bytes(), &msg) == nil && msg.Method == "mining.notify" {
+			w.jobs = append(w.jobs, msg.Params...)
+		}
+	}
+	if len(w.jobs) == 0 {
+		t.Fatal("stratum never sent a job")
+	}
+	return w.jobs[0]
+}
+
+func jsonInt(i int) string {
+	raw, _ := json.Marshal(i)
+	return string(raw)
+}
+
+func TestStratum(t *testing.T) {
+	n := blockchaintest.NewNode(t, func(cfg *node.Config) {
+		cfg.StratumAddr = "127.0.0.1:0"
+		cfg.StratumDifficulty = 1 // every hash is a share
+	})
+	w := dialStratum(t, n)
+
+	errCode := func(res node.RPCResponse) int {
+		if res.Error == nil {
+			return 0
+		}
+		return res.Error.Code
+	}
+	steps := []struct {
+		name   string
+		method string
+		params func() []interface{}
+		want   int // the error code, 0 for a result
+	}{
+		{"submit before subscribing", "mining.submit", func() []interface{} { return []interface{}{"1", 0} }, 25},
+		{"unknown method", "mining.nope", func() []interface{} { return nil }, -32601},
+		{"subscribe without a name", "mining.subscribe", func() []interface{} { return []interface{}{""} }, -32602},
+		{"subscribe", "mining.subscribe", func() []interface{} { return []interface{}{"rig1"} }, 0},
+		{"subscribe twice", "mining.subscribe", func() []interface{} { return []interface{}{"rig1"} }, 20},
+		{"share for a job that doesn't exist", "mining.submit", func() []interface{} { return []interface{}{"ffff", 0} }, 21},
+		{"share", "mining.submit", func() []interface{} {
+			job := w.job(t)
+			return []interface{}{job.JobID, job.NonceStart}
+		}, 0},
+	}
+	for _, step := range steps {
+		if got := errCode(w.call(t, step.method, step.params()...)); got != step.want {
+			t.Errorf("%s: error code %d, want %d", step.name, got, step.want)
+		}
+	}
+
+	var workers []node.WorkerStats
+	n.Do(t, http.MethodGet, "/v1/mining/workers", nil, &workers)
+	if len(workers) != 1 || workers[0].Name != "rig1" || !workers[0].Connected || workers[0].Accepted != 1 {
+		t.Errorf("workers = %+v, want rig1 connected with a share accepted", workers)
+	}
+	if workers[0].Blocks == 1 {
+		n.AssertHeight(t, 1) // the share was also a block
+	}
+}
@@ -0,0 +1,35 @@
+package node
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Supply ... how many coins exist, and how many ever will
+type Supply struct {
+	Height      int  // of the head
+	Current     int  // coins minted up to and including the head
+	Max         *int // coins that will ever be minted, null if the reward never halves
+	Reward      int  // what the next block's coinbase mints, before fees
+	NextHalving *int // height the reward next halves at, null if it doesn't
+}
+
+// supply works out the supply at the head from the emission schedule
+func (n *Node) supply() Supply {
+	params := n.chain.Params()
+	height := n.chain.Last().Index
+	s := Supply{Height: height, Current: params.Issued(height), Reward: params.Reward(height + 1)}
+	if max, ok := params.MaxSupply(); ok {
+		s.Max = &max
+	}
+	if next, ok := params.NextHalving(height + 1); ok {
+		s.NextHalving = &next
+	}
+	return s
+}
+
+// GetSupply handles the route reporting the current and maximum supply
+func (n *Node) GetSupply(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.supply())
+}
@@ -0,0 +1,151 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// syncLoop compares heads with peers every sync interval until the node shuts down
+func (n *Node) syncLoop() {
+	ticker := time.NewTicker(n.cfg.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		n.syncWithPeers()
+
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncWithPeers catches up with any peer that's ahead of us, then adds any orphans that build on where that left us
+func (n *Node) syncWithPeers() {
+	for _, peer := range n.peers() {
+		if err := n.SyncWithPeer(peer); err != nil {
+			n.logger.Printf("sync with %s failed: %v", peer, err)
+		}
+	}
+	n.connectOrphans(context.Background())
+}
+
+// SyncWithPeer checks the peer's head and, if it's ahead, requests the missing blocks in batches,
+// validating and appending each one, and storing each batch in one write. If the peer turns out to be on a different branch, its whole
+// chain is fetched and adopted when it's valid and longer. A node more than a batch behind syncs headers first.
+func (n *Node) SyncWithPeer(peer string) (err error) {
+	ctx, span := tracer.Start(context.Background(), "node.SyncWithPeer", trace.WithAttributes(attribute.String("peer", peer)))
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	head, err := FetchHead(n.client, peer)
+	n.peerStatus.record(peer, head.Index, time.Since(start), err) // for /readyz and /peers
+	if err != nil {
+		return err
+	}
+
+	if n.cfg.Light {
+		return n.syncLight(ctx, peer, head)
+	}
+	if len(n.cfg.StateCheckpoints) > 0 && n.chain.Len() == 1 { // a new node, that can skip the blocks before a state checkpoint
+		err := n.syncFromState(ctx, peer, head)
+		if !errors.Is(err, errNoStateSnapshot) {
+			return err
+		}
+		n.logger.Printf("%v at or below %d, syncing from the genesis block", err, head.Index)
+	}
+	if head.Index-n.chain.Last().Index > n.cfg.SyncBatchSize {
+		return n.syncHeadersFirst(ctx, peer, head)
+	}
+
+	added := 0
+	for n.chain.Last().Index < head.Index {
+		from := n.chain.Last().Index + 1
+		blocks, err := FetchBlockRange(n.client, peer, from, n.cfg.SyncBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(blocks) == 0 { // the peer has nothing more for us
+			break
+		}
+
+		for _, block := range blocks {
+			if !n.addBlock(ctx, block) {
+				if added > 0 {
+					n.persistAdded(ctx)
+				}
+				return n.syncFork(ctx, peer)
+			}
+			n.mempool.RemoveIncluded(block)
+			n.bus.Publish(events.BlockAdded{Block: block, Origin: events.OriginSync})
+			added++
+		}
+		if err := n.persistAdded(ctx); err != nil { // a long sync keeps what it's got so far if the node goes down
+			return err
+		}
+	}
+
+	if added > 0 {
+		n.logger.Printf("synced %d blocks from %s", added, peer)
+	}
+	return nil
+}
+
+// hasPrunedBlocks returns if any block in a chain is missing its body
+func hasPrunedBlocks(blocks []blockchain.Block) bool {
+	for _, block := range blocks {
+		if block.Pruned {
+			return true
+		}
+	}
+	return false
+}
+
+// syncFork handles a peer whose blocks don't build on our head by adopting its whole chain
+// if it's valid and longer than ours. Transactions only the old branch had go back in the mempool.
+func (n *Node) syncFork(ctx context.Context, peer string) error {
+	blocks, err := FetchBlocks(n.client, peer)
+	if err != nil {
+		return err
+	}
+	_, span := tracer.Start(ctx, "chain.Validate", trace.WithAttributes(attribute.Int("chain.length", len(blocks))))
+	valid := !hasPrunedBlocks(blocks) && n.chain.Validate(blocks) // a pruned chain can't be fully validated
+	span.End()
+	if !valid {
+		n.logger.Printf("ignoring invalid chain from %s", peer)
+		if !hasPrunedBlocks(blocks) {
+			n.penalizeURL(peer, "invalid chain", penaltyInvalidBlock)
+		}
+		return nil
+	}
+
+	reorg, ok := n.chain.ReplaceChain(blocks)
+	if !ok {
+		return nil
+	}
+	for _, block := range reorg.Added {
+		n.mempool.RemoveIncluded(block)
+		n.stale.forget(block.Header)
+	}
+	for i := len(reorg.Removed) - 1; i >= 0; i-- { // oldest first, so a sender's nonces come back in order
+		if reorg.Removed[i].Index > 0 { // a different genesis block means a different network, not a lost race
+			n.checkDoubleSeal(reorg.Removed[i].Header)
+			n.stale.add(reorg.Removed[i].Header)
+		}
+		for _, tx := range reorg.Removed[i].Transactions {
+			if !tx.BlockMade() {
+				n.addTx(tx) // the new branch may have it already, or have spent what it spends
+			}
+		}
+	}
+	n.logger.Printf("replaced chain with %d blocks from %s, forking at %d and dropping %d", len(blocks), peer, reorg.Fork, len(reorg.Removed))
+	n.bus.Publish(events.ChainReorg{Head: n.chain.Last().Header, Length: n.chain.Len(), Fork: reorg.Fork, Removed: len(reorg.Removed)})
+	return n.persist(ctx)
+}
@@ -0,0 +1,144 @@
+package node
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig ... the files needed for mutual TLS between cluster nodes
+type TLSConfig struct {
+	CertFile string // PEM certificate this node presents
+	KeyFile  string // PEM private key for the certificate
+	CAFile   string // PEM bundle of the CA(s) that sign every cluster member's certificate
+}
+
+// certReloader keeps the node's certificate and CA pool in sync with the files on disk,
+// so certs can be rotated by replacing the files without restarting the node
+type certReloader struct {
+	cfg TLSConfig
+
+	mu      sync.Mutex
+	modTime time.Time // newest modification time across the three files when they were last loaded
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" || cfg.CAFile == "" {
+		return nil, errors.New("tls: cert, key and CA files are all required for mutual TLS")
+	}
+
+	r := &certReloader{cfg: cfg}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// latestModTime returns the newest modification time across the cert, key and CA files
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload reads the files from disk if they've changed since they were last loaded
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && !modTime.After(r.modTime) { // nothing's changed
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	caPEM, err := os.ReadFile(r.cfg.CAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return errors.New("tls: no certificates found in CA file " + r.cfg.CAFile)
+	}
+
+	r.cert, r.pool, r.modTime = &cert, pool, modTime
+	return nil
+}
+
+// current returns the loaded cert and CA pool, picking up rotated files first.
+// If a rotated file can't be loaded (eg it's half written) the previous ones keep being used.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.reload() // errors are ignored so a bad rotation doesn't take the node down
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, r.pool
+}
+
+// ServerTLSConfig returns a tls config for a listener that requires clients to present
+// a certificate signed by the cluster CA
+func (r *certReloader) ServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			}, nil
+		},
+	}
+}
+
+// ClientTLSConfig returns a tls config for calling other cluster nodes, presenting this node's
+// certificate and only trusting servers signed by the cluster CA
+func (r *certReloader) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+		// the CA pool can rotate too, so verification is done by hand against the current pool
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			_, pool := r.current()
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("tls: peer presented no certificate")
+			}
+
+			intermediates := x509.NewCertPool()
+			for _, cert := range cs.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+				Roots:         pool,
+				Intermediates: intermediates,
+				DNSName:       cs.ServerName,
+			})
+			return err
+		},
+	}
+}
@@ -0,0 +1,22 @@
+package node
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// GetTokens handles the route to list every token issued on the chain
+func (n *Node) GetTokens(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.chain.Tokens().Tokens())
+}
+
+// GetTokenBalances handles the route to view who holds how much of a token
+func (n *Node) GetTokenBalances(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ledger := n.chain.Tokens()
+	if _, ok := ledger.Token(ps.ByName("id")); !ok {
+		RespondWithJSON(w, r, http.StatusNotFound, "token not found")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, ledger.Balances(ps.ByName("id")))
+}
@@ -0,0 +1,95 @@
+package node
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer makes the node's OpenTelemetry spans. They're dropped until a tracer provider is installed, see main.go
+var tracer = otel.Tracer("github.com/glensargent/go-blockchain/node")
+
+// traceRequests gives every API request a span, carrying on the caller's trace if it sent a traceparent header
+func traceRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method+" "+r.URL.Path, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.target", r.URL.Path),
+		))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// statusRecorder remembers the status code a handler wrote, and the start of the body for errors
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte // up to maxRecordedBody bytes of an error response
+}
+
+// maxRecordedBody caps how much of an error response is kept
+const maxRecordedBody = 512
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	if rec.status >= 400 && len(rec.body) < maxRecordedBody {
+		keep := p
+		if len(keep) > maxRecordedBody-len(rec.body) {
+			keep = keep[:maxRecordedBody-len(rec.body)]
+		}
+		rec.body = append(rec.body, keep...)
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush keeps /events streaming through the recorder
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the real writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// addBlock validates a block and appends it to the chain, traced so slow validation shows up
+func (n *Node) addBlock(ctx context.Context, block blockchain.Block) bool {
+	_, span := tracer.Start(ctx, "chain.AddBlock", trace.WithAttributes(
+		attribute.Int("block.index", block.Index),
+		attribute.Int("block.transactions", len(block.Transactions)),
+	))
+	defer span.End()
+
+	added := n.chain.AddBlock(block)
+	span.SetAttributes(attribute.Bool("block.added", added))
+	return added
+}
+
+// endSpan records err on a span, if there was one, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,179 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// POST /tx/build fills in everything about a transfer but its signature, for wallets that keep their keys
+// somewhere the node can't see. There are no inputs to pick, balances are per address, so the nonce is what
+// makes a transaction spend its sender's coins once: it's the one after the sender's last, pending ones
+// included. The fee is worked out for the transaction's size once it's signed, see fees.go.
+
+// Routes that build a transaction for a sender from a request, like POST /delegate, can't sign it for them
+// either. Sent without a Signature they answer with the TxBuild to sign, and sent again with its Nonce and
+// Timestamp and the signature over its SigHash, the node builds the same transaction and submits it.
+
+// placeholderSig stands in for a signature when working out how big a transaction will be once it's signed
+var placeholderSig = strings.Repeat("0", 2*64)
+
+// TxBuildRequest ... the body of POST /tx/build, eg {"From":"2h...","To":"2h...","Amount":10,"PublicKey":"ab12..."}
+type TxBuildRequest struct {
+	From      string
+	To        string
+	Amount    int
+	Payload   string `json:",omitempty"`
+	PublicKey string `json:",omitempty"` // hex ed25519 key From belongs to, fills in the Script, needed for From to be a script address rather than the key itself
+	Fee       int    `json:",omitempty"` // set to pay that instead of the estimated fee
+	Target    int    `json:",omitempty"` // blocks the fee should get it in within, defaults to 3
+	Expiry    int64  `json:",omitempty"` // the last height it can go in, or unix time, see expiry.go
+}
+
+// TxBuild ... an unsigned transaction from POST /tx/build
+type TxBuild struct {
+	Transaction blockchain.Transaction // sign SigHash and put the signature in its Witness, then POST it to /tx
+	SigHash     string                 // hex of what the signature signs
+	Fee         int
+	FeeRate     float64 // per byte, once it's signed
+	Balance     int     // what From has left to spend, confirmed balance less what its pending transactions send
+}
+
+// TxSignature ... the signature on a transaction a route builds from a request, eg the Nonce, Timestamp and
+// Signature in {"Delegator":"ab12...","Validator":"cd34...","Amount":100,"Nonce":3,"Timestamp":1760600000000000000,"Signature":"9f2c..."}
+type TxSignature struct {
+	Nonce     int    `json:",omitempty"` // the built transaction's, so the same one's built again
+	Timestamp int64  `json:",omitempty"`
+	PublicKey string `json:",omitempty"` // hex ed25519 key the sender's address is the wallet address of, left out if the address is the key
+	Signature string `json:",omitempty"` // hex, over the built transaction's SigHash, left out to get the transaction to sign
+}
+
+// signedTx fills in what a transaction built from a request is signed over, its chain ID, the Nonce and
+// Timestamp it was signed with, or the next ones if it hasn't been, and the Script for its PublicKey, then
+// puts the Signature in its Witness
+func (n *Node) signedTx(tx blockchain.Transaction, sig TxSignature) (blockchain.Transaction, error) {
+	tx.Nonce, tx.Timestamp = sig.Nonce, sig.Timestamp
+	tx, err := parseTxAddresses(withTxDefaults(tx, n.chain.Params().ChainID))
+	if err != nil {
+		return tx, err
+	}
+	if tx.Nonce == 0 {
+		tx.Nonce = n.nextNonce(tx.From)
+	}
+	if sig.PublicKey != "" {
+		if pub, err := hex.DecodeString(sig.PublicKey); err != nil || len(pub) != 32 {
+			return tx, errors.New("public key isn't 32 bytes of hex")
+		}
+		tx.Script = sig.PublicKey + " CHECKSIG" // like a wallet key's
+		if blockchain.ScriptAddress(tx.Script) != tx.From {
+			return tx, errors.New("the sender isn't the address of that public key")
+		}
+	}
+	if sig.Signature != "" {
+		tx.Witness = []string{sig.Signature}
+	}
+	return tx, nil
+}
+
+// submitSigned submits a transaction built from a request if it's signed, responding with its hash, or
+// responds with the transaction to sign if it isn't
+func (n *Node) submitSigned(w http.ResponseWriter, r *http.Request, tx blockchain.Transaction) {
+	if len(tx.Witness) == 0 {
+		signed := tx // how big it is with its signature
+		signed.Witness = []string{placeholderSig}
+		balance, _ := n.spendable(tx.From, tx.SpendKey())
+		RespondWithJSON(w, r, http.StatusOK, TxBuild{Transaction: tx, SigHash: hex.EncodeToString(tx.SigHash()), Fee: tx.Fee, FeeRate: signed.FeeRate(), Balance: balance})
+		return
+	}
+	hash, err := n.submitRawTx(r.Context(), tx)
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusAccepted, hash)
+}
+
+// PostTxBuild handles the route to build an unsigned transaction
+func (n *Node) PostTxBuild(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req TxBuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	tx, err := parseTxAddresses(withTxDefaults(blockchain.Transaction{From: req.From, To: req.To, Amount: req.Amount, Payload: req.Payload}, n.chain.Params().ChainID))
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	if tx.From == "" || tx.To == "" || tx.Amount <= 0 || req.Fee < 0 {
+		RespondWithJSON(w, r, http.StatusBadRequest, "a transaction needs a From, a To and an Amount, and a Fee can't be negative")
+		return
+	}
+	if req.Target == 0 {
+		req.Target = defaultFeeTarget
+	}
+	if req.Target < 1 || req.Target > maxFeeTarget {
+		RespondWithJSON(w, r, http.StatusBadRequest, "target must be 1 to "+strconv.Itoa(maxFeeTarget)+" blocks")
+		return
+	}
+	if req.PublicKey != "" {
+		if pub, err := hex.DecodeString(req.PublicKey); err != nil || len(pub) != 32 {
+			RespondWithJSON(w, r, http.StatusBadRequest, "public key isn't 32 bytes of hex")
+			return
+		}
+		tx.Script = req.PublicKey + " CHECKSIG" // like a wallet key's
+		if blockchain.ScriptAddress(tx.Script) != tx.From {
+			RespondWithJSON(w, r, http.StatusBadRequest, "From isn't the address of that public key")
+			return
+		}
+	}
+	if tx.Expiry = req.Expiry; tx.Expiry < 0 || tx.Expired(n.chain.Last().Index+1, time.Now()) {
+		rejectTx(w, r, ErrExpired)
+		return
+	}
+	tx.Nonce = n.nextNonce(tx.From)
+
+	signed := tx // how big it is with its signature
+	signed.Witness = []string{placeholderSig}
+	tx.Fee = req.Fee
+	if tx.Fee == 0 {
+		tx.Fee = feeFor(signed, n.estimateFee(req.Target).FeeRate)
+	}
+	signed.Fee = tx.Fee
+
+	balance, err := n.spendable(tx.From, "")
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if balance < tx.Amount+tx.Fee {
+		RespondWithJSON(w, r, http.StatusBadRequest, fmt.Sprintf("%s has %d to spend, the transaction needs %d", tx.From, balance, tx.Amount+tx.Fee))
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, TxBuild{Transaction: tx, SigHash: hex.EncodeToString(tx.SigHash()), Fee: tx.Fee, FeeRate: signed.FeeRate(), Balance: balance})
+}
+
+// spendable returns an address's confirmed balance less what its pending transactions send and pay in fees,
+// other than the one with the spend key replacing, which a transaction with it would replace
+func (n *Node) spendable(address, replacing string) (int, error) {
+	balance, err := n.chain.Spendable(address)
+	if err != nil {
+		return 0, err
+	}
+	for _, tx := range n.mempool.Pending() {
+		if tx.From == address && (replacing == "" || tx.SpendKey() != replacing) {
+			balance -= tx.Spends()
+		}
+	}
+	return balance, nil
+}
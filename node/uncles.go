@@ -0,0 +1,154 @@
+package node
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/julienschmidt/httprouter"
+)
+
+// staleWindow is how many heights back from the head stale blocks are kept and counted
+const staleWindow = 1000
+
+// staleBlocks ... valid blocks that lost the race for their height to the block the chain has there: ones peers
+// gossiped after we already had a block at that height, ones our miner solved too late, and ones a reorg dropped.
+// How often that happens says how long blocks take to get around the network compared to how often they're made.
+type staleBlocks struct {
+	mu       sync.Mutex
+	byHeight map[int][]blockchain.Header
+	hashes   map[string]bool
+	total    int // every stale block since the node started, including ones past the window
+}
+
+// StaleStats ... how often blocks go stale, for /stale
+type StaleStats struct {
+	Window    int     // how many of the latest heights it covers
+	Blocks    int     // blocks the chain has in the window
+	Stale     int     // stale blocks seen in the window
+	StaleRate float64 // stale blocks as a fraction of every block in the window, the chain's and the stale ones
+	Total     int     // stale blocks seen since the node started
+}
+
+func newStaleBlocks() *staleBlocks {
+	return &staleBlocks{byHeight: make(map[int][]blockchain.Header), hashes: make(map[string]bool)}
+}
+
+// add records a stale block, returning false if it already had been
+func (s *staleBlocks) add(header blockchain.Header) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hashes[header.Hash] {
+		return false
+	}
+	s.hashes[header.Hash] = true
+	s.byHeight[header.Index] = append(s.byHeight[header.Index], header)
+	s.total++
+	return true
+}
+
+// forget drops a block that's in the chain after all, a reorg having brought it back
+func (s *staleBlocks) forget(header blockchain.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.hashes[header.Hash] {
+		return
+	}
+	delete(s.hashes, header.Hash)
+	uncles := s.byHeight[header.Index]
+	for i, uncle := range uncles {
+		if uncle.Hash == header.Hash {
+			uncles = append(uncles[:i], uncles[i+1:]...)
+			break
+		}
+	}
+	if len(uncles) == 0 {
+		delete(s.byHeight, header.Index)
+	} else {
+		s.byHeight[header.Index] = uncles
+	}
+	s.total--
+}
+
+// at returns the stale blocks recorded at a height
+func (s *staleBlocks) at(height int) []blockchain.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]blockchain.Header{}, s.byHeight[height]...)
+}
+
+// stats counts the stale blocks in the window below head, forgetting older ones
+func (s *staleBlocks) stats(head int) StaleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from := head - staleWindow + 1
+	if from < 1 { // the genesis block isn't raced for
+		from = 1
+	}
+	stats := StaleStats{Window: staleWindow, Total: s.total}
+	if head >= from {
+		stats.Blocks = head - from + 1
+	}
+	for height, uncles := range s.byHeight {
+		if height < from {
+			for _, uncle := range uncles {
+				delete(s.hashes, uncle.Hash)
+			}
+			delete(s.byHeight, height)
+			continue
+		}
+		stats.Stale += len(uncles)
+	}
+	if all := stats.Blocks + stats.Stale; all > 0 {
+		stats.StaleRate = float64(stats.Stale) / float64(all)
+	}
+	return stats
+}
+
+// recordIfStale records a block that couldn't be added as stale if it's a valid block on a parent we have,
+// at a height the chain already has a different block at. Anything else, from a longer branch, an orphan or
+// simply invalid, isn't a block that lost a race.
+func (n *Node) recordIfStale(block blockchain.Block) {
+	if block.Index > n.chain.Last().Index {
+		return
+	}
+	if _, ok := n.chain.BlockByHash(block.Hash); ok {
+		return
+	}
+	parent, ok := n.chain.BlockByHash(block.PrevHash)
+	if !ok || !blockchain.ValidateBlock(parent, block) {
+		return
+	}
+	n.checkDoubleSeal(block.Header)
+	if n.stale.add(block.Header) {
+		n.logger.Printf("block %d %s went stale", block.Index, block.Hash)
+	}
+}
+
+// GetUncles handles the route listing the stale blocks seen at a height, by index or the hash of the block the chain has there
+func (n *Node) GetUncles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("id")
+	height, err := strconv.Atoi(id)
+	if err != nil {
+		block, ok := n.chain.BlockByHash(id)
+		if !ok {
+			RespondWithJSON(w, r, http.StatusNotFound, "block not found")
+			return
+		}
+		height = block.Index
+	}
+	if height < 0 || height >= n.chain.Len() {
+		RespondWithJSON(w, r, http.StatusNotFound, "block not found")
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, n.stale.at(height))
+}
+
+// GetStale handles the route reporting how often blocks go stale
+func (n *Node) GetStale(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.stale.stats(n.chain.Last().Index))
+}
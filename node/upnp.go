@@ -0,0 +1,223 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A UPnP router is found by multicasting an SSDP search and fetching the device description from the
+// LOCATION the router answers with. Somewhere in it is a WANIPConnection or WANPPPConnection service, and port
+// mappings are SOAP calls to its control URL.
+
+// ssdpAddr is where SSDP searches go
+var ssdpAddr = "239.255.255.250:1900"
+
+// upnpSearchTarget is the kind of device searched for
+const upnpSearchTarget = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+
+// upnpGateway ... a router that speaks UPnP
+type upnpGateway struct {
+	control string // the WAN connection service's control URL
+	service string // its service type, the actions are in its namespace
+	local   net.IP // the node's address on the router's network, what mappings forward to
+	client  *http.Client
+}
+
+// upnpDevice ... the parts of a UPnP device description needed to find the WAN connection service
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// discoverUPnP searches the network for a UPnP router, taking the first that describes a WAN connection
+func discoverUPnP() (*upnpGateway, error) {
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	search := "M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nST: " + upnpSearchTarget + "\r\nMAN: \"ssdp:discover\"\r\nMX: 2\r\n\r\n"
+	if _, err := conn.WriteToUDP([]byte(search), dst); err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(natTimeout))
+	lastErr := errors.New("no UPnP router answered")
+	buf := make([]byte, 2048)
+	for {
+		size, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil, lastErr
+		}
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:size])), nil)
+		if err != nil || res.Header.Get("Location") == "" {
+			continue
+		}
+		g, err := newUPnPGateway(res.Header.Get("Location"))
+		if err == nil {
+			return g, nil
+		}
+		lastErr = err
+	}
+}
+
+// newUPnPGateway reads a router's device description for its WAN connection service
+func newUPnPGateway(location string) (*upnpGateway, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: natTimeout}
+	res, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var root struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(res.Body).Decode(&root); err != nil {
+		return nil, fmt.Errorf("UPnP router at %s: %v", location, err)
+	}
+	if root.URLBase != "" {
+		if b, err := url.Parse(root.URLBase); err == nil {
+			base = b
+		}
+	}
+
+	service, control := findWANService(root.Device)
+	if control == "" {
+		return nil, fmt.Errorf("UPnP device at %s isn't a router, it has no WAN connection", location)
+	}
+	ref, err := url.Parse(control)
+	if err != nil {
+		return nil, err
+	}
+
+	probe, err := net.Dial("udp4", net.JoinHostPort(base.Hostname(), "1900")) // nothing's sent, it just picks the address that reaches the router
+	if err != nil {
+		return nil, err
+	}
+	defer probe.Close()
+	return &upnpGateway{control: base.ResolveReference(ref).String(), service: service, local: probe.LocalAddr().(*net.UDPAddr).IP, client: client}, nil
+}
+
+// findWANService looks through a device and the devices in it for a WAN connection service
+func findWANService(d upnpDevice) (service, control string) {
+	for _, s := range d.Services {
+		if strings.Contains(s.ServiceType, ":WANIPConnection:") || strings.Contains(s.ServiceType, ":WANPPPConnection:") {
+			return s.ServiceType, s.ControlURL
+		}
+	}
+	for _, child := range d.Devices {
+		if service, control = findWANService(child); control != "" {
+			return service, control
+		}
+	}
+	return "", ""
+}
+
+func (g *upnpGateway) protocol() string { return NATUPnP }
+func (g *upnpGateway) gateway() string  { return g.control }
+
+func (g *upnpGateway) externalIP() (net.IP, error) {
+	body, err := g.soap("GetExternalIPAddress", nil)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		IP string `xml:"Body>GetExternalIPAddressResponse>NewExternalIPAddress"`
+	}
+	if err := xml.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(strings.TrimSpace(res.IP))
+	if ip == nil {
+		return nil, fmt.Errorf("UPnP router gave an external address of %q", res.IP)
+	}
+	return ip, nil
+}
+
+func (g *upnpGateway) addMapping(internal, external int, lease time.Duration) (int, error) {
+	args := [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(external)},
+		{"NewProtocol", "TCP"},
+		{"NewInternalPort", strconv.Itoa(internal)},
+		{"NewInternalClient", g.local.String()},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", "go-blockchain node"},
+		{"NewLeaseDuration", strconv.Itoa(int(lease / time.Second))},
+	}
+	if _, err := g.soap("AddPortMapping", args); err != nil {
+		if !strings.Contains(err.Error(), "725") { // OnlyPermanentLeasesSupported, some routers can't do anything else
+			return 0, err
+		}
+		args[7][1] = "0"
+		if _, err := g.soap("AddPortMapping", args); err != nil {
+			return 0, err
+		}
+	}
+	return external, nil
+}
+
+func (g *upnpGateway) deleteMapping(internal, external int) error {
+	_, err := g.soap("DeletePortMapping", [][2]string{{"NewRemoteHost", ""}, {"NewExternalPort", strconv.Itoa(external)}, {"NewProtocol", "TCP"}})
+	return err
+}
+
+// soap calls an action on the router's WAN connection service, returning the response envelope
+func (g *upnpGateway) soap(action string, args [][2]string) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, g.service)
+	for _, arg := range args {
+		body.WriteString("<" + arg[0] + ">")
+		xml.EscapeText(&body, []byte(arg[1]))
+		body.WriteString("</" + arg[0] + ">")
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequest(http.MethodPost, g.control, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+g.service+"#"+action+`"`)
+	res, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	out, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		var fault struct {
+			Code        string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+			Description string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+		}
+		xml.Unmarshal(out, &fault)
+		return nil, fmt.Errorf("UPnP %s failed: %s %s %s", action, res.Status, fault.Code, fault.Description)
+	}
+	return out, nil
+}
@@ -0,0 +1,106 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/signer"
+	"github.com/julienschmidt/httprouter"
+)
+
+// ValidatorsInfo ... the validator set as of the head, for /validators
+type ValidatorsInfo struct {
+	Engine  string
+	Active  []string                    // in the order they take turns
+	Pending []blockchain.ValidatorVotes // votes for changes that haven't happened yet
+	History []blockchain.ValidatorChange
+}
+
+// ValidatorVoteRequest ... the body of POST /admin/validators, eg {"Action":"add","Validator":"ab12..."}
+type ValidatorVoteRequest struct {
+	Action    string // "add" or "remove"
+	Validator string // its node ID
+}
+
+// validators returns the validator set as of the head
+func (n *Node) validators() ValidatorsInfo {
+	set := n.chain.ValidatorSet()
+	return ValidatorsInfo{Engine: n.chain.Params().Engine, Active: set.Active(), Pending: set.Pending(), History: set.History()}
+}
+
+// newSigner returns what the node signs with as a validator: a remote signer, a keystore, or else its own key
+func (n *Node) newSigner() (blockchain.Signer, error) {
+	switch {
+	case n.cfg.SignerURL != "":
+		return signer.NewRemote(n.cfg.SignerURL, n.cfg.SignerToken)
+	case n.cfg.SignerKeystore != "":
+		return signer.LoadKeystore(n.cfg.SignerKeystore, n.cfg.SignerPassphrase)
+	}
+	return signer.NewLocal(n.key), nil
+}
+
+// validatorID returns who the node is as a validator, the signer's public key, which is its node ID unless it
+// has a signer of its own
+func (n *Node) validatorID() string {
+	return hex.EncodeToString(n.signer.PublicKey())
+}
+
+// voteValidator has this node, as a validator, vote to add or remove one, returning the vote's transaction hash
+func (n *Node) voteValidator(ctx context.Context, action, validator string) (string, error) {
+	id := n.validatorID()
+	if !n.chain.IsValidator(id) {
+		return "", blockchain.ErrNotValidator
+	}
+	validator, err := blockchain.ParseAddress(validator) // before signing, like the chain ID
+	if err != nil {
+		return "", err
+	}
+	tx := blockchain.Transaction{
+		Class:     blockchain.ClassValidator,
+		From:      id,
+		To:        validator,
+		Payload:   action,
+		Nonce:     n.nextNonce(id),
+		Timestamp: time.Now().UnixNano(),
+		ChainID:   n.chain.Params().ChainID, // before signing, the signature covers it
+	}
+	sig, err := tx.SignWith(n.signer)
+	if err != nil {
+		return "", err
+	}
+	tx.Witness = []string{sig}
+	return n.submitTx(ctx, tx)
+}
+
+// GetValidators handles the route reporting the validator set, the votes to change it and how it got here
+func (n *Node) GetValidators(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.validators())
+}
+
+// PostValidatorVote handles the admin route for a validator to vote on adding or removing a validator
+func (n *Node) PostValidatorVote(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req ValidatorVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid vote: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if req.Action != blockchain.ValidatorAdd && req.Action != blockchain.ValidatorRemove {
+		RespondWithJSON(w, r, http.StatusBadRequest, "action has to be add or remove")
+		return
+	}
+	hash, err := n.voteValidator(r.Context(), req.Action, req.Validator)
+	if err == blockchain.ErrNotValidator {
+		RespondWithJSON(w, r, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	RespondWithJSON(w, r, http.StatusAccepted, hash)
+}
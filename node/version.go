@@ -0,0 +1,54 @@
+package node
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Version is the node's release, set at build time with -ldflags "-X github.com/glensargent/go-blockchain/node.Version=1.2.3"
+var Version = "dev"
+
+// APIVersions are the API versions the node serves, oldest first. A version is only ever added to:
+// changes that would break clients go in the next version, and older ones keep working.
+var APIVersions = []string{"v1"}
+
+// VersionInfo ... the response from /version
+type VersionInfo struct {
+	Node string   // the node's release
+	API  []string // API versions served, each under /<version>
+	Go   string   // the Go version the node was built with
+}
+
+// apiRoutes registers one API version's routes under its prefix, documenting them in spec
+// and checking request bodies against it
+type apiRoutes struct {
+	router *httprouter.Router
+	prefix string
+	spec   *openAPI
+}
+
+func (r apiRoutes) handle(method, path string, handle httprouter.Handle) {
+	if body := r.spec.add(method, path); body != nil {
+		handle = r.spec.validateBody(body, handle)
+	}
+	r.router.Handle(method, r.prefix+path, handle)
+}
+
+func (r apiRoutes) GET(path string, handle httprouter.Handle) {
+	r.handle(http.MethodGet, path, handle)
+}
+
+func (r apiRoutes) POST(path string, handle httprouter.Handle) {
+	r.handle(http.MethodPost, path, handle)
+}
+
+func (r apiRoutes) DELETE(path string, handle httprouter.Handle) {
+	r.handle(http.MethodDelete, path, handle)
+}
+
+// GetVersion handles the route reporting the node's release and the API versions it serves
+func (n *Node) GetVersion(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, VersionInfo{Node: Version, API: APIVersions, Go: runtime.Version()})
+}
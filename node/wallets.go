@@ -0,0 +1,382 @@
+package node
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/signer"
+	"github.com/glensargent/go-blockchain/wallet"
+	"github.com/julienschmidt/httprouter"
+)
+
+// With a WalletDir the node keeps HD wallets for frontends, under /wallet: each one's backup phrase is sealed
+// under its passphrase in WalletDir/<name>.json, see wallet.Locked. Listing addresses doesn't need the
+// passphrase, handing out new ones and signing do, so a wallet is unlocked for a while first and its phrase
+// kept in memory till then. The routes need the admin token like the admin ones, and a node with a WalletDir
+// won't start without one, or anyone who could reach the API could sign with its wallets.
+
+// how long a wallet stays unlocked
+const (
+	defaultWalletUnlock = 5 * time.Minute
+	maxWalletUnlock     = time.Hour
+)
+
+// walletName is what a wallet can be called, it's part of a file name
+var walletName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// WalletCreateRequest ... the body of POST /wallet, eg {"Name":"alice","Passphrase":"..."}
+type WalletCreateRequest struct {
+	Name       string
+	Passphrase string // seals the backup phrase, and unlocks the wallet later
+	Words      int    // how many words the backup phrase has, defaults to 24
+}
+
+// WalletCreated ... what POST /wallet answers with, the only time the backup phrase is sent
+type WalletCreated struct {
+	Name      string
+	Mnemonic  string
+	Addresses []wallet.Address
+}
+
+// WalletInfo ... a wallet the node keeps
+type WalletInfo struct {
+	Name      string
+	Unlocked  bool
+	Until     *time.Time `json:",omitempty"` // when it locks again, if it's unlocked
+	Addresses []wallet.Address
+}
+
+// WalletUnlockRequest ... the body of POST /wallet/:name/unlock
+type WalletUnlockRequest struct {
+	Passphrase string
+	Seconds    int // how long to stay unlocked, defaults to 5 minutes, at most an hour
+}
+
+// WalletSignRequest ... the body of POST /wallet/:name/sign, a hex payload or a transaction to sign with the
+// key of one of the wallet's addresses, eg {"Index":0,"Payload":"68656c6c6f"}
+type WalletSignRequest struct {
+	Index       int                     // of the address, as listed
+	Payload     string                  `json:",omitempty"` // hex bytes to sign as they are
+	Transaction *blockchain.Transaction `json:",omitempty"` // filled in and signed, ready for POST /tx
+}
+
+// WalletSignature ... what POST /wallet/:name/sign answers with
+type WalletSignature struct {
+	PublicKey   string
+	Signature   string
+	Transaction *blockchain.Transaction `json:",omitempty"` // the signed transaction, if one was sent
+	Hash        string                  `json:",omitempty"` // its hash
+}
+
+// unlockedWallet ... a wallet's phrase, for as long as it's unlocked
+type unlockedWallet struct {
+	wallet *wallet.Wallet
+	until  time.Time
+}
+
+// walletStore ... the node's wallets, one change at a time so handing out addresses doesn't race
+type walletStore struct {
+	mu       sync.Mutex
+	unlocked map[string]unlockedWallet
+}
+
+func newWalletStore() *walletStore {
+	return &walletStore{unlocked: make(map[string]unlockedWallet)}
+}
+
+// walletPath returns where a wallet is kept
+func (n *Node) walletPath(name string) string {
+	return filepath.Join(n.cfg.WalletDir, name+".json")
+}
+
+// loadWallet reads a wallet, responding with why it can't and returning false if it doesn't exist
+func (n *Node) loadWallet(w http.ResponseWriter, r *http.Request, name string) (*wallet.Locked, bool) {
+	if n.cfg.WalletDir == "" {
+		RespondWithJSON(w, r, http.StatusNotFound, "this node doesn't keep wallets")
+		return nil, false
+	}
+	if !walletName.MatchString(name) {
+		RespondWithJSON(w, r, http.StatusNotFound, "no such wallet")
+		return nil, false
+	}
+	locked, err := wallet.LoadLocked(n.walletPath(name))
+	if os.IsNotExist(err) {
+		RespondWithJSON(w, r, http.StatusNotFound, "no such wallet")
+		return nil, false
+	} else if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return locked, true
+}
+
+// unlockedLocked returns a wallet if it's unlocked, n.wallets.mu has to be held
+func (n *Node) unlockedLocked(name string) (*wallet.Wallet, bool) {
+	u, ok := n.wallets.unlocked[name]
+	if ok && time.Now().After(u.until) {
+		delete(n.wallets.unlocked, name)
+		return nil, false
+	}
+	return u.wallet, ok
+}
+
+// walletInfo returns what's listed for a wallet, n.wallets.mu has to be held
+func (n *Node) walletInfo(name string, locked *wallet.Locked) WalletInfo {
+	info := WalletInfo{Name: name, Addresses: locked.Addresses}
+	if _, ok := n.unlockedLocked(name); ok {
+		until := n.wallets.unlocked[name].until
+		info.Unlocked, info.Until = true, &until
+	}
+	if info.Addresses == nil {
+		info.Addresses = []wallet.Address{}
+	}
+	return info
+}
+
+// PostWallet handles the route creating a wallet
+func (n *Node) PostWallet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if n.cfg.WalletDir == "" {
+		RespondWithJSON(w, r, http.StatusNotFound, "this node doesn't keep wallets")
+		return
+	}
+	var req WalletCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid wallet: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if !walletName.MatchString(req.Name) {
+		RespondWithJSON(w, r, http.StatusBadRequest, "a wallet's name has to be 1 to 64 letters, digits, - or _")
+		return
+	}
+	if req.Passphrase == "" {
+		RespondWithJSON(w, r, http.StatusBadRequest, "a wallet needs a passphrase")
+		return
+	}
+	if req.Words == 0 {
+		req.Words = 24
+	}
+
+	n.wallets.mu.Lock()
+	defer n.wallets.mu.Unlock()
+	path := n.walletPath(req.Name)
+	if _, err := os.Stat(path); err == nil {
+		RespondWithJSON(w, r, http.StatusConflict, wallet.ErrWalletExists.Error())
+		return
+	}
+	created, err := wallet.Create(req.Words)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	created.Next = 1 // hand out the first address straight away
+	locked, err := created.Lock(req.Passphrase)
+	if err == nil {
+		err = os.MkdirAll(n.cfg.WalletDir, 0o700)
+	}
+	if err == nil {
+		err = locked.Save(path)
+	}
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, r, http.StatusCreated, WalletCreated{Name: req.Name, Mnemonic: created.Mnemonic, Addresses: locked.Addresses})
+}
+
+// GetWallets handles the route listing the node's wallets
+func (n *Node) GetWallets(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if n.cfg.WalletDir == "" {
+		RespondWithJSON(w, r, http.StatusNotFound, "this node doesn't keep wallets")
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(n.cfg.WalletDir, "*.json"))
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Strings(files)
+
+	n.wallets.mu.Lock()
+	defer n.wallets.mu.Unlock()
+	wallets := []WalletInfo{}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		if locked, err := wallet.LoadLocked(file); err == nil && walletName.MatchString(name) {
+			wallets = append(wallets, n.walletInfo(name, locked))
+		}
+	}
+	RespondWithJSON(w, r, http.StatusOK, wallets)
+}
+
+// GetWallet handles the route listing a wallet's addresses
+func (n *Node) GetWallet(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	n.wallets.mu.Lock()
+	defer n.wallets.mu.Unlock()
+	locked, ok := n.loadWallet(w, r, ps.ByName("name"))
+	if !ok {
+		return
+	}
+	RespondWithJSON(w, r, http.StatusOK, n.walletInfo(ps.ByName("name"), locked))
+}
+
+// PostWalletUnlock handles the route unlocking a wallet with its passphrase for a while
+func (n *Node) PostWalletUnlock(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req WalletUnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	duration := time.Duration(req.Seconds) * time.Second
+	if duration <= 0 {
+		duration = defaultWalletUnlock
+	}
+	if duration > maxWalletUnlock {
+		duration = maxWalletUnlock
+	}
+
+	name := ps.ByName("name")
+	n.wallets.mu.Lock()
+	defer n.wallets.mu.Unlock()
+	locked, ok := n.loadWallet(w, r, name)
+	if !ok {
+		return
+	}
+	unlocked, err := locked.Unlock(req.Passphrase)
+	if err == signer.ErrBadPassphrase {
+		RespondWithJSON(w, r, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	n.wallets.unlocked[name] = unlockedWallet{wallet: unlocked, until: time.Now().Add(duration)}
+	RespondWithJSON(w, r, http.StatusOK, n.walletInfo(name, locked))
+}
+
+// PostWalletLock handles the route locking a wallet again before its time's up
+func (n *Node) PostWalletLock(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := ps.ByName("name")
+	n.wallets.mu.Lock()
+	defer n.wallets.mu.Unlock()
+	locked, ok := n.loadWallet(w, r, name)
+	if !ok {
+		return
+	}
+	delete(n.wallets.unlocked, name)
+	RespondWithJSON(w, r, http.StatusOK, n.walletInfo(name, locked))
+}
+
+// PostWalletAddress handles the route handing out a wallet's next address, it has to be unlocked
+func (n *Node) PostWalletAddress(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := ps.ByName("name")
+	n.wallets.mu.Lock()
+	defer n.wallets.mu.Unlock()
+	locked, ok := n.loadWallet(w, r, name)
+	if !ok {
+		return
+	}
+	unlocked, ok := n.unlockedLocked(name)
+	if !ok {
+		RespondWithJSON(w, r, http.StatusLocked, "unlock the wallet first")
+		return
+	}
+
+	index := len(locked.Addresses)
+	unlocked.Next = index
+	key, err := unlocked.NextKey("")
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	address := wallet.KeyAddress(index, key)
+	locked.Addresses = append(locked.Addresses, address)
+	if err := locked.Save(n.walletPath(name)); err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, r, http.StatusCreated, address)
+}
+
+// PostWalletSign handles the route signing a payload or a transaction with one of a wallet's keys, it has to
+// be unlocked. A transaction gets its defaults, the chain ID, the sender's next nonce if it has none and the
+// key's script filled in before it's signed, and it's from the address unless it says otherwise.
+func (n *Node) PostWalletSign(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req WalletSignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if (req.Payload == "") == (req.Transaction == nil) {
+		RespondWithJSON(w, r, http.StatusBadRequest, "send a Payload or a Transaction to sign")
+		return
+	}
+
+	name := ps.ByName("name")
+	n.wallets.mu.Lock()
+	locked, ok := n.loadWallet(w, r, name)
+	if !ok {
+		n.wallets.mu.Unlock()
+		return
+	}
+	unlocked, ok := n.unlockedLocked(name)
+	n.wallets.mu.Unlock()
+	if !ok {
+		RespondWithJSON(w, r, http.StatusLocked, "unlock the wallet first")
+		return
+	}
+	if req.Index < 0 || req.Index >= len(locked.Addresses) {
+		RespondWithJSON(w, r, http.StatusBadRequest, "the wallet hasn't handed out that address")
+		return
+	}
+	key, err := unlocked.Key("", 0, req.Index)
+	if err != nil {
+		RespondWithJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if req.Transaction == nil {
+		payload, err := hex.DecodeString(req.Payload)
+		if err != nil {
+			RespondWithJSON(w, r, http.StatusBadRequest, "payload isn't hex")
+			return
+		}
+		sig := ed25519.Sign(key.PrivateKey(), payload)
+		RespondWithJSON(w, r, http.StatusOK, WalletSignature{PublicKey: key.PublicKey(), Signature: hex.EncodeToString(sig)})
+		return
+	}
+
+	tx := *req.Transaction
+	tx.Witness = nil // it's replaced, and the chain ID is only filled in for unsigned transactions
+	tx, err = parseTxAddresses(withTxDefaults(tx, n.chain.Params().ChainID))
+	if err != nil {
+		rejectTx(w, r, err)
+		return
+	}
+	if tx.From == "" {
+		tx.From = key.Address()
+	}
+	if tx.From != key.Address() {
+		RespondWithJSON(w, r, http.StatusBadRequest, "the transaction isn't from that address")
+		return
+	}
+	if tx.Nonce == 0 {
+		tx.Nonce = n.nextNonce(tx.From)
+	}
+	tx.Script = key.Script() // before signing, the signature covers it
+	sig := tx.Sign(key.PrivateKey())
+	tx.Witness = []string{sig}
+	setAuditTarget(r.Context(), tx.Hash())
+	RespondWithJSON(w, r, http.StatusOK, WalletSignature{PublicKey: key.PublicKey(), Signature: sig, Transaction: &tx, Hash: tx.Hash()})
+}
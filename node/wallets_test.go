@@ -0,0 +1,45 @@
+package node_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"github.com/glensargent/go-blockchain/node"
+)
+
+func TestWalletRoutesNeedAdminToken(t *testing.T) {
+	if _, err := node.New(node.Config{DataDir: t.TempDir(), WalletDir: t.TempDir()}); err == nil {
+		t.Error("New() started a node keeping wallets without an admin token")
+	}
+
+	n := blockchaintest.NewNode(t, func(cfg *node.Config) {
+		cfg.WalletDir = t.TempDir()
+		cfg.AdminToken = "secret"
+	})
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer guess", http.StatusUnauthorized},
+		{"admin token", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, n.URL()+"/v1/wallet", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			res, err := n.Client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.want {
+				t.Errorf("GET /wallet = %d, want %d", res.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package node
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+)
+
+// webhookAttempts is how many times a delivery is tried before giving up, waiting twice as long each time
+const webhookAttempts = 5
+
+// WebhookPayload ... what's POSTed to webhooks when a block is accepted
+type WebhookPayload struct {
+	Event string // always "block-added" for now
+	Block blockchain.Block
+}
+
+// webhookSet is the URLs blocks get posted to
+type webhookSet struct {
+	mu   sync.Mutex
+	urls map[string]bool
+}
+
+func newWebhookSet(urls []string) *webhookSet {
+	s := &webhookSet{urls: make(map[string]bool)}
+	for _, u := range urls {
+		s.urls[u] = true
+	}
+	return s
+}
+
+func (s *webhookSet) add(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.urls[u] = true
+}
+
+func (s *webhookSet) remove(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.urls, u)
+}
+
+// list returns every registered URL, sorted
+func (s *webhookSet) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	urls := make([]string, 0, len(s.urls))
+	for u := range s.urls {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// webhookEvent posts every accepted block to the registered webhooks
+func (n *Node) webhookEvent(e events.Event) {
+	added, ok := e.(events.BlockAdded)
+	if !ok {
+		return
+	}
+	body, err := json.Marshal(WebhookPayload{Event: e.Name(), Block: added.Block})
+	if err != nil {
+		return
+	}
+	for _, u := range n.webhooks.list() {
+		go n.deliverWebhook(u, body)
+	}
+}
+
+// deliverWebhook POSTs a payload to a webhook, retrying with exponential backoff until it gets a 2xx.
+// If WebhookSecret is set the body is signed, "X-Signature-256: sha256=<hex HMAC-SHA256 of the body>".
+func (n *Node) deliverWebhook(u string, body []byte) {
+	client := &http.Client{Timeout: 10 * time.Second} // webhooks aren't peers, so no handshake
+	backoff := n.cfg.WebhookBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := postWebhook(client, u, body, n.cfg.WebhookSecret)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			n.logger.Printf("webhook %s failed after %d attempts: %v", u, attempt, err)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-n.done:
+			return
+		}
+	}
+}
+
+// postWebhook makes a single delivery attempt
+func postWebhook(client *http.Client, u string, body []byte, secret string) error {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", events.NameBlockAdded)
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("got status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// WebhookRequest ... the body of POST /admin/webhooks, eg {"URL":"https://example.com/hook"}
+type WebhookRequest struct {
+	URL string
+}
+
+// GetWebhooks handles the admin route to list webhook URLs
+func (n *Node) GetWebhooks(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	RespondWithJSON(w, r, http.StatusOK, n.webhooks.list())
+}
+
+// PostWebhook handles the admin route to register a webhook
+func (n *Node) PostWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithJSON(w, r, http.StatusBadRequest, "invalid webhook: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		RespondWithJSON(w, r, http.StatusBadRequest, "webhook URL has to be an absolute http or https URL")
+		return
+	}
+	n.webhooks.add(req.URL)
+	RespondWithJSON(w, r, http.StatusOK, n.webhooks.list())
+}
+
+// DeleteWebhook handles the admin route to remove a webhook, eg /admin/webhooks?url=https://example.com/hook
+func (n *Node) DeleteWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	n.webhooks.remove(r.URL.Query().Get("url"))
+	RespondWithJSON(w, r, http.StatusOK, n.webhooks.list())
+}
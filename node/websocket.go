@@ -0,0 +1,79 @@
+package node
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/glensargent/go-blockchain/events"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/websocket"
+)
+
+// GET /events/ws streams the same events as /events over a WebSocket, for clients that would rather not use
+// server-sent events. Each event is a text message, {"Event":"block-added","Data":{...}} with Data what /events
+// sends as the event's data, and ?types= filters them the same way. The stream's one way, anything a client
+// sends is read and dropped, and a client closing the socket ends it.
+
+// wsWriteTimeout is how long sending an event to a WebSocket client can take before it's dropped
+const wsWriteTimeout = 10 * time.Second
+
+// WebSocketEvent ... a message on /events/ws
+type WebSocketEvent struct {
+	Event string       // the event's name, as on /events
+	Data  events.Event // the event itself
+}
+
+// GetEventsWebSocket handles the route streaming chain events over a WebSocket, eg /events/ws?types=block-added
+func (n *Node) GetEventsWebSocket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var names []string // empty for every event
+	if t := r.URL.Query().Get("types"); t != "" {
+		for _, name := range strings.Split(t, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+
+	for { // the socket takes over the connection, which the writers wrapping the response can't hand over
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	server := websocket.Server{
+		Handshake: func(*websocket.Config, *http.Request) error { return nil }, // any origin, the events are public
+		Handler:   func(ws *websocket.Conn) { n.streamEvents(ws, names) },
+	}
+	server.ServeHTTP(w, r)
+}
+
+// streamEvents sends events with the names to a WebSocket client until it goes away or the node stops
+func (n *Node) streamEvents(ws *websocket.Conn, names []string) {
+	defer ws.Close()
+	ws.SetDeadline(time.Time{}) // streams outlive the server's timeouts
+
+	gone := make(chan struct{})
+	go func() { // reading is how a close shows up
+		defer close(gone)
+		var discard []byte
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	sub := n.bus.Subscribe(64, names...)
+	defer sub.Close()
+
+	for {
+		select {
+		case e := <-sub.C:
+			ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := websocket.JSON.Send(ws, WebSocketEvent{Event: e.Name(), Data: e}); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-n.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package node_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/blockchaintest"
+	"golang.org/x/net/websocket"
+)
+
+// eventFrame ... a message from /events/ws
+type eventFrame struct {
+	Event string
+	Data  struct {
+		Block  blockchain.Block
+		Origin string
+	}
+}
+
+func TestEventsWebSocket(t *testing.T) {
+	n := blockchaintest.NewNode(t)
+	n.MineBlocks(t, 1)
+
+	ws, err := websocket.Dial(strings.Replace(n.URL(), "http", "ws", 1)+"/v1/events/ws?types=block-added", "", n.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	frames := make(chan eventFrame, 1)
+	go func() {
+		var msg eventFrame
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Error(err)
+		}
+		frames <- msg
+	}()
+
+	// the node subscribes some time after the dial returns, so keep making events till the first one arrives
+	mined := make(map[string]bool)
+	for {
+		n.SubmitTx(t, n.Sign(t, n.Key, blockchain.Transaction{To: blockchaintest.Address(blockchaintest.NewKey(t)), Amount: 1})) // filtered out
+		mined[n.MineBlock(t, 7).Hash] = true
+
+		select {
+		case msg := <-frames:
+			if msg.Event != "block-added" || !mined[msg.Data.Block.Hash] || msg.Data.Origin != "local" {
+				t.Errorf("got %s of block %d from %s, want block-added of a block mined here from local", msg.Event, msg.Data.Block.Index, msg.Data.Origin)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
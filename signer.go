@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/glensargent/go-blockchain/signer"
+)
+
+// runSigner handles the `signer` subcommands
+func runSigner(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: signer <create|serve> [flags]")
+	}
+
+	switch args[0] {
+	case "create":
+		return runSignerCreate(args[1:])
+	case "serve":
+		return runSignerServe(args[1:])
+	default:
+		return fmt.Errorf("unknown signer command %q", args[0])
+	}
+}
+
+// keystoreFlags adds the flags every signer command has, the keystore file and its passphrase
+func keystoreFlags(fs *flag.FlagSet) (file, passphrase *string) {
+	file = fs.String("keystore", envOr("SIGNER_KEYSTORE", "signer.json"), "the keystore file")
+	passphrase = fs.String("passphrase", os.Getenv("SIGNER_PASSPHRASE"), "the keystore's passphrase")
+	return file, passphrase
+}
+
+// runSignerCreate makes a keystore with a new key, printing the validator ID it signs as
+func runSignerCreate(args []string) error {
+	fs := flag.NewFlagSet("signer create", flag.ContinueOnError)
+	file, passphrase := keystoreFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *passphrase == "" {
+		return errors.New("signer create: a keystore needs a --passphrase")
+	}
+	s, err := signer.CreateKeystore(*file, *passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s, its validator ID is %s\n", *file, hex.EncodeToString(s.PublicKey()))
+	return nil
+}
+
+// runSignerServe serves a keystore's key for nodes to sign with remotely
+func runSignerServe(args []string) error {
+	fs := flag.NewFlagSet("signer serve", flag.ContinueOnError)
+	file, passphrase := keystoreFlags(fs)
+	addr := fs.String("addr", envOr("SIGNER_ADDR", "127.0.0.1:7000"), "where to listen, keep it where only the node can reach")
+	token := fs.String("token", os.Getenv("SIGNER_TOKEN"), "the bearer token nodes have to send")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, err := signer.LoadKeystore(*file, *passphrase)
+	if err != nil {
+		return err
+	}
+	log.Printf("signing as %s on %s", hex.EncodeToString(s.PublicKey()), *addr)
+	return http.ListenAndServe(*addr, signer.Handler(s, *token))
+}
@@ -0,0 +1,147 @@
+package signer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// A keystore is a JSON file holding one key's seed, encrypted with AES-GCM under a key scrypt derives from a
+// passphrase, so a copy of the file alone doesn't give the key away. Seal and Open do the encrypting, and
+// anything else kept under a passphrase, like the node's wallets, uses them too.
+
+// errors opening a keystore
+var (
+	ErrKeystoreExists = errors.New("there's already a keystore there, move it out of the way first")
+	ErrBadPassphrase  = errors.New("wrong passphrase for the keystore")
+)
+
+// scrypt's cost, what go-ethereum's keystores use by default
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Sealed ... data encrypted under a passphrase, everything hex
+type Sealed struct {
+	Salt       string
+	Nonce      string
+	Ciphertext string
+}
+
+// keystoreFile ... what a keystore file holds
+type keystoreFile struct {
+	PublicKey string // so it can be told apart from others without the passphrase
+	Sealed           // the key's seed
+}
+
+// Seal encrypts data under a passphrase
+func Seal(data []byte, passphrase string) (Sealed, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return Sealed{}, err
+	}
+	aead, err := keystoreCipher(passphrase, salt)
+	if err != nil {
+		return Sealed{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return Sealed{}, err
+	}
+	return Sealed{
+		Salt:       hex.EncodeToString(salt),
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, data, nil)),
+	}, nil
+}
+
+// Open decrypts sealed data, returning ErrBadPassphrase if it's the wrong passphrase
+func (s Sealed) Open(passphrase string) ([]byte, error) {
+	salt, err1 := hex.DecodeString(s.Salt)
+	nonce, err2 := hex.DecodeString(s.Nonce)
+	ciphertext, err3 := hex.DecodeString(s.Ciphertext)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, errors.New("signer: sealed data isn't hex")
+	}
+	aead, err := keystoreCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("signer: sealed data has the wrong size nonce")
+	}
+	data, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return data, nil
+}
+
+// CreateKeystore generates a key and writes it to a new keystore file, encrypted with passphrase
+func CreateKeystore(path, passphrase string) (*Local, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, ErrKeystoreExists
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := Seal(key.Seed(), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(keystoreFile{PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)), Sealed: sealed}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, err
+	}
+	return NewLocal(key), nil
+}
+
+// LoadKeystore decrypts the key in a keystore file
+func LoadKeystore(path, passphrase string) (*Local, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	bad := errors.New("signer: " + path + " isn't a keystore file")
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, bad
+	}
+	seed, err := file.Open(passphrase)
+	if err == ErrBadPassphrase {
+		return nil, err
+	} else if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, bad
+	}
+	l := NewLocal(ed25519.NewKeyFromSeed(seed))
+	if hex.EncodeToString(l.PublicKey()) != file.PublicKey {
+		return nil, bad
+	}
+	return l, nil
+}
+
+// keystoreCipher returns the AES-GCM cipher for a passphrase and salt
+func keystoreCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,158 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// A remote signer speaks JSON over HTTP:
+//
+//	GET  /public-key  -> {"PublicKey":"<hex>"}
+//	POST /sign        {"Message":"<hex>"} -> {"Signature":"<hex>"}
+//
+// with "Authorization: Bearer <token>" on both if it has a token.
+
+// PublicKeyResponse ... the body of GET /public-key
+type PublicKeyResponse struct {
+	PublicKey string
+}
+
+// SignRequest ... the body of POST /sign
+type SignRequest struct {
+	Message string
+}
+
+// SignResponse ... what POST /sign answers with
+type SignResponse struct {
+	Signature string
+}
+
+// Remote ... a signer in another process, asked over HTTP
+type Remote struct {
+	url    string
+	token  string
+	client *http.Client
+	public ed25519.PublicKey // fetched once, a signer doesn't change its key
+}
+
+// NewRemote connects to the signer at url, fetching its public key
+func NewRemote(url, token string) (*Remote, error) {
+	r := &Remote{url: strings.TrimRight(url, "/"), token: token, client: &http.Client{Timeout: 10 * time.Second}}
+	var resp PublicKeyResponse
+	if err := r.call(http.MethodGet, "/public-key", nil, &resp); err != nil {
+		return nil, err
+	}
+	public, err := hex.DecodeString(resp.PublicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("signer: %s sent %q, which isn't a public key", r.url, resp.PublicKey)
+	}
+	r.public = public
+	return r, nil
+}
+
+// PublicKey returns the remote signer's public key
+func (r *Remote) PublicKey() ed25519.PublicKey {
+	return r.public
+}
+
+// Sign has the remote signer sign a message, checking the signature before handing it back
+func (r *Remote) Sign(message []byte) ([]byte, error) {
+	var resp SignResponse
+	if err := r.call(http.MethodPost, "/sign", SignRequest{Message: hex.EncodeToString(message)}, &resp); err != nil {
+		return nil, err
+	}
+	sig, err := hex.DecodeString(resp.Signature)
+	if err != nil || !ed25519.Verify(r.public, message, sig) {
+		return nil, ErrBadSignature
+	}
+	return sig, nil
+}
+
+// call makes a request to the signer and decodes its answer into out
+func (r *Remote) call(method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, r.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("signer: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("signer: %s%s answered %s", r.url, path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Handler serves a signer over HTTP for Remote to call, needing token if it isn't empty. It signs whatever
+// it's asked to, so it should only be reachable by the node using it.
+func Handler(s blockchain.Signer, token string) http.Handler {
+	mux := http.NewServeMux()
+	authorized := func(r *http.Request) bool {
+		return token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1
+	}
+	respond := func(w http.ResponseWriter, status int, body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+
+	mux.HandleFunc("/public-key", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodGet:
+			respond(w, http.StatusMethodNotAllowed, "GET only")
+		case !authorized(r):
+			respond(w, http.StatusUnauthorized, "unauthorized")
+		default:
+			respond(w, http.StatusOK, PublicKeyResponse{PublicKey: hex.EncodeToString(s.PublicKey())})
+		}
+	})
+	mux.HandleFunc("/sign", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			respond(w, http.StatusMethodNotAllowed, "POST only")
+			return
+		}
+		if !authorized(r) {
+			respond(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		var req SignRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			respond(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		message, err := hex.DecodeString(req.Message)
+		if err != nil {
+			respond(w, http.StatusBadRequest, "message isn't hex")
+			return
+		}
+		sig, err := s.Sign(message)
+		if err != nil {
+			respond(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respond(w, http.StatusOK, SignResponse{Signature: hex.EncodeToString(sig)})
+	})
+	return mux
+}
@@ -0,0 +1,35 @@
+package signer
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+// A node's validator duties, voting, sealing blocks and voting on the validator set, need signatures from its
+// validator key, but the key itself doesn't have to be in the node's memory. Anything that's a
+// blockchain.Signer can do the signing: Local holds the key in the process, loaded from an encrypted keystore
+// file, and Remote asks another process over HTTP, which can be a signer serving Handler in front of a
+// keystore, a hardware wallet or a signing service, as long as it speaks the same two routes.
+
+// ErrBadSignature is returned when a signer hands back a signature that isn't from its key
+var ErrBadSignature = errors.New("signer returned a signature that doesn't check out")
+
+// Local ... a signer holding its key in memory
+type Local struct {
+	key ed25519.PrivateKey
+}
+
+// NewLocal returns a signer for a key
+func NewLocal(key ed25519.PrivateKey) *Local {
+	return &Local{key: key}
+}
+
+// PublicKey returns the public half of the key
+func (l *Local) PublicKey() ed25519.PublicKey {
+	return l.key.Public().(ed25519.PublicKey)
+}
+
+// Sign signs a message with the key, it never fails
+func (l *Local) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(l.key, message), nil
+}
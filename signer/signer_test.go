@@ -0,0 +1,100 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// liar ... a signer that signs with a different key than the one it claims
+type liar struct {
+	claimed, signs *Local
+}
+
+func (l liar) PublicKey() ed25519.PublicKey        { return l.claimed.PublicKey() }
+func (l liar) Sign(message []byte) ([]byte, error) { return l.signs.Sign(message) }
+
+// testLocal returns a signer with a key made from a seed of n's
+func testLocal(n byte) *Local {
+	return NewLocal(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{n}, ed25519.SeedSize)))
+}
+
+func TestRemote(t *testing.T) {
+	honest := testLocal(1)
+	message := []byte("block hash")
+
+	tests := []struct {
+		name        string
+		served      blockchain.Signer
+		serverToken string
+		clientToken string
+		wantConnect bool
+		wantSignErr error
+	}{
+		{"no token", honest, "", "", true, nil},
+		{"matching tokens", honest, "secret", "secret", true, nil},
+		{"wrong token", honest, "secret", "guess", false, nil},
+		{"no token sent", honest, "secret", "", false, nil},
+		{"signs with another key", liar{claimed: honest, signs: testLocal(2)}, "", "", true, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(Handler(tt.served, tt.serverToken))
+			defer server.Close()
+
+			remote, err := NewRemote(server.URL, tt.clientToken)
+			if (err == nil) != tt.wantConnect {
+				t.Fatalf("NewRemote() error = %v, want connected %v", err, tt.wantConnect)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(remote.PublicKey(), honest.PublicKey()) {
+				t.Errorf("PublicKey() = %x, want %x", remote.PublicKey(), honest.PublicKey())
+			}
+			sig, err := remote.Sign(message)
+			if err != tt.wantSignErr {
+				t.Fatalf("Sign() error = %v, want %v", err, tt.wantSignErr)
+			}
+			if err == nil && !ed25519.Verify(honest.PublicKey(), message, sig) {
+				t.Error("Sign() returned a signature that doesn't verify")
+			}
+		})
+	}
+}
+
+func TestKeystore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "validator.json")
+	created, err := CreateKeystore(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateKeystore(path, "correct horse"); err != ErrKeystoreExists {
+		t.Errorf("CreateKeystore() over an existing one = %v, want %v", err, ErrKeystoreExists)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    error
+	}{
+		{"right passphrase", "correct horse", nil},
+		{"wrong passphrase", "battery staple", ErrBadPassphrase},
+		{"no passphrase", "", ErrBadPassphrase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := LoadKeystore(path, tt.passphrase)
+			if err != tt.wantErr {
+				t.Fatalf("LoadKeystore() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(loaded.PublicKey(), created.PublicKey()) {
+				t.Errorf("LoadKeystore() key %x, want %x", loaded.PublicKey(), created.PublicKey())
+			}
+		})
+	}
+}
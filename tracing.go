@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// setupTracing exports the node's spans over OTLP/HTTP when an OTLP endpoint is configured, using the
+// standard OTEL_EXPORTER_OTLP_* env vars (and OTEL_SERVICE_NAME). Without one, spans are dropped.
+func setupTracing() error {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		return err
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{}) // pick up traceparent headers from callers
+	return nil
+}
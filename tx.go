@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/node"
+	"github.com/glensargent/go-blockchain/wallet"
+)
+
+// runTx handles the `tx` subcommands
+func runTx(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: tx <sign|send|decode> [flags]")
+	}
+
+	switch args[0] {
+	case "sign":
+		return runTxSign(args[1:])
+	case "send":
+		return runTxSend(args[1:])
+	case "decode":
+		return runTxDecode(args[1:])
+	default:
+		return fmt.Errorf("unknown tx command %q", args[0])
+	}
+}
+
+// nodeFlag adds the flag for the node a command talks to
+func nodeFlag(fs *flag.FlagSet) *string {
+	return fs.String("node", envOr("NODE_URL", "http://localhost:"+os.Getenv("ADDR")), "base URL of the node")
+}
+
+// runTxSign signs a transaction with a wallet key and prints it raw, ready for `tx send` or POST /tx/raw. With
+// --offline it never touches the network, so it can run on a machine that's never online.
+func runTxSign(args []string) error {
+	fs := flag.NewFlagSet("tx sign", flag.ContinueOnError)
+	file, passphrase := walletFlags(fs)
+	in := fs.String("in", "-", "the transaction to sign as JSON, like the body of POST /tx, - for stdin")
+	account := fs.Int("account", 0, "the BIP44 account of the key to sign with")
+	index := fs.Int("index", 0, "the index of the key to sign with")
+	offline := fs.Bool("offline", false, "don't ask a node for the chain ID and nonce, they have to be in the transaction or flags")
+	chainID := fs.String("chain-id", "", "the network the transaction is for, eg mainnet")
+	nonce := fs.Int("nonce", 0, "the sender's next nonce")
+	nodeURL := nodeFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	var tx blockchain.Transaction
+	if err := json.Unmarshal(data, &tx); err != nil {
+		return fmt.Errorf("tx sign: reading the transaction: %v", err)
+	}
+	w, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	key, err := w.Key(*passphrase, *account, *index)
+	if err != nil {
+		return err
+	}
+
+	tx.Witness = nil // it's replaced
+	if tx.Class == "" {
+		tx.Class = blockchain.ClassUser
+	}
+	if tx.Timestamp == 0 {
+		tx.Timestamp = time.Now().UnixNano()
+	}
+	if tx.From, err = blockchain.ParseAddress(tx.From); err != nil {
+		return err
+	}
+	if tx.To, err = blockchain.ParseAddress(tx.To); err != nil {
+		return err
+	}
+	if tx.From == "" {
+		tx.From = key.Address()
+	}
+	if tx.From != key.Address() {
+		return fmt.Errorf("tx sign: the transaction is from %s, key %d is %s", tx.From, *index, key.Address())
+	}
+	if *chainID != "" {
+		tx.ChainID = *chainID
+	}
+	if *nonce > 0 {
+		tx.Nonce = *nonce
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	if tx.ChainID == "" {
+		if *offline {
+			return errors.New("tx sign: offline, so the transaction needs a ChainID or --chain-id")
+		}
+		head, err := node.FetchHead(client, *nodeURL)
+		if err != nil {
+			return err
+		}
+		tx.ChainID = head.ChainID
+	}
+	if tx.Nonce == 0 {
+		if *offline {
+			return errors.New("tx sign: offline, so the transaction needs a Nonce or --nonce")
+		}
+		last, err := node.FetchNonce(client, *nodeURL, tx.From)
+		if err != nil {
+			return err
+		}
+		tx.Nonce = last + 1 // transactions still in the mempool aren't counted, pass --nonce past them
+	}
+
+	tx.Script = key.Script() // before signing, the signature covers it
+	tx.Witness = []string{tx.Sign(key.PrivateKey())}
+	fmt.Fprintf(os.Stderr, "signed %s, %d from %s to %s, nonce %d on %s\n", tx.Hash(), tx.Amount, tx.From, tx.To, tx.Nonce, tx.ChainID)
+	fmt.Println(hex.EncodeToString(tx.Encode()))
+	return nil
+}
+
+// runTxSend sends a raw transaction, signed by `tx sign`, to a node's mempool
+func runTxSend(args []string) error {
+	fs := flag.NewFlagSet("tx send", flag.ContinueOnError)
+	in := fs.String("in", "-", "the raw transaction, - for stdin")
+	nodeURL := nodeFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	hash, err := node.SubmitRawTx(&http.Client{Timeout: 10 * time.Second}, *nodeURL, strings.TrimSpace(string(data)))
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+// runTxDecode prints a raw transaction as JSON, so it can be checked before it's sent
+func runTxDecode(args []string) error {
+	fs := flag.NewFlagSet("tx decode", flag.ContinueOnError)
+	in := fs.String("in", "-", "the raw transaction, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return errors.New("tx decode: the raw transaction isn't hex")
+	}
+	tx, err := blockchain.DecodeTransaction(raw)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s\nhash %s\n", out, tx.Hash())
+	return nil
+}
+
+// readInput reads a file, or stdin for -
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/glensargent/go-blockchain/wallet"
+)
+
+// runWallet handles the `wallet` subcommands
+func runWallet(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: wallet <create|restore|addresses|new-address|vanity> [flags]")
+	}
+
+	switch args[0] {
+	case "create":
+		return runWalletCreate(args[1:])
+	case "restore":
+		return runWalletRestore(args[1:])
+	case "addresses":
+		return runWalletAddresses(args[1:])
+	case "new-address":
+		return runWalletNewAddress(args[1:])
+	case "vanity":
+		return runWalletVanity(args[1:])
+	default:
+		return fmt.Errorf("unknown wallet command %q", args[0])
+	}
+}
+
+// walletFlags adds the flags every wallet command has, the wallet file and the passphrase
+func walletFlags(fs *flag.FlagSet) (file, passphrase *string) {
+	file = fs.String("file", envOr("WALLET_FILE", "wallet.json"), "the wallet file")
+	passphrase = fs.String("passphrase", os.Getenv("WALLET_PASSPHRASE"), "optional BIP39 passphrase, a different one gives different addresses")
+	return file, passphrase
+}
+
+// runWalletCreate makes a wallet with a new backup phrase
+func runWalletCreate(args []string) error {
+	fs := flag.NewFlagSet("wallet create", flag.ContinueOnError)
+	file, passphrase := walletFlags(fs)
+	words := fs.Int("words", 24, "how many words the backup phrase has, 12, 15, 18, 21 or 24")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*file); err == nil {
+		return wallet.ErrWalletExists
+	}
+
+	w, err := wallet.Create(*words)
+	if err != nil {
+		return err
+	}
+	key, err := w.NextKey(*passphrase)
+	if err != nil {
+		return err
+	}
+	if err := w.Save(*file); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s, write this backup phrase down and keep it somewhere safe, it's the only way to recover the wallet:\n\n%s\n\n", *file, w.Mnemonic)
+	printKey(0, key)
+	return nil
+}
+
+// runWalletRestore makes a wallet from a backup phrase, read from --mnemonic or stdin
+func runWalletRestore(args []string) error {
+	fs := flag.NewFlagSet("wallet restore", flag.ContinueOnError)
+	file, passphrase := walletFlags(fs)
+	mnemonic := fs.String("mnemonic", "", "the backup phrase, read from stdin if it's not given")
+	count := fs.Int("count", 20, "how many addresses to recover")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *count < 1 {
+		return errors.New("wallet restore: --count has to be at least 1")
+	}
+	if _, err := os.Stat(*file); err == nil {
+		return wallet.ErrWalletExists
+	}
+	if *mnemonic == "" { // keeps the phrase out of the shell history
+		fmt.Fprint(os.Stderr, "backup phrase: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		*mnemonic = strings.TrimSpace(line)
+	}
+
+	w, err := wallet.Restore(*mnemonic, *count)
+	if err != nil {
+		return err
+	}
+	if err := w.Save(*file); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s with %d addresses:\n", *file, *count)
+	return printAddresses(w, *passphrase, 0, *count)
+}
+
+// runWalletAddresses lists the addresses a wallet has handed out
+func runWalletAddresses(args []string) error {
+	fs := flag.NewFlagSet("wallet addresses", flag.ContinueOnError)
+	file, passphrase := walletFlags(fs)
+	account := fs.Int("account", 0, "the BIP44 account to list")
+	count := fs.Int("count", 0, "how many addresses to list, defaults to the ones handed out")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	w, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	if *count <= 0 {
+		*count = w.Next
+	}
+	return printAddresses(w, *passphrase, *account, *count)
+}
+
+// runWalletNewAddress hands out the wallet's next address
+func runWalletNewAddress(args []string) error {
+	fs := flag.NewFlagSet("wallet new-address", flag.ContinueOnError)
+	file, passphrase := walletFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	w, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	index := w.Next
+	key, err := w.NextKey(*passphrase)
+	if err != nil {
+		return err
+	}
+	if err := w.Save(*file); err != nil {
+		return err
+	}
+	printKey(index, key)
+	return nil
+}
+
+// runWalletVanity searches for a key whose address starts with a prefix, reporting progress every second
+func runWalletVanity(args []string) error {
+	fs := flag.NewFlagSet("wallet vanity", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "what the encoded address has to start with, eg 2hDemo")
+	kind := fs.String("kind", "script", "script for a wallet address, key for a node or validator ID")
+	threads := fs.Int("threads", runtime.NumCPU(), "goroutines generating keys")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	version := blockchain.AddressVersionScript
+	switch *kind {
+	case "script":
+	case "key":
+		version = blockchain.AddressVersionKey
+	default:
+		return fmt.Errorf("wallet vanity: --kind has to be script or key, not %q", *kind)
+	}
+	odds, err := wallet.VanityOdds(*prefix, version)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "searching for %s... on %d threads, 1 in %.0f keys match\n", *prefix, *threads, odds)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var attempts atomic.Uint64
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		start := time.Now()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				tried := attempts.Load()
+				rate := float64(tried) / time.Since(start).Seconds()
+				if rate == 0 {
+					continue
+				}
+				wait := time.Duration(odds / rate * float64(time.Second)) // it's luck, on average it's always this long to go
+				fmt.Fprintf(os.Stderr, "tried %d keys, %.0f/s, about %s per match\n", tried, rate, wait.Round(time.Second))
+			}
+		}
+	}()
+
+	key, err := wallet.SearchVanity(ctx, *prefix, version, *threads, &attempts)
+	if err == context.Canceled {
+		return fmt.Errorf("stopped after %d keys", attempts.Load())
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("found after %d keys:\naddress %s (%s)\npublic key %s\nseed %s\n", attempts.Load(), key.Address, key.Hex, key.PublicKey, key.Seed)
+	return nil
+}
+
+func printAddresses(w *wallet.Wallet, passphrase string, account, count int) error {
+	for i := 0; i < count; i++ {
+		key, err := w.Key(passphrase, account, i)
+		if err != nil {
+			return err
+		}
+		printKey(i, key)
+	}
+	return nil
+}
+
+func printKey(index int, key wallet.Key) {
+	fmt.Printf("%d  %s  address %s (%s)  public key %s\n", index, key.Path, key.EncodedAddress(), key.Address(), key.PublicKey())
+}
+
+// envOr returns an env var, or fallback if it's not set
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
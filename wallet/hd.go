@@ -0,0 +1,135 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// A wallet is one BIP39 mnemonic, the backup phrase, which every key it ever hands out is derived from, so
+// writing the phrase down once backs up all of a user's addresses. The phrase and an optional passphrase make
+// a seed, and keys are derived from the seed down a BIP44 path, m/44'/CoinType'/account'/0'/index'. Keys on
+// the chain are ed25519, which can't do BIP32's public derivation, so derivation follows SLIP-0010 and every
+// level is hardened. An address is the script address of a CHECKSIG on the key, see blockchain/script.go.
+
+// CoinType is the BIP44 coin type of the chain's keys
+const CoinType = 9000
+
+// hardened is added to an index to derive a hardened child, the only kind ed25519 has
+const hardened = 1 << 31
+
+// the reasons a mnemonic or path isn't any good
+var (
+	ErrBadMnemonic = errors.New("mnemonic isn't a valid BIP39 phrase, check the words and their order")
+	ErrBadPath     = errors.New("derivation path has to look like m/44'/9000'/0'/0'/0', every level hardened")
+)
+
+// NewMnemonic returns a new random backup phrase of 12, 15, 18, 21 or 24 words
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", fmt.Errorf("a mnemonic has 12, 15, 18, 21 or 24 words, not %d", words)
+	}
+	entropy, err := bip39.NewEntropy(words / 3 * 32) // every 3 words carry 32 bits and a checksum bit
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// Seed returns the seed a mnemonic and passphrase make, checking the mnemonic's words and checksum first
+func Seed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")         // however it was typed in
+	if _, err := bip39.EntropyFromMnemonic(mnemonic); err != nil { // checks the checksum as well as the words
+		return nil, ErrBadMnemonic
+	}
+	return bip39.NewSeed(mnemonic, passphrase), nil
+}
+
+// Key ... a key somewhere down the tree, and the chain code its children are derived with
+type Key struct {
+	Path      string
+	secret    []byte // the ed25519 seed of the key
+	chainCode []byte
+}
+
+// NewMasterKey returns the root of the tree a seed makes, m
+func NewMasterKey(seed []byte) Key {
+	secret, chainCode := hmacSHA512([]byte("ed25519 seed"), seed)
+	return Key{Path: "m", secret: secret, chainCode: chainCode}
+}
+
+// Child returns the key's hardened child at index, which is given without the hardened offset
+func (k Key) Child(index uint32) Key {
+	data := make([]byte, 0, 1+32+4)
+	data = append(data, 0)
+	data = append(data, k.secret...)
+	data = binary.BigEndian.AppendUint32(data, index|hardened)
+	secret, chainCode := hmacSHA512(k.chainCode, data)
+	return Key{Path: k.Path + "/" + strconv.FormatUint(uint64(index), 10) + "'", secret: secret, chainCode: chainCode}
+}
+
+// Derive returns the key at a path from the master key, eg m/44'/9000'/0'/0'/0'
+func (k Key) Derive(path string) (Key, error) {
+	levels := strings.Split(path, "/")
+	if levels[0] != "m" || k.Path != "m" {
+		return Key{}, ErrBadPath
+	}
+	for _, level := range levels[1:] {
+		if !strings.HasSuffix(level, "'") && !strings.HasSuffix(level, "h") {
+			return Key{}, ErrBadPath
+		}
+		index, err := strconv.ParseUint(level[:len(level)-1], 10, 31)
+		if err != nil {
+			return Key{}, ErrBadPath
+		}
+		k = k.Child(uint32(index))
+	}
+	return k, nil
+}
+
+// PrivateKey returns the key's ed25519 private key, for signing transactions with Transaction.Sign
+func (k Key) PrivateKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(k.secret)
+}
+
+// PublicKey returns the key's hex ed25519 public key
+func (k Key) PublicKey() string {
+	return hex.EncodeToString(k.PrivateKey().Public().(ed25519.PublicKey))
+}
+
+// Script returns the script that locks an address to the key
+func (k Key) Script() string {
+	return k.PublicKey() + " CHECKSIG"
+}
+
+// Address returns the address coins sent to the key go to, spent with Script and a signature in the witness
+func (k Key) Address() string {
+	return blockchain.ScriptAddress(k.Script())
+}
+
+// EncodedAddress returns the key's address base58check encoded, the form to hand out
+func (k Key) EncodedAddress() string {
+	encoded, _ := blockchain.EncodeAddress(blockchain.AddressVersionScript, k.Address())
+	return encoded
+}
+
+// Path returns the BIP44 path of an account's index'th address
+func Path(account, index int) string {
+	return fmt.Sprintf("m/44'/%d'/%d'/0'/%d'", CoinType, account, index)
+}
+
+func hmacSHA512(key, data []byte) (left, right []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
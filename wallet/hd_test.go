@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSeed(t *testing.T) {
+	tests := []struct {
+		name       string
+		mnemonic   string
+		passphrase string
+		want       string
+		wantErr    error
+	}{
+		// from the BIP39 test vectors
+		{"12 words", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", nil},
+		{"typed with extra spaces", "  abandon abandon abandon abandon abandon abandon\tabandon abandon abandon abandon abandon   about ", "TREZOR",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", nil},
+		{"bad checksum", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", "", "", ErrBadMnemonic},
+		{"not a word", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abut", "", "", ErrBadMnemonic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seed, err := Seed(tt.mnemonic, tt.passphrase)
+			if got := hex.EncodeToString(seed); got != tt.want || err != tt.wantErr {
+				t.Errorf("Seed() = %s, %v, want %s, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDerive(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f") // SLIP-0010 ed25519 test vector 1
+	master := NewMasterKey(seed)
+
+	tests := []struct {
+		path      string
+		secret    string
+		chainCode string
+		public    string
+		wantErr   error
+	}{
+		{"m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb",
+			"a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed", nil},
+		{"m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69",
+			"8c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c", nil},
+		{"m/0h/1h", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", "a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14",
+			"1932a5270f335bed617d5b935c80aedb1a35bd9fc1e31acafd5372c30f5c1187", nil},
+		{"m/0", "", "", "", ErrBadPath},
+		{"0'/1'", "", "", "", ErrBadPath},
+		{"m/x'", "", "", "", ErrBadPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			key, err := master.Derive(tt.path)
+			if err != tt.wantErr {
+				t.Fatalf("Derive() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if secret, chainCode := hex.EncodeToString(key.secret), hex.EncodeToString(key.chainCode); secret != tt.secret || chainCode != tt.chainCode {
+				t.Errorf("Derive() = secret %s, chain code %s, want %s, %s", secret, chainCode, tt.secret, tt.chainCode)
+			}
+			if got := key.PublicKey(); got != tt.public {
+				t.Errorf("PublicKey() = %s, want %s", got, tt.public)
+			}
+		})
+	}
+}
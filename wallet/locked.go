@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/glensargent/go-blockchain/signer"
+)
+
+// A wallet file has its backup phrase in the clear, fine on a user's own machine but not on a node serving
+// several users. A Locked wallet keeps the phrase sealed under a passphrase instead, see signer.Seal, and the
+// addresses it's handed out in the clear so they can be listed without it.
+
+// Address ... an address a wallet has handed out, and its key's public half
+type Address struct {
+	Index     int
+	Path      string
+	Address   string // encoded
+	Hex       string // the address as the chain keys it
+	PublicKey string
+}
+
+// Locked ... a wallet with its backup phrase encrypted
+type Locked struct {
+	signer.Sealed           // the backup phrase
+	Addresses     []Address // on account 0, in the order they were handed out
+}
+
+// KeyAddress returns what a locked wallet lists for a key
+func KeyAddress(index int, key Key) Address {
+	return Address{Index: index, Path: key.Path, Address: key.EncodedAddress(), Hex: key.Address(), PublicKey: key.PublicKey()}
+}
+
+// Lock returns the wallet with its phrase sealed under passphrase. The phrase's BIP39 passphrase isn't used,
+// a locked wallet's addresses are always the phrase's own.
+func (w *Wallet) Lock(passphrase string) (*Locked, error) {
+	sealed, err := signer.Seal([]byte(w.Mnemonic), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	l := &Locked{Sealed: sealed}
+	for i := 0; i < w.Next; i++ {
+		key, err := w.Key("", 0, i)
+		if err != nil {
+			return nil, err
+		}
+		l.Addresses = append(l.Addresses, KeyAddress(i, key))
+	}
+	return l, nil
+}
+
+// Unlock returns the wallet a locked one holds, or signer.ErrBadPassphrase
+func (l *Locked) Unlock(passphrase string) (*Wallet, error) {
+	mnemonic, err := l.Open(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{Mnemonic: string(mnemonic), Next: len(l.Addresses)}, nil
+}
+
+// LoadLocked reads a locked wallet file
+func LoadLocked(path string) (*Locked, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var l Locked
+	if err := json.Unmarshal(data, &l); err != nil || l.Ciphertext == "" {
+		return nil, errors.New("wallet: " + path + " isn't a locked wallet file")
+	}
+	return &l, nil
+}
+
+// Save writes the locked wallet to a file only its owner can read
+func (l *Locked) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,133 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/glensargent/go-blockchain/blockchain"
+)
+
+// A vanity address is one whose encoded form starts with something readable, found by generating keys until
+// one's address does. Every character of the prefix makes it 58 times as many keys, so past 4 or 5 it's a
+// long wait. The first character or two of an encoded address are fixed by its version byte, eg script
+// addresses start 2g to 2i, so a prefix has to start with those.
+
+// ErrVanityPrefix is returned for a prefix no address of the kind can start with
+var ErrVanityPrefix = errors.New("no address can start with that prefix, it has to be base58 and start like the kind of address asked for, eg 2h for a script address")
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// encodedLen is how long every encoded address is
+const encodedLen = 51
+
+// VanityKey ... a key whose address starts with the prefix searched for
+type VanityKey struct {
+	Seed      string // the hex ed25519 seed, the private key, keep it secret
+	PublicKey string
+	Address   string // encoded
+	Hex       string // the address as the chain keys it
+}
+
+// VanityOdds returns how many keys it takes on average to find an address of the version starting with prefix
+func VanityOdds(prefix string, version byte) (float64, error) {
+	if prefix == "" || len(prefix) > encodedLen {
+		return 0, ErrVanityPrefix
+	}
+	value := new(big.Int)
+	for _, c := range prefix {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return 0, ErrVanityPrefix
+		}
+		value.Mul(value, big.NewInt(58)).Add(value, big.NewInt(int64(digit)))
+	}
+	scale := new(big.Int).Exp(big.NewInt(58), big.NewInt(int64(encodedLen-len(prefix))), nil)
+	from := new(big.Int).Mul(value, scale) // the addresses with the prefix, as numbers
+	to := new(big.Int).Add(from, scale)
+	to.Sub(to, big.NewInt(1))
+	low := new(big.Int).SetBytes(append([]byte{version}, make([]byte, 36)...)) // the addresses of the version
+	high := new(big.Int).SetBytes(append([]byte{version}, bytes.Repeat([]byte{0xff}, 36)...))
+	if from.Cmp(low) < 0 {
+		from = low
+	}
+	if to.Cmp(high) > 0 {
+		to = high
+	}
+	if from.Cmp(to) > 0 {
+		return 0, ErrVanityPrefix
+	}
+	span := func(a, b *big.Int) *big.Float { // how many numbers from a to b
+		n := new(big.Int).Sub(b, a)
+		return new(big.Float).SetInt(n.Add(n, big.NewInt(1)))
+	}
+	odds, _ := new(big.Float).Quo(span(low, high), span(from, to)).Float64()
+	return odds, nil
+}
+
+// SearchVanity generates keys on threads goroutines until one's address of the version starts with prefix, or
+// ctx is done. attempts counts the keys tried, for reporting progress.
+func SearchVanity(ctx context.Context, prefix string, version byte, threads int, attempts *atomic.Uint64) (VanityKey, error) {
+	if _, err := VanityOdds(prefix, version); err != nil {
+		return VanityKey{}, err
+	}
+	if threads < 1 {
+		threads = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make(chan VanityKey, threads)
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seed := make([]byte, ed25519.SeedSize)
+			for ctx.Err() == nil {
+				if _, err := rand.Read(seed); err != nil {
+					return
+				}
+				key := vanityKey(seed, version)
+				attempts.Add(1)
+				if strings.HasPrefix(key.Address, prefix) {
+					found <- key
+					return
+				}
+			}
+		}()
+	}
+
+	select {
+	case key := <-found:
+		cancel()
+		wg.Wait()
+		return key, nil
+	case <-ctx.Done():
+		wg.Wait()
+		select {
+		case key := <-found: // found just as it was cancelled
+			return key, nil
+		default:
+			return VanityKey{}, ctx.Err()
+		}
+	}
+}
+
+// vanityKey returns a seed's key and its address of the version
+func vanityKey(seed []byte, version byte) VanityKey {
+	pub := hex.EncodeToString(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+	address := pub // a key address is the public key itself
+	if version == blockchain.AddressVersionScript {
+		address = blockchain.ScriptAddress(pub + " CHECKSIG") // the same as a wallet's
+	}
+	encoded, _ := blockchain.EncodeAddress(version, address)
+	return VanityKey{Seed: hex.EncodeToString(seed), PublicKey: pub, Address: encoded, Hex: address}
+}
@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// ErrWalletExists is returned rather than overwrite a wallet file, which could lose the only copy of a phrase
+var ErrWalletExists = errors.New("there's already a wallet there, move it out of the way first")
+
+// Wallet ... what a wallet file keeps: the backup phrase and how many addresses have been handed out
+type Wallet struct {
+	Mnemonic string
+	Next     int // the index of the next address on account 0
+}
+
+// Create returns a new wallet with a random backup phrase of words words
+func Create(words int) (*Wallet, error) {
+	mnemonic, err := NewMnemonic(words)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{Mnemonic: mnemonic}, nil
+}
+
+// Restore returns the wallet a backup phrase makes, with the first used addresses already handed out
+func Restore(mnemonic string, used int) (*Wallet, error) {
+	if _, err := Seed(mnemonic, ""); err != nil {
+		return nil, err
+	}
+	return &Wallet{Mnemonic: mnemonic, Next: used}, nil
+}
+
+// Load reads a wallet file
+func Load(path string) (*Wallet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var w Wallet
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, errors.New("wallet: " + path + " isn't a wallet file")
+	}
+	return &w, nil
+}
+
+// Save writes the wallet to a file only its owner can read, since anyone with the phrase has the coins
+func (w *Wallet) Save(path string) error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Key returns the key of an account's index'th address
+func (w *Wallet) Key(passphrase string, account, index int) (Key, error) {
+	seed, err := Seed(w.Mnemonic, passphrase)
+	if err != nil {
+		return Key{}, err
+	}
+	return NewMasterKey(seed).Derive(Path(account, index))
+}
+
+// NextKey hands out the next address's key on account 0, the wallet has to be saved after
+func (w *Wallet) NextKey(passphrase string) (Key, error) {
+	key, err := w.Key(passphrase, 0, w.Next)
+	if err == nil {
+		w.Next++
+	}
+	return key, err
+}